@@ -0,0 +1,139 @@
+package analytics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	gh "github.com/google/go-github/github"
+
+	"github.com/lszucs/github-to-discourse/internal/github"
+)
+
+// Bucket is an issue age range.
+type Bucket struct {
+	Name string
+	// Max is the exclusive upper bound of the age, zero means unbounded.
+	Max time.Duration
+}
+
+const day = 24 * time.Hour
+
+// Buckets are the age ranges of the histogram.
+var Buckets = []Bucket{
+	{Name: "<1m", Max: 30 * day},
+	{Name: "1-3m", Max: 91 * day},
+	{Name: "3-12m", Max: 365 * day},
+	{Name: ">1y"},
+}
+
+// Quantiles are the last activity quantiles reported per repo.
+var Quantiles = []float64{0.5, 0.75, 0.9, 1}
+
+// RepoStats holds the age analytics of a repo's open issues.
+type RepoStats struct {
+	Repo string
+	// Histogram counts issues per Buckets index by creation date.
+	Histogram []int
+	// Inactive holds the days since the last activity per Quantiles index.
+	Inactive []float64
+}
+
+func bucket(age time.Duration) int {
+	for i, b := range Buckets {
+		if b.Max == 0 || age < b.Max {
+			return i
+		}
+	}
+	return len(Buckets) - 1
+}
+
+func quantile(sorted []float64, q float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	idx := int(math.Ceil(q*float64(len(sorted)))) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	return sorted[idx]
+}
+
+func repoOf(i *gh.Issue) string {
+	ref, err := github.ParseIssueURL(i.GetHTMLURL())
+	if err != nil {
+		return i.GetHTMLURL()
+	}
+	return ref.FullName()
+}
+
+func newRepoStats(repo string, issues []*gh.Issue, now time.Time) RepoStats {
+	s := RepoStats{
+		Repo:      repo,
+		Histogram: make([]int, len(Buckets)),
+	}
+
+	var inactive []float64
+	for _, i := range issues {
+		s.Histogram[bucket(now.Sub(i.GetCreatedAt()))]++
+		inactive = append(inactive, now.Sub(i.GetUpdatedAt()).Hours()/24)
+	}
+	sort.Float64s(inactive)
+
+	for _, q := range Quantiles {
+		s.Inactive = append(s.Inactive, quantile(inactive, q))
+	}
+	return s
+}
+
+// Analyze returns the stats of every repo and the total of all issues, pull requests excluded.
+func Analyze(issues []*gh.Issue, now time.Time) ([]RepoStats, RepoStats) {
+	var all []*gh.Issue
+	byRepo := map[string][]*gh.Issue{}
+	var repos []string
+	for _, i := range issues {
+		if i.IsPullRequest() {
+			continue
+		}
+
+		repo := repoOf(i)
+		if _, ok := byRepo[repo]; !ok {
+			repos = append(repos, repo)
+		}
+		byRepo[repo] = append(byRepo[repo], i)
+		all = append(all, i)
+	}
+	sort.Strings(repos)
+
+	var stats []RepoStats
+	for _, r := range repos {
+		stats = append(stats, newRepoStats(r, byRepo[r], now))
+	}
+	return stats, newRepoStats("total", all, now)
+}
+
+// Print writes the stats as a table.
+func Print(w io.Writer, stats []RepoStats, total RepoStats) {
+	header := []string{"repo"}
+	for _, b := range Buckets {
+		header = append(header, b.Name)
+	}
+	for _, q := range Quantiles {
+		header = append(header, fmt.Sprintf("inactive p%d (days)", int(q*100)))
+	}
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+
+	for _, s := range append(stats, total) {
+		row := []string{s.Repo}
+		for _, c := range s.Histogram {
+			row = append(row, fmt.Sprintf("%d", c))
+		}
+		for _, d := range s.Inactive {
+			row = append(row, fmt.Sprintf("%.0f", d))
+		}
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+}
@@ -0,0 +1,127 @@
+package archive
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	gh "github.com/google/go-github/github"
+
+	"github.com/lszucs/github-to-discourse/internal/github"
+	"github.com/lszucs/github-to-discourse/internal/run"
+)
+
+var (
+	snapshotDir string
+	archiveURL  string
+)
+
+func init() {
+	flag.StringVar(&snapshotDir, "snapshot-dir", "", "--snapshot-dir=<path> (directory to snapshot the issues and their comments to before live runs change them, read by export-archive, relative to --out-dir; empty disables it)")
+	flag.StringVar(&archiveURL, "archive-url", "", "--archive-url=<url> (base URL the site of export-archive is published at, topics link the archived page of their issue)")
+}
+
+// Snapshot is an issue with its comments as they were before the migration.
+type Snapshot struct {
+	Issue    *gh.Issue          `json:"issue"`
+	Comments []*gh.IssueComment `json:"comments,omitempty"`
+	TakenAt  time.Time          `json:"taken_at"`
+}
+
+// Validate fails if topics would link archive pages without snapshots to generate them from.
+func Validate() error {
+	if archiveURL != "" && snapshotDir == "" {
+		return fmt.Errorf("--archive-url requires --snapshot-dir")
+	}
+	return nil
+}
+
+// Enabled reports whether issues are snapshot.
+func Enabled() bool {
+	return snapshotDir != ""
+}
+
+// pagePath returns the path of the issue relative to the snapshot dir and the site, without extension.
+func pagePath(issueURL string) (string, error) {
+	ref, err := github.ParseIssueURL(issueURL)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(ref.Owner, ref.Name, fmt.Sprintf("%d", ref.Number)), nil
+}
+
+// Taken reports whether the issue has a snapshot already.
+func Taken(issueURL string) bool {
+	p, err := pagePath(issueURL)
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(filepath.Join(run.Path(snapshotDir), p+".json"))
+	return err == nil
+}
+
+// Save writes the snapshot of the issue. A snapshot is never overwritten, so the first one,
+// taken before any change, is kept when a run is resumed.
+func Save(i *gh.Issue, comments []*gh.IssueComment) error {
+	p, err := pagePath(i.GetHTMLURL())
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(run.Path(snapshotDir), p+".json")
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(Snapshot{Issue: i, Comments: comments, TakenAt: time.Now()}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal snapshot of %s: %s", i.GetHTMLURL(), err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("create snapshot dir: %s", err)
+	}
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("write snapshot %s: %s", path, err)
+	}
+	return nil
+}
+
+// Load reads the snapshot of the issue, nil if it has none.
+func Load(issueURL string) (*Snapshot, error) {
+	if !Enabled() {
+		return nil, nil
+	}
+	p, err := pagePath(issueURL)
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(run.Path(snapshotDir), p+".json")
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("read snapshot %s: %s", path, err)
+	}
+
+	var s Snapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("unmarshal snapshot %s: %s", path, err)
+	}
+	return &s, nil
+}
+
+// PageURL returns the URL of the archived page of the issue, empty without --archive-url.
+func PageURL(issueURL string) string {
+	if archiveURL == "" {
+		return ""
+	}
+	p, err := pagePath(issueURL)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSuffix(archiveURL, "/") + "/" + filepath.ToSlash(p) + ".html"
+}
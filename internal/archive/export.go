@@ -0,0 +1,160 @@
+package archive
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/log"
+
+	"github.com/lszucs/github-to-discourse/internal/run"
+)
+
+var issueTpl = template.Must(template.New("issue").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Issue.GetTitle}} · {{.Repo}}#{{.Issue.GetNumber}}</title>
+<style>body{max-width:50em;margin:2em auto;font-family:sans-serif}.post{border:1px solid #ddd;margin:1em 0;padding:0 1em}pre{white-space:pre-wrap;font-family:inherit}</style>
+</head>
+<body>
+<p><a href="index.html">{{.Repo}}</a></p>
+<h1>{{.Issue.GetTitle}} #{{.Issue.GetNumber}}</h1>
+<p>Archived from <a href="{{.Issue.GetHTMLURL}}">{{.Issue.GetHTMLURL}}</a> on {{.TakenAt.UTC.Format "2006-01-02"}}.</p>
+<div class="post">
+<p><b>@{{.Issue.GetUser.GetLogin}}</b> opened on {{.Issue.GetCreatedAt.UTC.Format "2006-01-02 15:04 MST"}}</p>
+<pre>{{.Issue.GetBody}}</pre>
+</div>
+{{range .Comments}}<div class="post">
+<p><b>@{{.GetUser.GetLogin}}</b> commented on {{.GetCreatedAt.UTC.Format "2006-01-02 15:04 MST"}}</p>
+<pre>{{.GetBody}}</pre>
+</div>
+{{end}}</body>
+</html>
+`))
+
+var indexTpl = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>body{max-width:50em;margin:2em auto;font-family:sans-serif}</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<ul>
+{{range .Links}}<li><a href="{{.Href}}">{{.Text}}</a></li>
+{{end}}</ul>
+</body>
+</html>
+`))
+
+type page struct {
+	Snapshot
+	Repo string
+}
+
+type link struct {
+	Href string
+	Text string
+}
+
+func render(path string, tpl *template.Template, data interface{}) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("create dir of %s: %s", path, err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("create %s: %s", path, err)
+	}
+	if err := tpl.Execute(f, data); err != nil {
+		f.Close()
+		return fmt.Errorf("render %s: %s", path, err)
+	}
+	return f.Close()
+}
+
+// Export renders a static HTML site from the snapshots to outDir: a page per issue at
+// <owner>/<repo>/<number>.html, an index per repo and an index of the repos.
+func Export(outDir string) error {
+	if snapshotDir == "" {
+		return fmt.Errorf("--snapshot-dir not set")
+	}
+
+	repos := map[string][]link{}
+	snapshots := run.Path(snapshotDir)
+	err := filepath.Walk(snapshots, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || filepath.Ext(path) != ".json" {
+			return err
+		}
+		rel, err := filepath.Rel(snapshots, strings.TrimSuffix(path, ".json"))
+		if err != nil {
+			return err
+		}
+
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("read snapshot %s: %s", path, err)
+		}
+		var s Snapshot
+		if err := json.Unmarshal(b, &s); err != nil {
+			return fmt.Errorf("unmarshal snapshot %s: %s", path, err)
+		}
+
+		repo := filepath.ToSlash(filepath.Dir(rel))
+		if err := render(filepath.Join(outDir, rel+".html"), issueTpl, page{Snapshot: s, Repo: repo}); err != nil {
+			return err
+		}
+		repos[repo] = append(repos[repo], link{
+			Href: filepath.Base(rel) + ".html",
+			Text: fmt.Sprintf("#%d %s", s.Issue.GetNumber(), s.Issue.GetTitle()),
+		})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var names []string
+	for repo := range repos {
+		names = append(names, repo)
+	}
+	sort.Strings(names)
+
+	var index []link
+	pages := 0
+	for _, repo := range names {
+		links := repos[repo]
+		sort.Slice(links, func(a, b int) bool { return issueNumber(links[a].Href) < issueNumber(links[b].Href) })
+		data := struct {
+			Title string
+			Links []link
+		}{repo, links}
+		if err := render(filepath.Join(outDir, filepath.FromSlash(repo), "index.html"), indexTpl, data); err != nil {
+			return err
+		}
+		index = append(index, link{Href: repo + "/index.html", Text: fmt.Sprintf("%s (%d issues)", repo, len(links))})
+		pages += len(links)
+	}
+
+	data := struct {
+		Title string
+		Links []link
+	}{"Archived GitHub issues", index}
+	if err := render(filepath.Join(outDir, "index.html"), indexTpl, data); err != nil {
+		return err
+	}
+	log.Printf("archived %d issues of %d repos to %s", pages, len(names), outDir)
+	return nil
+}
+
+func issueNumber(href string) int {
+	var n int
+	fmt.Sscanf(href, "%d.html", &n)
+	return n
+}
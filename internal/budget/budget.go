@@ -0,0 +1,126 @@
+package budget
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// Services whose API calls are budgeted.
+const (
+	GitHub    = "github"
+	Discourse = "discourse"
+)
+
+// ErrExhausted is returned once the budget of a service doesn't allow more calls.
+var ErrExhausted = errors.New("API call budget exhausted")
+
+var (
+	limits = map[string]*int{GitHub: new(int), Discourse: new(int)}
+
+	mu   sync.Mutex
+	used = map[string]int{}
+)
+
+func init() {
+	flag.IntVar(limits[GitHub], "github-budget", 0, "--github-budget=<int> (maximum number of GitHub API calls of the run, 0 is unlimited)")
+	flag.IntVar(limits[Discourse], "discourse-budget", 0, "--discourse-budget=<int> (maximum number of Discourse API calls of the run, 0 is unlimited)")
+}
+
+// Used returns the number of calls made to the service.
+func Used(service string) int {
+	mu.Lock()
+	defer mu.Unlock()
+	return used[service]
+}
+
+// Remaining returns the number of calls left in the budget of the service, -1 if unlimited.
+func Remaining(service string) int {
+	mu.Lock()
+	defer mu.Unlock()
+	return remaining(service)
+}
+
+func remaining(service string) int {
+	limit := *limits[service]
+	if limit == 0 {
+		return -1
+	}
+	if left := limit - used[service]; left > 0 {
+		return left
+	}
+	return 0
+}
+
+// Allow reports whether the budgets have room for the given number of calls per service.
+func Allow(calls map[string]int) bool {
+	mu.Lock()
+	defer mu.Unlock()
+	for service, n := range calls {
+		if left := remaining(service); left >= 0 && left < n {
+			return false
+		}
+	}
+	return true
+}
+
+func take(service string) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if remaining(service) == 0 {
+		return fmt.Errorf("%s: %d %s calls made", ErrExhausted, used[service], service)
+	}
+	used[service]++
+	return nil
+}
+
+// Estimate describes the calls a run is expected to make, per phase.
+type Estimate struct {
+	// Discovery is the number of calls listing the issues of the repos.
+	Discovery map[string]int
+	// PerIssue is the number of calls migrating a single issue.
+	PerIssue map[string]int
+}
+
+// Print writes the estimate and how many issues the budgets allow.
+func (e Estimate) Print(printf func(format string, v ...interface{})) {
+	for _, service := range []string{GitHub, Discourse} {
+		limit := "unlimited"
+		if *limits[service] > 0 {
+			limit = fmt.Sprintf("%d", *limits[service])
+		}
+		printf("%s API calls: at least %d for discovery, %d per issue; budget: %s", service, e.Discovery[service], e.PerIssue[service], limit)
+		if *limits[service] > 0 && e.PerIssue[service] > 0 {
+			printf("%s budget allows migrating about %d issues", service, (*limits[service]-e.Discovery[service])/e.PerIssue[service])
+		}
+	}
+}
+
+// Transport counts the calls to a service and fails them once its budget is exhausted.
+type Transport struct {
+	Service string
+	// Base is the underlying transport, http.DefaultTransport if nil.
+	Base http.RoundTripper
+}
+
+func (t *Transport) base() http.RoundTripper {
+	if t.Base == nil {
+		return http.DefaultTransport
+	}
+	return t.Base
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := take(t.Service); err != nil {
+		if req.Body != nil {
+			if cerr := req.Body.Close(); cerr != nil {
+				return nil, cerr
+			}
+		}
+		return nil, err
+	}
+	return t.base().RoundTrip(req)
+}
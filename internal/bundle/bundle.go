@@ -0,0 +1,201 @@
+package bundle
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Bundle formats, see --format.
+const (
+	FormatTarGz = "tar.gz"
+	FormatTar   = "tar"
+)
+
+var (
+	format    string
+	overwrite bool
+)
+
+func init() {
+	flag.StringVar(&format, "format", FormatTarGz, "--format=tar.gz|tar (archive format of state export)")
+	flag.BoolVar(&overwrite, "overwrite", false, "--overwrite (let state import replace existing files)")
+}
+
+// DefaultPath returns the bundle file used if none is given.
+func DefaultPath() string {
+	return "github-to-discourse-state." + format
+}
+
+// skipped tells the files left out of bundles: lock files and partial writes.
+func skipped(name string) bool {
+	base := filepath.Base(name)
+	return base == ".lock" || strings.HasSuffix(base, ".lock") || strings.HasSuffix(base, ".tmp")
+}
+
+// Export writes the files and directories at paths to the bundle at out and returns the files
+// bundled. Paths must be relative to the working directory, so the bundle can be imported on
+// another machine; missing ones are skipped.
+func Export(out string, paths []string) ([]string, error) {
+	if format != FormatTarGz && format != FormatTar {
+		return nil, fmt.Errorf("not recognized --format %s", format)
+	}
+	for _, p := range paths {
+		if filepath.IsAbs(p) || strings.HasPrefix(filepath.Clean(p), "..") {
+			return nil, fmt.Errorf("can't bundle %s: only paths inside the working directory can be moved to another machine", p)
+		}
+	}
+
+	f, err := os.Create(out)
+	if err != nil {
+		return nil, fmt.Errorf("create %s: %s", out, err)
+	}
+	defer f.Close()
+
+	var w io.Writer = f
+	var gz *gzip.Writer
+	if format == FormatTarGz {
+		gz = gzip.NewWriter(f)
+		w = gz
+	}
+	tw := tar.NewWriter(w)
+
+	var files []string
+	for _, p := range paths {
+		err := filepath.Walk(filepath.Clean(p), func(pth string, fi os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !fi.Mode().IsRegular() || skipped(pth) || pth == filepath.Clean(out) {
+				return nil
+			}
+			if err := add(tw, pth, fi); err != nil {
+				return err
+			}
+			files = append(files, pth)
+			return nil
+		})
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("bundle %s: %s", p, err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("write %s: %s", out, err)
+	}
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			return nil, fmt.Errorf("write %s: %s", out, err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		return nil, fmt.Errorf("close %s: %s", out, err)
+	}
+	return files, nil
+}
+
+func add(tw *tar.Writer, pth string, fi os.FileInfo) error {
+	hdr, err := tar.FileInfoHeader(fi, "")
+	if err != nil {
+		return err
+	}
+	hdr.Name = filepath.ToSlash(pth)
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+
+	f, err := os.Open(pth)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+// Import extracts the bundle at in to the working directory and returns the files extracted.
+// Unless --overwrite is set, nothing is extracted if any of the files exists.
+func Import(in string) ([]string, error) {
+	f, err := os.Open(in)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %s", in, err)
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	var src io.Reader = r
+	if magic, err := r.Peek(2); err == nil && bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %s", in, err)
+		}
+		defer gz.Close()
+		src = gz
+	}
+
+	// read the whole bundle first, so a conflict or a broken bundle extracts nothing
+	type entry struct {
+		name string
+		mode os.FileMode
+		data []byte
+	}
+	var entries []entry
+	tr := tar.NewReader(src)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %s", in, err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		name := filepath.FromSlash(hdr.Name)
+		if filepath.IsAbs(name) || strings.HasPrefix(filepath.Clean(name), "..") {
+			return nil, fmt.Errorf("read %s: %s is outside the working directory", in, hdr.Name)
+		}
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("read %s from %s: %s", hdr.Name, in, err)
+		}
+		entries = append(entries, entry{name: filepath.Clean(name), mode: hdr.FileInfo().Mode().Perm(), data: data})
+	}
+
+	if !overwrite {
+		var existing []string
+		for _, e := range entries {
+			if _, err := os.Stat(e.name); err == nil {
+				existing = append(existing, e.name)
+			}
+		}
+		if len(existing) > 0 {
+			return nil, fmt.Errorf("%d files exist, import with --overwrite to replace them: %s", len(existing), strings.Join(existing, ", "))
+		}
+	}
+
+	var files []string
+	for _, e := range entries {
+		if err := os.MkdirAll(filepath.Dir(e.name), 0755); err != nil {
+			return files, fmt.Errorf("create directory of %s: %s", e.name, err)
+		}
+		if err := ioutil.WriteFile(e.name, e.data, e.mode); err != nil {
+			return files, fmt.Errorf("write %s: %s", e.name, err)
+		}
+		files = append(files, e.name)
+	}
+	return files, nil
+}
@@ -0,0 +1,102 @@
+package chaos
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	rate float64
+	seed int64
+
+	mu  sync.Mutex
+	rnd *rand.Rand
+)
+
+func init() {
+	flag.Float64Var(&rate, "chaos", 0, "--chaos=<0..1> (randomly fail this fraction of GitHub and Discourse API calls, to rehearse retries and resuming; dry mode or a staging --discourse-url only)")
+	flag.Int64Var(&seed, "chaos-seed", 0, "--chaos-seed=<int> (seed of the --chaos failures to reproduce a rehearsal, random if 0)")
+}
+
+// Validate returns an error if --chaos is out of range, or enabled for a live run against the
+// production Discourse instance.
+func Validate(mode string, staging bool) error {
+	if rate < 0 || rate > 1 {
+		return fmt.Errorf("--chaos must be between 0 and 1, got %g", rate)
+	}
+	if rate > 0 && mode == "live" && !staging {
+		return fmt.Errorf("--chaos in live mode requires a staging --discourse-url")
+	}
+	return nil
+}
+
+// Enabled reports whether failures are injected.
+func Enabled() bool {
+	return rate > 0
+}
+
+func roll() float64 {
+	mu.Lock()
+	defer mu.Unlock()
+	if rnd == nil {
+		s := seed
+		if s == 0 {
+			s = time.Now().UnixNano()
+		}
+		rnd = rand.New(rand.NewSource(s))
+	}
+	return rnd.Float64()
+}
+
+// Transport fails --chaos of the requests, alternately with a connection error or a 503 response,
+// without sending them.
+type Transport struct {
+	// Base is the underlying transport, http.DefaultTransport if nil.
+	Base http.RoundTripper
+}
+
+func (t *Transport) base() http.RoundTripper {
+	if t.Base == nil {
+		return http.DefaultTransport
+	}
+	return t.Base
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if rate == 0 {
+		return t.base().RoundTrip(req)
+	}
+
+	r := roll()
+	if r >= rate {
+		return t.base().RoundTrip(req)
+	}
+
+	if req.Body != nil {
+		if err := req.Body.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if r < rate/2 {
+		return nil, fmt.Errorf("chaos: injected connection error for %s %s", req.Method, req.URL.Path)
+	}
+	body := "chaos: injected service unavailable"
+	return &http.Response{
+		Status:        "503 Service Unavailable",
+		StatusCode:    http.StatusServiceUnavailable,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"text/plain"}},
+		Body:          ioutil.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
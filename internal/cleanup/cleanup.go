@@ -0,0 +1,228 @@
+package cleanup
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/log"
+	gh "github.com/google/go-github/github"
+
+	"github.com/lszucs/github-to-discourse/internal/archive"
+	"github.com/lszucs/github-to-discourse/internal/discourse"
+	"github.com/lszucs/github-to-discourse/internal/github"
+	"github.com/lszucs/github-to-discourse/internal/run"
+	"github.com/lszucs/github-to-discourse/internal/state"
+)
+
+// Run deletes the artifacts of the run: the topics and the comments of the issues recorded with
+// the run ID in the state file, and the topics tagged with the run ID missing from the state file.
+// Issues closed or locked by the tool are reopened and unlocked, with --snapshot-dir their state,
+// labels and milestone are restored from their snapshot unless changed since, and their records
+// are removed, so they are migrated again from scratch. Nothing is changed unless live is set.
+func Run(runID string, live bool) error {
+	prefix := "would "
+	if live {
+		prefix = ""
+	}
+
+	tracked := map[int]bool{}
+	var failed, manual int
+	for _, rec := range state.All() {
+		if rec.RunID != runID {
+			continue
+		}
+		if id, err := discourse.KnownTopicID(rec.TopicID, rec.TopicURL); err == nil {
+			tracked[id] = true
+		}
+		if err := issue(rec, live, prefix); err != nil {
+			log.Errorf("%s", err)
+			failed++
+			if live {
+				// keep what was undone, the next cleanup retries the rest only
+				state.Commit(rec)
+			}
+			continue
+		}
+		if len(rec.ManualCleanup) > 0 {
+			// keep the record, it tells what is left to clean up
+			manual++
+			if live {
+				state.Commit(rec)
+			}
+			continue
+		}
+		if live {
+			state.Delete(rec.IssueURL)
+		}
+	}
+
+	tagged, err := discourse.TaggedTopics(run.TagPrefix + runID)
+	if err != nil {
+		log.Warnf("%s, only the topics in the state file are deleted", err)
+	}
+	for _, t := range tagged {
+		if tracked[t.TopicID] {
+			continue
+		}
+		log.Printf("%sdelete topic %s tagged with the run but missing from the state file", prefix, t.URL)
+		if !live {
+			continue
+		}
+		if err := discourse.DeleteTopic(t.TopicID); err != nil {
+			log.Errorf("%s", err)
+			failed++
+		}
+	}
+
+	if live {
+		if err := state.Save(); err != nil {
+			return err
+		}
+	}
+	if manual > 0 {
+		log.Warnf("%d issues need manual cleanup, see manual_cleanup of their records in the state file", manual)
+	}
+	if failed > 0 {
+		return fmt.Errorf("cleanup of run %s: %d artifacts failed, run it again", runID, failed)
+	}
+	return nil
+}
+
+// Undo deletes the topic and the comment of the record, and reopens (or restores from its
+// snapshot) and unlocks the issue if the tool closed or locked it. The undone steps are removed
+// from the record. It fails if the comment has to be deleted by hand.
+func Undo(rec *state.Record) error {
+	if err := issue(rec, true, ""); err != nil {
+		return err
+	}
+	if len(rec.ManualCleanup) > 0 {
+		return fmt.Errorf("needs manual cleanup: %s", strings.Join(rec.ManualCleanup, ", "))
+	}
+	return nil
+}
+
+// issue deletes the artifacts of the run on the issue of the record. Each step undone is removed
+// from the record as it succeeds, so undoing it again only retries the steps left.
+func issue(rec *state.Record, live bool, prefix string) error {
+	ref, err := github.ParseIssueURL(rec.IssueURL)
+	if err != nil {
+		return err
+	}
+	if live {
+		rec.ManualCleanup = nil
+	}
+	snap, err := archive.Load(rec.IssueURL)
+	if err != nil {
+		return err
+	}
+
+	if rec.TopicURL != "" {
+		log.Printf("%sdelete topic %s of %s", prefix, rec.TopicURL, rec.IssueURL)
+		if live {
+			id, err := discourse.KnownTopicID(rec.TopicID, rec.TopicURL)
+			if err != nil {
+				return err
+			}
+			if err := discourse.DeleteTopic(id); err != nil {
+				return err
+			}
+			rec.TopicURL = ""
+			rec.TopicID, rec.PostID = 0, 0
+			rec.Identity, rec.PostedAs = "", ""
+			rec.Unmark(state.StepDiscourse)
+		}
+	}
+	if rec.CommentID != 0 {
+		own, reason, err := github.OwnComment(ref, rec.CommentID)
+		if err != nil {
+			return err
+		}
+		if !own {
+			log.Warnf("%sskip comment %d of %s: %s, check and delete it manually", prefix, rec.CommentID, rec.IssueURL, reason)
+			if live {
+				rec.ManualCleanup = append(rec.ManualCleanup, fmt.Sprintf("comment %d: %s", rec.CommentID, reason))
+			}
+		} else {
+			log.Printf("%sdelete comment %d of %s", prefix, rec.CommentID, rec.IssueURL)
+			if live {
+				if err := github.DeleteComment(ref, rec.CommentID); err != nil {
+					return err
+				}
+				rec.CommentID = 0
+				rec.Unmark(state.StepComment)
+			}
+		}
+	} else if rec.IsDone(state.StepComment) {
+		log.Warnf("comment of %s wasn't recorded, delete it manually", rec.IssueURL)
+	}
+	if rec.IsDone(state.StepLock) && !alreadyDone(rec, state.StepLock) {
+		log.Printf("%sunlock %s", prefix, rec.IssueURL)
+		if live {
+			if err := github.Unlock(ref); err != nil {
+				return err
+			}
+			rec.Unmark(state.StepLock)
+		}
+	}
+	if rec.IsDone(state.StepClose) && !alreadyDone(rec, state.StepClose) {
+		if err := reopen(ref, rec, snap, live, prefix); err != nil {
+			return err
+		}
+		if live {
+			rec.Unmark(state.StepClose)
+		}
+	}
+	return nil
+}
+
+// reopen reopens the issue closed by the tool. With a snapshot its state, labels and milestone are
+// restored as the snapshot recorded them, unless the labels or the milestone changed since: the
+// tool doesn't change them, so the changes are someone else's and the issue is only reopened.
+func reopen(ref github.IssueRef, rec *state.Record, snap *archive.Snapshot, live bool, prefix string) error {
+	if snap != nil && snap.Issue != nil {
+		current, err := github.GetIssue(rec.IssueURL)
+		if err != nil {
+			return err
+		}
+		if sameLabelsAndMilestone(current, snap.Issue) {
+			log.Printf("%srestore state %s, labels and milestone of %s from its snapshot", prefix, snap.Issue.GetState(), rec.IssueURL)
+			if !live {
+				return nil
+			}
+			return github.Restore(ref, snap.Issue)
+		}
+		log.Warnf("%sonly reopen %s: its labels or milestone changed since the snapshot, they are kept", prefix, rec.IssueURL)
+	} else {
+		log.Printf("%sreopen %s", prefix, rec.IssueURL)
+	}
+	if !live {
+		return nil
+	}
+	return github.Reopen(ref)
+}
+
+// sameLabelsAndMilestone reports whether the issue has the labels and the milestone of the snapshot.
+func sameLabelsAndMilestone(i, snap *gh.Issue) bool {
+	if i.GetMilestone().GetNumber() != snap.GetMilestone().GetNumber() || len(i.Labels) != len(snap.Labels) {
+		return false
+	}
+	labels := map[string]bool{}
+	for _, l := range snap.Labels {
+		labels[l.GetName()] = true
+	}
+	for _, l := range i.Labels {
+		if !labels[l.GetName()] {
+			return false
+		}
+	}
+	return true
+}
+
+func alreadyDone(rec *state.Record, step string) bool {
+	for _, s := range rec.AlreadyDone {
+		if s == step {
+			return true
+		}
+	}
+	return false
+}
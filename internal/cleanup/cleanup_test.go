@@ -0,0 +1,207 @@
+package cleanup
+
+import (
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	gh "github.com/google/go-github/github"
+
+	"github.com/lszucs/github-to-discourse/internal/archive"
+	"github.com/lszucs/github-to-discourse/internal/state"
+)
+
+// fakeGitHub answers the GitHub API in place of http.DefaultTransport and records the requests
+// changing the issue.
+type fakeGitHub struct {
+	mu      sync.Mutex
+	changes []string
+	// issue is the current issue, edits is the body of each issue edit
+	issue string
+	edits []string
+}
+
+func (f *fakeGitHub) RoundTrip(r *http.Request) (*http.Response, error) {
+	status, body := http.StatusOK, `{}`
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/user":
+		body = `{"login": "migration-bot"}`
+	case r.Method == http.MethodGet && strings.Contains(r.URL.Path, "/comments/"):
+		// edited by a maintainer, it's not the tool's anymore
+		body = `{"id": 42, "body": "see the forum", "user": {"login": "maintainer"}}`
+	case r.Method == http.MethodGet && f.issue != "":
+		body = f.issue
+	case r.Method == http.MethodGet:
+	case r.Method == http.MethodPatch:
+		edit, _ := ioutil.ReadAll(r.Body)
+		f.mu.Lock()
+		f.changes = append(f.changes, r.Method+" "+r.URL.Path)
+		f.edits = append(f.edits, strings.TrimSpace(string(edit)))
+		f.mu.Unlock()
+	default:
+		f.mu.Lock()
+		f.changes = append(f.changes, r.Method+" "+r.URL.Path)
+		f.mu.Unlock()
+		if r.Method == http.MethodPut || r.Method == http.MethodDelete {
+			status, body = http.StatusNoContent, ""
+		}
+	}
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       ioutil.NopCloser(strings.NewReader(body)),
+		Request:    r,
+	}, nil
+}
+
+func setFlag(t *testing.T, name, value string) {
+	t.Helper()
+	old := flag.Lookup(name).Value.String()
+	if err := flag.Set(name, value); err != nil {
+		t.Fatalf("set --%s: %s", name, err)
+	}
+	t.Cleanup(func() { flag.Set(name, old) })
+}
+
+// useFakes answers the GitHub and Discourse APIs for the test, the returned slice collects the
+// Discourse requests changing topics.
+func useFakes(t *testing.T) (*fakeGitHub, *[]string) {
+	var mu sync.Mutex
+	var deleted []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			mu.Lock()
+			deleted = append(deleted, r.Method+" "+r.URL.Path)
+			mu.Unlock()
+		}
+		w.Write([]byte(`{"topic_list": {"topics": []}}`))
+	}))
+	t.Cleanup(srv.Close)
+	setFlag(t, "discourse-url", srv.URL)
+	setFlag(t, "state-file", filepath.Join(t.TempDir(), "state.json"))
+	setFlag(t, "max-retries", "0")
+
+	fake := &fakeGitHub{}
+	defaultTransport := http.DefaultTransport
+	http.DefaultTransport = fake
+	t.Cleanup(func() { http.DefaultTransport = defaultTransport })
+	return fake, &deleted
+}
+
+func TestRunAgainRetriesTheManualCommentOnly(t *testing.T) {
+	const issueURL = "https://github.com/octo/repo/issues/7"
+	github, discourse := useFakes(t)
+
+	rec := state.Get(issueURL)
+	rec.RunID = "run-1"
+	rec.Done = []string{state.StepDiscourse, state.StepComment, state.StepClose, state.StepLock}
+	rec.TopicURL, rec.TopicID, rec.CommentID = "https://forum.example.com/t/3", 3, 42
+	defer state.Delete(issueURL)
+
+	if err := Run("run-1", true); err != nil {
+		t.Fatalf("Run: %s", err)
+	}
+	if got := strings.Join(github.changes, ", "); got != "DELETE /repos/octo/repo/issues/7/lock, PATCH /repos/octo/repo/issues/7" {
+		t.Errorf("first run: GitHub changes = %s, want the issue unlocked and reopened", got)
+	}
+	if got := strings.Join(*discourse, ", "); got != "DELETE /t/3.json" {
+		t.Errorf("first run: Discourse changes = %s, want the topic deleted", got)
+	}
+	rec, ok := state.Lookup(issueURL)
+	if !ok {
+		t.Fatal("record deleted, want it kept for the manual cleanup")
+	}
+	if strings.Join(rec.Done, ",") != state.StepComment || rec.TopicURL != "" || rec.CommentID != 42 {
+		t.Errorf("record = done %v, topic %q, comment %d, want only the comment left", rec.Done, rec.TopicURL, rec.CommentID)
+	}
+
+	github.changes, *discourse = nil, nil
+	if err := Run("run-1", true); err != nil {
+		t.Fatalf("Run again: %s", err)
+	}
+	if len(github.changes) > 0 || len(*discourse) > 0 {
+		t.Errorf("second run: GitHub changes = %v, Discourse changes = %v, want none: only the comment is checked again", github.changes, *discourse)
+	}
+	if rec, _ := state.Lookup(issueURL); len(rec.ManualCleanup) != 1 {
+		t.Errorf("second run: manual cleanup = %v, want the comment", rec.ManualCleanup)
+	}
+}
+
+func TestRunRestoresSnapshotOfClosedIssues(t *testing.T) {
+	const issueURL = "https://github.com/octo/repo/issues/7"
+	tests := []struct {
+		name        string
+		done        []string
+		alreadyDone []string
+		// current is the issue on GitHub at the time of the cleanup
+		current   string
+		wantEdits []string
+	}{
+		{
+			name:      "closed by the run",
+			done:      []string{state.StepDiscourse, state.StepComment, state.StepClose},
+			current:   `{"number": 7, "state": "closed", "labels": [{"name": "bug"}], "milestone": {"number": 2}}`,
+			wantEdits: []string{`{"state":"open","labels":["bug"],"milestone":2}`},
+		},
+		{
+			name:      "commented only",
+			done:      []string{state.StepDiscourse, state.StepComment},
+			current:   `{"number": 7, "state": "open", "labels": [{"name": "bug"}], "milestone": {"number": 2}}`,
+			wantEdits: nil,
+		},
+		{
+			name:        "closed before the run",
+			done:        []string{state.StepDiscourse, state.StepComment, state.StepClose},
+			alreadyDone: []string{state.StepClose},
+			current:     `{"number": 7, "state": "closed", "labels": [{"name": "bug"}], "milestone": {"number": 2}}`,
+			wantEdits:   nil,
+		},
+		{
+			name:      "labels changed since",
+			done:      []string{state.StepDiscourse, state.StepComment, state.StepClose},
+			current:   `{"number": 7, "state": "closed", "labels": [{"name": "bug"}, {"name": "wontfix"}], "milestone": {"number": 2}}`,
+			wantEdits: []string{`{"state":"open"}`},
+		},
+		{
+			name:      "milestone removed since",
+			done:      []string{state.StepDiscourse, state.StepComment, state.StepClose},
+			current:   `{"number": 7, "state": "closed", "labels": [{"name": "bug"}]}`,
+			wantEdits: []string{`{"state":"open"}`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			github, _ := useFakes(t)
+			setFlag(t, "snapshot-dir", t.TempDir())
+			snap := &gh.Issue{
+				Number:    gh.Int(7),
+				State:     gh.String("open"),
+				HTMLURL:   gh.String(issueURL),
+				Labels:    []gh.Label{{Name: gh.String("bug")}},
+				Milestone: &gh.Milestone{Number: gh.Int(2)},
+			}
+			if err := archive.Save(snap, nil); err != nil {
+				t.Fatal(err)
+			}
+			github.issue = tt.current
+
+			rec := state.Get(issueURL)
+			rec.RunID = "run-1"
+			rec.Done, rec.AlreadyDone = tt.done, tt.alreadyDone
+			defer state.Delete(issueURL)
+
+			if err := Run("run-1", true); err != nil {
+				t.Fatalf("Run: %s", err)
+			}
+			if strings.Join(github.edits, "\n") != strings.Join(tt.wantEdits, "\n") {
+				t.Errorf("issue edits = %v, want %v", github.edits, tt.wantEdits)
+			}
+		})
+	}
+}
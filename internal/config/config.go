@@ -0,0 +1,259 @@
+package config
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+)
+
+// DefaultProfile is applied if no profile is selected.
+const DefaultProfile = "default"
+
+// Profile bundles command line flag values, keyed by flag name.
+type Profile map[string]string
+
+// TemplateSet holds the message templates of a locale. Empty templates are looked up
+// along the fallback chain of the locale.
+type TemplateSet struct {
+	Active   string `json:"active,omitempty"`
+	Stale    string `json:"stale,omitempty"`
+	Footer   string `json:"footer,omitempty"`
+	Fallback string `json:"fallback,omitempty"`
+}
+
+// Locales configures the language of the messages.
+type Locales struct {
+	// Default is the locale of repos and categories without a specific one, "en" if empty.
+	Default string `json:"default,omitempty"`
+	// ByRepo maps owner/name to a locale, it takes precedence over ByCategory.
+	ByRepo map[string]string `json:"by_repo,omitempty"`
+	// ByCategory maps Discourse category IDs to a locale.
+	ByCategory map[string]string `json:"by_category,omitempty"`
+	// Sets holds the templates per locale.
+	Sets map[string]TemplateSet `json:"sets,omitempty"`
+}
+
+// CategoryOptions configures how migrated topics are handled in a Discourse category.
+type CategoryOptions struct {
+	// NoBump dates topics back to the creation of the issue (honored for admin API users only),
+	// so the category keeps the original chronological order.
+	NoBump bool `json:"no_bump,omitempty"`
+	// PinTop pins this many of the most reacted topics migrated in a run.
+	PinTop int `json:"pin_top,omitempty"`
+	// PinDays is the number of days the topics stay pinned, 7 if zero.
+	PinDays int `json:"pin_days,omitempty"`
+	// DefaultTags are added to topics without tags of their own if the category requires tags.
+	DefaultTags []string `json:"default_tags,omitempty"`
+	// DailyQuota caps the topics created in the category per UTC day, overriding --category-daily-quota.
+	DailyQuota int `json:"daily_quota,omitempty"`
+}
+
+// TemplateRule overrides templates for issues having a label. Empty templates are not overridden.
+type TemplateRule struct {
+	Label  string `json:"label"`
+	Active string `json:"active,omitempty"`
+	Stale  string `json:"stale,omitempty"`
+	Footer string `json:"footer,omitempty"`
+}
+
+// HookAction is a side effect of a hook, its fields depend on the type.
+type HookAction struct {
+	// Type is one of webhook, email, skip or redact.
+	Type string `json:"type"`
+	// URL is the endpoint of webhook actions, and the HTTP endpoint of the mail relay of email actions.
+	URL string `json:"url,omitempty"`
+	// To is the recipient of email actions.
+	To string `json:"to,omitempty"`
+}
+
+// Hook runs its actions on every issue having its label.
+type Hook struct {
+	Label   string       `json:"label"`
+	Actions []HookAction `json:"actions"`
+}
+
+// Discourse configures the Discourse instance to migrate to.
+type Discourse struct {
+	// URL is the base URL of the instance, required if the section is given.
+	URL string `json:"url,omitempty"`
+	// Credentials is the source of the API key and user as of --discourse-credentials, the key
+	// itself is never read from the config file.
+	Credentials string `json:"credentials,omitempty"`
+	// CategoryID is the category topics are posted to, required if the section is given.
+	CategoryID         int `json:"category_id,omitempty"`
+	FallbackCategoryID int `json:"fallback_category_id,omitempty"`
+	// SummaryCategoryID is the staff category of the run summary topics.
+	SummaryCategoryID int `json:"summary_category_id,omitempty"`
+}
+
+// Templates holds the message templates replacing the built-in ones. Locales and template rules
+// take precedence over them.
+type Templates struct {
+	Active    string `json:"active,omitempty"`
+	Stale     string `json:"stale,omitempty"`
+	Footer    string `json:"footer,omitempty"`
+	Duplicate string `json:"duplicate,omitempty"`
+	Campaign  string `json:"campaign,omitempty"`
+	Title     string `json:"title,omitempty"`
+}
+
+// Config is the content of the JSON config file.
+type Config struct {
+	Discourse Discourse `json:"discourse,omitempty"`
+	Templates Templates `json:"templates,omitempty"`
+
+	Profiles map[string]Profile `json:"profiles,omitempty"`
+	Locales  Locales            `json:"locales,omitempty"`
+	// TemplateRules are evaluated in order per issue, the first rule matching a label of the issue wins.
+	TemplateRules []TemplateRule `json:"template_rules,omitempty"`
+	// Categories holds the options per Discourse category ID or slug path (parent/child).
+	Categories map[string]CategoryOptions `json:"categories,omitempty"`
+	// Hooks are evaluated per issue, every hook matching a label of the issue runs.
+	Hooks []Hook `json:"hooks,omitempty"`
+	// BlockedWords is what to do with topics rejected for words blocked by the instance (watched
+	// words): defang (the default) or strip the words and post again, or quarantine the issue.
+	BlockedWords string `json:"blocked_words,omitempty"`
+}
+
+var (
+	path    string
+	profile string
+)
+
+func init() {
+	flag.StringVar(&path, "config", "", "--config=<path> (JSON config file)")
+	flag.StringVar(&profile, "profile", "", "--profile=<name> (named profile of the config file to apply, e.g. pilot|full|cleanup)")
+}
+
+// setting binds a field of the config file to the flag it sets and the environment variable
+// overriding it.
+type setting struct {
+	path  string
+	flag  string
+	env   string
+	value string
+}
+
+func (cfg Config) settings() []setting {
+	itoa := func(n int) string {
+		if n == 0 {
+			return ""
+		}
+		return strconv.Itoa(n)
+	}
+	return []setting{
+		{"discourse.url", "discourse-url", "DISCOURSE_URL", cfg.Discourse.URL},
+		{"discourse.credentials", "discourse-credentials", "DISCOURSE_CREDENTIALS", cfg.Discourse.Credentials},
+		{"discourse.category_id", "discourse-category-id", "DISCOURSE_CATEGORY_ID", itoa(cfg.Discourse.CategoryID)},
+		{"discourse.fallback_category_id", "fallback-category-id", "DISCOURSE_FALLBACK_CATEGORY_ID", itoa(cfg.Discourse.FallbackCategoryID)},
+		{"discourse.summary_category_id", "summary-category-id", "DISCOURSE_SUMMARY_CATEGORY_ID", itoa(cfg.Discourse.SummaryCategoryID)},
+		{"templates.title", "title-tpl", "", cfg.Templates.Title},
+	}
+}
+
+// Load reads the config file given by --config and applies its settings, the profile given by
+// --profile and the environment variables, in this order. Flags set explicitly on the command
+// line take precedence over all of them.
+func Load() (Config, error) {
+	var cfg Config
+	explicit := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+
+	if path == "" {
+		if profile != "" {
+			return cfg, fmt.Errorf("profile %s selected, but no config file given", profile)
+		}
+		return cfg, applyEnv(cfg, explicit)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return cfg, fmt.Errorf("read config file %s: %s", path, err)
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("unmarshal config file %s: %s", path, decodeError(data, err))
+	}
+	raw = data
+
+	for _, s := range cfg.settings() {
+		if s.value == "" || explicit[s.flag] {
+			continue
+		}
+		if err := flag.Set(s.flag, s.value); err != nil {
+			return cfg, fmt.Errorf("%s: set --%s to %s: %s", s.path, s.flag, s.value, err)
+		}
+	}
+
+	name := profile
+	if name == "" {
+		name = DefaultProfile
+	}
+	if _, ok := cfg.Profiles[name]; ok || profile != "" {
+		if err := applyProfile(cfg, name, explicit); err != nil {
+			return cfg, fmt.Errorf("apply profile %s: %s", name, err)
+		}
+	}
+	return cfg, applyEnv(cfg, explicit)
+}
+
+// applyEnv sets the flags of the settings from their environment variables.
+func applyEnv(cfg Config, explicit map[string]bool) error {
+	for _, s := range cfg.settings() {
+		v := os.Getenv(s.env)
+		if s.env == "" || v == "" || explicit[s.flag] {
+			continue
+		}
+		if err := flag.Set(s.flag, v); err != nil {
+			return fmt.Errorf("set --%s from $%s to %s: %s", s.flag, s.env, v, err)
+		}
+	}
+	return nil
+}
+
+// Path returns the path of the config file given by --config.
+func Path() string {
+	return path
+}
+
+// Write writes cfg as the config file at pth.
+func Write(pth string, cfg Config) error {
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal config: %s", err)
+	}
+	if err := ioutil.WriteFile(pth, data, 0644); err != nil {
+		return fmt.Errorf("write config file %s: %s", pth, err)
+	}
+	return nil
+}
+
+func applyProfile(cfg Config, name string, explicit map[string]bool) error {
+	p, ok := cfg.Profiles[name]
+	if !ok {
+		var names []string
+		for n := range cfg.Profiles {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("not found, available profiles: %v", names)
+	}
+
+	for k, v := range p {
+		if k == "config" || k == "profile" {
+			return fmt.Errorf("flag %s can not be set from a profile", k)
+		}
+		if explicit[k] {
+			continue
+		}
+		if err := flag.Set(k, v); err != nil {
+			return fmt.Errorf("set flag %s to %s: %s", k, v, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,267 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Violation is a problem of the config file or of the flags, found before a run.
+type Violation struct {
+	// Path locates the value in the config file, e.g. hooks[0].actions[1].type, or names a flag, e.g. --mirror.
+	Path    string
+	Message string
+}
+
+// String prefixes the violation with the file:line:column of its value in the config file.
+func (v Violation) String() string {
+	if strings.HasPrefix(v.Path, "--") {
+		return fmt.Sprintf("%s: %s", v.Path, v.Message)
+	}
+	if line, col, ok := position(v.Path); ok {
+		return fmt.Sprintf("%s:%d:%d: %s: %s", path, line, col, v.Path, v.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", path, v.Path, v.Message)
+}
+
+// raw is the content of the loaded config file, to locate violations.
+var raw []byte
+
+// Check returns the violations of the cross-field constraints of the config file.
+func Check(cfg Config) []Violation {
+	var vs []Violation
+	add := func(p, format string, args ...interface{}) {
+		vs = append(vs, Violation{Path: p, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if d := cfg.Discourse; d != (Discourse{}) {
+		if d.URL == "" {
+			add("discourse", "url missing")
+		} else if u, err := url.Parse(d.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			add("discourse.url", "%s is not an http(s) URL", d.URL)
+		}
+		if d.CategoryID == 0 {
+			add("discourse", "category_id missing")
+		}
+		for _, s := range []struct {
+			path string
+			id   int
+		}{{"discourse.category_id", d.CategoryID}, {"discourse.fallback_category_id", d.FallbackCategoryID}, {"discourse.summary_category_id", d.SummaryCategoryID}} {
+			if s.id < 0 {
+				add(s.path, "negative")
+			}
+		}
+		if d.Credentials != "" && d.Credentials != "env" && !strings.HasPrefix(d.Credentials, "file:") && !strings.HasPrefix(d.Credentials, "exec:") {
+			add("discourse.credentials", "unknown source %s, one of env, file:<path> or exec:<command>", d.Credentials)
+		}
+	}
+
+	for _, name := range sortedKeys(cfg.Profiles) {
+		for _, k := range sortedKeys(cfg.Profiles[name]) {
+			p := "profiles." + name + "." + k
+			switch {
+			case k == "config" || k == "profile":
+				add(p, "flag %s can not be set from a profile", k)
+			case flag.Lookup(k) == nil:
+				add(p, "unknown flag %s", k)
+			}
+		}
+	}
+
+	locale := func(p, l string) {
+		if _, ok := cfg.Locales.Sets[l]; !ok && l != "en" {
+			add(p, "unknown locale %s, not defined under locales.sets", l)
+		}
+	}
+	if cfg.Locales.Default != "" {
+		locale("locales.default", cfg.Locales.Default)
+	}
+	for _, r := range sortedKeys(cfg.Locales.ByRepo) {
+		if strings.Count(r, "/") != 1 {
+			add("locales.by_repo."+r, "repo %s is not owner/name", r)
+		}
+		locale("locales.by_repo."+r, cfg.Locales.ByRepo[r])
+	}
+	for _, c := range sortedKeys(cfg.Locales.ByCategory) {
+		if _, err := strconv.Atoi(c); err != nil {
+			add("locales.by_category."+c, "category %s is not an ID", c)
+		}
+		locale("locales.by_category."+c, cfg.Locales.ByCategory[c])
+	}
+	for _, name := range sortedKeys(cfg.Locales.Sets) {
+		if fb := cfg.Locales.Sets[name].Fallback; fb != "" {
+			locale("locales.sets."+name+".fallback", fb)
+		}
+	}
+
+	for i, r := range cfg.TemplateRules {
+		p := fmt.Sprintf("template_rules[%d]", i)
+		if r.Label == "" {
+			add(p, "label missing")
+		}
+		if r.Active == "" && r.Stale == "" && r.Footer == "" {
+			add(p, "no template overridden")
+		}
+	}
+
+	for _, c := range sortedKeys(cfg.Categories) {
+		o := cfg.Categories[c]
+		p := "categories." + c
+		if o.PinTop < 0 {
+			add(p+".pin_top", "negative")
+		}
+		if o.PinDays < 0 {
+			add(p+".pin_days", "negative")
+		}
+		if o.PinDays > 0 && o.PinTop == 0 {
+			add(p+".pin_days", "set without pin_top, nothing is pinned")
+		}
+		if o.DailyQuota < 0 {
+			add(p+".daily_quota", "negative")
+		}
+	}
+
+	switch cfg.BlockedWords {
+	case "", "defang", "strip", "quarantine":
+	default:
+		add("blocked_words", "unknown policy %s, one of defang, strip or quarantine", cfg.BlockedWords)
+	}
+
+	for i, h := range cfg.Hooks {
+		if h.Label == "" {
+			add(fmt.Sprintf("hooks[%d]", i), "label missing")
+		}
+		if len(h.Actions) == 0 {
+			add(fmt.Sprintf("hooks[%d]", i), "no actions")
+		}
+	}
+	return vs
+}
+
+func sortedKeys(m interface{}) []string {
+	var keys []string
+	switch m := m.(type) {
+	case map[string]Profile:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case Profile:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[string]string:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[string]TemplateSet:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[string]CategoryOptions:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// position returns the line and column of the value at the path in the config file.
+func position(p string) (int, int, bool) {
+	if raw == nil {
+		return 0, 0, false
+	}
+	off, ok := offsets(raw)[p]
+	if !ok {
+		return 0, 0, false
+	}
+	return lineCol(raw, off)
+}
+
+func lineCol(data []byte, off int) (int, int, bool) {
+	if off > len(data) {
+		return 0, 0, false
+	}
+	before := data[:off]
+	line := bytes.Count(before, []byte("\n")) + 1
+	return line, off - bytes.LastIndexByte(before, '\n'), true
+}
+
+// offsets maps the paths of the values of a JSON document to their offset: object members are
+// located at their key, array elements at their value.
+func offsets(data []byte) map[string]int {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	found := map[string]int{}
+
+	// start returns the offset of the next token, skipping separators
+	start := func() int {
+		off := int(dec.InputOffset())
+		for off < len(data) && strings.IndexByte(" \t\r\n,:", data[off]) >= 0 {
+			off++
+		}
+		return off
+	}
+
+	var walk func(p string) bool
+	walk = func(p string) bool {
+		tok, err := dec.Token()
+		if err != nil {
+			return false
+		}
+		switch tok {
+		case json.Delim('{'):
+			for dec.More() {
+				off := start()
+				key, err := dec.Token()
+				if err != nil {
+					return false
+				}
+				child := fmt.Sprint(key)
+				if p != "" {
+					child = p + "." + child
+				}
+				found[child] = off
+				if !walk(child) {
+					return false
+				}
+			}
+			_, err = dec.Token()
+			return err == nil
+		case json.Delim('['):
+			for i := 0; dec.More(); i++ {
+				child := fmt.Sprintf("%s[%d]", p, i)
+				found[child] = start()
+				if !walk(child) {
+					return false
+				}
+			}
+			_, err = dec.Token()
+			return err == nil
+		}
+		return true
+	}
+	walk("")
+	return found
+}
+
+// decodeError adds the line and column to JSON syntax and type errors.
+func decodeError(data []byte, err error) error {
+	var off int64
+	switch e := err.(type) {
+	case *json.SyntaxError:
+		off = e.Offset
+	case *json.UnmarshalTypeError:
+		off = e.Offset
+	default:
+		return err
+	}
+	if line, col, ok := lineCol(data, int(off)); ok {
+		return fmt.Errorf("line %d, column %d: %s", line, col, err)
+	}
+	return err
+}
@@ -0,0 +1,193 @@
+package daemon
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/bitrise-io/go-utils/log"
+	gh "github.com/google/go-github/github"
+
+	"github.com/lszucs/github-to-discourse/internal/queue"
+	"github.com/lszucs/github-to-discourse/internal/runmode"
+	"github.com/lszucs/github-to-discourse/internal/state"
+)
+
+const (
+	retryDelay = time.Minute
+	// maxPayload is the largest webhook payload GitHub delivers.
+	maxPayload = 25 << 20
+)
+
+var (
+	listen   string
+	workers  int
+	queueDir string
+)
+
+func init() {
+	flag.StringVar(&listen, "listen", ":8080", "--listen=<addr> (address the daemon receives GitHub webhooks on)")
+	flag.IntVar(&workers, "workers", 1, "--workers=<int> (number of workers draining the webhook queue in daemon mode)")
+	flag.StringVar(&queueDir, "queue-dir", "queue", "--queue-dir=<path> (directory of the durable webhook event queue)")
+}
+
+// Run receives GitHub issue webhooks, queues the opened issues durably and migrates them
+// with the configured number of workers. Failed issues are retried later, so events received
+// while Discourse is down are not lost.
+func Run() error {
+	q, err := queue.Open(queueDir)
+	if err != nil {
+		return err
+	}
+	log.Printf("%d queued events to process", q.Len())
+
+	d, err := openDeliveries(queueDir)
+	if err != nil {
+		return err
+	}
+	if webhookSecret == "" {
+		log.Warnf("no --webhook-secret, webhook signatures are not verified")
+	}
+
+	for w := 0; w < workers; w++ {
+		go work(q)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/webhook", webhookHandler(q, d))
+	mux.HandleFunc("/healthz", healthzHandler(q.Len))
+	if adminToken != "" {
+		mux.HandleFunc("/runs", runsHandler)
+		mux.HandleFunc("/runs/", runsHandler)
+	}
+
+	log.Infof("listening on %s", listen)
+	return http.ListenAndServe(listen, mux)
+}
+
+func webhookHandler(q *queue.Queue, d *deliveries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		// read before the signature is verified, so bounded to the largest payload GitHub sends
+		payload, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxPayload))
+		if err != nil {
+			status := http.StatusBadRequest
+			if _, ok := err.(*http.MaxBytesError); ok {
+				status = http.StatusRequestEntityTooLarge
+			}
+			http.Error(w, fmt.Sprintf("read body: %s", err), status)
+			return
+		}
+		if err := verify(r, payload); err != nil {
+			log.Warnf("reject webhook from %s: %s", r.RemoteAddr, err)
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+
+		id := gh.DeliveryID(r)
+		if id == "" {
+			http.Error(w, "missing X-GitHub-Delivery header", http.StatusBadRequest)
+			return
+		}
+		if !d.claim(id) {
+			log.Warnf("reject replayed webhook delivery %s", id)
+			http.Error(w, "delivery already received", http.StatusConflict)
+			return
+		}
+		accepted := false
+		defer func() {
+			if !accepted {
+				d.release(id)
+				return
+			}
+			if err := d.commit(id); err != nil {
+				log.Warnf("record webhook delivery %s: %s", id, err)
+			}
+		}()
+
+		event, err := gh.ParseWebHook(gh.WebHookType(r), payload)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("parse webhook: %s", err), http.StatusBadRequest)
+			return
+		}
+
+		e, ok := event.(*gh.IssuesEvent)
+		if !ok || (e.GetAction() != "opened" && e.GetAction() != "reopened") {
+			accepted = true
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		data, err := json.Marshal(e.GetIssue())
+		if err != nil {
+			http.Error(w, fmt.Sprintf("marshal issue: %s", err), http.StatusInternalServerError)
+			return
+		}
+		// reported before the push, a worker may report the migration right after it
+		setStatus(e.GetIssue(), "pending", fmt.Sprintf("#%d queued for migration", e.GetIssue().GetNumber()), "")
+		if err := q.Push(data); err != nil {
+			log.Errorf("queue %s: %s", e.GetIssue().GetHTMLURL(), err)
+			setStatus(e.GetIssue(), "error", fmt.Sprintf("#%d could not be queued: %s", e.GetIssue().GetNumber(), err), "")
+			http.Error(w, "queue event", http.StatusServiceUnavailable)
+			return
+		}
+
+		accepted = true
+		log.Printf("queued %s", e.GetIssue().GetHTMLURL())
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
+
+func work(q *queue.Queue) {
+	for {
+		e, ok := q.Pop()
+		if !ok {
+			return
+		}
+
+		var i gh.Issue
+		if err := json.Unmarshal(e.Data, &i); err != nil {
+			log.Errorf("drop queued event %d: unmarshal issue: %s", e.Seq, err)
+			ack(q, e)
+			continue
+		}
+
+		setStatus(&i, "pending", fmt.Sprintf("migrating #%d", i.GetNumber()), "")
+
+		var stats runmode.Stats
+		if err := runmode.Process(&i, &stats); err != nil {
+			log.Errorf("%s, retry in %s", err, retryDelay)
+			setStatus(&i, "failure", fmt.Sprintf("#%d failed, retry in %s: %s", i.GetNumber(), retryDelay, err), "")
+			q.Retry(e, retryDelay)
+			continue
+		}
+
+		rec := state.Get(i.GetHTMLURL())
+		if rec.Status == state.StatusDeferred {
+			delay := runmode.NextDay()
+			setStatus(&i, "pending", fmt.Sprintf("#%d deferred by the daily quota of its category, retry in %s", i.GetNumber(), delay.Round(time.Minute)), "")
+			q.Retry(e, delay)
+			continue
+		}
+		ack(q, e)
+
+		if rec.Status == state.StatusQuarantined {
+			setStatus(&i, "failure", fmt.Sprintf("#%d quarantined after %d failed attempts", i.GetNumber(), rec.Attempts), "")
+			continue
+		}
+		setStatus(&i, "success", fmt.Sprintf("#%d migrated to Discourse", i.GetNumber()), rec.TopicURL)
+	}
+}
+
+func ack(q *queue.Queue, e queue.Entry) {
+	if err := q.Ack(e.Seq); err != nil {
+		log.Warnf("ack queued event %d: %s", e.Seq, err)
+	}
+}
@@ -0,0 +1,182 @@
+package daemon
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bitrise-io/go-utils/log"
+
+	"github.com/lszucs/github-to-discourse/internal/github"
+	"github.com/lszucs/github-to-discourse/internal/runmode"
+	"github.com/lszucs/github-to-discourse/internal/state"
+)
+
+// Run statuses.
+const (
+	RunRunning  = "running"
+	RunFinished = "finished"
+	RunCanceled = "canceled"
+	RunFailed   = "failed"
+)
+
+var adminToken string
+
+func init() {
+	flag.StringVar(&adminToken, "admin-token", "", "--admin-token=<token> (enables the /runs admin endpoints of the daemon, requests must send it as a bearer token)")
+}
+
+// batch is a migration of every open issue of the given repos, started through the admin API.
+type batch struct {
+	ID         string        `json:"id"`
+	Repos      []string      `json:"repos"`
+	Status     string        `json:"status"`
+	Stats      runmode.Stats `json:"stats"`
+	Error      string        `json:"error,omitempty"`
+	StartedAt  time.Time     `json:"started_at"`
+	FinishedAt *time.Time    `json:"finished_at,omitempty"`
+
+	stop chan struct{}
+}
+
+var (
+	runsMu sync.Mutex
+	runs   = map[string]*batch{}
+	lastID int
+)
+
+func startBatch(repos []string) *batch {
+	runsMu.Lock()
+	defer runsMu.Unlock()
+
+	lastID++
+	b := &batch{
+		ID:        fmt.Sprintf("%d", lastID),
+		Repos:     repos,
+		Status:    RunRunning,
+		StartedAt: time.Now(),
+		stop:      make(chan struct{}),
+	}
+	runs[b.ID] = b
+
+	go func() {
+		log.Infof("run %s: migrate %s", b.ID, repos)
+		stats, err := migrateBatch(b)
+
+		runsMu.Lock()
+		defer runsMu.Unlock()
+		now := time.Now()
+		b.Stats = stats
+		b.FinishedAt = &now
+		switch {
+		case err == runmode.ErrCanceled:
+			b.Status = RunCanceled
+		case err != nil:
+			b.Status = RunFailed
+			b.Error = err.Error()
+		default:
+			b.Status = RunFinished
+		}
+		log.Infof("run %s: %s", b.ID, b.Status)
+	}()
+	return b
+}
+
+func migrateBatch(b *batch) (runmode.Stats, error) {
+	fetchStop := make(chan struct{})
+	issues, fetchErrs := github.StreamOpenIssues(b.Repos, nil, fetchStop)
+	stats, err := runmode.LiveRunUntil(issues, b.stop)
+	close(fetchStop)
+	if ferr := <-fetchErrs; ferr != nil && err == nil {
+		err = ferr
+	}
+	// flush the state even if the run was interrupted
+	if serr := state.Save(); serr != nil && err == nil {
+		err = serr
+	}
+	return stats, err
+}
+
+// cancel stops the batch after the issue in progress.
+func (b *batch) cancel() bool {
+	if b.Status != RunRunning {
+		return false
+	}
+	select {
+	case <-b.stop:
+	default:
+		close(b.stop)
+	}
+	return true
+}
+
+func authorized(r *http.Request) bool {
+	given := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(given), []byte(adminToken)) == 1
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Warnf("write response: %s", err)
+	}
+}
+
+// runsHandler serves the admin API:
+//
+//	POST /runs               {"repos": [...]} starts a run
+//	GET  /runs/{id}          returns the run
+//	POST /runs/{id}/cancel   stops the run after the issue in progress
+func runsHandler(w http.ResponseWriter, r *http.Request) {
+	if !authorized(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/runs"), "/"), "/")
+	switch {
+	case parts[0] == "" && r.Method == http.MethodPost:
+		var req struct {
+			Repos []string `json:"repos"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Repos) == 0 {
+			http.Error(w, "body must be {\"repos\": [...]}", http.StatusBadRequest)
+			return
+		}
+		b := startBatch(req.Repos)
+		runsMu.Lock()
+		defer runsMu.Unlock()
+		writeJSON(w, http.StatusAccepted, b)
+	case len(parts) == 1 && parts[0] != "" && r.Method == http.MethodGet:
+		runsMu.Lock()
+		defer runsMu.Unlock()
+		b, ok := runs[parts[0]]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		writeJSON(w, http.StatusOK, b)
+	case len(parts) == 2 && parts[1] == "cancel" && r.Method == http.MethodPost:
+		runsMu.Lock()
+		defer runsMu.Unlock()
+		b, ok := runs[parts[0]]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if !b.cancel() {
+			http.Error(w, fmt.Sprintf("run %s is %s", b.ID, b.Status), http.StatusConflict)
+			return
+		}
+		log.Infof("run %s: cancel requested", b.ID)
+		writeJSON(w, http.StatusAccepted, b)
+	default:
+		http.NotFound(w, r)
+	}
+}
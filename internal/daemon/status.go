@@ -0,0 +1,33 @@
+package daemon
+
+import (
+	"flag"
+
+	"github.com/bitrise-io/go-utils/log"
+	gh "github.com/google/go-github/github"
+
+	"github.com/lszucs/github-to-discourse/internal/github"
+)
+
+var reportStatus bool
+
+func init() {
+	flag.BoolVar(&reportStatus, "report-status", false, "--report-status (report the migration of webhook issues as a commit status on the default branch of their repo)")
+}
+
+// setStatus reports the migration state of the issue to GitHub, if enabled. Failing to do so
+// never fails the migration.
+func setStatus(i *gh.Issue, state, description, targetURL string) {
+	if !reportStatus {
+		return
+	}
+
+	ref, err := github.ParseIssueURL(i.GetHTMLURL())
+	if err != nil {
+		log.Warnf("report status of %s: %s", i.GetHTMLURL(), err)
+		return
+	}
+	if err := github.SetStatus(ref, state, description, targetURL); err != nil {
+		log.Warnf("report status of %s: %s", i.GetHTMLURL(), err)
+	}
+}
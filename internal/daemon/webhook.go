@@ -0,0 +1,163 @@
+package daemon
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	gh "github.com/google/go-github/github"
+)
+
+const (
+	signatureHeader = "X-Hub-Signature-256"
+	deliveriesFile  = "deliveries.log"
+
+	// maxDeliveries is the number of delivery IDs remembered to reject replays.
+	maxDeliveries = 10000
+)
+
+var webhookSecret string
+
+func init() {
+	flag.StringVar(&webhookSecret, "webhook-secret", os.Getenv("GITHUB_WEBHOOK_SECRET"), "--webhook-secret=<secret> (secret of the GitHub webhook, payloads without a matching X-Hub-Signature-256 are rejected, defaults to $GITHUB_WEBHOOK_SECRET)")
+}
+
+// verify checks the X-Hub-Signature-256 of the payload against the webhook secret.
+func verify(r *http.Request, payload []byte) error {
+	if webhookSecret == "" {
+		return nil
+	}
+	signature := r.Header.Get(signatureHeader)
+	if signature == "" {
+		return fmt.Errorf("missing %s header", signatureHeader)
+	}
+	if !strings.HasPrefix(signature, "sha256=") {
+		return fmt.Errorf("unsupported signature %s", signature)
+	}
+	return gh.ValidateSignature(signature, payload, []byte(webhookSecret))
+}
+
+// deliveries remembers the IDs of the last accepted webhook deliveries, persisted next to
+// the queue, so replayed deliveries are rejected even after a restart.
+type deliveries struct {
+	pth string
+
+	mu      sync.Mutex
+	seen    map[string]bool
+	order   []string
+	pending map[string]bool
+}
+
+func openDeliveries(dir string) (*deliveries, error) {
+	d := &deliveries{
+		pth:     filepath.Join(dir, deliveriesFile),
+		seen:    map[string]bool{},
+		pending: map[string]bool{},
+	}
+
+	f, err := os.Open(d.pth)
+	if os.IsNotExist(err) {
+		return d, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("open %s: %s", d.pth, err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if id := strings.TrimSpace(scanner.Text()); id != "" && !d.seen[id] {
+			d.seen[id] = true
+			d.order = append(d.order, id)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read %s: %s", d.pth, err)
+	}
+
+	// compact the log to the remembered IDs
+	d.evict()
+	return d, d.rewrite()
+}
+
+// claim reserves the delivery ID, returning false if it was already delivered or is being
+// handled. A claimed ID is either committed or released.
+func (d *deliveries) claim(id string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.seen[id] || d.pending[id] {
+		return false
+	}
+	d.pending[id] = true
+	return true
+}
+
+// release gives up a claimed ID, e.g. when the event could not be queued, so GitHub can
+// redeliver it.
+func (d *deliveries) release(id string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.pending, id)
+}
+
+// commit records a claimed ID as delivered.
+func (d *deliveries) commit(id string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	delete(d.pending, id)
+	d.seen[id] = true
+	d.order = append(d.order, id)
+	if d.evict() {
+		return d.rewrite()
+	}
+
+	f, err := os.OpenFile(d.pth, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("open %s: %s", d.pth, err)
+	}
+	defer f.Close()
+	if _, err := fmt.Fprintln(f, id); err != nil {
+		return fmt.Errorf("write %s: %s", d.pth, err)
+	}
+	return nil
+}
+
+// evict forgets the oldest IDs above maxDeliveries, reporting whether any was dropped.
+func (d *deliveries) evict() bool {
+	if len(d.order) <= maxDeliveries {
+		return false
+	}
+	for _, id := range d.order[:len(d.order)-maxDeliveries] {
+		delete(d.seen, id)
+	}
+	d.order = append([]string(nil), d.order[len(d.order)-maxDeliveries:]...)
+	return true
+}
+
+func (d *deliveries) rewrite() error {
+	tmp := d.pth + ".tmp"
+	if err := ioutil.WriteFile(tmp, []byte(strings.Join(append(d.order, ""), "\n")), 0644); err != nil {
+		return fmt.Errorf("write %s: %s", tmp, err)
+	}
+	if err := os.Rename(tmp, d.pth); err != nil {
+		return fmt.Errorf("rename %s: %s", tmp, err)
+	}
+	return nil
+}
+
+func healthzHandler(queued func() int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(map[string]interface{}{"status": "ok", "queued": queued()}); err != nil {
+			http.Error(w, fmt.Sprintf("encode health: %s", err), http.StatusInternalServerError)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package daemon
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lszucs/github-to-discourse/internal/queue"
+)
+
+const testSecret = "webhook secret"
+
+func sign(payload string) string {
+	mac := hmac.New(sha256.New, []byte(testSecret))
+	mac.Write([]byte(payload))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestWebhookHandler(t *testing.T) {
+	const payload = `{"zen": "Keep it logically awesome.", "hook_id": 1}`
+
+	tests := []struct {
+		name      string
+		signature string
+		delivery  string
+		want      int
+	}{
+		{name: "signed", signature: sign(payload), delivery: "d-1", want: http.StatusNoContent},
+		{name: "missing signature", delivery: "d-2", want: http.StatusUnauthorized},
+		{name: "missing sha256 prefix", signature: strings.TrimPrefix(sign(payload), "sha256="), delivery: "d-3", want: http.StatusUnauthorized},
+		{name: "sha1 signature", signature: "sha1=" + strings.TrimPrefix(sign(payload), "sha256="), delivery: "d-4", want: http.StatusUnauthorized},
+		{name: "bad MAC", signature: sign(payload + " "), delivery: "d-5", want: http.StatusUnauthorized},
+		{name: "not hex", signature: "sha256=zz", delivery: "d-6", want: http.StatusUnauthorized},
+		{name: "missing delivery ID", signature: sign(payload), want: http.StatusBadRequest},
+		{name: "replayed delivery", signature: sign(payload), delivery: "d-1", want: http.StatusConflict},
+		// a delivery rejected before its ID was recorded may come again
+		{name: "redelivered after a bad signature", signature: sign(payload), delivery: "d-5", want: http.StatusNoContent},
+	}
+
+	defaultSecret := webhookSecret
+	webhookSecret = testSecret
+	defer func() { webhookSecret = defaultSecret }()
+
+	dir := t.TempDir()
+	q, err := queue.Open(dir)
+	if err != nil {
+		t.Fatalf("open queue: %s", err)
+	}
+	defer q.Close()
+	d, err := openDeliveries(dir)
+	if err != nil {
+		t.Fatalf("open deliveries: %s", err)
+	}
+	handler := webhookHandler(q, d)
+
+	// the cases run in order: the replays follow the first deliveries
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(payload))
+		req.Header.Set("X-GitHub-Event", "ping")
+		if tt.signature != "" {
+			req.Header.Set(signatureHeader, tt.signature)
+		}
+		if tt.delivery != "" {
+			req.Header.Set("X-GitHub-Delivery", tt.delivery)
+		}
+		w := httptest.NewRecorder()
+		handler(w, req)
+		if w.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d: %s", tt.name, w.Code, tt.want, w.Body)
+		}
+	}
+	if q.Len() != 0 {
+		t.Errorf("queued %d events, want none for pings", q.Len())
+	}
+}
+
+func TestWebhookHandlerRejectsLargePayloads(t *testing.T) {
+	defaultSecret := webhookSecret
+	webhookSecret = testSecret
+	defer func() { webhookSecret = defaultSecret }()
+
+	dir := t.TempDir()
+	q, err := queue.Open(dir)
+	if err != nil {
+		t.Fatalf("open queue: %s", err)
+	}
+	defer q.Close()
+	d, err := openDeliveries(dir)
+	if err != nil {
+		t.Fatalf("open deliveries: %s", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/webhook", bytes.NewReader(make([]byte, maxPayload+1)))
+	req.Header.Set("X-GitHub-Event", "issues")
+	req.Header.Set("X-GitHub-Delivery", "d-1")
+	w := httptest.NewRecorder()
+	webhookHandler(q, d)(w, req)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("status = %d, want %d: %s", w.Code, http.StatusRequestEntityTooLarge, w.Body)
+	}
+}
+
+func TestVerifyWithoutSecret(t *testing.T) {
+	defaultSecret := webhookSecret
+	webhookSecret = ""
+	defer func() { webhookSecret = defaultSecret }()
+
+	req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader("{}"))
+	if err := verify(req, []byte("{}")); err != nil {
+		t.Errorf("verify without a secret: %s, want unsigned payloads accepted", err)
+	}
+}
+
+func TestDeliveriesRejectReplaysAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	d, err := openDeliveries(dir)
+	if err != nil {
+		t.Fatalf("open deliveries: %s", err)
+	}
+	if !d.claim("a") || d.claim("a") {
+		t.Fatal("claim of a new ID failed, or a pending ID was claimed twice")
+	}
+	if err := d.commit("a"); err != nil {
+		t.Fatalf("commit: %s", err)
+	}
+	if !d.claim("b") {
+		t.Fatal("claim of b failed")
+	}
+	d.release("b")
+
+	reopened, err := openDeliveries(dir)
+	if err != nil {
+		t.Fatalf("reopen deliveries: %s", err)
+	}
+	if reopened.claim("a") {
+		t.Error("committed delivery a claimed again after the restart")
+	}
+	if !reopened.claim("b") {
+		t.Error("released delivery b rejected after the restart")
+	}
+}
+
+func TestDeliveriesForgetTheOldest(t *testing.T) {
+	dir := t.TempDir()
+	d, err := openDeliveries(dir)
+	if err != nil {
+		t.Fatalf("open deliveries: %s", err)
+	}
+	for n := 0; n <= maxDeliveries; n++ {
+		id := fmt.Sprint(n)
+		if !d.claim(id) {
+			t.Fatalf("claim %s failed", id)
+		}
+		if err := d.commit(id); err != nil {
+			t.Fatalf("commit %s: %s", id, err)
+		}
+	}
+
+	reopened, err := openDeliveries(dir)
+	if err != nil {
+		t.Fatalf("reopen deliveries: %s", err)
+	}
+	if !reopened.claim("0") {
+		t.Error("the oldest delivery is still remembered, want it forgotten above the limit")
+	}
+	if reopened.claim(fmt.Sprint(maxDeliveries)) {
+		t.Error("the last delivery was forgotten")
+	}
+}
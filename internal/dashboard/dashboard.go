@@ -0,0 +1,335 @@
+package dashboard
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bitrise-io/go-utils/log"
+	gh "github.com/google/go-github/github"
+
+	"github.com/lszucs/github-to-discourse/internal/budget"
+	"github.com/lszucs/github-to-discourse/internal/github"
+	"github.com/lszucs/github-to-discourse/internal/ratelimit"
+)
+
+const (
+	refreshInterval = 500 * time.Millisecond
+	barWidth        = 30
+	recentLines     = 5
+)
+
+var enabled bool
+
+func init() {
+	flag.BoolVar(&enabled, "dashboard", false, "--dashboard (show a terminal dashboard of live runs instead of the log: progress per repo, throughput, budgets, recent errors; p pauses and resumes, s skips the current repo)")
+}
+
+// Enabled reports whether --dashboard is set.
+func Enabled() bool {
+	return enabled
+}
+
+// repoProgress counts the issues of a repo.
+type repoProgress struct {
+	name    string
+	fetched int
+	done    int
+	failed  int
+	skipped bool
+}
+
+var (
+	mu      sync.Mutex
+	resumed = sync.NewCond(&mu)
+	running bool
+	paused  bool
+	started time.Time
+	repos   []*repoProgress
+	byName  = map[string]*repoProgress{}
+	current string
+	// currentRepo is the repo of the current issue, skipped with s
+	currentRepo *repoProgress
+	done        int
+	failed      int
+	errs        []string
+	logs        []string
+)
+
+func repoOf(i *gh.Issue) *repoProgress {
+	name := i.GetHTMLURL()
+	if ref, err := github.ParseIssueURL(i.GetHTMLURL()); err == nil {
+		name = ref.FullName()
+	}
+	p, ok := byName[name]
+	if !ok {
+		p = &repoProgress{name: name}
+		byName[name] = p
+		repos = append(repos, p)
+	}
+	return p
+}
+
+// Fetched counts a fetched issue in the total of its repo.
+func Fetched(i *gh.Issue) {
+	mu.Lock()
+	defer mu.Unlock()
+	repoOf(i).fetched++
+}
+
+// Started shows the issue as the one being migrated.
+func Started(i *gh.Issue) {
+	mu.Lock()
+	defer mu.Unlock()
+	current = i.GetHTMLURL()
+	currentRepo = repoOf(i)
+}
+
+// Finished counts the issue as processed, failed if err is not nil.
+func Finished(i *gh.Issue, err error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	p := repoOf(i)
+	p.done++
+	done++
+	if err != nil {
+		p.failed++
+		failed++
+		errs = appendRecent(errs, fmt.Sprintf("%s %s", time.Now().Format("15:04:05"), err))
+	}
+}
+
+// Wait blocks while the run is paused from the dashboard.
+func Wait() {
+	mu.Lock()
+	defer mu.Unlock()
+	for paused && running {
+		resumed.Wait()
+	}
+}
+
+// Skipped reports whether the repo of the issue was skipped from the dashboard. Its remaining
+// issues are left to the next run.
+func Skipped(i *gh.Issue) bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return repoOf(i).skipped
+}
+
+func appendRecent(lines []string, line string) []string {
+	lines = append(lines, line)
+	if len(lines) > recentLines {
+		lines = lines[len(lines)-recentLines:]
+	}
+	return lines
+}
+
+// logWriter keeps the recent log lines for the dashboard instead of printing them.
+type logWriter struct{}
+
+var ansiRe = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+func (logWriter) Write(p []byte) (int, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		logs = appendRecent(logs, ansiRe.ReplaceAllString(line, ""))
+	}
+	return len(p), nil
+}
+
+// Start shows the dashboard on the terminal and reads the keys, until the returned function is called.
+// The log is printed again once stopped. It returns a no-op and the log is printed as usual if the
+// standard output isn't a terminal or its input can't be read key by key.
+//
+// The dashboard is drawn with plain ANSI escapes and the keys are read through stty instead of a TUI
+// library like bubbletea: a full redraw twice a second and two keys don't need its event loop, and
+// it would bring a dozen more packages to vendor for a view of the log.
+func Start() func() {
+	if fi, err := os.Stdout.Stat(); err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+		log.Warnf("--dashboard needs a terminal, print the log instead")
+		return func() {}
+	}
+	restore, err := readKeys()
+	if err != nil {
+		log.Warnf("--dashboard needs a terminal read key by key, print the log instead: %s", err)
+		return func() {}
+	}
+
+	mu.Lock()
+	running = true
+	started = time.Now()
+	mu.Unlock()
+	log.SetOutWriter(logWriter{})
+
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(refreshInterval)
+		defer ticker.Stop()
+		for {
+			draw(os.Stdout)
+			select {
+			case <-ticker.C:
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+		<-stopped
+		restore()
+
+		mu.Lock()
+		running, paused = false, false
+		resumed.Broadcast()
+		mu.Unlock()
+		draw(os.Stdout)
+		log.SetOutWriter(os.Stdout)
+	}
+}
+
+// readKeys switches the terminal to unbuffered input without echo and handles the keys, it returns
+// the function restoring the terminal.
+func readKeys() (func(), error) {
+	tty, err := os.Open("/dev/tty")
+	if err != nil {
+		return nil, fmt.Errorf("open terminal: %s", err)
+	}
+	saved, err := stty(tty, "-g")
+	if err != nil {
+		tty.Close()
+		return nil, err
+	}
+	if _, err := stty(tty, "cbreak", "-echo"); err != nil {
+		tty.Close()
+		return nil, err
+	}
+
+	go func() {
+		key := make([]byte, 1)
+		for {
+			if _, err := tty.Read(key); err != nil {
+				return
+			}
+			handleKey(key[0])
+		}
+	}()
+
+	return func() {
+		if _, err := stty(tty, strings.TrimSpace(saved)); err != nil {
+			fmt.Fprintf(os.Stderr, "restore terminal: %s\n", err)
+		}
+		tty.Close()
+	}, nil
+}
+
+func stty(tty *os.File, args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = tty
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("stty %s: %s", strings.Join(args, " "), err)
+	}
+	return string(out), nil
+}
+
+func handleKey(key byte) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	switch key {
+	case 'p', 'P':
+		paused = !paused
+		if !paused {
+			resumed.Broadcast()
+		}
+	case 's', 'S':
+		if currentRepo != nil {
+			currentRepo.skipped = true
+		}
+	}
+}
+
+// draw renders the dashboard from the top left corner of the terminal.
+func draw(w io.Writer) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	var b strings.Builder
+	b.WriteString("\x1b[H\x1b[2J")
+
+	elapsed := time.Since(started)
+	status := "running"
+	if paused {
+		status = "PAUSED (p to resume)"
+	}
+	fmt.Fprintf(&b, "github-to-discourse  %s  elapsed %s\n\n", status, elapsed.Round(time.Second))
+
+	throughput := 0.0
+	if minutes := elapsed.Minutes(); minutes > 0 {
+		throughput = float64(done) / minutes
+	}
+	fmt.Fprintf(&b, "processed %d, failed %d, %.1f issues per minute\n", done, failed, throughput)
+	for _, service := range []string{budget.GitHub, budget.Discourse} {
+		left := "unlimited"
+		if r := budget.Remaining(service); r >= 0 {
+			left = fmt.Sprintf("%d left", r)
+		}
+		pace := "no rate limit"
+		if rpm := ratelimit.RPM(service); rpm > 0 {
+			pace = fmt.Sprintf("%d rpm", rpm)
+		}
+		fmt.Fprintf(&b, "%-9s %d calls, budget %s, %s\n", service, budget.Used(service), left, pace)
+	}
+
+	b.WriteString("\nrepos\n")
+	for _, p := range repos {
+		note := ""
+		if p.failed > 0 {
+			note = fmt.Sprintf(" %d failed", p.failed)
+		}
+		if p.skipped {
+			note += " skipped"
+		}
+		fmt.Fprintf(&b, "  %-40s %s %d/%d%s\n", p.name, bar(p.done, p.fetched), p.done, p.fetched, note)
+	}
+	if current != "" {
+		fmt.Fprintf(&b, "\ncurrent  %s\n", current)
+	}
+
+	if len(errs) > 0 {
+		b.WriteString("\nrecent errors\n")
+		for _, e := range errs {
+			fmt.Fprintf(&b, "  %s\n", e)
+		}
+	}
+	b.WriteString("\nlog\n")
+	for _, l := range logs {
+		fmt.Fprintf(&b, "  %s\n", l)
+	}
+	b.WriteString("\np pause/resume  s skip the current repo\n")
+
+	fmt.Fprint(w, b.String())
+}
+
+func bar(n, total int) string {
+	filled := 0
+	if total > 0 {
+		filled = n * barWidth / total
+	}
+	if filled > barWidth {
+		filled = barWidth
+	}
+	return "[" + strings.Repeat("#", filled) + strings.Repeat("-", barWidth-filled) + "]"
+}
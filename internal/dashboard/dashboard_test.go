@@ -0,0 +1,156 @@
+package dashboard
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	gh "github.com/google/go-github/github"
+)
+
+// reset forgets the state of the previous test and starts a run.
+func reset(t *testing.T) {
+	mu.Lock()
+	defer mu.Unlock()
+	running, paused = true, false
+	repos, byName = nil, map[string]*repoProgress{}
+	current, currentRepo = "", nil
+	done, failed = 0, 0
+	errs, logs = nil, nil
+	t.Cleanup(func() {
+		mu.Lock()
+		running, paused = false, false
+		resumed.Broadcast()
+		mu.Unlock()
+	})
+}
+
+func issue(repo string, number int) *gh.Issue {
+	return &gh.Issue{HTMLURL: gh.String(fmt.Sprintf("https://github.com/%s/issues/%d", repo, number))}
+}
+
+// waits reports whether Wait blocks for the timeout.
+func waits(timeout time.Duration) bool {
+	returned := make(chan struct{})
+	go func() {
+		Wait()
+		close(returned)
+	}()
+	select {
+	case <-returned:
+		return false
+	case <-time.After(timeout):
+		return true
+	}
+}
+
+func TestPause(t *testing.T) {
+	reset(t)
+	if waits(50 * time.Millisecond) {
+		t.Fatal("Wait blocks before pausing")
+	}
+
+	handleKey('p')
+	returned := make(chan struct{})
+	go func() {
+		Wait()
+		close(returned)
+	}()
+	select {
+	case <-returned:
+		t.Fatal("Wait returned while paused")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	handleKey('P')
+	select {
+	case <-returned:
+	case <-time.After(time.Second):
+		t.Fatal("Wait still blocks after resuming")
+	}
+}
+
+func TestPauseStoppedDashboard(t *testing.T) {
+	reset(t)
+	handleKey('p')
+	mu.Lock()
+	running = false
+	mu.Unlock()
+	if waits(50 * time.Millisecond) {
+		t.Error("Wait blocks once the dashboard is stopped")
+	}
+}
+
+func TestSkip(t *testing.T) {
+	tests := []struct {
+		name    string
+		current *gh.Issue
+		keys    string
+		// wantSkipped are the repos skipped, the others are not
+		wantSkipped map[string]bool
+	}{
+		{
+			name:        "no current issue",
+			keys:        "s",
+			wantSkipped: map[string]bool{},
+		},
+		{
+			name:        "repo of the current issue",
+			current:     issue("octo/one", 1),
+			keys:        "s",
+			wantSkipped: map[string]bool{"octo/one": true},
+		},
+		{
+			name:        "upper case key",
+			current:     issue("octo/two", 4),
+			keys:        "S",
+			wantSkipped: map[string]bool{"octo/two": true},
+		},
+		{
+			name:        "other keys",
+			current:     issue("octo/one", 1),
+			keys:        "xq\n",
+			wantSkipped: map[string]bool{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reset(t)
+			Fetched(issue("octo/one", 1))
+			Fetched(issue("octo/two", 4))
+			if tt.current != nil {
+				Started(tt.current)
+			}
+			for _, k := range []byte(tt.keys) {
+				handleKey(k)
+			}
+
+			for _, repo := range []string{"octo/one", "octo/two"} {
+				if got := Skipped(issue(repo, 9)); got != tt.wantSkipped[repo] {
+					t.Errorf("%s skipped = %t, want %t", repo, got, tt.wantSkipped[repo])
+				}
+			}
+			if waits(50 * time.Millisecond) {
+				t.Error("Wait blocks, want skipping not to pause")
+			}
+		})
+	}
+}
+
+func TestDrawShowsPauseAndSkip(t *testing.T) {
+	reset(t)
+	Fetched(issue("octo/one", 1))
+	Started(issue("octo/one", 1))
+	handleKey('s')
+	handleKey('p')
+
+	var b strings.Builder
+	draw(&b)
+	for _, want := range []string{"PAUSED (p to resume)", "octo/one", "skipped"} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("dashboard doesn't show %q:\n%s", want, b.String())
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package debugbundle
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bitrise-io/go-utils/log"
+
+	"github.com/lszucs/github-to-discourse/internal/run"
+)
+
+const redacted = "REDACTED"
+
+var (
+	dir string
+
+	mu  sync.Mutex
+	seq int
+
+	unsafeChars      = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+	sensitiveHeaders = []string{"Authorization", "Api-Key", "Api-Username", "Cookie", "Set-Cookie"}
+	sensitiveParams  = []string{"api_key", "api_username", "access_token"}
+)
+
+func init() {
+	flag.StringVar(&dir, "debug-dir", "", "--debug-dir=<path> (directory to save request/response pairs of failed API calls to, one subdirectory per issue, relative to --out-dir)")
+}
+
+type issueKey struct{}
+
+// WithIssue returns a copy of ctx marking the requests sent with it as requests of the issue, their
+// bundles are saved to the directory of the issue. Requests without an issue are not issue specific.
+func WithIssue(ctx context.Context, issueURL string) context.Context {
+	return context.WithValue(ctx, issueKey{}, issueURL)
+}
+
+// issueOf returns the issue the request was sent for, empty if none.
+func issueOf(req *http.Request) string {
+	issueURL, _ := req.Context().Value(issueKey{}).(string)
+	return issueURL
+}
+
+type message struct {
+	Method string      `json:"method,omitempty"`
+	URL    string      `json:"url,omitempty"`
+	Status string      `json:"status,omitempty"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+}
+
+type bundle struct {
+	Time     time.Time `json:"time"`
+	Issue    string    `json:"issue,omitempty"`
+	Request  message   `json:"request"`
+	Response *message  `json:"response,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// Transport saves the request/response pair of failed calls to --debug-dir.
+type Transport struct {
+	// Base is the underlying transport, http.DefaultTransport if nil.
+	Base http.RoundTripper
+}
+
+func (t *Transport) base() http.RoundTripper {
+	if t.Base == nil {
+		return http.DefaultTransport
+	}
+	return t.Base
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if dir == "" {
+		return t.base().RoundTrip(req)
+	}
+
+	var reqBody []byte
+	if req.Body != nil {
+		var err error
+		if reqBody, err = ioutil.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		if err := req.Body.Close(); err != nil {
+			return nil, err
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(reqBody))
+	}
+
+	resp, err := t.base().RoundTrip(req)
+	if err == nil && resp.StatusCode < 400 {
+		return resp, nil
+	}
+
+	b := bundle{
+		Time:  time.Now(),
+		Issue: issueOf(req),
+		Request: message{
+			Method: req.Method,
+			URL:    redactURL(req.URL),
+			Header: redactHeader(req.Header),
+			Body:   string(reqBody),
+		},
+	}
+	if err != nil {
+		b.Error = err.Error()
+	} else {
+		respBody, rerr := ioutil.ReadAll(resp.Body)
+		if cerr := resp.Body.Close(); cerr != nil {
+			log.Warnf("warning: close response body: %s", cerr)
+		}
+		if rerr != nil {
+			return nil, rerr
+		}
+		resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+
+		b.Response = &message{
+			Status: resp.Status,
+			Header: redactHeader(resp.Header),
+			Body:   string(respBody),
+		}
+	}
+
+	if serr := save(b); serr != nil {
+		log.Warnf("warning: save debug bundle: %s", serr)
+	}
+	return resp, err
+}
+
+func save(b bundle) error {
+	mu.Lock()
+	seq++
+	n := seq
+	mu.Unlock()
+
+	sub := "run"
+	if b.Issue != "" {
+		sub = strings.Trim(unsafeChars.ReplaceAllString(strings.TrimPrefix(b.Issue, "https://"), "_"), "_")
+	}
+	bundleDir := filepath.Join(run.Path(dir), sub)
+	if err := os.MkdirAll(bundleDir, 0755); err != nil {
+		return fmt.Errorf("create %s: %s", bundleDir, err)
+	}
+
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal bundle: %s", err)
+	}
+
+	pth := filepath.Join(bundleDir, fmt.Sprintf("%s-%03d.json", b.Time.UTC().Format("20060102T150405"), n))
+	if err := ioutil.WriteFile(pth, data, 0644); err != nil {
+		return fmt.Errorf("write %s: %s", pth, err)
+	}
+	log.Warnf("failed request saved to %s", pth)
+	return nil
+}
+
+func redactHeader(h http.Header) http.Header {
+	c := http.Header{}
+	for k, v := range h {
+		c[k] = v
+	}
+	for _, k := range sensitiveHeaders {
+		if c.Get(k) != "" {
+			c.Set(k, redacted)
+		}
+	}
+	return c
+}
+
+func redactURL(u *url.URL) string {
+	c := *u
+	q := c.Query()
+	for _, k := range sensitiveParams {
+		if q.Get(k) != "" {
+			q.Set(k, redacted)
+		}
+	}
+	c.RawQuery = q.Encode()
+	return c.String()
+}
@@ -0,0 +1,66 @@
+package delta
+
+import (
+	"time"
+
+	"github.com/bitrise-io/go-utils/log"
+	gh "github.com/google/go-github/github"
+
+	"github.com/lszucs/github-to-discourse/internal/state"
+)
+
+// LastRun returns the ID and start of the last run recorded in the state: the run of the most
+// recent event, started at the earliest event of that run. ok is false if no run is recorded.
+func LastRun(records []*state.Record) (id string, start time.Time, ok bool) {
+	var last time.Time
+	for _, r := range records {
+		if r.RunID == "" {
+			continue
+		}
+		for _, e := range r.Events {
+			if e.Time.After(last) {
+				last, id = e.Time, r.RunID
+			}
+		}
+	}
+	if id == "" {
+		return "", time.Time{}, false
+	}
+
+	for _, r := range records {
+		if r.RunID != id {
+			continue
+		}
+		for _, e := range r.Events {
+			if start.IsZero() || e.Time.Before(start) {
+				start = e.Time
+			}
+		}
+	}
+	return id, start, true
+}
+
+// Filter forwards the issues which have no state record and were created at or after since. Issues
+// without a record created earlier are logged, a regular run migrates them.
+func Filter(issues <-chan *gh.Issue, since time.Time) <-chan *gh.Issue {
+	out := make(chan *gh.Issue)
+	go func() {
+		defer close(out)
+		known, older := 0, 0
+		for i := range issues {
+			if _, ok := state.Lookup(i.GetHTMLURL()); ok {
+				known++
+				continue
+			}
+			if i.GetCreatedAt().Before(since) {
+				older++
+				log.Printf("%s was created before the last run (%s) but never processed, a regular run migrates it", i.GetHTMLURL(), i.GetCreatedAt().Format(time.RFC3339))
+				continue
+			}
+			log.Printf("%s is new since the last run", i.GetHTMLURL())
+			out <- i
+		}
+		log.Printf("delta: skipped %d issues already in the state and %d older unprocessed issues", known, older)
+	}()
+	return out
+}
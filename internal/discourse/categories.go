@@ -0,0 +1,170 @@
+package discourse
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/log"
+
+	"github.com/lszucs/github-to-discourse/internal/config"
+)
+
+var (
+	categoryPath     string
+	createCategories bool
+)
+
+func init() {
+	flag.StringVar(&categoryPath, "discourse-category", "", "--discourse-category=<slug>|<parent>/<child> (category to post topics to by slug path, overrides --discourse-category-id)")
+	flag.BoolVar(&createCategories, "create-categories", false, "--create-categories (create the missing child category of --discourse-category and of the category paths of the config file in live runs, the API user needs the permission)")
+}
+
+// categoryNode is a category with its subcategories as listed by /categories.json.
+type categoryNode struct {
+	ID            int            `json:"id"`
+	Slug          string         `json:"slug"`
+	Subcategories []categoryNode `json:"subcategory_list"`
+}
+
+func listCategories() ([]categoryNode, error) {
+	var data struct {
+		CategoryList struct {
+			Categories []categoryNode `json:"categories"`
+		} `json:"category_list"`
+	}
+	if err := get("/categories.json?include_subcategories=true", &data); err != nil {
+		return nil, fmt.Errorf("list categories: %s", err)
+	}
+	return data.CategoryList.Categories, nil
+}
+
+func findSlug(nodes []categoryNode, slug string) (categoryNode, bool) {
+	for _, n := range nodes {
+		if strings.EqualFold(n.Slug, slug) {
+			return n, true
+		}
+	}
+	return categoryNode{}, false
+}
+
+func createCategory(name string, parentID int) (int, error) {
+	payload, err := json.Marshal(map[string]interface{}{
+		"name":               name,
+		"slug":               name,
+		"parent_category_id": parentID,
+		"color":              "0088CC",
+		"text_color":         "FFFFFF",
+	})
+	if err != nil {
+		return 0, fmt.Errorf("marshal category: %s", err)
+	}
+
+	status, body, err := send(http.MethodPost, "/categories.json", "application/json", payload)
+	if err != nil {
+		return 0, err
+	}
+	if status != 200 {
+		return 0, fmt.Errorf("api error creating category %s: %d %s", name, status, body)
+	}
+	var data struct {
+		Category Category `json:"category"`
+	}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return 0, fmt.Errorf("could not unmarshal response body %s; reason: %s", body, err)
+	}
+	return data.Category.ID, nil
+}
+
+// resolvePath returns the ID of the category at the slug path, a top level slug or parent/child.
+// A missing child is created if create is set, otherwise 0 is returned with the missing slug.
+func resolvePath(tree []categoryNode, path string, create bool) (int, string, error) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) > 2 {
+		return 0, "", fmt.Errorf("category path %s: at most parent/child is supported", path)
+	}
+
+	parent, ok := findSlug(tree, parts[0])
+	if !ok {
+		return 0, "", fmt.Errorf("category path %s: no category %s", path, parts[0])
+	}
+	if len(parts) == 1 {
+		return parent.ID, "", nil
+	}
+
+	if child, ok := findSlug(parent.Subcategories, parts[1]); ok {
+		return child.ID, "", nil
+	}
+	if !create {
+		return 0, parts[1], nil
+	}
+	id, err := createCategory(parts[1], parent.ID)
+	if err != nil {
+		return 0, "", fmt.Errorf("category path %s: %s", path, err)
+	}
+	log.Printf("created category %s (%d)", path, id)
+	return id, "", nil
+}
+
+func isCategoryID(s string) bool {
+	_, err := strconv.Atoi(s)
+	return err == nil
+}
+
+// ResolveCategories resolves --discourse-category and the category paths keying the options of the
+// config file to category IDs. Missing child categories are created in live runs with --create-categories.
+func ResolveCategories(live bool) error {
+	var paths []string
+	for key := range categories {
+		if !isCategoryID(key) {
+			paths = append(paths, key)
+		}
+	}
+	if categoryPath == "" && len(paths) == 0 {
+		return nil
+	}
+
+	tree, err := listCategories()
+	if err != nil {
+		return err
+	}
+	create := live && createCategories
+
+	if categoryPath != "" {
+		id, missing, err := resolvePath(tree, categoryPath, create)
+		if err != nil {
+			return err
+		}
+		switch {
+		case missing != "" && createCategories:
+			log.Warnf("category path %s: subcategory %s would be created", categoryPath, missing)
+		case missing != "":
+			return fmt.Errorf("category path %s: no subcategory %s, --create-categories creates it in live runs", categoryPath, missing)
+		default:
+			discourseCategoryID = id
+			log.Printf("post topics to category %s (%d)", categoryPath, id)
+		}
+	}
+
+	resolved := map[string]config.CategoryOptions{}
+	for key, opts := range categories {
+		if isCategoryID(key) {
+			resolved[key] = opts
+			continue
+		}
+		id, missing, err := resolvePath(tree, key, create)
+		if err != nil {
+			return err
+		}
+		if missing != "" {
+			log.Warnf("category path %s: no subcategory %s, its options are ignored", key, missing)
+			continue
+		}
+		resolved[strconv.Itoa(id)] = opts
+	}
+	categories = resolved
+	return nil
+}
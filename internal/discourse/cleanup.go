@@ -0,0 +1,38 @@
+package discourse
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// DeleteTopic deletes the topic.
+func DeleteTopic(id int) error {
+	status, body, err := send(http.MethodDelete, fmt.Sprintf("/t/%d.json", id), "", nil)
+	if err != nil {
+		return err
+	}
+	if status != 200 {
+		return fmt.Errorf("api error for deleting topic %d: %d %s", id, status, body)
+	}
+	return nil
+}
+
+// TaggedTopics returns the topics having the tag.
+func TaggedTopics(tag string) ([]Created, error) {
+	var data struct {
+		TopicList struct {
+			Topics []struct {
+				ID int `json:"id"`
+			} `json:"topics"`
+		} `json:"topic_list"`
+	}
+	if err := get(fmt.Sprintf("/tag/%s.json", tag), &data); err != nil {
+		return nil, fmt.Errorf("list topics tagged %s: %s", tag, err)
+	}
+
+	var topics []Created
+	for _, t := range data.TopicList.Topics {
+		topics = append(topics, Created{URL: fmt.Sprintf("%s/t/%d", baseURL, t.ID), TopicID: t.ID})
+	}
+	return topics, nil
+}
@@ -0,0 +1,148 @@
+package discourse
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Credentials authenticate Discourse API requests.
+type Credentials struct {
+	APIKey  string `json:"api_key"`
+	APIUser string `json:"api_username"`
+}
+
+// Resolver provides the current Discourse API credentials. It is re-evaluated when the
+// API rejects the credentials, so keys can be rotated during a run.
+type Resolver interface {
+	Resolve() (Credentials, error)
+}
+
+// EnvResolver reads the credentials from DISCOURSE_API_KEY and DISCOURSE_API_USER.
+type EnvResolver struct{}
+
+// Resolve implements Resolver.
+func (EnvResolver) Resolve() (Credentials, error) {
+	c := Credentials{
+		APIKey:  os.Getenv("DISCOURSE_API_KEY"),
+		APIUser: os.Getenv("DISCOURSE_API_USER"),
+	}
+	if c.APIKey == "" {
+		return c, fmt.Errorf("DISCOURSE_API_KEY empty")
+	}
+	if c.APIUser == "" {
+		return c, fmt.Errorf("DISCOURSE_API_USER empty")
+	}
+	return c, nil
+}
+
+// FileResolver reads the credentials from a JSON file: {"api_key": "...", "api_username": "..."}.
+type FileResolver struct {
+	Path string
+}
+
+// Resolve implements Resolver.
+func (r FileResolver) Resolve() (Credentials, error) {
+	b, err := ioutil.ReadFile(r.Path)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("read credentials file %s: %s", r.Path, err)
+	}
+	return parseCredentials(b)
+}
+
+// ExecResolver runs a shell command printing the credentials as JSON: {"api_key": "...", "api_username": "..."}.
+type ExecResolver struct {
+	Command string
+}
+
+// Resolve implements Resolver.
+func (r ExecResolver) Resolve() (Credentials, error) {
+	out, err := exec.Command("sh", "-c", r.Command).Output()
+	if err != nil {
+		return Credentials{}, fmt.Errorf("run credentials command: %s", err)
+	}
+	return parseCredentials(out)
+}
+
+func parseCredentials(b []byte) (Credentials, error) {
+	var c Credentials
+	if err := json.Unmarshal(b, &c); err != nil {
+		return c, fmt.Errorf("unmarshal credentials: %s", err)
+	}
+	if c.APIKey == "" || c.APIUser == "" {
+		return c, fmt.Errorf("api_key or api_username empty")
+	}
+	return c, nil
+}
+
+var (
+	credentialsSrc string
+
+	credsMu sync.Mutex
+	creds   Credentials
+)
+
+func init() {
+	flag.StringVar(&credentialsSrc, "discourse-credentials", "env", "--discourse-credentials=env|file:<path>|exec:<command> (source of the Discourse API credentials, re-read when the API responds 401)")
+}
+
+func resolver() (Resolver, error) {
+	switch {
+	case credentialsSrc == "env":
+		return EnvResolver{}, nil
+	case strings.HasPrefix(credentialsSrc, "file:"):
+		return FileResolver{Path: strings.TrimPrefix(credentialsSrc, "file:")}, nil
+	case strings.HasPrefix(credentialsSrc, "exec:"):
+		return ExecResolver{Command: strings.TrimPrefix(credentialsSrc, "exec:")}, nil
+	default:
+		return nil, fmt.Errorf("not recognized credentials source %s", credentialsSrc)
+	}
+}
+
+// refreshCredentials re-evaluates the configured resolver.
+func refreshCredentials() error {
+	r, err := resolver()
+	if err != nil {
+		return err
+	}
+	c, err := r.Resolve()
+	if err != nil {
+		return err
+	}
+
+	credsMu.Lock()
+	defer credsMu.Unlock()
+	creds = c
+	return nil
+}
+
+func currentCredentials() Credentials {
+	credsMu.Lock()
+	defer credsMu.Unlock()
+	return creds
+}
+
+// CheckCredentials resolves the Discourse API credentials and returns an error if they are not available.
+func CheckCredentials() error {
+	return refreshCredentials()
+}
+
+// Ping sends a cheap authenticated request and returns how long it took.
+func Ping() (time.Duration, error) {
+	start := time.Now()
+	status, body, err := send(http.MethodGet, "/session/current.json", "", nil)
+	if err != nil {
+		return 0, err
+	}
+	if status != http.StatusOK {
+		return 0, fmt.Errorf("api error for GET /session/current.json: %d %s", status, body)
+	}
+	return time.Since(start), nil
+}
@@ -0,0 +1,61 @@
+package discourse
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/bitrise-io/go-utils/log"
+)
+
+// Policies of --preserve-dates.
+const (
+	preserveAuto   = "auto"
+	preserveAlways = "always"
+	preserveNever  = "never"
+)
+
+var (
+	preserveDates string
+	// importMode is set if every topic is dated back to the creation of its issue.
+	importMode bool
+)
+
+func init() {
+	flag.StringVar(&preserveDates, "preserve-dates", preserveAuto, "--preserve-dates=auto|always|never (date topics back to the creation of their issue, to keep the chronology of category listings; auto does if the API user is admin, the only users whose created_at the instance honors)")
+}
+
+// ValidatePreserveDates checks the --preserve-dates policy.
+func ValidatePreserveDates() error {
+	switch preserveDates {
+	case preserveAuto, preserveAlways, preserveNever:
+		return nil
+	default:
+		return fmt.Errorf("not recognized --preserve-dates policy %s", preserveDates)
+	}
+}
+
+// ConfigureImportMode decides whether topics are dated back with --preserve-dates, detecting
+// whether the API user is admin for auto.
+func ConfigureImportMode() error {
+	switch preserveDates {
+	case preserveAlways:
+		importMode = true
+	case preserveNever:
+		importMode = false
+	default:
+		caps, err := DetectCapabilities()
+		if err != nil {
+			return fmt.Errorf("detect discourse capabilities: %s", err)
+		}
+		importMode = caps.Admin
+	}
+	if importMode {
+		log.Printf("import mode: topics keep the creation date of their issue")
+	}
+	return nil
+}
+
+// keepsDate reports whether a topic of the category is created with the creation date of its issue.
+func keepsDate(categoryID int) bool {
+	return importMode || quiet || Options(categoryID).NoBump
+}
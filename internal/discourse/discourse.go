@@ -2,79 +2,493 @@ package discourse
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"mime/multipart"
 	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/bitrise-io/go-utils/log"
+	"github.com/lszucs/github-to-discourse/internal/budget"
+	"github.com/lszucs/github-to-discourse/internal/chaos"
+	"github.com/lszucs/github-to-discourse/internal/config"
+	"github.com/lszucs/github-to-discourse/internal/debugbundle"
+	"github.com/lszucs/github-to-discourse/internal/ratelimit"
+	"github.com/lszucs/github-to-discourse/internal/trace"
 )
 
 const (
+	defaultBaseURL       = "https://discuss.bitrise.io"
 	internalTestCategory  = 29
 	buildIssuesCat = 11
 )
 
 var (
-	discourseAPIKey     = os.Getenv("DISCOURSE_API_KEY")
-	discourseAPIUser    = os.Getenv("DISCOURSE_API_USER")
+	baseURL             string
 	discourseCategoryID int
+	fallbackCategoryID  int
+	quiet               bool
+	categories          map[string]config.CategoryOptions
+	retry               = &ratelimit.Retry{Service: budget.Discourse, Base: &chaos.Transport{Base: &budget.Transport{Service: budget.Discourse, Base: &ratelimit.Transport{Service: budget.Discourse, Base: pool}}}, Timeout: time.Minute}
+	httpClient          = &http.Client{Transport: &debugbundle.Transport{Base: retry}}
 	topicTpl = `Original GitHub post: %s
 	
 	%s`
 )
 
+// NeedsRecategorizationTag marks topics posted to the fallback category.
+const NeedsRecategorizationTag = "needs-recategorization"
+
+// Topic is a Discourse topic to be created from a GitHub issue.
+type Topic struct {
+	Title     string
+	OriginURL string
+	Content   string
+	CreatedAt time.Time
+	// CategoryID overrides --discourse-category-id if not zero.
+	CategoryID int
+	Tags       []string
+	// Author is the GitHub login of the author, the topic is posted on behalf of with --post-as.
+	Author string
+}
+
+// CategoryError is returned by PostTopic if the category rejected the topic, e.g. because it is
+// restricted or doesn't accept more topics.
+type CategoryError struct {
+	CategoryID int
+	Status     int
+	Body       string
+}
+
+func (e *CategoryError) Error() string {
+	return fmt.Sprintf("category %d rejected the topic: %d %s", e.CategoryID, e.Status, e.Body)
+}
+
+// isCategoryError reports whether the failed topic creation response is specific to the category.
+func isCategoryError(status int, body []byte) bool {
+	if status != http.StatusForbidden && status != http.StatusUnprocessableEntity {
+		return false
+	}
+	return strings.Contains(strings.ToLower(string(body)), "categor")
+}
+
 func init() {
-	if discourseAPIKey == "" {
-		fmt.Println("DISCOURSE_API_KEY empty")
-		os.Exit(1)
+	flag.StringVar(&baseURL, "discourse-url", defaultBaseURL, "--discourse-url=<url> (base URL of the Discourse instance)")
+	flag.IntVar(&discourseCategoryID, "discourse-category-id", internalTestCategory, "--discourse-category-id=<int> (discourse category to post topics to)")
+	flag.IntVar(&fallbackCategoryID, "fallback-category-id", 0, "--fallback-category-id=<int> (category to post topics to if their category rejects them, tagged "+NeedsRecategorizationTag+"; 0 fails the issue instead)")
+	flag.BoolVar(&quiet, "discourse-quiet", false, "--discourse-quiet (create topics with their original GitHub creation date and without auto tracking, to spare category watchers from notifications)")
+}
+
+// authenticate authenticates the request as the API user, or as the given user (the API key has
+// to be valid for all users). The credentials go in headers, not in the query string, so they
+// don't end up in the URLs of errors, the state file or the report.
+func authenticate(req *http.Request, as string) {
+	c := currentCredentials()
+	if as != "" {
+		c.APIUser = as
 	}
+	req.Header.Set("Api-Key", c.APIKey)
+	req.Header.Set("Api-Username", c.APIUser)
+}
+
+// send sends an API request and returns the response status code and body. If the credentials
+// are rejected, they are resolved again and the request is retried once.
+func send(method, path, contentType string, payload []byte) (int, []byte, error) {
+	return sendAs("", method, path, contentType, payload)
+}
+
+// sendAs sends an API request on behalf of the user, see send.
+func sendAs(user, method, path, contentType string, payload []byte) (int, []byte, error) {
+	return sendFor("", user, method, path, contentType, payload)
+}
 
-	if discourseAPIUser == "" {
-		fmt.Println("DISCOURSE_API_USER empty")
-		os.Exit(1)
+// sendFor sends an API request of the issue on behalf of the user, see send. The issue URL, if not
+// empty, files the debug bundle of a failed request under the issue.
+func sendFor(issueURL, user, method, path, contentType string, payload []byte) (int, []byte, error) {
+	status, body, err := sendOnce(issueURL, user, method, path, contentType, payload)
+	if err != nil || status != http.StatusUnauthorized {
+		return status, body, err
 	}
 
-	flag.IntVar(&discourseCategoryID, "discourse-category-id", internalTestCategory, "--discourse-category-id=<int> (discourse category to post topics to)")
+	log.Warnf("discourse API responded 401, resolve credentials again")
+	if err := refreshCredentials(); err != nil {
+		return 0, nil, fmt.Errorf("refresh credentials: %s", err)
+	}
+	return sendOnce(issueURL, user, method, path, contentType, payload)
 }
 
-func PostTopic(title string, originURL, content string) (string, error) {
-	message := map[string]interface{}{
-		"title": title,
-		"category": discourseCategoryID,
-		"raw": fmt.Sprintf(topicTpl, originURL, content),
+func sendOnce(issueURL, user, method, path, contentType string, payload []byte) (int, []byte, error) {
+	var reqBody io.Reader
+	if payload != nil {
+		reqBody = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(debugbundle.WithIssue(context.Background(), issueURL), method, baseURL+path, reqBody)
+	if err != nil {
+		return 0, nil, fmt.Errorf("could not create request: %s", err)
+	}
+	authenticate(req, user)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
 	}
 
-	payload, err := json.Marshal(message)
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return 0, nil, fmt.Errorf("error sending %s %s: %s", method, path, err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			log.Warnf("warning: could not close response body: %s", err)
+		}
+	}()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, fmt.Errorf("could not read response body: %s", err)
+	}
+	return resp.StatusCode, body, nil
+}
+
+func get(path string, v interface{}) error {
+	status, body, err := send(http.MethodGet, path, "", nil)
+	if err != nil {
+		return err
+	}
+	if status != 200 {
+		return fmt.Errorf("api error for GET %s: %d %s", path, status, body)
+	}
+
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("could not unmarshal response body %s; reason: %s", body, err)
+	}
+	return nil
+}
+
+// Connect sets the instance and the credentials used by subsequent calls, bypassing --discourse-url and --discourse-credentials.
+func Connect(url string, c Credentials) {
+	baseURL = strings.TrimSuffix(url, "/")
+
+	credsMu.Lock()
+	defer credsMu.Unlock()
+	creds = c
+}
+
+// Category is a Discourse category.
+type Category struct {
+	ID         int    `json:"id"`
+	Name       string `json:"name"`
+	Slug       string `json:"slug"`
+	Permission int    `json:"permission"`
+}
+
+// GetCategory fetches the category with the given ID.
+func GetCategory(id int) (Category, error) {
+	var data struct {
+		Category Category `json:"category"`
+	}
+	if err := get(fmt.Sprintf("/c/%d/show.json", id), &data); err != nil {
+		return Category{}, err
+	}
+	return data.Category, nil
+}
+
+// Category permission levels of the current user, see the permission field of a Category.
+const (
+	PermissionFull       = 1
+	PermissionCreatePost = 2
+	PermissionReadonly   = 3
+)
+
+// CheckCategories fails listing every category of ids in which the API user can not create topics.
+func CheckCategories(ids []int) error {
+	seen := map[int]bool{}
+	var unwritable []string
+	for _, id := range ids {
+		if id == 0 || seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		c, err := GetCategory(id)
+		if err != nil {
+			unwritable = append(unwritable, fmt.Sprintf("%d (%s)", id, err))
+			continue
+		}
+		if c.Permission != PermissionFull {
+			unwritable = append(unwritable, fmt.Sprintf("%d %s (permission level %d)", id, c.Name, c.Permission))
+		}
+	}
+
+	if len(unwritable) > 0 {
+		return fmt.Errorf("API user %s can not create topics in categories: %s", currentCredentials().APIUser, strings.Join(unwritable, ", "))
+	}
+	return nil
+}
+
+// ConfiguredCategories returns the target category and the categories with options in the config file.
+func ConfiguredCategories() []int {
+	ids := []int{discourseCategoryID, fallbackCategoryID}
+	var keys []string
+	for k := range categories {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if id, err := strconv.Atoi(k); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// Capabilities describes what the API user is allowed to do on the Discourse instance.
+type Capabilities struct {
+	Admin     bool
+	Moderator bool
+}
+
+// DetectCapabilities queries the API user's permissions on the instance.
+func DetectCapabilities() (Capabilities, error) {
+	var data struct {
+		CurrentUser struct {
+			Admin     bool `json:"admin"`
+			Moderator bool `json:"moderator"`
+		} `json:"current_user"`
+	}
+	if err := get("/session/current.json", &data); err != nil {
+		return Capabilities{}, err
+	}
+
+	return Capabilities{
+		Admin:     data.CurrentUser.Admin,
+		Moderator: data.CurrentUser.Moderator,
+	}, nil
+}
+
+// Configure sets the per category options of the config file.
+func Configure(cfg config.Config) {
+	categories = cfg.Categories
+	blockedWords = cfg.BlockedWords
+}
+
+// Options returns the options of the category.
+func Options(categoryID int) config.CategoryOptions {
+	return categories[strconv.Itoa(categoryID)]
+}
+
+// TopicID returns the ID of the topic at topicURL (<base>/t/<id> or <base>/t/<slug>/<id>).
+func TopicID(topicURL string) (int, error) {
+	fragments := strings.Split(strings.TrimSuffix(topicURL, "/"), "/")
+	id, err := strconv.Atoi(fragments[len(fragments)-1])
 	if err != nil {
-		return "", fmt.Errorf("could not marshal %s; reason: %s", message, err)
+		return 0, fmt.Errorf("no topic ID in %s", topicURL)
 	}
+	return id, nil
+}
 
-	queryStr := url.Values{
-		"api_key": []string{discourseAPIKey},
-		"api_username": []string{discourseAPIUser},
+// KnownTopicID returns the recorded ID of a topic, or the ID in its URL for topics recorded
+// before their IDs were.
+func KnownTopicID(id int, topicURL string) (int, error) {
+	if id != 0 {
+		return id, nil
+	}
+	return TopicID(topicURL)
+}
+
+// PinTopic pins the topic until the given time.
+func PinTopic(id int, until time.Time) error {
+	form := url.Values{
+		"status":  []string{"pinned"},
+		"enabled": []string{"true"},
+		"until":   []string{until.UTC().Format("2006-01-02")},
 	}.Encode()
-	url := fmt.Sprintf("https://discuss.bitrise.io/posts.json?%s", queryStr)
-	resp, err := http.Post(url, "application/json", bytes.NewBuffer(payload))
+	status, body, err := send(http.MethodPut, fmt.Sprintf("/t/%d/status.json", id), "application/x-www-form-urlencoded", []byte(form))
 	if err != nil {
-		return "", fmt.Errorf("error posting payload %s: %s", payload, err)
+		return err
+	}
+	if status != 200 {
+		return fmt.Errorf("api error for pinning topic %d: %d %s", id, status, body)
 	}
+	return nil
+}
 
-	body, err := ioutil.ReadAll(resp.Body)
+// FallbackCategoryID returns the category to retry topics rejected by their category in, 0 if disabled.
+func FallbackCategoryID() int {
+	return fallbackCategoryID
+}
+
+// CategoryID returns the category topics are posted to.
+func CategoryID() int {
+	return discourseCategoryID
+}
+
+// Staging reports whether --discourse-url points to another instance than the production one.
+func Staging() bool {
+	return strings.TrimSuffix(baseURL, "/") != defaultBaseURL
+}
+
+// Quiet reports whether topics are created in quiet mode.
+func Quiet() bool {
+	return quiet
+}
+
+// Upload uploads the file at path and returns its short URL to be used in posts.
+func Upload(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("could not open %s: %s", path, err)
+	}
 	defer func() {
-		if err := resp.Body.Close(); err != nil {
-			log.Warnf("warning: could not close response body: %s", err)
+		if err := f.Close(); err != nil {
+			log.Warnf("warning: could not close %s: %s", path, err)
 		}
 	}()
+
+	return UploadReader(path, f)
+}
+
+// UploadReader uploads the content of f as a file named after the base of path.
+func UploadReader(path string, f io.Reader) (string, error) {
+	var payload bytes.Buffer
+	w := multipart.NewWriter(&payload)
+	if err := w.WriteField("type", "composer"); err != nil {
+		return "", fmt.Errorf("could not write form field: %s", err)
+	}
+	if err := w.WriteField("synchronous", "true"); err != nil {
+		return "", fmt.Errorf("could not write form field: %s", err)
+	}
+	fw, err := w.CreateFormFile("file", filepath.Base(path))
 	if err != nil {
-		return "", fmt.Errorf("could not read response body: %s", err)
+		return "", fmt.Errorf("could not create form file: %s", err)
+	}
+	if _, err := io.Copy(fw, f); err != nil {
+		return "", fmt.Errorf("could not copy %s: %s", path, err)
 	}
-	if resp.StatusCode != 200 {
-		return "", fmt.Errorf("api error for payload %s; response body: %s", payload, body)
+	if err := w.Close(); err != nil {
+		return "", fmt.Errorf("could not close multipart writer: %s", err)
+	}
+
+	status, body, err := send(http.MethodPost, "/uploads.json", w.FormDataContentType(), payload.Bytes())
+	if err != nil {
+		return "", fmt.Errorf("error uploading %s: %s", path, err)
+	}
+	if status != 200 {
+		return "", fmt.Errorf("api error for upload %s: %d %s", path, status, body)
+	}
+
+	var data struct {
+		ShortURL string `json:"short_url"`
+		URL      string `json:"url"`
+	}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return "", fmt.Errorf("could not unmarshal response body %s; reason: %s", body, err)
+	}
+	if data.ShortURL != "" {
+		return data.ShortURL, nil
+	}
+	return data.URL, nil
+}
+
+// Raw returns the markdown of the first post of the topic.
+func Raw(t Topic) string {
+	if t.OriginURL == "" {
+		return t.Content
+	}
+	return fmt.Sprintf(topicTpl, t.OriginURL, t.Content)
+}
+
+// Created identifies a topic created by PostTopic.
+type Created struct {
+	URL     string
+	TopicID int
+	// PostID is the ID of the first post of the topic.
+	PostID int
+	// Call is the API call made, also set if it failed.
+	Call trace.Call
+	// Identity is the --post-as identity the topic was posted as, empty for the API user.
+	Identity string
+	// PostedAs is the Discourse username the topic was posted as, empty for the API user.
+	PostedAs string
+	// Rewritten lists the blocked words defanged or stripped from the topic to post it.
+	Rewritten []string
+}
+
+// PostTopic creates the topic as the API user, or on behalf of its author along the --post-as chain.
+// If the instance rejects words of the topic, they are defanged or stripped as configured and the
+// topic is posted again once.
+func PostTopic(t Topic) (Created, error) {
+	created, err := postTopicAs(t)
+	blocked, ok := err.(*BlockedWordsError)
+	if !ok || blockedWords == blockedQuarantine {
+		return created, err
+	}
+
+	log.Warnf("%s: %s, %s them and post again", t.OriginURL, blocked, blockedPolicy())
+	t.Title, t.Content = rewriteBlocked(t.Title, blocked.Words), rewriteBlocked(t.Content, blocked.Words)
+	created, err = postTopicAs(t)
+	created.Rewritten = blocked.Words
+	return created, err
+}
+
+func postTopicAs(t Topic) (Created, error) {
+	if postAs == "" || t.Author == "" {
+		return postTopic(t, "", Raw(t))
+	}
+	return postTopicAsAuthor(t)
+}
+
+func postTopic(t Topic, user, raw string) (Created, error) {
+	categoryID := discourseCategoryID
+	if t.CategoryID != 0 {
+		categoryID = t.CategoryID
+	}
+
+	call := trace.Call{Method: http.MethodPost, URL: baseURL + "/posts.json"}
+
+	message := map[string]interface{}{
+		"title":    t.Title,
+		"category": categoryID,
+		"raw":      raw,
+	}
+	if keepsDate(categoryID) && !t.CreatedAt.IsZero() {
+		// created_at is honored for admin API users only: the topic is dated back,
+		// so it keeps the chronology and neither bumps the category nor shows up as new for watchers
+		message["created_at"] = t.CreatedAt.UTC().Format(time.RFC3339)
+	}
+	if quiet {
+		message["auto_track"] = false
+	}
+	if tags := withDefaultTags(categoryID, t.Tags); len(tags) > 0 {
+		message["tags"] = tags
+	}
+
+	payload, err := json.Marshal(message)
+	if err != nil {
+		return Created{Call: call}, fmt.Errorf("could not marshal %s; reason: %s", message, err)
+	}
+
+	status, body, err := sendFor(t.OriginURL, user, http.MethodPost, "/posts.json", "application/json", payload)
+	if err != nil {
+		return Created{Call: call}, fmt.Errorf("error posting payload %s: %s", payload, err)
+	}
+	call.Status = status
+	if status != 200 {
+		if isCategoryError(status, body) {
+			return Created{Call: call}, &CategoryError{CategoryID: categoryID, Status: status, Body: string(body)}
+		}
+		if words := blockedWordsOf(status, body); len(words) > 0 {
+			return Created{Call: call}, &BlockedWordsError{Words: words}
+		}
+		return Created{Call: call}, fmt.Errorf("api error for payload %s; response body: %s", payload, body)
 	}
 
 	decoder := json.NewDecoder(strings.NewReader(string(body)))
@@ -82,14 +496,32 @@ func PostTopic(title string, originURL, content string) (string, error) {
 
 	var data map[string]interface{}
 	if err := decoder.Decode(&data); err != nil {
-		return "", fmt.Errorf("could not unmarshal response body %s; reason: %s", body, err)
+		return Created{Call: call}, fmt.Errorf("could not unmarshal response body %s; reason: %s", body, err)
 	}
 
-	topicID, err := data["topic_id"].(json.Number).Int64()
+	n, ok := data["topic_id"].(json.Number)
+	if !ok {
+		return Created{Call: call}, fmt.Errorf("could not unmarshal response body %s; reason: topic_id is not a number", body)
+	}
+	topicID, err := n.Int64()
 	if err != nil {
-		return "", fmt.Errorf("could not unmarshal response body %s; reason: %s", body, err)
+		return Created{Call: call}, fmt.Errorf("could not unmarshal response body %s; reason: %s", body, err)
+	}
+	n, ok = data["id"].(json.Number)
+	if !ok {
+		return Created{Call: call}, fmt.Errorf("could not unmarshal response body %s; reason: id is not a number", body)
+	}
+	postID, err := n.Int64()
+	if err != nil {
+		return Created{Call: call}, fmt.Errorf("could not unmarshal response body %s; reason: %s", body, err)
 	}
-	discourseURL := fmt.Sprintf("https://discuss.bitrise.io/t/%d", topicID)
 
-	return discourseURL, nil
+	topicURL := fmt.Sprintf("%s/t/%d", baseURL, topicID)
+	call.Resource = topicURL
+	return Created{
+		URL:     topicURL,
+		TopicID: int(topicID),
+		PostID:  int(postID),
+		Call:    call,
+	}, nil
 }
@@ -0,0 +1,202 @@
+package discourse
+
+import (
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func setFlag(t *testing.T, name, value string) {
+	t.Helper()
+	old := flag.Lookup(name).Value.String()
+	if err := flag.Set(name, value); err != nil {
+		t.Fatalf("set --%s: %s", name, err)
+	}
+	t.Cleanup(func() { flag.Set(name, old) })
+}
+
+// useServer points the API at the test server with the credentials for the test.
+func useServer(t *testing.T, handler http.HandlerFunc) *httptest.Server {
+	srv := httptest.NewServer(handler)
+	oldURL, oldCreds := baseURL, currentCredentials()
+	baseURL = srv.URL
+	credsMu.Lock()
+	creds = Credentials{APIKey: "secret-key", APIUser: "system"}
+	credsMu.Unlock()
+	t.Cleanup(func() {
+		srv.Close()
+		baseURL = oldURL
+		credsMu.Lock()
+		creds = oldCreds
+		credsMu.Unlock()
+	})
+	return srv
+}
+
+func TestSendAuthenticatesInHeaders(t *testing.T) {
+	tests := []struct {
+		name     string
+		user     string
+		path     string
+		wantUser string
+		wantURL  string
+	}{
+		{name: "API user", path: "/posts.json", wantUser: "system", wantURL: "/posts.json"},
+		{name: "on behalf of a user", user: "octocat", path: "/posts.json", wantUser: "octocat", wantURL: "/posts.json"},
+		{name: "path with query", path: "/search.json?q=octo", wantUser: "system", wantURL: "/search.json?q=octo"},
+	}
+
+	var got *http.Request
+	useServer(t, func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		w.Write([]byte(`{}`))
+	})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := sendAs(tt.user, http.MethodGet, tt.path, "", nil); err != nil {
+				t.Fatalf("send: %s", err)
+			}
+			if key, user := got.Header.Get("Api-Key"), got.Header.Get("Api-Username"); key != "secret-key" || user != tt.wantUser {
+				t.Errorf("headers Api-Key: %s, Api-Username: %s, want secret-key, %s", key, user, tt.wantUser)
+			}
+			if url := got.URL.RequestURI(); url != tt.wantURL {
+				t.Errorf("URL = %s, want %s", url, tt.wantURL)
+			}
+		})
+	}
+}
+
+func TestSendErrorHidesTheKey(t *testing.T) {
+	srv := useServer(t, func(w http.ResponseWriter, r *http.Request) {})
+	srv.Close()
+
+	_, _, err := send(http.MethodPost, "/posts.json", "application/json", []byte(`{}`))
+	if err == nil {
+		t.Fatal("send to a closed server: no error")
+	}
+	if strings.Contains(err.Error(), "secret-key") {
+		t.Errorf("error = %s, want it without the API key", err)
+	}
+}
+
+func TestPostTopicResponse(t *testing.T) {
+	tests := []struct {
+		name        string
+		response    string
+		wantTopicID int
+		wantPostID  int
+		wantErr     string
+	}{
+		{name: "created", response: `{"id": 12, "topic_id": 3}`, wantTopicID: 3, wantPostID: 12},
+		{name: "no topic_id", response: `{"id": 12}`, wantErr: "topic_id is not a number"},
+		{name: "no id", response: `{"topic_id": 3}`, wantErr: "id is not a number"},
+		{name: "string id", response: `{"id": "12", "topic_id": 3}`, wantErr: "id is not a number"},
+		{name: "not an integer", response: `{"id": 12, "topic_id": 3.5}`, wantErr: "could not unmarshal"},
+		{name: "not an object", response: `"ok"`, wantErr: "could not unmarshal"},
+	}
+
+	var response string
+	useServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(response))
+	})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response = tt.response
+			created, err := postTopic(Topic{Title: "Step fails", OriginURL: "https://github.com/octo/repo/issues/7"}, "", "body")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("postTopic: error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("postTopic: %s", err)
+			}
+			if created.TopicID != tt.wantTopicID || created.PostID != tt.wantPostID {
+				t.Errorf("postTopic = topic %d, post %d, want %d, %d", created.TopicID, created.PostID, tt.wantTopicID, tt.wantPostID)
+			}
+		})
+	}
+}
+
+func TestSendRefreshesCredentialsOn401(t *testing.T) {
+	tests := []struct {
+		name string
+		// rotated is the credentials file after the first request, empty removes it
+		rotated    string
+		wantKeys   []string
+		wantStatus int
+		wantErr    string
+	}{
+		{
+			name:       "key rotated",
+			rotated:    `{"api_key": "new-key", "api_username": "system"}`,
+			wantKeys:   []string{"old-key", "new-key"},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "key not rotated yet",
+			rotated:    `{"api_key": "old-key", "api_username": "system"}`,
+			wantKeys:   []string{"old-key", "old-key"},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:     "file removed",
+			wantKeys: []string{"old-key"},
+			wantErr:  "refresh credentials: read credentials file",
+		},
+		{
+			name:     "file invalid",
+			rotated:  `{"api_key": "new-key"}`,
+			wantKeys: []string{"old-key"},
+			wantErr:  "refresh credentials: api_key or api_username empty",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			credsFile := filepath.Join(t.TempDir(), "credentials.json")
+			var keys []string
+			useServer(t, func(w http.ResponseWriter, r *http.Request) {
+				keys = append(keys, r.Header.Get("Api-Key"))
+				if len(keys) == 1 {
+					// the key is rotated while the first request is on its way
+					if tt.rotated == "" {
+						os.Remove(credsFile)
+					} else if err := ioutil.WriteFile(credsFile, []byte(tt.rotated), 0600); err != nil {
+						t.Error(err)
+					}
+				}
+				if r.Header.Get("Api-Key") != "new-key" {
+					http.Error(w, `{"errors": ["invalid api key"]}`, http.StatusUnauthorized)
+					return
+				}
+				w.Write([]byte(`{}`))
+			})
+			if err := ioutil.WriteFile(credsFile, []byte(`{"api_key": "old-key", "api_username": "system"}`), 0600); err != nil {
+				t.Fatal(err)
+			}
+			setFlag(t, "discourse-credentials", "file:"+credsFile)
+			if err := CheckCredentials(); err != nil {
+				t.Fatalf("CheckCredentials: %s", err)
+			}
+
+			status, _, err := send(http.MethodGet, "/session/current.json", "", nil)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("send: error = %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil || status != tt.wantStatus {
+				t.Errorf("send = %d, %v, want %d", status, err, tt.wantStatus)
+			}
+			if strings.Join(keys, ", ") != strings.Join(tt.wantKeys, ", ") {
+				t.Errorf("requests sent with Api-Key %v, want %v", keys, tt.wantKeys)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package discourse
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/log"
+)
+
+// Identities a topic is posted as with --post-as.
+const (
+	// IdentityAuthor is the Discourse user with the GitHub login of the author.
+	IdentityAuthor = "author"
+	// IdentityStaged is a staged user created for the author if it has no Discourse user.
+	IdentityStaged = "staged"
+	// IdentitySystem is the system user, the topic starts with an attribution to the author.
+	IdentitySystem = "system"
+)
+
+const systemUser = "system"
+
+// attributionTpl starts the topics posted as the system user.
+const attributionTpl = "_Originally opened by [@%s](https://github.com/%s) on GitHub._\n\n"
+
+var postAs string
+
+func init() {
+	flag.StringVar(&postAs, "post-as", "", "--post-as=author,staged,system (post topics on behalf of the issue author, trying the identities in order until one is accepted: the Discourse user with the author's login, a staged user created for the author, the system user with an attribution line; empty posts as the API user)")
+}
+
+// ValidatePostAs checks the identities of --post-as.
+func ValidatePostAs() error {
+	for _, id := range strings.Split(postAs, ",") {
+		switch id {
+		case "", IdentityAuthor, IdentityStaged, IdentitySystem:
+		default:
+			return fmt.Errorf("not recognized --post-as identity %s", id)
+		}
+	}
+	return nil
+}
+
+// postTopicAsAuthor posts the topic as the first identity of the --post-as chain accepted by Discourse.
+// Identities rejected for the user (unknown, suspended, not allowed to post) are skipped, any other
+// error fails the topic.
+func postTopicAsAuthor(t Topic) (Created, error) {
+	var created Created
+	err := fmt.Errorf("no identity in --post-as")
+	for _, id := range strings.Split(postAs, ",") {
+		if id == "" {
+			continue
+		}
+		user, raw := t.Author, Raw(t)
+		switch id {
+		case IdentityStaged:
+			if err = ensureStagedUser(t.Author); err != nil {
+				log.Warnf("post %s as %s: %s", t.OriginURL, id, err)
+				continue
+			}
+		case IdentitySystem:
+			user = systemUser
+			raw = fmt.Sprintf(attributionTpl, t.Author, t.Author) + raw
+		}
+
+		created, err = postTopic(t, user, raw)
+		if err == nil {
+			created.Identity, created.PostedAs = id, user
+			return created, nil
+		}
+		if _, ok := err.(*CategoryError); ok || !identityRejected(created.Call.Status) {
+			return created, err
+		}
+		log.Warnf("post %s as %s %s: %s", t.OriginURL, id, user, err)
+	}
+	return created, err
+}
+
+// identityRejected reports whether the status of a request made on behalf of a user means the user
+// can't act, e.g. it doesn't exist.
+func identityRejected(status int) bool {
+	switch status {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound:
+		return true
+	}
+	return false
+}
+
+// ensureStagedUser creates a staged user with the login unless a user with it exists. Staged users
+// can't log in until they sign up with the same email, the email of GitHub users being unknown
+// a placeholder is used.
+func ensureStagedUser(login string) error {
+	status, body, err := send(http.MethodGet, "/u/"+url.PathEscape(login)+".json", "", nil)
+	if err != nil {
+		return err
+	}
+	if status == 200 {
+		return nil
+	}
+	if status != http.StatusNotFound {
+		return fmt.Errorf("api error for user %s: %d %s", login, status, body)
+	}
+
+	password := make([]byte, 16)
+	if _, err := rand.Read(password); err != nil {
+		return fmt.Errorf("generate password: %s", err)
+	}
+	message := map[string]interface{}{
+		"name":     login,
+		"username": login,
+		"email":    login + "@users.noreply.github.com",
+		"password": hex.EncodeToString(password),
+		"active":   true,
+		"staged":   true,
+	}
+	payload, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("could not marshal %s; reason: %s", message, err)
+	}
+	status, body, err = send(http.MethodPost, "/users.json", "application/json", payload)
+	if err != nil {
+		return err
+	}
+	var data struct {
+		Success bool   `json:"success"`
+		Message string `json:"message"`
+	}
+	if status != 200 || json.Unmarshal(body, &data) != nil || !data.Success {
+		return fmt.Errorf("api error for creating staged user %s: %d %s", login, status, body)
+	}
+	log.Printf("created staged user %s", login)
+	return nil
+}
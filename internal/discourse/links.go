@@ -0,0 +1,54 @@
+package discourse
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// TopicAlive reports whether the topic exists and isn't deleted.
+func TopicAlive(id int) (bool, error) {
+	status, body, err := send(http.MethodGet, fmt.Sprintf("/t/%d.json", id), "", nil)
+	if err != nil {
+		return false, err
+	}
+	switch status {
+	case http.StatusOK:
+	case http.StatusNotFound, http.StatusGone:
+		return false, nil
+	default:
+		return false, fmt.Errorf("api error for GET topic %d: %d %s", id, status, body)
+	}
+
+	var data struct {
+		DeletedAt *string `json:"deleted_at"`
+	}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return false, fmt.Errorf("could not unmarshal response body %s; reason: %s", body, err)
+	}
+	return data.DeletedAt == nil, nil
+}
+
+// FindTopic returns a live topic linking the original GitHub issue, if there is one.
+func FindTopic(issueURL string) (Created, bool, error) {
+	var data struct {
+		Topics []struct {
+			ID int `json:"id"`
+		} `json:"topics"`
+	}
+	if err := get("/search.json?q="+url.QueryEscape(`"`+issueURL+`"`), &data); err != nil {
+		return Created{}, false, fmt.Errorf("search topic of %s: %s", issueURL, err)
+	}
+
+	for _, t := range data.Topics {
+		alive, err := TopicAlive(t.ID)
+		if err != nil {
+			return Created{}, false, err
+		}
+		if alive {
+			return Created{URL: fmt.Sprintf("%s/t/%d", baseURL, t.ID), TopicID: t.ID}, true, nil
+		}
+	}
+	return Created{}, false, nil
+}
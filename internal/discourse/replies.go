@@ -0,0 +1,78 @@
+package discourse
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/lszucs/github-to-discourse/internal/trace"
+)
+
+// Reply is a post answering a topic.
+type Reply struct {
+	TopicID int
+	Content string
+	// CreatedAt dates the reply back in categories keeping the dates of the issues.
+	CreatedAt  time.Time
+	CategoryID int
+	// OriginURL is the URL of the issue the reply comes from.
+	OriginURL string
+}
+
+// PostReply posts the reply to its topic as the API user and returns the ID of the post. If the
+// instance rejects words of the reply, they are defanged or stripped as configured and the reply is
+// posted again once.
+func PostReply(r Reply) (int, trace.Call, error) {
+	id, call, err := postReply(r)
+	blocked, ok := err.(*BlockedWordsError)
+	if !ok || blockedWords == blockedQuarantine {
+		return id, call, err
+	}
+
+	log.Warnf("reply to topic %d: %s, %s them and post again", r.TopicID, blocked, blockedPolicy())
+	r.Content = rewriteBlocked(r.Content, blocked.Words)
+	return postReply(r)
+}
+
+func postReply(r Reply) (int, trace.Call, error) {
+	call := trace.Call{Method: http.MethodPost, URL: baseURL + "/posts.json"}
+
+	message := map[string]interface{}{
+		"topic_id": r.TopicID,
+		"raw":      r.Content,
+	}
+	if keepsDate(r.CategoryID) && !r.CreatedAt.IsZero() {
+		message["created_at"] = r.CreatedAt.UTC().Format(time.RFC3339)
+	}
+	if quiet {
+		message["auto_track"] = false
+	}
+
+	payload, err := json.Marshal(message)
+	if err != nil {
+		return 0, call, fmt.Errorf("could not marshal %s; reason: %s", message, err)
+	}
+
+	status, body, err := sendFor(r.OriginURL, "", http.MethodPost, "/posts.json", "application/json", payload)
+	if err != nil {
+		return 0, call, fmt.Errorf("error posting payload %s: %s", payload, err)
+	}
+	call.Status = status
+	if status != 200 {
+		if words := blockedWordsOf(status, body); len(words) > 0 {
+			return 0, call, &BlockedWordsError{Words: words}
+		}
+		return 0, call, fmt.Errorf("api error for payload %s; response body: %s", payload, body)
+	}
+
+	var post struct {
+		ID int `json:"id"`
+	}
+	if err := json.Unmarshal(body, &post); err != nil {
+		return 0, call, fmt.Errorf("could not unmarshal response body %s; reason: %s", body, err)
+	}
+	call.Resource = fmt.Sprintf("%s/p/%d", baseURL, post.ID)
+	return post.ID, call, nil
+}
@@ -0,0 +1,74 @@
+package discourse
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// TagGroup is a named group of tags of the instance.
+type TagGroup struct {
+	ID       int      `json:"id"`
+	Name     string   `json:"name"`
+	TagNames []string `json:"tag_names"`
+}
+
+// TagGroupByName returns the tag group, ok is false if the instance has none of the name.
+func TagGroupByName(name string) (TagGroup, bool, error) {
+	var data struct {
+		TagGroups []TagGroup `json:"tag_groups"`
+	}
+	if err := get("/tag_groups.json", &data); err != nil {
+		return TagGroup{}, false, fmt.Errorf("get tag groups: %s", err)
+	}
+	for _, g := range data.TagGroups {
+		if g.Name == name {
+			return g, true, nil
+		}
+	}
+	return TagGroup{}, false, nil
+}
+
+// EnsureTagGroup creates the tag group with the tags, or adds the tags it misses if it exists,
+// and returns the tags added. Discourse creates the tags which don't exist yet. It requires an
+// admin API user.
+func EnsureTagGroup(name string, tags []string) ([]string, error) {
+	g, ok, err := TagGroupByName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	has := map[string]bool{}
+	for _, t := range g.TagNames {
+		has[t] = true
+	}
+	var added []string
+	for _, t := range tags {
+		if !has[t] {
+			added = append(added, t)
+			has[t] = true
+		}
+	}
+	if ok && len(added) == 0 {
+		return nil, nil
+	}
+
+	method, path := http.MethodPost, "/tag_groups.json"
+	if ok {
+		method, path = http.MethodPut, fmt.Sprintf("/tag_groups/%d.json", g.ID)
+	}
+	payload, err := json.Marshal(map[string]interface{}{
+		"tag_group": map[string]interface{}{"name": name, "tag_names": append(g.TagNames, added...)},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("marshal tag group %s: %s", name, err)
+	}
+	status, body, err := send(method, path, "application/json", payload)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("api error for saving tag group %s: %d %s", name, status, body)
+	}
+	return added, nil
+}
@@ -0,0 +1,147 @@
+package discourse
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/bitrise-io/go-utils/log"
+
+	"github.com/lszucs/github-to-discourse/internal/run"
+)
+
+var (
+	defaultTags string
+
+	tagRulesMu sync.Mutex
+	tagRules   map[int]tagRule
+)
+
+func init() {
+	flag.StringVar(&defaultTags, "default-tags", "", "--default-tags=<tag,...> (tags added to topics without tags of their own in categories requiring tags, overridden by the default_tags category option)")
+}
+
+// tagRule is the tag requirement of a category.
+type tagRule struct {
+	Minimum int
+	// Groups lists the tag groups the topic needs a tag from.
+	Groups []string
+}
+
+func (r tagRule) required() bool {
+	return r.Minimum > 0 || len(r.Groups) > 0
+}
+
+func (r tagRule) String() string {
+	var s []string
+	if r.Minimum > 0 {
+		s = append(s, fmt.Sprintf("at least %d tags", r.Minimum))
+	}
+	for _, g := range r.Groups {
+		s = append(s, fmt.Sprintf("a tag of group %s", g))
+	}
+	return strings.Join(s, " and ")
+}
+
+// loadTagRules reads the tag requirements of the categories from the site API, once per run.
+func loadTagRules() (map[int]tagRule, error) {
+	tagRulesMu.Lock()
+	defer tagRulesMu.Unlock()
+	if tagRules != nil {
+		return tagRules, nil
+	}
+
+	var data struct {
+		Categories []struct {
+			ID                  int `json:"id"`
+			MinimumRequiredTags int `json:"minimum_required_tags"`
+			RequiredTagGroups   []struct {
+				Name     string `json:"name"`
+				MinCount int    `json:"min_count"`
+			} `json:"required_tag_groups"`
+			// set by instances before required_tag_groups was introduced
+			RequiredTagGroupName string `json:"required_tag_group_name"`
+		} `json:"categories"`
+	}
+	if err := get("/site.json", &data); err != nil {
+		return nil, fmt.Errorf("get site: %s", err)
+	}
+
+	rules := map[int]tagRule{}
+	for _, c := range data.Categories {
+		r := tagRule{Minimum: c.MinimumRequiredTags}
+		for _, g := range c.RequiredTagGroups {
+			r.Groups = append(r.Groups, g.Name)
+		}
+		if len(r.Groups) == 0 && c.RequiredTagGroupName != "" {
+			r.Groups = []string{c.RequiredTagGroupName}
+		}
+		rules[c.ID] = r
+	}
+	tagRules = rules
+	return rules, nil
+}
+
+// DefaultTags returns the tags added to the topics of the category if it requires tags.
+func DefaultTags(categoryID int) []string {
+	if tags := Options(categoryID).DefaultTags; len(tags) > 0 {
+		return tags
+	}
+	if defaultTags == "" {
+		return nil
+	}
+	return strings.Split(defaultTags, ",")
+}
+
+// CheckTagRequirements warns about the categories requiring tags and tells the default tags their
+// topics get. Categories without default tags will reject the topics.
+func CheckTagRequirements(ids []int) error {
+	rules, err := loadTagRules()
+	if err != nil {
+		return err
+	}
+
+	seen := map[int]bool{}
+	for _, id := range ids {
+		if id == 0 || seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		r := rules[id]
+		if !r.required() {
+			continue
+		}
+		if tags := DefaultTags(id); len(tags) > 0 {
+			log.Printf("category %d requires %s, topics get the tags %s", id, r, strings.Join(tags, ", "))
+			continue
+		}
+		log.Warnf("category %d requires %s, its topics will be rejected: set --default-tags or the default_tags option of the category", id, r)
+	}
+	return nil
+}
+
+// withDefaultTags adds the default tags of the category to the tags of a topic which has no tags of
+// its own, if the category requires tags. Tags of the run don't count as own tags.
+func withDefaultTags(categoryID int, tags []string) []string {
+	rules, err := loadTagRules()
+	if err != nil {
+		log.Warnf("check tag requirements of category %d: %s", categoryID, err)
+		return tags
+	}
+	if !rules[categoryID].required() {
+		return tags
+	}
+
+	own := 0
+	for _, t := range tags {
+		if !strings.HasPrefix(t, run.TagPrefix) && t != NeedsRecategorizationTag {
+			own++
+		}
+	}
+	if own > 0 {
+		return tags
+	}
+	return append(tags, DefaultTags(categoryID)...)
+}
@@ -0,0 +1,59 @@
+package discourse
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+var (
+	maxIdleConns    int
+	idleConnTimeout time.Duration
+	requestTimeout  time.Duration
+)
+
+func init() {
+	flag.IntVar(&maxIdleConns, "max-idle-conns", 64, "--max-idle-conns=<int> (idle keep-alive connections kept open to Discourse for reuse by the next requests, shared by every worker)")
+	flag.DurationVar(&idleConnTimeout, "idle-conn-timeout", 90*time.Second, "--idle-conn-timeout=<duration> (time an idle connection to Discourse is kept open)")
+	flag.DurationVar(&requestTimeout, "discourse-timeout", time.Minute, "--discourse-timeout=<duration> (time limit of a Discourse request including reading the response, 0: none)")
+}
+
+// pool is the transport of every Discourse request. Every request goes to the same host, so its
+// idle connections are kept for that host instead of the two of http.DefaultTransport, and
+// concurrent workers reuse them instead of opening a connection per request.
+var pool = &http.Transport{
+	Proxy: http.ProxyFromEnvironment,
+	DialContext: (&net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}).DialContext,
+	ForceAttemptHTTP2:     true,
+	MaxIdleConns:          64,
+	MaxIdleConnsPerHost:   64,
+	IdleConnTimeout:       90 * time.Second,
+	TLSHandshakeTimeout:   10 * time.Second,
+	ExpectContinueTimeout: time.Second,
+}
+
+// ConfigureTransport applies --max-idle-conns, --idle-conn-timeout and --discourse-timeout to the
+// Discourse client, before the first request.
+func ConfigureTransport() error {
+	if maxIdleConns < 0 {
+		return fmt.Errorf("invalid --max-idle-conns %d: negative", maxIdleConns)
+	}
+	if idleConnTimeout < 0 {
+		return fmt.Errorf("invalid --idle-conn-timeout %s: negative", idleConnTimeout)
+	}
+	if requestTimeout < 0 {
+		return fmt.Errorf("invalid --discourse-timeout %s: negative", requestTimeout)
+	}
+
+	pool.MaxIdleConns = maxIdleConns
+	pool.MaxIdleConnsPerHost = maxIdleConns
+	pool.IdleConnTimeout = idleConnTimeout
+	// per attempt, the client's timeout would cover the waits between retries as well
+	retry.Timeout = requestTimeout
+	return nil
+}
@@ -0,0 +1,51 @@
+package discourse
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// FirstPostCooked returns the rendered HTML of the first post of the topic.
+func FirstPostCooked(id int) (string, error) {
+	var data struct {
+		PostStream struct {
+			Posts []struct {
+				Cooked string `json:"cooked"`
+			} `json:"posts"`
+		} `json:"post_stream"`
+	}
+	if err := get(fmt.Sprintf("/t/%d.json", id), &data); err != nil {
+		return "", err
+	}
+	if len(data.PostStream.Posts) == 0 {
+		return "", fmt.Errorf("topic %d has no posts", id)
+	}
+	return data.PostStream.Posts[0].Cooked, nil
+}
+
+var (
+	fenceLine = regexp.MustCompile("(?m)^\\s*(```|~~~)")
+	preTag    = regexp.MustCompile(`<pre[\s>]`)
+)
+
+// VerifyCooked returns the problems of the rendered HTML of a post compared to its markdown:
+// empty content, code fence markers left unparsed, or fewer code blocks than fenced in raw.
+func VerifyCooked(raw, cooked string) []string {
+	var problems []string
+	if strings.TrimSpace(cooked) == "" {
+		return []string{"rendered post is empty"}
+	}
+	if strings.Contains(cooked, "```") || strings.Contains(cooked, "~~~") {
+		problems = append(problems, "rendered post contains unparsed code fence markers")
+	}
+
+	fences := len(fenceLine.FindAllString(raw, -1))
+	if fences%2 != 0 {
+		problems = append(problems, "markdown has an unclosed code fence")
+	}
+	if blocks := len(preTag.FindAllString(cooked, -1)); blocks < fences/2 {
+		problems = append(problems, fmt.Sprintf("rendered post has %d code blocks, markdown has %d", blocks, fences/2))
+	}
+	return problems
+}
@@ -0,0 +1,24 @@
+package discourse
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// notificationWatching is the notification level of users watching a topic.
+const notificationWatching = "3"
+
+// WatchTopic makes the user watch the topic, acting as the user: the API key has to be valid
+// for all users.
+func WatchTopic(id int, username string) error {
+	form := url.Values{"notification_level": []string{notificationWatching}}.Encode()
+	status, body, err := sendAs(username, http.MethodPost, fmt.Sprintf("/t/%d/notifications", id), "application/x-www-form-urlencoded", []byte(form))
+	if err != nil {
+		return err
+	}
+	if status != 200 {
+		return fmt.Errorf("api error for watching topic %d as %s: %d %s", id, username, status, body)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package discourse
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// Policies of the blocked_words config.
+const (
+	blockedDefang     = "defang"
+	blockedStrip      = "strip"
+	blockedQuarantine = "quarantine"
+)
+
+// strippedWord replaces the stripped blocked words.
+const strippedWord = "[removed]"
+
+var (
+	blockedWords string
+
+	// blockedWordRes match the errors of posts rejected for watched words of the block action,
+	// capturing the words.
+	blockedWordRes = []*regexp.Regexp{
+		regexp.MustCompile(`(?i)contains (?:a word|multiple words) that.{0,12}allowed: (.+)$`),
+		regexp.MustCompile(`(?i)can't post the word '([^']+)'`),
+	}
+)
+
+// BlockedWordsError is returned by PostTopic if the instance rejected the topic for words on its
+// blocked list (watched words).
+type BlockedWordsError struct {
+	Words []string
+}
+
+func (e *BlockedWordsError) Error() string {
+	return fmt.Sprintf("words blocked by the instance: %s", strings.Join(e.Words, ", "))
+}
+
+// blockedWordsOf returns the blocked words of a failed post response, nil if it failed otherwise.
+func blockedWordsOf(status int, body []byte) []string {
+	if status != http.StatusUnprocessableEntity {
+		return nil
+	}
+	var data struct {
+		Errors []string `json:"errors"`
+	}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil
+	}
+
+	var words []string
+	for _, e := range data.Errors {
+		for _, re := range blockedWordRes {
+			m := re.FindStringSubmatch(strings.TrimSpace(e))
+			if m == nil {
+				continue
+			}
+			for _, w := range strings.Split(strings.TrimSuffix(m[1], "."), ",") {
+				if w = strings.TrimSpace(w); w != "" {
+					words = append(words, w)
+				}
+			}
+			break
+		}
+	}
+	return words
+}
+
+func blockedPolicy() string {
+	if blockedWords == "" {
+		return blockedDefang
+	}
+	return blockedWords
+}
+
+// rewriteBlocked defangs or strips every occurrence of the words in text, ignoring case.
+func rewriteBlocked(text string, words []string) string {
+	for _, w := range words {
+		re := regexp.MustCompile(`(?i)` + regexp.QuoteMeta(w))
+		text = re.ReplaceAllStringFunc(text, func(m string) string {
+			if blockedPolicy() == blockedStrip {
+				return strippedWord
+			}
+			return defang(m)
+		})
+	}
+	return text
+}
+
+// defang makes a word unrecognizable for the watched words while keeping it readable: the dots of
+// domains and URLs are bracketed and their scheme mangled, other words keep their first and last letter.
+func defang(w string) string {
+	if strings.Contains(w, ".") {
+		w = strings.Replace(w, "http", "hxxp", 1)
+		return strings.Replace(w, ".", "[.]", -1)
+	}
+	r := []rune(w)
+	if len(r) <= 2 {
+		return strings.Repeat("*", len(r))
+	}
+	return string(r[0]) + strings.Repeat("*", len(r)-2) + string(r[len(r)-1])
+}
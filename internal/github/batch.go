@@ -0,0 +1,36 @@
+package github
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/go-github/github"
+	"github.com/lszucs/github-to-discourse/internal/debugbundle"
+	"github.com/lszucs/github-to-discourse/internal/trace"
+)
+
+// CloseAndLock closes the issue with the state reason, if not empty, and locks its conversation in a
+// single GraphQL request and returns the call made. The mutations run in order: if locking fails the
+// issue may be closed already.
+func CloseAndLock(i *github.Issue, reason string) (trace.Call, error) {
+	call := trace.Call{Method: http.MethodPost, URL: graphqlURL, Resource: i.GetNodeID()}
+	if i.GetNodeID() == "" {
+		return call, fmt.Errorf("close and lock %s: node ID unknown", i.GetHTMLURL())
+	}
+
+	variables := map[string]interface{}{"id": i.GetNodeID(), "reason": nil}
+	if reason != "" {
+		// the GraphQL enum values are the upper case REST values: COMPLETED, NOT_PLANNED
+		variables["reason"] = strings.ToUpper(reason)
+	}
+	status, err := graphqlStatus(debugbundle.WithIssue(ctx, i.GetHTMLURL()), `mutation($id: ID!, $reason: IssueClosedStateReason) {
+  closeIssue(input: {issueId: $id, stateReason: $reason}) { issue { state } }
+  lockLockable(input: {lockableId: $id}) { lockedRecord { locked } }
+}`, variables, nil)
+	call.Status = status
+	if err != nil {
+		return call, fmt.Errorf("close and lock %s: %s", i.GetHTMLURL(), err)
+	}
+	return call, nil
+}
@@ -0,0 +1,36 @@
+package github
+
+import (
+	"github.com/google/go-github/github"
+)
+
+// DeleteComment deletes a comment of the repo of the issue.
+func DeleteComment(ref IssueRef, commentID int64) error {
+	return service.DeleteComment(ref, commentID)
+}
+
+// Reopen reopens the issue.
+func Reopen(ref IssueRef) error {
+	return service.Reopen(ref)
+}
+
+// Restore sets the state, the labels and the milestone of the issue back to the ones of its
+// snapshot.
+func Restore(ref IssueRef, snap *github.Issue) error {
+	return service.Restore(ref, snap)
+}
+
+// Unlock unlocks the issue.
+func Unlock(ref IssueRef) error {
+	return service.Unlock(ref)
+}
+
+// EditComment replaces the body of a comment of the repo of the issue, ending it with the Marker.
+func EditComment(ref IssueRef, commentID int64, body string) error {
+	return service.EditComment(ref, commentID, withMarker(body))
+}
+
+// CreateIssue opens an issue in the repo.
+func CreateIssue(repo Repo, title, body string) (*github.Issue, error) {
+	return service.CreateIssue(repo, title, body)
+}
@@ -0,0 +1,75 @@
+package github
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+// staleMonths is the inactivity after which an issue is stale.
+const staleMonths = 3
+
+// Clock tells the current time.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// FixedClock is a Clock stopped at the given time.
+type FixedClock time.Time
+
+// Now returns the time of the clock.
+func (c FixedClock) Now() time.Time {
+	return time.Time(c)
+}
+
+var (
+	asOf  string
+	clock Clock = systemClock{}
+)
+
+func init() {
+	flag.StringVar(&asOf, "as-of", "", "--as-of=<RFC 3339 time> (reference time staleness is evaluated against instead of now, e.g. to reproduce the decisions of an earlier run)")
+}
+
+// ValidateAsOf parses --as-of and stops the clock at it.
+func ValidateAsOf() error {
+	if asOf == "" {
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339, asOf)
+	if err != nil {
+		return fmt.Errorf("invalid --as-of %s, expected an RFC 3339 time like 2024-01-01T00:00:00Z: %s", asOf, err)
+	}
+	SetClock(FixedClock(t))
+	return nil
+}
+
+// SetClock replaces the clock staleness is evaluated with.
+func SetClock(c Clock) {
+	clock = c
+}
+
+// Now returns the reference time of the run in UTC: --as-of if set, the current time otherwise.
+func Now() time.Time {
+	return clock.Now().UTC()
+}
+
+// IsStale reports whether the issue wasn't updated in the last three months before Now.
+func IsStale(i *github.Issue) bool {
+	return IsStaleAt(i, Now())
+}
+
+// IsStaleAt reports whether the issue wasn't updated in the three months before the given time.
+// Both times are compared in UTC, so the result doesn't depend on the local timezone or DST.
+func IsStaleAt(i *github.Issue, now time.Time) bool {
+	cutoff := now.UTC().AddDate(0, -staleMonths, 0)
+	return i.GetUpdatedAt().UTC().Before(cutoff)
+}
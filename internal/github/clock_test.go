@@ -0,0 +1,102 @@
+package github
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+func issueUpdatedAt(t time.Time) *github.Issue {
+	return &github.Issue{UpdatedAt: &t}
+}
+
+func mustParse(t *testing.T, value string) time.Time {
+	t.Helper()
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed
+}
+
+func TestIsStaleAt(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("no timezone database: %s", err)
+	}
+
+	tests := []struct {
+		name    string
+		updated string
+		asOf    string
+		want    bool
+	}{
+		{name: "updated recently", updated: "2024-05-01T00:00:00Z", asOf: "2024-06-10T12:00:00Z", want: false},
+		{name: "updated long ago", updated: "2023-01-01T00:00:00Z", asOf: "2024-06-10T12:00:00Z", want: true},
+		{name: "exactly three months", updated: "2024-03-10T12:00:00Z", asOf: "2024-06-10T12:00:00Z", want: false},
+		{name: "a second over three months", updated: "2024-03-10T11:59:59Z", asOf: "2024-06-10T12:00:00Z", want: true},
+		// the US switched to DST on 2024-03-10, the offsets of the two times differ
+		{name: "offset before the cutoff", updated: "2024-03-10T06:59:59-05:00", asOf: "2024-06-10T08:00:00-04:00", want: true},
+		{name: "offset after the cutoff", updated: "2024-03-10T07:00:00-05:00", asOf: "2024-06-10T08:00:00-04:00", want: false},
+		{name: "month end", updated: "2024-03-01T23:59:59Z", asOf: "2024-05-31T00:00:00Z", want: true},
+	}
+
+	for _, tt := range tests {
+		i := issueUpdatedAt(mustParse(t, tt.updated))
+		asOf := mustParse(t, tt.asOf)
+		if got := IsStaleAt(i, asOf); got != tt.want {
+			t.Errorf("%s: IsStaleAt(%s, %s) = %t, want %t", tt.name, tt.updated, tt.asOf, got, tt.want)
+		}
+		// the location of the times doesn't matter
+		if got := IsStaleAt(issueUpdatedAt(i.GetUpdatedAt().In(newYork)), asOf.In(time.Local)); got != tt.want {
+			t.Errorf("%s: IsStaleAt in other locations = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestIsStaleUsesClock(t *testing.T) {
+	defer SetClock(clock)
+
+	i := issueUpdatedAt(mustParse(t, "2024-01-15T00:00:00Z"))
+
+	SetClock(FixedClock(mustParse(t, "2024-03-01T00:00:00Z")))
+	if IsStale(i) {
+		t.Errorf("IsStale = true six weeks after the update, want false")
+	}
+
+	SetClock(FixedClock(mustParse(t, "2024-05-01T00:00:00Z")))
+	if !IsStale(i) {
+		t.Errorf("IsStale = false three and a half months after the update, want true")
+	}
+}
+
+func TestNowIsUTC(t *testing.T) {
+	defer SetClock(clock)
+
+	budapest := time.FixedZone("CET", 3600)
+	SetClock(FixedClock(time.Date(2024, 1, 1, 0, 30, 0, 0, budapest)))
+	if got, want := Now(), time.Date(2023, 12, 31, 23, 30, 0, 0, time.UTC); !got.Equal(want) || got.Location() != time.UTC {
+		t.Errorf("Now() = %s, want %s", got, want)
+	}
+}
+
+func TestValidateAsOf(t *testing.T) {
+	defer SetClock(clock)
+	defer func(v string) { asOf = v }(asOf)
+
+	asOf = "2024-06-10T08:00:00-04:00"
+	if err := ValidateAsOf(); err != nil {
+		t.Fatalf("ValidateAsOf() error = %s", err)
+	}
+	if got, want := Now(), time.Date(2024, 6, 10, 12, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("Now() = %s, want %s", got, want)
+	}
+
+	for _, invalid := range []string{"2024-06-10", "yesterday", "2024-06-10 12:00:00"} {
+		asOf = invalid
+		if err := ValidateAsOf(); err == nil {
+			t.Errorf("ValidateAsOf() with --as-of=%s succeeded, want error", invalid)
+		}
+	}
+}
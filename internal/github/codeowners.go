@@ -0,0 +1,81 @@
+package github
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/google/go-github/github"
+)
+
+// codeOwnersPaths are the locations GitHub reads CODEOWNERS from, in order.
+var codeOwnersPaths = []string{".github/CODEOWNERS", "CODEOWNERS", "docs/CODEOWNERS"}
+
+var (
+	ownersMu sync.Mutex
+	owners   = map[string][]string{}
+)
+
+// CodeOwners returns the default owners of the repo, the owners of every file in its CODEOWNERS
+// file: user logins and org/team names, without @. Repos without CODEOWNERS have none.
+// Owners are cached for the run.
+func CodeOwners(repo Repo) ([]string, error) {
+	ownersMu.Lock()
+	cached, ok := owners[repo.FullName()]
+	ownersMu.Unlock()
+	if ok {
+		return cached, nil
+	}
+
+	var found []string
+	for _, p := range codeOwnersPaths {
+		fc, _, resp, err := client.Repositories.GetContents(ctx, repo.Owner, repo.Name, p, &github.RepositoryContentGetOptions{})
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("get %s of %s: %s", p, repo.FullName(), err)
+		}
+		if fc == nil {
+			continue
+		}
+		content, err := fc.GetContent()
+		if err != nil {
+			return nil, fmt.Errorf("decode %s of %s: %s", p, repo.FullName(), err)
+		}
+		found = defaultOwners(content)
+		break
+	}
+
+	ownersMu.Lock()
+	owners[repo.FullName()] = found
+	ownersMu.Unlock()
+	return found, nil
+}
+
+// defaultOwners returns the owners of the last rule of a CODEOWNERS file matching every file.
+func defaultOwners(content string) []string {
+	var found []string
+	for _, line := range strings.Split(content, "\n") {
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "*", "/", "/*", "/**", "**":
+		default:
+			continue
+		}
+		found = nil
+		for _, o := range fields[1:] {
+			if strings.HasPrefix(o, "@") {
+				found = append(found, strings.TrimPrefix(o, "@"))
+			}
+		}
+	}
+	return found
+}
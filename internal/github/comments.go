@@ -0,0 +1,14 @@
+package github
+
+import (
+	"github.com/google/go-github/github"
+)
+
+// GetComments fetches every comment of the issue, oldest first.
+func GetComments(i *github.Issue) ([]*github.IssueComment, error) {
+	ref, err := ParseIssueURL(i.GetHTMLURL())
+	if err != nil {
+		return nil, err
+	}
+	return service.Comments(ref)
+}
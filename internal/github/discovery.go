@@ -0,0 +1,69 @@
+package github
+
+import (
+	"fmt"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/google/go-github/github"
+)
+
+// discoverable filters out repos whose issues can't be migrated.
+func discoverable(repos []*github.Repository) []string {
+	var urls []string
+	for _, r := range repos {
+		if r.GetArchived() {
+			log.Printf("skip %s: archived", r.GetHTMLURL())
+			continue
+		}
+		if !r.GetHasIssues() {
+			log.Printf("skip %s: issues disabled", r.GetHTMLURL())
+			continue
+		}
+		urls = append(urls, r.GetHTMLURL())
+	}
+	return urls
+}
+
+// ReposByTopic returns the repos tagged with the GitHub topic. The search API returns at most
+// 1000 repos.
+func ReposByTopic(topic string) ([]string, error) {
+	var all []*github.Repository
+	opts := github.SearchOptions{Sort: "updated", ListOptions: github.ListOptions{PerPage: perPage}}
+	for {
+		result, resp, err := client.Search.Repositories(ctx, "topic:"+topic, &opts)
+		if err != nil {
+			return nil, fmt.Errorf("search repos by topic %s: %s", topic, err)
+		}
+		for n := range result.Repositories {
+			all = append(all, &result.Repositories[n])
+		}
+		if result.GetIncompleteResults() {
+			log.Warnf("search repos by topic %s: incomplete results", topic)
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+	return discoverable(all), nil
+}
+
+// StarredRepos returns the repos starred by the user.
+func StarredRepos(user string) ([]string, error) {
+	var all []*github.Repository
+	opts := github.ActivityListStarredOptions{ListOptions: github.ListOptions{PerPage: perPage}}
+	for {
+		starred, resp, err := client.Activity.ListStarred(ctx, user, &opts)
+		if err != nil {
+			return nil, fmt.Errorf("list repos starred by %s: %s", user, err)
+		}
+		for _, s := range starred {
+			all = append(all, s.Repository)
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+	return discoverable(all), nil
+}
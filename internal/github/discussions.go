@@ -0,0 +1,158 @@
+package github
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/google/go-github/github"
+)
+
+const graphqlURL = "https://api.github.com/graphql"
+
+// ConvertedError is returned by mutations of an issue which has been converted to a discussion.
+type ConvertedError struct {
+	IssueURL string
+	// DiscussionURL is empty if GitHub didn't tell where the issue moved.
+	DiscussionURL string
+}
+
+func (e *ConvertedError) Error() string {
+	if e.DiscussionURL == "" {
+		return fmt.Sprintf("%s has been converted to a discussion", e.IssueURL)
+	}
+	return fmt.Sprintf("%s has been converted to discussion %s", e.IssueURL, e.DiscussionURL)
+}
+
+func isDiscussionURL(u string) bool {
+	return strings.Contains(u, "/discussions/")
+}
+
+// stopAtDiscussions keeps the client from following redirects to discussions: a redirected
+// PATCH or POST would be retried as GET and look like a successful mutation.
+func stopAtDiscussions(req *http.Request, via []*http.Request) error {
+	if isDiscussionURL(req.URL.String()) {
+		return http.ErrUseLastResponse
+	}
+	if len(via) >= 10 {
+		return fmt.Errorf("stopped after 10 redirects")
+	}
+	return nil
+}
+
+// checkConverted returns a *ConvertedError if the response tells the issue has been converted to a discussion.
+func checkConverted(i *github.Issue, resp *http.Response) error {
+	location := resp.Header.Get("Location")
+	switch {
+	case resp.StatusCode >= 300 && resp.StatusCode < 400 && isDiscussionURL(location):
+		return &ConvertedError{IssueURL: i.GetHTMLURL(), DiscussionURL: toHTMLURL(location)}
+	case resp.StatusCode == http.StatusGone:
+		return &ConvertedError{IssueURL: i.GetHTMLURL()}
+	}
+	return nil
+}
+
+// toHTMLURL turns an API discussion URL into its web URL.
+func toHTMLURL(u string) string {
+	u = strings.Replace(u, "https://api.github.com/repos/", "https://github.com/", 1)
+	return u
+}
+
+func graphql(query string, variables map[string]interface{}, v interface{}) error {
+	_, err := graphqlStatus(ctx, query, variables, v)
+	return err
+}
+
+// graphqlStatus sends the GraphQL request with the context and decodes its data into v, if not nil.
+// It returns the HTTP status of the response too, 0 if there was none.
+func graphqlStatus(ctx context.Context, query string, variables map[string]interface{}, v interface{}) (int, error) {
+	payload, err := json.Marshal(map[string]interface{}{
+		"query":     query,
+		"variables": variables,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("marshal graphql request: %s", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, graphqlURL, bytes.NewBuffer(payload))
+	if err != nil {
+		return 0, fmt.Errorf("create graphql request: %s", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := tc.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("send graphql request: %s", err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			log.Warnf("warning: close response body: %s", err)
+		}
+	}()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, fmt.Errorf("read response body: %s", err)
+	}
+	if resp.StatusCode != 200 {
+		return resp.StatusCode, fmt.Errorf("api error: graphql %s: %s %s", payload, resp.Status, body)
+	}
+
+	var data struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return resp.StatusCode, fmt.Errorf("unmarshal graphql response %s: %s", body, err)
+	}
+	if len(data.Errors) > 0 {
+		return resp.StatusCode, fmt.Errorf("graphql error: %s", data.Errors[0].Message)
+	}
+	if v == nil {
+		return resp.StatusCode, nil
+	}
+	return resp.StatusCode, json.Unmarshal(data.Data, v)
+}
+
+// CommentDiscussion posts a comment to the discussion at discussionURL (https://github.com/<owner>/<repo>/discussions/<number>).
+func CommentDiscussion(discussionURL, comment string) error {
+	_, segments, err := splitURL(discussionURL)
+	if err != nil {
+		return err
+	}
+	if len(segments) < 4 || segments[2] != "discussions" {
+		return fmt.Errorf("not a discussion URL: %s", discussionURL)
+	}
+	number, err := strconv.Atoi(segments[3])
+	if err != nil {
+		return fmt.Errorf("not a discussion URL: %s", discussionURL)
+	}
+	owner, name := segments[0], segments[1]
+
+	var found struct {
+		Repository struct {
+			Discussion struct {
+				ID string `json:"id"`
+			} `json:"discussion"`
+		} `json:"repository"`
+	}
+	if err := graphql(`query($owner: String!, $name: String!, $number: Int!) {
+  repository(owner: $owner, name: $name) { discussion(number: $number) { id } }
+}`, map[string]interface{}{"owner": owner, "name": name, "number": number}, &found); err != nil {
+		return fmt.Errorf("find discussion %s: %s", discussionURL, err)
+	}
+
+	if err := graphql(`mutation($id: ID!, $body: String!) {
+  addDiscussionComment(input: {discussionId: $id, body: $body}) { comment { id } }
+}`, map[string]interface{}{"id": found.Repository.Discussion.ID, "body": comment}, nil); err != nil {
+		return fmt.Errorf("comment discussion %s: %s", discussionURL, err)
+	}
+	return nil
+}
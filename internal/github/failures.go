@@ -0,0 +1,33 @@
+package github
+
+import (
+	"sync"
+
+	"github.com/bitrise-io/go-utils/log"
+)
+
+// RepoFailure is a repo whose issues couldn't be fetched.
+type RepoFailure struct {
+	Repo  string `json:"repo"`
+	Error string `json:"error"`
+}
+
+var (
+	failuresMu sync.Mutex
+	failures   []RepoFailure
+)
+
+func failRepo(repo Repo, err error) {
+	log.Errorf("skip %s: %s", repo.FullName(), err)
+
+	failuresMu.Lock()
+	defer failuresMu.Unlock()
+	failures = append(failures, RepoFailure{Repo: repo.FullName(), Error: err.Error()})
+}
+
+// RepoFailures returns the repos whose issues couldn't be fetched, in the order they failed.
+func RepoFailures() []RepoFailure {
+	failuresMu.Lock()
+	defer failuresMu.Unlock()
+	return append([]RepoFailure(nil), failures...)
+}
@@ -1,179 +1,243 @@
 package github
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
+	"flag"
 	"fmt"
-	"io/ioutil"
 	"net/http"
 	"os"
-	"strings"
+	"sort"
 	"time"
 
 	"github.com/bitrise-io/go-utils/log"
 	"github.com/google/go-github/github"
+	"github.com/lszucs/github-to-discourse/internal/budget"
+	"github.com/lszucs/github-to-discourse/internal/chaos"
+	"github.com/lszucs/github-to-discourse/internal/debugbundle"
+	"github.com/lszucs/github-to-discourse/internal/onerror"
+	"github.com/lszucs/github-to-discourse/internal/ratelimit"
+	"github.com/lszucs/github-to-discourse/internal/trace"
 	"golang.org/x/oauth2"
 )
 
 var (
-	client *github.Client
-	ctx    context.Context
-	tc     *http.Client
+	streamBuffer int
+	repoTimeout  time.Duration
+
+	// service performs the API calls of the package level functions, the client and tc are its
+	// go-github client and HTTP client for the calls not covered by Service.
+	service *Service
+	client  *github.Client
+	ctx     context.Context
+	tc      *http.Client
+	token   = os.Getenv("GITHUB_ACCESS_TOKEN")
 )
 
 func init() {
-	ctx = context.Background()
-	ts := oauth2.StaticTokenSource(
-		&oauth2.Token{AccessToken: os.Getenv("GITHUB_ACCESS_TOKEN")},
-	)
-	tc = oauth2.NewClient(ctx, ts)
-	client = github.NewClient(tc)
-}
+	flag.IntVar(&streamBuffer, "stream-buffer", 100, "--stream-buffer=<int> (number of fetched issues buffered ahead of processing, bounds the memory used for large orgs)")
+	flag.DurationVar(&repoTimeout, "repo-timeout", 5*time.Minute, "--repo-timeout=<duration> (time to fetch the issues of a repo, the repo is recorded as failed after it)")
 
-func GetHTMLURLs(issues []*github.Issue) []string {
-	var urls []string
-	for _, iss := range issues {
-		urls = append(urls, iss.GetHTMLURL())
+	// unauthenticated clients can still read public repos, with lower rate limits
+	var err error
+	service, err = NewService(token, "", &debugbundle.Transport{Base: &ratelimit.Retry{Service: budget.GitHub, Base: &chaos.Transport{Base: &budget.Transport{Service: budget.GitHub, Base: &ratelimit.Transport{Service: budget.GitHub}}}}})
+	if err != nil {
+		panic(err)
 	}
-	return urls
+	client, ctx, tc = service.client, service.ctx, service.http
 }
 
-func GetOpenIssues(repoURLs []string) []*github.Issue {
-	var all []*github.Issue
-	opts := github.IssueListByRepoOptions{
-		State: "open",
-	}
-	for _, url := range repoURLs {
-		fragments := strings.Split(string(url), "/")
-		owner := fragments[len(fragments)-2]
-		name := strings.TrimSuffix(fragments[len(fragments)-1], ".git")
-		
-		issues, resp, err := client.Issues.ListByRepo(ctx, owner, name, &opts)
-		if err != nil {
-			log.Warnf("fetch issues from %s: %s", url, err)
-			continue
-		}
+// Authenticated reports whether requests are sent with a GitHub access token.
+func Authenticated() bool {
+	return token != ""
+}
 
-		if resp.Response.StatusCode != 200 {
-			log.Warnf("fetch issues from %s: %s", url, resp.Response.Status)
-			continue
-		}
+// ValidateToken returns the login of the user the token belongs to.
+func ValidateToken(token string) (string, error) {
+	ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token})
+	user, _, err := github.NewClient(oauth2.NewClient(ctx, ts)).Users.Get(ctx, "")
+	if err != nil {
+		return "", fmt.Errorf("get authenticated user: %s", err)
+	}
+	return user.GetLogin(), nil
+}
 
-		all = append(all, issues...)
+// RateLimit returns the core API rate limit of the current client.
+func RateLimit() (*github.Rate, error) {
+	limits, _, err := client.RateLimits(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("fetch rate limits: %s", err)
+	}
+	return limits.GetCore(), nil
+}
 
+func GetHTMLURLs(issues []*github.Issue) []string {
+	var urls []string
+	for _, iss := range issues {
+		urls = append(urls, iss.GetHTMLURL())
 	}
-	return all
+	return urls
 }
 
-func IsStale(i *github.Issue) bool {
-	threeMonthsAgo := time.Now().AddDate(0, -3, 0)
-	return i.GetUpdatedAt().Before(threeMonthsAgo)
+func GetOpenIssues(repoURLs []string) ([]*github.Issue, error) {
+	return GetOpenIssuesSince(repoURLs, nil)
 }
 
-func PostComment(i *github.Issue, comment string) error {
-	payload := map[string]interface{}{
-		"body": comment,
-	}
+// GetOpenIssuesSince fetches the open issues of the repos updated since the time given for the repo
+// in since, keyed by repo full name, or since --since if later. Repos missing from since are fetched
+// entirely, unless --since is set.
+func GetOpenIssuesSince(repoURLs []string, since map[string]time.Time) ([]*github.Issue, error) {
+	stop := make(chan struct{})
+	defer close(stop)
 
-	data, err := json.Marshal(payload)
-	if err != nil {
-		return fmt.Errorf("marshal %s: %s", payload, err)
+	var all []*github.Issue
+	issues, errs := StreamOpenIssues(repoURLs, since, stop)
+	for i := range issues {
+		all = append(all, i)
 	}
-
-	req, err := http.NewRequest(http.MethodPost, i.GetCommentsURL(), bytes.NewBuffer(data))
-	if err != nil {
-		return fmt.Errorf("create POST %s request with request body %s: %s", i.GetCommentsURL(), string(data), err)
+	if err := <-errs; err != nil {
+		return nil, err
 	}
+	return all, nil
+}
 
-	resp, err := tc.Do(req)
-	if err != nil {
-		return fmt.Errorf("send POST %s request with request body %s: %s", i.GetCommentsURL(), string(data), err)
-	}
-	defer func() {
-		if err := resp.Body.Close(); err != nil {
-			log.Warnf("warning: close response body: %s", err)
+// StreamOpenIssues fetches the open issues of the repos like GetOpenIssuesSince, but in the background:
+// the issues are sent on the returned channel as they are fetched, holding at most --stream-buffer
+// issues, ordered by repo full name then issue number. The error aborting the fetch, if any, is sent
+// on the error channel. Repos whose issues can't be fetched are recorded as failed, see RepoFailures, and
+// the fetch goes on. Both channels are closed when the fetch is done or stop is closed.
+func StreamOpenIssues(repoURLs []string, since map[string]time.Time, stop <-chan struct{}) (<-chan *github.Issue, <-chan error) {
+	issues := make(chan *github.Issue, streamBuffer)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(errs)
+		defer close(issues)
+
+		var repos []Repo
+		for _, url := range repoURLs {
+			repo, err := ParseRepoURL(url)
+			if err != nil {
+				if err := onerror.Handle(err); err != nil {
+					errs <- err
+					return
+				}
+				continue
+			}
+			repos = append(repos, repo)
+		}
+		sort.SliceStable(repos, func(a, b int) bool { return repos[a].FullName() < repos[b].FullName() })
+
+		for _, repo := range repos {
+			if err := streamRepo(repo, sinceFor(since[repo.FullName()]), issues, stop); err != nil {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				failRepo(repo, err)
+			}
 		}
 	}()
-
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return fmt.Errorf("read response body: %s", err)
-	}
-	if resp.StatusCode != 201 {
-		return fmt.Errorf("api error: POST %s %s: %s %s", i.GetCommentsURL(), data, resp.Status, body)
-	}
-
-	return nil
+	return issues, errs
 }
 
-func Close(i *github.Issue) error {
-	payload := map[string]interface{}{
-		"state": "closed",
-	}
+// streamRepo sends the open issues of the repo on issues page by page, as they are fetched. Fetching
+// is canceled after --repo-timeout, not counting the time the issues wait to be taken, or when stop
+// is closed. If a page fails, the issues of the pages before are sent already.
+func streamRepo(repo Repo, since time.Time, issues chan<- *github.Issue, stop <-chan struct{}) error {
+	sctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-stop:
+			cancel()
+		case <-sctx.Done():
+		}
+	}()
 
-	data, err := json.Marshal(payload)
-	if err != nil {
-		return fmt.Errorf("could not marshal %s: %s", payload, err)
-	}
+	left := repoTimeout
+	fetched, pages := 0, 0
+	for page := 1; page != 0; pages++ {
+		start := time.Now()
+		pctx, pcancel := context.WithTimeout(sctx, left)
+		batch, next, err := service.OpenIssuesPage(pctx, repo, since, page)
+		pcancel()
+		left -= time.Since(start)
+		if err != nil {
+			return err
+		}
 
-	request, err := http.NewRequest("PATCH", i.GetURL(), bytes.NewBuffer(data))
-	if err != nil {
-		return fmt.Errorf("could not create request: %s", err)
+		if fetched == 0 && len(batch) > 0 {
+			if ref, err := ParseIssueURL(batch[0].GetHTMLURL()); err == nil && ref.FullName() != repo.FullName() {
+				renamed(repo, ref.Repo)
+			}
+		}
+		for _, i := range batch {
+			select {
+			case issues <- i:
+			case <-stop:
+				return fmt.Errorf("fetch issues of %s: stopped", repo.FullName())
+			}
+		}
+		fetched += len(batch)
+		page = next
 	}
+	log.Printf("%s: fetched %d issues across %d pages", repo.FullName(), fetched, pages)
+	return nil
+}
 
-	resp, err := tc.Do(request)
-	if err != nil {
-		return fmt.Errorf("could not send request: %s", err)
-	}
-	defer func() {
-		if err := resp.Body.Close(); err != nil {
-			log.Warnf("warning: could not close response body: %s", err)
-		}
-	}()
+// PostComment comments on the issue, ending the comment with the Marker, and returns the ID of the
+// comment and the call made.
+func PostComment(i *github.Issue, comment string) (int64, trace.Call, error) {
+	return service.Comment(i, withMarker(comment))
+}
 
-	if err != nil {
-		return fmt.Errorf("error sending request: %s", err)
-	}
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return fmt.Errorf("could not read response body: %s", err)
-	}
-	if resp.StatusCode != 200 {
-		return fmt.Errorf("api error for payload %s: %s", payload, body)
-	}
+// Close closes the issue with the state reason, if not empty, and returns the call made.
+func Close(i *github.Issue, reason string) (trace.Call, error) {
+	return service.Close(i, reason)
+}
 
-	return nil
+// Lock locks the conversation of the issue and returns the call made.
+func Lock(i *github.Issue) (trace.Call, error) {
+	return service.Lock(i)
 }
 
-func Lock(i *github.Issue) error {
-	url := fmt.Sprintf("%s/lock", i.GetURL())
-	request, err := http.NewRequest("PUT", url, bytes.NewBuffer([]byte{}))
-	request.Header.Add("Content-Length", "0")
+// GetIssue fetches the issue at the web or API URL.
+func GetIssue(issueURL string) (*github.Issue, error) {
+	ref, err := ParseIssueURL(issueURL)
 	if err != nil {
-		return fmt.Errorf("could not create request: %s", err)
+		return nil, err
 	}
+	return service.Issue(ref)
+}
 
-	resp, err := tc.Do(request)
-	if err != nil {
-		return fmt.Errorf("could not send request: %s", err)
-	}
-	defer func() {
-		if err := resp.Body.Close(); err != nil {
-			log.Warnf("warning: could not close response body: %s", err)
+// CountOpenIssues counts the open issues of the repos, updated since the time given for the repo
+// like GetOpenIssuesSince, without fetching them. Pull requests are not counted.
+func CountOpenIssues(repoURLs []string, since map[string]time.Time) (int, error) {
+	total := 0
+	for _, url := range repoURLs {
+		repo, err := ParseRepoURL(url)
+		if err != nil {
+			return 0, err
 		}
-	}()
 
-	if err != nil {
-		return fmt.Errorf("error sending request: %s", err)
-	}
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return fmt.Errorf("could not read response body: %s", err)
-	}
-	if resp.StatusCode != 204 {
-		return fmt.Errorf("api error: %s", body)
+		variables := map[string]interface{}{"owner": repo.Owner, "name": repo.Name, "since": nil}
+		if t := sinceFor(since[repo.FullName()]); !t.IsZero() {
+			variables["since"] = t.Format(time.RFC3339)
+		}
+		var data struct {
+			Repository struct {
+				Issues struct {
+					TotalCount int `json:"totalCount"`
+				} `json:"issues"`
+			} `json:"repository"`
+		}
+		if err := graphql(`query($owner: String!, $name: String!, $since: DateTime) {
+  repository(owner: $owner, name: $name) { issues(states: OPEN, filterBy: {since: $since}) { totalCount } }
+}`, variables, &data); err != nil {
+			return 0, fmt.Errorf("count open issues of %s: %s", repo.FullName(), err)
+		}
+		total += data.Repository.Issues.TotalCount
 	}
-
-	return nil
+	return total, nil
 }
@@ -0,0 +1,54 @@
+package github
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+func TestStreamRepoSendsPagesAsFetched(t *testing.T) {
+	firstTaken := make(chan struct{})
+	var srvURL string
+	s := newTestService(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == "" {
+			w.Header().Set("Link", fmt.Sprintf(`<%s/repos/octo/repo/issues?page=2>; rel="next"`, srvURL))
+			fmt.Fprint(w, `[{"number": 1, "html_url": "https://github.com/octo/repo/issues/1"}]`)
+			return
+		}
+		select {
+		case <-firstTaken:
+		case <-time.After(time.Second):
+			t.Error("page 2 fetched before the issue of page 1 was taken")
+		}
+		fmt.Fprint(w, `[{"number": 2, "html_url": "https://github.com/octo/repo/issues/2"}]`)
+	}))
+	srvURL = s.client.BaseURL.String()
+	srvURL = srvURL[:len(srvURL)-1]
+	defaultService := service
+	service = s
+	defer func() { service = defaultService }()
+
+	issues := make(chan *github.Issue)
+	errs := make(chan error, 1)
+	go func() {
+		errs <- streamRepo(Repo{"github.com", "octo", "repo"}, time.Time{}, issues, make(chan struct{}))
+		close(issues)
+	}()
+
+	var numbers []int
+	for i := range issues {
+		if i.GetNumber() == 1 {
+			close(firstTaken)
+		}
+		numbers = append(numbers, i.GetNumber())
+	}
+	if err := <-errs; err != nil {
+		t.Fatalf("streamRepo: %s", err)
+	}
+	if fmt.Sprint(numbers) != "[1 2]" {
+		t.Errorf("numbers = %v, want [1 2]", numbers)
+	}
+}
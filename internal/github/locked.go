@@ -0,0 +1,38 @@
+package github
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/lszucs/github-to-discourse/internal/debugbundle"
+)
+
+// LockedAt returns when the conversation of the issue was last locked, or the zero time if it isn't locked.
+func LockedAt(i *github.Issue) (time.Time, error) {
+	if !i.GetLocked() {
+		return time.Time{}, nil
+	}
+	ref, err := ParseIssueURL(i.GetHTMLURL())
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var locked time.Time
+	opts := github.ListOptions{PerPage: perPage}
+	for {
+		events, resp, err := client.Issues.ListIssueEvents(debugbundle.WithIssue(ctx, i.GetHTMLURL()), ref.Owner, ref.Name, ref.Number, &opts)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("fetch events of %s: %s", i.GetHTMLURL(), err)
+		}
+		for _, e := range events {
+			if e.GetEvent() == "locked" && e.GetCreatedAt().After(locked) {
+				locked = e.GetCreatedAt()
+			}
+		}
+		if resp.NextPage == 0 {
+			return locked, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
@@ -0,0 +1,39 @@
+package github
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Marker is the hidden HTML comment ending the comments posted by the tool, so a rollback never
+// deletes a comment it didn't post.
+const Marker = "<!-- github-to-discourse -->"
+
+func withMarker(body string) string {
+	if strings.Contains(body, Marker) {
+		return body
+	}
+	return body + "\n\n" + Marker
+}
+
+// OwnComment reports whether the comment of the repo of the issue was posted by the tool: authored
+// by the user of the token and carrying the Marker. If not, the reason tells why.
+func OwnComment(ref IssueRef, commentID int64) (bool, string, error) {
+	comment, err := service.GetComment(ref, commentID)
+	if err != nil {
+		return false, "", err
+	}
+	// the empty login is the authenticated user
+	viewer, err := getUser("")
+	if err != nil {
+		return false, "", err
+	}
+
+	if author := comment.GetUser().GetLogin(); !strings.EqualFold(author, viewer.GetLogin()) {
+		return false, fmt.Sprintf("authored by %s, not %s", author, viewer.GetLogin()), nil
+	}
+	if !strings.Contains(comment.GetBody(), Marker) {
+		return false, "the marker of the tool is missing", nil
+	}
+	return true, "", nil
+}
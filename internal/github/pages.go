@@ -0,0 +1,23 @@
+package github
+
+import (
+	"flag"
+	"fmt"
+)
+
+// maxPerPage is the maximum number of items per page of the list calls of the API.
+const maxPerPage = 100
+
+var perPage int
+
+func init() {
+	flag.IntVar(&perPage, "per-page", maxPerPage, fmt.Sprintf("--per-page=<int> (number of items fetched per page of GitHub list calls, 1-%d; lower it if large pages time out)", maxPerPage))
+}
+
+// ValidatePerPage checks --per-page against the limits of the API.
+func ValidatePerPage() error {
+	if perPage < 1 || perPage > maxPerPage {
+		return fmt.Errorf("invalid --per-page %d, the API accepts 1-%d", perPage, maxPerPage)
+	}
+	return nil
+}
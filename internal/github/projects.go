@@ -0,0 +1,139 @@
+package github
+
+import (
+	"fmt"
+)
+
+// Project is a GitHub Projects (v2) board with its single select status field.
+type Project struct {
+	ID            string
+	URL           string
+	StatusFieldID string
+	// StatusOptions maps the status option names to their IDs.
+	StatusOptions map[string]string
+}
+
+type projectData struct {
+	ID    string `json:"id"`
+	URL   string `json:"url"`
+	Field struct {
+		ID      string `json:"id"`
+		Options []struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		} `json:"options"`
+	} `json:"field"`
+}
+
+const projectFields = `id url field(name: $field) { ... on ProjectV2SingleSelectField { id options { id name } } }`
+
+func (d projectData) project() Project {
+	p := Project{
+		ID:            d.ID,
+		URL:           d.URL,
+		StatusFieldID: d.Field.ID,
+		StatusOptions: map[string]string{},
+	}
+	for _, o := range d.Field.Options {
+		p.StatusOptions[o.Name] = o.ID
+	}
+	return p
+}
+
+// GetProject fetches the project of the organization (or user) owner by number, with its field
+// named statusField.
+func GetProject(owner string, number int, statusField string) (Project, error) {
+	var data struct {
+		Organization *struct {
+			ProjectV2 *projectData `json:"projectV2"`
+		} `json:"organization"`
+		User *struct {
+			ProjectV2 *projectData `json:"projectV2"`
+		} `json:"user"`
+	}
+
+	vars := map[string]interface{}{"owner": owner, "number": number, "field": statusField}
+	orgErr := graphql(`query($owner: String!, $number: Int!, $field: String!) {
+  organization(login: $owner) { projectV2(number: $number) { `+projectFields+` } }
+}`, vars, &data)
+	if orgErr == nil && data.Organization != nil && data.Organization.ProjectV2 != nil {
+		return data.Organization.ProjectV2.project(), nil
+	}
+
+	if err := graphql(`query($owner: String!, $number: Int!, $field: String!) {
+  user(login: $owner) { projectV2(number: $number) { `+projectFields+` } }
+}`, vars, &data); err != nil {
+		return Project{}, fmt.Errorf("get project %s/%d: %s", owner, number, err)
+	}
+	if data.User == nil || data.User.ProjectV2 == nil {
+		return Project{}, fmt.Errorf("project %s/%d not found", owner, number)
+	}
+	return data.User.ProjectV2.project(), nil
+}
+
+// CreateProject creates a project owned by the organization (or user) owner.
+func CreateProject(owner, title, statusField string) (Project, error) {
+	var found struct {
+		RepositoryOwner struct {
+			ID string `json:"id"`
+		} `json:"repositoryOwner"`
+	}
+	if err := graphql(`query($owner: String!) { repositoryOwner(login: $owner) { id } }`,
+		map[string]interface{}{"owner": owner}, &found); err != nil {
+		return Project{}, fmt.Errorf("find owner %s: %s", owner, err)
+	}
+
+	var created struct {
+		CreateProjectV2 struct {
+			ProjectV2 projectData `json:"projectV2"`
+		} `json:"createProjectV2"`
+	}
+	if err := graphql(`mutation($owner: ID!, $title: String!, $field: String!) {
+  createProjectV2(input: {ownerId: $owner, title: $title}) { projectV2 { `+projectFields+` } }
+}`, map[string]interface{}{"owner": found.RepositoryOwner.ID, "title": title, "field": statusField}, &created); err != nil {
+		return Project{}, fmt.Errorf("create project: %s", err)
+	}
+	return created.CreateProjectV2.ProjectV2.project(), nil
+}
+
+// AddProjectItem adds the issue at issueURL to the project, or returns its existing item.
+func AddProjectItem(p Project, issueURL string) (string, error) {
+	var found struct {
+		Resource struct {
+			ID string `json:"id"`
+		} `json:"resource"`
+	}
+	if err := graphql(`query($url: URI!) { resource(url: $url) { ... on Issue { id } } }`,
+		map[string]interface{}{"url": issueURL}, &found); err != nil {
+		return "", fmt.Errorf("find issue %s: %s", issueURL, err)
+	}
+	if found.Resource.ID == "" {
+		return "", fmt.Errorf("issue %s not found", issueURL)
+	}
+
+	var added struct {
+		AddProjectV2ItemByID struct {
+			Item struct {
+				ID string `json:"id"`
+			} `json:"item"`
+		} `json:"addProjectV2ItemById"`
+	}
+	if err := graphql(`mutation($project: ID!, $content: ID!) {
+  addProjectV2ItemById(input: {projectId: $project, contentId: $content}) { item { id } }
+}`, map[string]interface{}{"project": p.ID, "content": found.Resource.ID}, &added); err != nil {
+		return "", fmt.Errorf("add %s to project: %s", issueURL, err)
+	}
+	return added.AddProjectV2ItemByID.Item.ID, nil
+}
+
+// SetProjectItemStatus sets the status field of the item to the option named status.
+func SetProjectItemStatus(p Project, itemID, status string) error {
+	optionID, ok := p.StatusOptions[status]
+	if !ok {
+		return fmt.Errorf("project has no status option %s", status)
+	}
+
+	return graphql(`mutation($project: ID!, $item: ID!, $field: ID!, $option: String!) {
+  updateProjectV2ItemFieldValue(input: {projectId: $project, itemId: $item, fieldId: $field, value: {singleSelectOptionId: $option}}) { projectV2Item { id } }
+}`, map[string]interface{}{"project": p.ID, "item": itemID, "field": p.StatusFieldID, "option": optionID}, nil)
+}
@@ -0,0 +1,75 @@
+package github
+
+import (
+	"fmt"
+
+	"github.com/google/go-github/github"
+)
+
+// File is a file of the default branch of a repo.
+type File struct {
+	Repo    Repo
+	Path    string
+	Content string
+	SHA     string
+	Branch  string
+}
+
+// GetFile fetches the file at path from the default branch of repo.
+func GetFile(repo Repo, path string) (File, error) {
+	r, _, err := client.Repositories.Get(ctx, repo.Owner, repo.Name)
+	if err != nil {
+		return File{}, fmt.Errorf("get repo %s: %s", repo.FullName(), err)
+	}
+
+	fc, _, _, err := client.Repositories.GetContents(ctx, repo.Owner, repo.Name, path, &github.RepositoryContentGetOptions{Ref: r.GetDefaultBranch()})
+	if err != nil {
+		return File{}, fmt.Errorf("get %s of %s: %s", path, repo.FullName(), err)
+	}
+	if fc == nil {
+		return File{}, fmt.Errorf("get %s of %s: not a file", path, repo.FullName())
+	}
+
+	content, err := fc.GetContent()
+	if err != nil {
+		return File{}, fmt.Errorf("decode %s of %s: %s", path, repo.FullName(), err)
+	}
+
+	return File{Repo: repo, Path: path, Content: content, SHA: fc.GetSHA(), Branch: r.GetDefaultBranch()}, nil
+}
+
+// ProposeChange commits content to f on a new branch and opens a pull request to the branch of f,
+// returning its URL.
+func ProposeChange(f File, branch, content, title, body string) (string, error) {
+	base, _, err := client.Git.GetRef(ctx, f.Repo.Owner, f.Repo.Name, "heads/"+f.Branch)
+	if err != nil {
+		return "", fmt.Errorf("get %s of %s: %s", f.Branch, f.Repo.FullName(), err)
+	}
+
+	if _, _, err := client.Git.CreateRef(ctx, f.Repo.Owner, f.Repo.Name, &github.Reference{
+		Ref:    github.String("refs/heads/" + branch),
+		Object: &github.GitObject{SHA: base.Object.SHA},
+	}); err != nil {
+		return "", fmt.Errorf("create branch %s of %s: %s", branch, f.Repo.FullName(), err)
+	}
+
+	if _, _, err := client.Repositories.UpdateFile(ctx, f.Repo.Owner, f.Repo.Name, f.Path, &github.RepositoryContentFileOptions{
+		Message: github.String(title),
+		Content: []byte(content),
+		SHA:     github.String(f.SHA),
+		Branch:  github.String(branch),
+	}); err != nil {
+		return "", fmt.Errorf("update %s of %s: %s", f.Path, f.Repo.FullName(), err)
+	}
+
+	pr, _, err := client.PullRequests.Create(ctx, f.Repo.Owner, f.Repo.Name, &github.NewPullRequest{
+		Title: github.String(title),
+		Head:  github.String(branch),
+		Base:  github.String(f.Branch),
+		Body:  github.String(body),
+	})
+	if err != nil {
+		return "", fmt.Errorf("open pull request to %s: %s", f.Repo.FullName(), err)
+	}
+	return pr.GetHTMLURL(), nil
+}
@@ -0,0 +1,43 @@
+package github
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/bitrise-io/go-utils/log"
+
+	"github.com/lszucs/github-to-discourse/internal/state"
+)
+
+var (
+	renamesMu sync.Mutex
+	renames   = map[string]string{}
+)
+
+// renamed records that repo has been renamed (or moved) to canonical, and moves the state records
+// of its issues to their new URLs, so they are resumed instead of migrated again.
+func renamed(repo, canonical Repo) {
+	if strings.EqualFold(repo.FullName(), canonical.FullName()) {
+		// only the case of the name given differs
+		return
+	}
+
+	renamesMu.Lock()
+	renames[repo.FullName()] = canonical.FullName()
+	renamesMu.Unlock()
+
+	moved := state.RenameRepo(repo.URL(), canonical.URL(), canonical.FullName())
+	log.Warnf("%s has been renamed to %s, using the new name (%d state records moved)", repo.FullName(), canonical.FullName(), moved)
+}
+
+// Renames returns the canonical full names of the renamed repos fetched, keyed by the name given.
+func Renames() map[string]string {
+	renamesMu.Lock()
+	defer renamesMu.Unlock()
+
+	m := map[string]string{}
+	for from, to := range renames {
+		m[from] = to
+	}
+	return m
+}
@@ -0,0 +1,267 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/lszucs/github-to-discourse/internal/debugbundle"
+	"github.com/lszucs/github-to-discourse/internal/trace"
+	"golang.org/x/oauth2"
+)
+
+// Service performs the issue operations of the migration through go-github.
+type Service struct {
+	client *github.Client
+	http   *http.Client
+	ctx    context.Context
+}
+
+// NewService returns a service authenticating with the token, anonymous if empty, sending the
+// requests through transport, http.DefaultTransport if nil. baseURL is the API URL, the public
+// API if empty, e.g. https://ghe.example.com/api/v3/.
+func NewService(token, baseURL string, transport http.RoundTripper) (*Service, error) {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	if token != "" {
+		transport = &oauth2.Transport{Source: oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token}), Base: transport}
+	}
+	hc := &http.Client{Transport: transport, CheckRedirect: stopAtDiscussions}
+
+	client := github.NewClient(hc)
+	if baseURL != "" {
+		u, err := url.Parse(strings.TrimSuffix(baseURL, "/") + "/")
+		if err != nil {
+			return nil, fmt.Errorf("parse GitHub API URL %s: %s", baseURL, err)
+		}
+		client.BaseURL = u
+	}
+	return &Service{client: client, http: hc, ctx: context.Background()}, nil
+}
+
+func issueRef(i *github.Issue) (IssueRef, error) {
+	return ParseIssueURL(i.GetHTMLURL())
+}
+
+// issueCtx returns the context of the requests made for the issue.
+func (s *Service) issueCtx(issueURL string) context.Context {
+	return debugbundle.WithIssue(s.ctx, issueURL)
+}
+
+// Comment comments on the issue and returns the ID of the comment and the call made.
+func (s *Service) Comment(i *github.Issue, body string) (int64, trace.Call, error) {
+	call := trace.Call{Method: http.MethodPost, URL: i.GetCommentsURL()}
+	ref, err := issueRef(i)
+	if err != nil {
+		return 0, call, err
+	}
+
+	created, resp, err := s.client.Issues.CreateComment(s.issueCtx(i.GetHTMLURL()), ref.Owner, ref.Name, ref.Number, &github.IssueComment{Body: github.String(body)})
+	if resp != nil {
+		call.Status = resp.StatusCode
+		if cerr := checkConverted(i, resp.Response); cerr != nil {
+			return 0, call, cerr
+		}
+	}
+	if err != nil {
+		return 0, call, fmt.Errorf("comment on %s: %s", ref.URL(), err)
+	}
+	call.Resource = trace.ID(created.GetID())
+	return created.GetID(), call, nil
+}
+
+// EditComment replaces the body of a comment of the repo of the issue.
+func (s *Service) EditComment(ref IssueRef, commentID int64, body string) error {
+	if _, _, err := s.client.Issues.EditComment(s.issueCtx(ref.URL()), ref.Owner, ref.Name, commentID, &github.IssueComment{Body: github.String(body)}); err != nil {
+		return fmt.Errorf("edit comment %d of %s: %s", commentID, ref.URL(), err)
+	}
+	return nil
+}
+
+// GetComment fetches a comment of the repo of the issue.
+func (s *Service) GetComment(ref IssueRef, commentID int64) (*github.IssueComment, error) {
+	comment, _, err := s.client.Issues.GetComment(s.issueCtx(ref.URL()), ref.Owner, ref.Name, commentID)
+	if err != nil {
+		return nil, fmt.Errorf("get comment %d of %s: %s", commentID, ref.URL(), err)
+	}
+	return comment, nil
+}
+
+// DeleteComment deletes a comment of the repo of the issue.
+func (s *Service) DeleteComment(ref IssueRef, commentID int64) error {
+	if _, err := s.client.Issues.DeleteComment(s.issueCtx(ref.URL()), ref.Owner, ref.Name, commentID); err != nil {
+		return fmt.Errorf("delete comment %d of %s: %s", commentID, ref.URL(), err)
+	}
+	return nil
+}
+
+// Close reasons of issues, see Close.
+const (
+	ReasonCompleted  = "completed"
+	ReasonNotPlanned = "not_planned"
+)
+
+// closeRequest is an issue edit closing the issue, go-github doesn't know the state reason.
+type closeRequest struct {
+	State       string `json:"state"`
+	StateReason string `json:"state_reason,omitempty"`
+}
+
+// Close closes the issue with the state reason, if not empty, and returns the call made.
+func (s *Service) Close(i *github.Issue, reason string) (trace.Call, error) {
+	call := trace.Call{Method: http.MethodPatch, URL: i.GetURL()}
+	ref, err := issueRef(i)
+	if err != nil {
+		return call, err
+	}
+
+	req, err := s.client.NewRequest(http.MethodPatch, fmt.Sprintf("repos/%s/%s/issues/%d", ref.Owner, ref.Name, ref.Number), closeRequest{State: "closed", StateReason: reason})
+	if err != nil {
+		return call, fmt.Errorf("close %s: %s", ref.URL(), err)
+	}
+	resp, err := s.client.Do(s.issueCtx(i.GetHTMLURL()), req, nil)
+	if resp != nil {
+		call.Status = resp.StatusCode
+		if cerr := checkConverted(i, resp.Response); cerr != nil {
+			return call, cerr
+		}
+	}
+	if err != nil {
+		return call, fmt.Errorf("close %s: %s", ref.URL(), err)
+	}
+	return call, nil
+}
+
+// Reopen reopens the issue.
+func (s *Service) Reopen(ref IssueRef) error {
+	if _, _, err := s.client.Issues.Edit(s.issueCtx(ref.URL()), ref.Owner, ref.Name, ref.Number, &github.IssueRequest{State: github.String("open")}); err != nil {
+		return fmt.Errorf("reopen %s: %s", ref.URL(), err)
+	}
+	return nil
+}
+
+// restoreRequest is an issue edit restoring a snapshot, a nil Milestone removes the milestone.
+type restoreRequest struct {
+	State     string   `json:"state"`
+	Labels    []string `json:"labels"`
+	Milestone *int     `json:"milestone"`
+}
+
+// Restore sets the state, the labels and the milestone of the issue to the ones of snap, the
+// issue as it was before the migration.
+func (s *Service) Restore(ref IssueRef, snap *github.Issue) error {
+	r := restoreRequest{State: snap.GetState(), Labels: []string{}}
+	for _, l := range snap.Labels {
+		r.Labels = append(r.Labels, l.GetName())
+	}
+	if snap.Milestone != nil {
+		r.Milestone = snap.Milestone.Number
+	}
+
+	req, err := s.client.NewRequest(http.MethodPatch, fmt.Sprintf("repos/%s/%s/issues/%d", ref.Owner, ref.Name, ref.Number), r)
+	if err != nil {
+		return fmt.Errorf("restore %s: %s", ref.URL(), err)
+	}
+	if _, err := s.client.Do(s.issueCtx(ref.URL()), req, nil); err != nil {
+		return fmt.Errorf("restore %s: %s", ref.URL(), err)
+	}
+	return nil
+}
+
+// Lock locks the conversation of the issue and returns the call made.
+func (s *Service) Lock(i *github.Issue) (trace.Call, error) {
+	call := trace.Call{Method: http.MethodPut, URL: i.GetURL() + "/lock"}
+	ref, err := issueRef(i)
+	if err != nil {
+		return call, err
+	}
+
+	resp, err := s.client.Issues.Lock(s.issueCtx(i.GetHTMLURL()), ref.Owner, ref.Name, ref.Number, nil)
+	if resp != nil {
+		call.Status = resp.StatusCode
+		if cerr := checkConverted(i, resp.Response); cerr != nil {
+			return call, cerr
+		}
+	}
+	if err != nil {
+		return call, fmt.Errorf("lock %s: %s", ref.URL(), err)
+	}
+	return call, nil
+}
+
+// Unlock unlocks the issue.
+func (s *Service) Unlock(ref IssueRef) error {
+	if _, err := s.client.Issues.Unlock(s.issueCtx(ref.URL()), ref.Owner, ref.Name, ref.Number); err != nil {
+		return fmt.Errorf("unlock %s: %s", ref.URL(), err)
+	}
+	return nil
+}
+
+// CreateIssue opens an issue in the repo.
+func (s *Service) CreateIssue(repo Repo, title, body string) (*github.Issue, error) {
+	i, _, err := s.client.Issues.Create(s.ctx, repo.Owner, repo.Name, &github.IssueRequest{Title: github.String(title), Body: github.String(body)})
+	if err != nil {
+		return nil, fmt.Errorf("create issue in %s: %s", repo.FullName(), err)
+	}
+	return i, nil
+}
+
+// Issue fetches the issue.
+func (s *Service) Issue(ref IssueRef) (*github.Issue, error) {
+	i, _, err := s.client.Issues.Get(s.issueCtx(ref.URL()), ref.Owner, ref.Name, ref.Number)
+	if err != nil {
+		return nil, fmt.Errorf("fetch issue %s: %s", ref.URL(), err)
+	}
+	return i, nil
+}
+
+// Comments fetches every comment of the issue, oldest first.
+func (s *Service) Comments(ref IssueRef) ([]*github.IssueComment, error) {
+	var all []*github.IssueComment
+	opts := github.IssueListCommentsOptions{ListOptions: github.ListOptions{PerPage: perPage}}
+	for {
+		comments, resp, err := s.client.Issues.ListComments(s.issueCtx(ref.URL()), ref.Owner, ref.Name, ref.Number, &opts)
+		if err != nil {
+			return nil, fmt.Errorf("fetch comments of %s: %s", ref.URL(), err)
+		}
+		all = append(all, comments...)
+		if resp.NextPage == 0 {
+			return all, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// OpenIssuesPage fetches a page, numbered from 1, of the open issues of the repo updated since the
+// given time, all of them if zero, in ascending issue number. It returns the number of the next
+// page, 0 after the last one. Pull requests are included, as the API lists them.
+func (s *Service) OpenIssuesPage(ctx context.Context, repo Repo, since time.Time, page int) ([]*github.Issue, int, error) {
+	opts := github.IssueListByRepoOptions{
+		State: "open",
+		Since: since,
+		// ascending creation time is ascending issue number
+		Sort:        "created",
+		Direction:   "asc",
+		ListOptions: github.ListOptions{PerPage: perPage},
+	}
+	if page > 1 {
+		opts.Page = page
+	}
+
+	// the client follows the redirects of renamed repos, the issues carry the canonical name
+	issues, resp, err := s.client.Issues.ListByRepo(ctx, repo.Owner, repo.Name, &opts)
+	if err != nil {
+		if resp != nil {
+			if serr := ssoError(repo.Owner, resp.Response); serr != nil {
+				return nil, 0, fmt.Errorf("fetch issues from %s: %s", repo.URL(), serr)
+			}
+		}
+		return nil, 0, fmt.Errorf("fetch page %d of the issues of %s: %s", page, repo.URL(), err)
+	}
+	return issues, resp.NextPage, nil
+}
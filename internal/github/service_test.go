@@ -0,0 +1,284 @@
+package github
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+// newTestService returns a service authenticated with a token sending its requests to a test
+// server with the handler.
+func newTestService(t *testing.T, handler http.Handler) *Service {
+	return newTestServiceWithToken(t, "secret", handler)
+}
+
+func newTestServiceWithToken(t *testing.T, token string, handler http.Handler) *Service {
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	s, err := NewService(token, srv.URL, nil)
+	if err != nil {
+		t.Fatalf("NewService: %s", err)
+	}
+	return s
+}
+
+func testIssue(number int) *github.Issue {
+	return &github.Issue{
+		Number:      github.Int(number),
+		HTMLURL:     github.String(fmt.Sprintf("https://github.com/octo/repo/issues/%d", number)),
+		URL:         github.String(fmt.Sprintf("https://api.github.com/repos/octo/repo/issues/%d", number)),
+		CommentsURL: github.String(fmt.Sprintf("https://api.github.com/repos/octo/repo/issues/%d/comments", number)),
+	}
+}
+
+func TestServiceComment(t *testing.T) {
+	s := newTestService(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/repos/octo/repo/issues/7/comments" {
+			t.Errorf("request = %s %s, want POST /repos/octo/repo/issues/7/comments", r.Method, r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer secret" {
+			t.Errorf("Authorization = %q, want Bearer secret", got)
+		}
+		var c github.IssueComment
+		if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+			t.Errorf("decode request body: %s", err)
+		}
+		if c.GetBody() != "moved to Discourse" {
+			t.Errorf("body = %q, want moved to Discourse", c.GetBody())
+		}
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"id": 42}`)
+	}))
+
+	id, call, err := s.Comment(testIssue(7), "moved to Discourse")
+	if err != nil {
+		t.Fatalf("Comment: %s", err)
+	}
+	if id != 42 {
+		t.Errorf("id = %d, want 42", id)
+	}
+	if call.Status != http.StatusCreated || call.Resource != "42" {
+		t.Errorf("call = %+v, want status 201 and resource 42", call)
+	}
+}
+
+func TestServiceCommentConverted(t *testing.T) {
+	tests := []struct {
+		name           string
+		handler        http.HandlerFunc
+		wantDiscussion string
+	}{
+		{
+			name: "redirected",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Location", "https://api.github.com/repos/octo/repo/discussions/3")
+				w.WriteHeader(http.StatusMovedPermanently)
+			},
+			wantDiscussion: "https://github.com/octo/repo/discussions/3",
+		},
+		{
+			name: "gone",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusGone)
+				fmt.Fprint(w, `{"message": "This issue was deleted"}`)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		s := newTestService(t, tt.handler)
+		_, call, err := s.Comment(testIssue(7), "moved to Discourse")
+		cerr, ok := err.(*ConvertedError)
+		if !ok {
+			t.Errorf("%s: error = %v, want *ConvertedError", tt.name, err)
+			continue
+		}
+		if cerr.DiscussionURL != tt.wantDiscussion {
+			t.Errorf("%s: discussion = %q, want %q", tt.name, cerr.DiscussionURL, tt.wantDiscussion)
+		}
+		if call.Status == 0 {
+			t.Errorf("%s: call status not recorded", tt.name)
+		}
+	}
+}
+
+func TestServiceEditComment(t *testing.T) {
+	s := newTestService(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPatch || r.URL.Path != "/repos/octo/repo/issues/comments/42" {
+			t.Errorf("request = %s %s, want PATCH /repos/octo/repo/issues/comments/42", r.Method, r.URL.Path)
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		if string(body) != `{"body":"new topic"}`+"\n" {
+			t.Errorf("body = %s", body)
+		}
+		fmt.Fprint(w, `{"id": 42}`)
+	}))
+
+	ref := IssueRef{Repo: Repo{"github.com", "octo", "repo"}, Number: 7}
+	if err := s.EditComment(ref, 42, "new topic"); err != nil {
+		t.Fatalf("EditComment: %s", err)
+	}
+}
+
+func TestServiceGetComment(t *testing.T) {
+	s := newTestService(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/repos/octo/repo/issues/comments/42" {
+			t.Errorf("request = %s %s, want GET /repos/octo/repo/issues/comments/42", r.Method, r.URL.Path)
+		}
+		fmt.Fprint(w, `{"id": 42, "body": "moved to Discourse", "user": {"login": "bot"}}`)
+	}))
+
+	ref := IssueRef{Repo: Repo{"github.com", "octo", "repo"}, Number: 7}
+	c, err := s.GetComment(ref, 42)
+	if err != nil {
+		t.Fatalf("GetComment: %s", err)
+	}
+	if c.GetUser().GetLogin() != "bot" || c.GetBody() != "moved to Discourse" {
+		t.Errorf("comment = %+v, want the body and author of comment 42", c)
+	}
+}
+
+func TestServiceCloseAndLock(t *testing.T) {
+	var requests []string
+	s := newTestService(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		switch r.Method {
+		case http.MethodPatch:
+			var req closeRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.State != "closed" || req.StateReason != ReasonNotPlanned {
+				t.Errorf("close request = %+v, %v, want state closed as not_planned", req, err)
+			}
+			fmt.Fprint(w, `{"number": 7, "state": "closed"}`)
+		case http.MethodPut:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+
+	call, err := s.Close(testIssue(7), ReasonNotPlanned)
+	if err != nil {
+		t.Fatalf("Close: %s", err)
+	}
+	if call.Status != http.StatusOK {
+		t.Errorf("close status = %d, want 200", call.Status)
+	}
+
+	call, err = s.Lock(testIssue(7))
+	if err != nil {
+		t.Fatalf("Lock: %s", err)
+	}
+	if call.Status != http.StatusNoContent {
+		t.Errorf("lock status = %d, want 204", call.Status)
+	}
+
+	want := []string{"PATCH /repos/octo/repo/issues/7", "PUT /repos/octo/repo/issues/7/lock"}
+	if fmt.Sprint(requests) != fmt.Sprint(want) {
+		t.Errorf("requests = %v, want %v", requests, want)
+	}
+}
+
+func TestServiceCloseError(t *testing.T) {
+	s := newTestService(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, `{"message": "Must have admin rights to Repository."}`)
+	}))
+
+	call, err := s.Close(testIssue(7), ReasonCompleted)
+	if err == nil {
+		t.Fatal("Close: want error")
+	}
+	if _, ok := err.(*ConvertedError); ok {
+		t.Errorf("Close: error = %v, want API error", err)
+	}
+	if call.Status != http.StatusForbidden {
+		t.Errorf("status = %d, want 403", call.Status)
+	}
+}
+
+func TestServiceOpenIssuesPaginates(t *testing.T) {
+	var srvURL string
+	s := newTestService(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("state") != "open" || q.Get("per_page") != "100" {
+			t.Errorf("query = %s, want state=open and per_page=100", r.URL.RawQuery)
+		}
+		switch q.Get("page") {
+		case "":
+			w.Header().Set("Link", fmt.Sprintf(`<%s/repos/octo/repo/issues?page=2>; rel="next", <%s/repos/octo/repo/issues?page=2>; rel="last"`, srvURL, srvURL))
+			fmt.Fprint(w, `[{"number": 1}, {"number": 2}]`)
+		case "2":
+			fmt.Fprint(w, `[{"number": 3}]`)
+		default:
+			t.Errorf("unexpected page %s", q.Get("page"))
+		}
+	}))
+	srvURL = s.client.BaseURL.String()
+	srvURL = srvURL[:len(srvURL)-1]
+
+	var numbers []int
+	pages := 0
+	for page := 1; page != 0; pages++ {
+		issues, next, err := s.OpenIssuesPage(s.ctx, Repo{"github.com", "octo", "repo"}, time.Time{}, page)
+		if err != nil {
+			t.Fatalf("OpenIssuesPage %d: %s", page, err)
+		}
+		for _, i := range issues {
+			numbers = append(numbers, i.GetNumber())
+		}
+		page = next
+	}
+	if pages != 2 {
+		t.Errorf("pages = %d, want 2", pages)
+	}
+	if fmt.Sprint(numbers) != "[1 2 3]" {
+		t.Errorf("numbers = %v, want [1 2 3]", numbers)
+	}
+}
+
+func TestServiceComments(t *testing.T) {
+	var srvURL string
+	s := newTestService(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/octo/repo/issues/7/comments" {
+			t.Errorf("path = %s", r.URL.Path)
+		}
+		if r.URL.Query().Get("page") == "" {
+			w.Header().Set("Link", fmt.Sprintf(`<%s/repos/octo/repo/issues/7/comments?page=2>; rel="next"`, srvURL))
+			fmt.Fprint(w, `[{"id": 1}]`)
+			return
+		}
+		fmt.Fprint(w, `[{"id": 2}]`)
+	}))
+	srvURL = s.client.BaseURL.String()
+	srvURL = srvURL[:len(srvURL)-1]
+
+	comments, err := s.Comments(IssueRef{Repo: Repo{"github.com", "octo", "repo"}, Number: 7})
+	if err != nil {
+		t.Fatalf("Comments: %s", err)
+	}
+	if len(comments) != 2 || comments[0].GetID() != 1 || comments[1].GetID() != 2 {
+		t.Errorf("comments = %v, want IDs 1 and 2", comments)
+	}
+}
+
+func TestNewServiceAnonymous(t *testing.T) {
+	s := newTestServiceWithToken(t, "", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "" {
+			t.Errorf("Authorization = %q, want none", got)
+		}
+		fmt.Fprint(w, `{"number": 7}`)
+	}))
+
+	i, err := s.Issue(IssueRef{Repo: Repo{"github.com", "octo", "repo"}, Number: 7})
+	if err != nil {
+		t.Fatalf("Issue: %s", err)
+	}
+	if i.GetNumber() != 7 {
+		t.Errorf("number = %d, want 7", i.GetNumber())
+	}
+}
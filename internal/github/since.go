@@ -0,0 +1,41 @@
+package github
+
+import (
+	"flag"
+	"fmt"
+	"time"
+)
+
+var (
+	sinceFlag string
+	since     time.Time
+)
+
+func init() {
+	flag.StringVar(&sinceFlag, "since", "", "--since=<RFC 3339 time> (only fetch issues updated since, e.g. 2024-01-01T00:00:00Z, for incremental syncs; with --incremental the later of it and the watermark of the repo applies)")
+}
+
+// ValidateSince parses --since.
+func ValidateSince() error {
+	if sinceFlag == "" {
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339, sinceFlag)
+	if err != nil {
+		return fmt.Errorf("invalid --since %s, expected an RFC 3339 time like 2024-01-01T00:00:00Z: %s", sinceFlag, err)
+	}
+	if t.After(time.Now()) {
+		return fmt.Errorf("invalid --since %s: in the future", sinceFlag)
+	}
+	since = t
+	return nil
+}
+
+// sinceFor returns the time the issues of a repo are fetched since: the later of --since and the
+// watermark of the repo, zero to fetch every open issue.
+func sinceFor(watermark time.Time) time.Time {
+	if since.After(watermark) {
+		return since
+	}
+	return watermark
+}
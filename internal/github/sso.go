@@ -0,0 +1,83 @@
+package github
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/log"
+)
+
+// ssoHeader is set on responses to tokens not authorized for the SAML SSO of an organization.
+const ssoHeader = "X-GitHub-SSO"
+
+// SSOError tells the token has to be authorized for the SAML SSO of an organization.
+type SSOError struct {
+	Owner string
+	// URL is the page authorizing the token, empty if GitHub didn't send it.
+	URL string
+}
+
+func (e *SSOError) Error() string {
+	if e.URL == "" {
+		return fmt.Sprintf("GITHUB_ACCESS_TOKEN is not authorized for the SAML SSO of %s, authorize it in the token settings", e.Owner)
+	}
+	return fmt.Sprintf("GITHUB_ACCESS_TOKEN is not authorized for the SAML SSO of %s, authorize it at %s", e.Owner, e.URL)
+}
+
+// ssoError returns an *SSOError if the response requires SSO authorization of the token,
+// the header is e.g. "required; url=https://github.com/orgs/bitrise-io/sso?authorization_request=...".
+func ssoError(owner string, resp *http.Response) error {
+	if resp == nil {
+		return nil
+	}
+	header := resp.Header.Get(ssoHeader)
+	if !strings.HasPrefix(header, "required") {
+		return nil
+	}
+
+	e := &SSOError{Owner: owner}
+	for _, part := range strings.Split(header, ";") {
+		if url := strings.TrimPrefix(strings.TrimSpace(part), "url="); url != strings.TrimSpace(part) {
+			e.URL = url
+		}
+	}
+	return e
+}
+
+// CheckSSO fetches a repo of every owner of the repos and fails listing the organizations
+// the token has to be authorized for, with their authorization URLs.
+func CheckSSO(repoURLs []string) error {
+	if !Authenticated() {
+		return nil
+	}
+
+	seen := map[string]bool{}
+	var problems []string
+	for _, url := range repoURLs {
+		repo, err := ParseRepoURL(url)
+		if err != nil || seen[repo.Owner] {
+			continue
+		}
+		seen[repo.Owner] = true
+
+		_, resp, err := client.Repositories.Get(ctx, repo.Owner, repo.Name)
+		if err == nil {
+			continue
+		}
+		var r *http.Response
+		if resp != nil {
+			r = resp.Response
+		}
+		if serr := ssoError(repo.Owner, r); serr != nil {
+			problems = append(problems, serr.Error())
+			continue
+		}
+		// other errors are reported per repo by the fetch
+		log.Warnf("check SSO authorization with %s: %s", repo.FullName(), err)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%s", strings.Join(problems, "\n"))
+	}
+	return nil
+}
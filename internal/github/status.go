@@ -0,0 +1,45 @@
+package github
+
+import (
+	"fmt"
+
+	"github.com/google/go-github/github"
+)
+
+// StatusContext prefixes the context commit statuses are reported with, followed by the issue
+// number, so the statuses of the issues of a repo don't replace each other.
+const StatusContext = "github-to-discourse/migration"
+
+// maxStatusDescription is the longest description GitHub accepts for a commit status.
+const maxStatusDescription = 140
+
+// SetStatus reports the commit status of the issue on the head of the default branch of its repo.
+// state is one of pending, success, failure or error.
+func SetStatus(ref IssueRef, state, description, targetURL string) error {
+	repo := ref.Repo
+	r, _, err := client.Repositories.Get(ctx, repo.Owner, repo.Name)
+	if err != nil {
+		return fmt.Errorf("get repo %s: %s", repo.FullName(), err)
+	}
+	head, _, err := client.Git.GetRef(ctx, repo.Owner, repo.Name, "heads/"+r.GetDefaultBranch())
+	if err != nil {
+		return fmt.Errorf("get %s of %s: %s", r.GetDefaultBranch(), repo.FullName(), err)
+	}
+
+	if len(description) > maxStatusDescription {
+		description = description[:maxStatusDescription-3] + "..."
+	}
+	status := &github.RepoStatus{
+		State:       github.String(state),
+		Description: github.String(description),
+		Context:     github.String(fmt.Sprintf("%s/%d", StatusContext, ref.Number)),
+	}
+	if targetURL != "" {
+		status.TargetURL = github.String(targetURL)
+	}
+
+	if _, _, err := client.Repositories.CreateStatus(ctx, repo.Owner, repo.Name, head.GetObject().GetSHA(), status); err != nil {
+		return fmt.Errorf("set status of %s: %s", repo.FullName(), err)
+	}
+	return nil
+}
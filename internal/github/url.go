@@ -0,0 +1,116 @@
+package github
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const defaultHost = "github.com"
+
+// Repo identifies a GitHub repository.
+type Repo struct {
+	// Host is the web host, e.g. github.com or a GitHub Enterprise host.
+	Host  string
+	Owner string
+	Name  string
+}
+
+// FullName returns owner/name.
+func (r Repo) FullName() string {
+	return r.Owner + "/" + r.Name
+}
+
+// URL returns the canonical web URL of the repo.
+func (r Repo) URL() string {
+	return fmt.Sprintf("https://%s/%s/%s", r.Host, r.Owner, r.Name)
+}
+
+// IssueRef identifies a GitHub issue (or pull request).
+type IssueRef struct {
+	Repo
+	Number int
+}
+
+// URL returns the canonical web URL of the issue.
+func (i IssueRef) URL() string {
+	return fmt.Sprintf("%s/issues/%d", i.Repo.URL(), i.Number)
+}
+
+// splitURL normalizes web, API, git and scheme-less GitHub URLs and returns the host and the path segments.
+func splitURL(raw string) (string, []string, error) {
+	s := strings.TrimSpace(raw)
+	if strings.HasPrefix(s, "git@") {
+		// git@github.com:owner/repo.git
+		s = "ssh://" + strings.Replace(strings.TrimPrefix(s, "git@"), ":", "/", 1)
+	}
+	if !strings.Contains(s, "://") {
+		s = "https://" + s
+	}
+
+	u, err := url.Parse(s)
+	if err != nil {
+		return "", nil, fmt.Errorf("parse %s: %s", raw, err)
+	}
+	host := strings.ToLower(u.Hostname())
+	host = strings.TrimPrefix(host, "www.")
+	host = strings.TrimPrefix(host, "api.")
+	if host == "" {
+		return "", nil, fmt.Errorf("parse %s: no host", raw)
+	}
+
+	var segments []string
+	for _, seg := range strings.Split(u.Path, "/") {
+		if seg != "" {
+			segments = append(segments, seg)
+		}
+	}
+	// GitHub Enterprise API: /api/v3/repos/..., github.com API: /repos/...
+	if len(segments) >= 2 && segments[0] == "api" && segments[1] == "v3" {
+		segments = segments[2:]
+	}
+	if len(segments) > 0 && segments[0] == "repos" {
+		segments = segments[1:]
+	}
+	return host, segments, nil
+}
+
+// ParseRepoURL parses the URL of a repository, or of any page within it.
+func ParseRepoURL(raw string) (Repo, error) {
+	host, segments, err := splitURL(raw)
+	if err != nil {
+		return Repo{}, err
+	}
+	if len(segments) < 2 {
+		return Repo{}, fmt.Errorf("parse %s: no owner/name in path", raw)
+	}
+
+	return Repo{
+		Host:  host,
+		Owner: segments[0],
+		Name:  strings.TrimSuffix(segments[1], ".git"),
+	}, nil
+}
+
+// ParseIssueURL parses the web or API URL of an issue or pull request.
+func ParseIssueURL(raw string) (IssueRef, error) {
+	repo, err := ParseRepoURL(raw)
+	if err != nil {
+		return IssueRef{}, err
+	}
+
+	_, segments, err := splitURL(raw)
+	if err != nil {
+		return IssueRef{}, err
+	}
+	if len(segments) < 4 || (segments[2] != "issues" && segments[2] != "pull" && segments[2] != "pulls") {
+		return IssueRef{}, fmt.Errorf("parse %s: not an issue URL", raw)
+	}
+
+	number, err := strconv.Atoi(segments[3])
+	if err != nil || number <= 0 {
+		return IssueRef{}, fmt.Errorf("parse %s: invalid issue number %s", raw, segments[3])
+	}
+	return IssueRef{Repo: repo, Number: number}, nil
+}
@@ -0,0 +1,85 @@
+package github
+
+import "testing"
+
+func TestParseRepoURL(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    Repo
+		wantErr bool
+	}{
+		{raw: "https://github.com/bitrise-io/steps-xcode-test", want: Repo{"github.com", "bitrise-io", "steps-xcode-test"}},
+		{raw: "https://github.com/bitrise-io/steps-xcode-test.git", want: Repo{"github.com", "bitrise-io", "steps-xcode-test"}},
+		{raw: "https://github.com/bitrise-io/steps-xcode-test/", want: Repo{"github.com", "bitrise-io", "steps-xcode-test"}},
+		{raw: "https://www.github.com/bitrise-io/steps-xcode-test", want: Repo{"github.com", "bitrise-io", "steps-xcode-test"}},
+		{raw: "http://GitHub.com/bitrise-io/steps-xcode-test", want: Repo{"github.com", "bitrise-io", "steps-xcode-test"}},
+		{raw: "github.com/bitrise-io/steps-xcode-test", want: Repo{"github.com", "bitrise-io", "steps-xcode-test"}},
+		{raw: "  https://github.com/bitrise-io/steps-xcode-test  ", want: Repo{"github.com", "bitrise-io", "steps-xcode-test"}},
+		{raw: "git@github.com:bitrise-io/steps-xcode-test.git", want: Repo{"github.com", "bitrise-io", "steps-xcode-test"}},
+		{raw: "https://github.com/bitrise-io/steps-xcode-test/issues/87", want: Repo{"github.com", "bitrise-io", "steps-xcode-test"}},
+		{raw: "https://api.github.com/repos/bitrise-io/steps-xcode-test", want: Repo{"github.com", "bitrise-io", "steps-xcode-test"}},
+		{raw: "https://ghe.example.com/team/repo", want: Repo{"ghe.example.com", "team", "repo"}},
+		{raw: "https://ghe.example.com/api/v3/repos/team/repo", want: Repo{"ghe.example.com", "team", "repo"}},
+		{raw: "https://github.com/bitrise-io", wantErr: true},
+		{raw: "https://github.com/", wantErr: true},
+		{raw: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseRepoURL(tt.raw)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseRepoURL(%q) error = %v, wantErr %t", tt.raw, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseRepoURL(%q) = %+v, want %+v", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestParseIssueURL(t *testing.T) {
+	xcodeTest := Repo{"github.com", "bitrise-io", "steps-xcode-test"}
+	tests := []struct {
+		raw     string
+		want    IssueRef
+		wantErr bool
+	}{
+		{raw: "https://github.com/bitrise-io/steps-xcode-test/issues/87", want: IssueRef{xcodeTest, 87}},
+		{raw: "https://github.com/bitrise-io/steps-xcode-test/issues/87/", want: IssueRef{xcodeTest, 87}},
+		{raw: "https://www.github.com/bitrise-io/steps-xcode-test/issues/87", want: IssueRef{xcodeTest, 87}},
+		{raw: "https://github.com/bitrise-io/steps-xcode-test/issues/87#issuecomment-1", want: IssueRef{xcodeTest, 87}},
+		{raw: "https://github.com/bitrise-io/steps-xcode-test/pull/12", want: IssueRef{xcodeTest, 12}},
+		{raw: "https://api.github.com/repos/bitrise-io/steps-xcode-test/issues/87", want: IssueRef{xcodeTest, 87}},
+		{raw: "https://api.github.com/repos/bitrise-io/steps-xcode-test/issues/87/comments", want: IssueRef{xcodeTest, 87}},
+		{raw: "https://ghe.example.com/api/v3/repos/team/repo/issues/3", want: IssueRef{Repo{"ghe.example.com", "team", "repo"}, 3}},
+		{raw: "https://github.com/bitrise-io/steps-xcode-test", wantErr: true},
+		{raw: "https://github.com/bitrise-io/steps-xcode-test/issues", wantErr: true},
+		{raw: "https://github.com/bitrise-io/steps-xcode-test/issues/abc", wantErr: true},
+		{raw: "https://github.com/bitrise-io/steps-xcode-test/discussions/5", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseIssueURL(tt.raw)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseIssueURL(%q) error = %v, wantErr %t", tt.raw, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseIssueURL(%q) = %+v, want %+v", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestIssueRefURL(t *testing.T) {
+	ref, err := ParseIssueURL("https://www.github.com/bitrise-io/steps-xcode-test/issues/87/")
+	if err != nil {
+		t.Fatalf("ParseIssueURL() error = %v", err)
+	}
+
+	if got, want := ref.URL(), "https://github.com/bitrise-io/steps-xcode-test/issues/87"; got != want {
+		t.Errorf("URL() = %s, want %s", got, want)
+	}
+	if got, want := ref.FullName(), "bitrise-io/steps-xcode-test"; got != want {
+		t.Errorf("FullName() = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,55 @@
+package github
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/google/go-github/github"
+)
+
+var (
+	usersMu sync.Mutex
+	users   = map[string]*github.User{}
+)
+
+// getUser fetches the profile of the user, profiles are cached for the run.
+func getUser(login string) (*github.User, error) {
+	usersMu.Lock()
+	user, ok := users[login]
+	usersMu.Unlock()
+	if ok {
+		return user, nil
+	}
+
+	user, _, err := client.Users.Get(ctx, login)
+	if err != nil {
+		return nil, fmt.Errorf("get user %s: %s", login, err)
+	}
+
+	usersMu.Lock()
+	users[login] = user
+	usersMu.Unlock()
+	return user, nil
+}
+
+// DisplayName returns the profile name of the user, or the login if the user has no name set.
+// Names are cached for the run.
+func DisplayName(login string) (string, error) {
+	user, err := getUser(login)
+	if err != nil {
+		return login, err
+	}
+	if user.GetName() == "" {
+		return login, nil
+	}
+	return user.GetName(), nil
+}
+
+// PublicEmail returns the public email of the user's profile, empty if the user hides it.
+func PublicEmail(login string) (string, error) {
+	user, err := getUser(login)
+	if err != nil {
+		return "", err
+	}
+	return user.GetEmail(), nil
+}
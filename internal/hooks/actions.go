@@ -0,0 +1,71 @@
+package hooks
+
+import (
+	"fmt"
+
+	gh "github.com/google/go-github/github"
+
+	"github.com/lszucs/github-to-discourse/internal/config"
+)
+
+func init() {
+	Register("webhook", newWebhook)
+	Register("email", newEmail)
+	Register("skip", func(config.HookAction) (Action, error) { return skip{}, nil })
+	Register("redact", func(config.HookAction) (Action, error) { return redact{}, nil })
+}
+
+// webhook posts the issue as JSON to a URL.
+type webhook struct {
+	url string
+}
+
+func newWebhook(a config.HookAction) (Action, error) {
+	if a.URL == "" {
+		return nil, fmt.Errorf("url required")
+	}
+	return webhook{url: a.URL}, nil
+}
+
+func (w webhook) Apply(*gh.Issue, *Result) {}
+
+func (w webhook) Notify(i *gh.Issue) error {
+	return postJSON(w.url, i)
+}
+
+// email sends a notification about the issue through an HTTP mail relay.
+type email struct {
+	url string
+	to  string
+}
+
+func newEmail(a config.HookAction) (Action, error) {
+	if a.URL == "" || a.To == "" {
+		return nil, fmt.Errorf("url and to required")
+	}
+	return email{url: a.URL, to: a.To}, nil
+}
+
+func (e email) Apply(*gh.Issue, *Result) {}
+
+func (e email) Notify(i *gh.Issue) error {
+	return postJSON(e.url, map[string]string{
+		"to":      e.to,
+		"subject": fmt.Sprintf("[github-to-discourse] %s", i.GetTitle()),
+		"text":    fmt.Sprintf("%s was opened by @%s:\n\n%s", i.GetHTMLURL(), i.GetUser().GetLogin(), i.GetBody()),
+	})
+}
+
+// skip leaves the issue out of the migration.
+type skip struct{}
+
+func (skip) Apply(_ *gh.Issue, res *Result) { res.Skip = true }
+
+func (skip) Notify(*gh.Issue) error { return nil }
+
+// redact keeps the issue content off the public topic.
+type redact struct{}
+
+func (redact) Apply(_ *gh.Issue, res *Result) { res.Redact = true }
+
+func (redact) Notify(*gh.Issue) error { return nil }
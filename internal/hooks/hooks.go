@@ -0,0 +1,161 @@
+package hooks
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	gh "github.com/google/go-github/github"
+
+	"github.com/lszucs/github-to-discourse/internal/config"
+)
+
+// Result holds how the hooks of an issue change its migration.
+type Result struct {
+	// Skip leaves the issue out of the migration.
+	Skip bool
+	// Redact keeps the issue body and comments off the public topic.
+	Redact bool
+	// Matched lists the labels of the hooks that ran.
+	Matched []string
+}
+
+// Action is a side effect of a hook.
+type Action interface {
+	// Apply changes how the issue is migrated. It has no side effects and runs on every attempt.
+	Apply(i *gh.Issue, res *Result)
+	// Notify performs the side effects of the action, once per issue.
+	Notify(i *gh.Issue) error
+}
+
+// Factory builds an action from its config.
+type Factory func(a config.HookAction) (Action, error)
+
+var (
+	registry = map[string]Factory{}
+	hooks    []hook
+)
+
+type hook struct {
+	label   string
+	actions []Action
+}
+
+// Register makes an action type available to the config file.
+func Register(typ string, f Factory) {
+	registry[typ] = f
+}
+
+// Types returns the registered action types.
+func Types() []string {
+	var types []string
+	for t := range registry {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Configure builds the hooks of the config file.
+func Configure(cfg config.Config) error {
+	hooks = nil
+	for _, h := range cfg.Hooks {
+		if h.Label == "" {
+			return fmt.Errorf("hook without label")
+		}
+		built := hook{label: h.Label}
+		for _, a := range h.Actions {
+			f, ok := registry[a.Type]
+			if !ok {
+				return fmt.Errorf("hook %s: unknown action %s, available: %s", h.Label, a.Type, Types())
+			}
+			action, err := f(a)
+			if err != nil {
+				return fmt.Errorf("hook %s: %s action: %s", h.Label, a.Type, err)
+			}
+			built.actions = append(built.actions, action)
+		}
+		hooks = append(hooks, built)
+	}
+	return nil
+}
+
+func matching(i *gh.Issue) []hook {
+	var matched []hook
+	for _, h := range hooks {
+		for _, l := range i.Labels {
+			if l.GetName() == h.label {
+				matched = append(matched, h)
+				break
+			}
+		}
+	}
+	return matched
+}
+
+// Evaluate returns how the hooks matching the labels of the issue change its migration.
+func Evaluate(i *gh.Issue) Result {
+	var res Result
+	for _, h := range matching(i) {
+		res.Matched = append(res.Matched, h.label)
+		for _, a := range h.actions {
+			a.Apply(i, &res)
+		}
+	}
+	return res
+}
+
+// Notify performs the side effects of the hooks matching the labels of the issue.
+func Notify(i *gh.Issue) error {
+	for _, h := range matching(i) {
+		for _, a := range h.actions {
+			if err := a.Notify(i); err != nil {
+				return fmt.Errorf("hook %s: %s", h.label, err)
+			}
+		}
+	}
+	return nil
+}
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+func postJSON(url string, v interface{}) error {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("marshal payload: %s", err)
+	}
+	resp, err := httpClient.Post(url, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("post to %s: %s", url, err)
+	}
+	if err := resp.Body.Close(); err != nil {
+		return fmt.Errorf("close response body: %s", err)
+	}
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("post to %s: %s", url, resp.Status)
+	}
+	return nil
+}
+
+// Check returns the violations of the hooks of the config file: unknown action types and actions
+// their factory rejects.
+func Check(cfg config.Config) []config.Violation {
+	var vs []config.Violation
+	for n, h := range cfg.Hooks {
+		for m, a := range h.Actions {
+			p := fmt.Sprintf("hooks[%d].actions[%d]", n, m)
+			f, ok := registry[a.Type]
+			if !ok {
+				vs = append(vs, config.Violation{Path: p + ".type", Message: fmt.Sprintf("unknown action %s, available: %s", a.Type, Types())})
+				continue
+			}
+			if _, err := f(a); err != nil {
+				vs = append(vs, config.Violation{Path: p, Message: fmt.Sprintf("%s action: %s", a.Type, err)})
+			}
+		}
+	}
+	return vs
+}
@@ -0,0 +1,114 @@
+package invites
+
+import (
+	"bytes"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/log"
+	gh "github.com/google/go-github/github"
+
+	"github.com/lszucs/github-to-discourse/internal/github"
+	"github.com/lszucs/github-to-discourse/internal/sealed"
+)
+
+var (
+	path   string
+	groups string
+)
+
+func init() {
+	flag.StringVar(&path, "invites-file", "invites.csv", "--invites-file=<path> (invites: CSV file to write the Discourse bulk invite list to)")
+	flag.StringVar(&groups, "invite-groups", "", "--invite-groups=<group;group> (invites: Discourse groups to add the invited users to, separated by semicolons)")
+}
+
+// Person is a distinct GitHub user who opened or commented on issues in scope.
+type Person struct {
+	Login string
+	// Email is the public email of the profile, empty if hidden.
+	Email string
+}
+
+// bot reports whether the user is an app or a bot account, not to be invited.
+func bot(u *gh.User) bool {
+	return u.GetType() == "Bot" || strings.HasSuffix(u.GetLogin(), "[bot]")
+}
+
+// Collect returns the distinct authors and commenters of the issues ordered by login, with their
+// public email if they have one. Bots are left out.
+func Collect(issues []*gh.Issue) ([]Person, error) {
+	byLogin := map[string]*Person{}
+	add := func(u *gh.User) {
+		if u == nil || u.GetLogin() == "" || bot(u) {
+			return
+		}
+		if _, ok := byLogin[u.GetLogin()]; !ok {
+			byLogin[u.GetLogin()] = &Person{Login: u.GetLogin()}
+		}
+	}
+
+	for _, i := range issues {
+		add(i.GetUser())
+		if i.GetComments() == 0 {
+			continue
+		}
+		comments, err := github.GetComments(i)
+		if err != nil {
+			return nil, fmt.Errorf("get comments of %s: %s", i.GetHTMLURL(), err)
+		}
+		for _, c := range comments {
+			add(c.GetUser())
+		}
+	}
+
+	var logins []string
+	for l := range byLogin {
+		logins = append(logins, l)
+	}
+	sort.Strings(logins)
+
+	var people []Person
+	for _, l := range logins {
+		p := byLogin[l]
+		email, err := github.PublicEmail(l)
+		if err != nil {
+			log.Warnf("email of %s: %s", l, err)
+		}
+		p.Email = email
+		people = append(people, *p)
+	}
+	return people, nil
+}
+
+// Write writes the people to --invites-file in the Discourse bulk invite format (email, groups,
+// topic_id). The login stands in for unknown emails, to be completed before the import; the
+// rows of known emails come first.
+func Write(people []Person) error {
+	sort.SliceStable(people, func(a, b int) bool {
+		return people[a].Email != "" && people[b].Email == ""
+	})
+
+	var b bytes.Buffer
+	w := csv.NewWriter(&b)
+	rows := [][]string{{"email", "groups", "topic_id"}}
+	unknown := 0
+	for _, p := range people {
+		email := p.Email
+		if email == "" {
+			email = p.Login
+			unknown++
+		}
+		rows = append(rows, []string{email, groups, ""})
+	}
+	if err := w.WriteAll(rows); err != nil {
+		return fmt.Errorf("write invites: %s", err)
+	}
+	if err := sealed.WriteFile(path, b.Bytes(), 0644); err != nil {
+		return fmt.Errorf("write %s: %s", path, err)
+	}
+	log.Printf("wrote %d users to %s, %d without public email (login as placeholder)", len(people), path, unknown)
+	return nil
+}
@@ -0,0 +1,131 @@
+package linkcheck
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/bitrise-io/go-utils/log"
+
+	"github.com/lszucs/github-to-discourse/internal/discourse"
+	"github.com/lszucs/github-to-discourse/internal/github"
+	"github.com/lszucs/github-to-discourse/internal/state"
+	"github.com/lszucs/github-to-discourse/internal/templates"
+)
+
+var (
+	sample   int
+	fixLinks bool
+)
+
+func init() {
+	flag.IntVar(&sample, "check-sample", 0, "--check-sample=<int> (check-links: check this many randomly picked topic links, 0 checks every link)")
+	flag.BoolVar(&fixLinks, "fix-links", false, "--fix-links (check-links: point the comments of dead links to a live topic of the issue, found by searching Discourse for the issue URL, in live mode)")
+}
+
+// Run checks that the topics recorded in the state file, which the GitHub comments link to, are
+// still alive, and returns an error if any is dead. With --fix-links the comments of dead links
+// are corrected when a live topic of the issue is found, only if live is set.
+func Run(live bool) error {
+	var linked []*state.Record
+	for _, rec := range state.All() {
+		if rec.TopicURL != "" && rec.IsDone(state.StepComment) {
+			linked = append(linked, rec)
+		}
+	}
+	if sample > 0 && sample < len(linked) {
+		r := rand.New(rand.NewSource(time.Now().UnixNano()))
+		r.Shuffle(len(linked), func(i, j int) { linked[i], linked[j] = linked[j], linked[i] })
+		linked = linked[:sample]
+	}
+	log.Printf("check %d topic links", len(linked))
+
+	var dead, fixed int
+	for _, rec := range linked {
+		id, err := discourse.KnownTopicID(rec.TopicID, rec.TopicURL)
+		if err != nil {
+			return err
+		}
+		alive, err := discourse.TopicAlive(id)
+		if err != nil {
+			return fmt.Errorf("check %s: %s", rec.TopicURL, err)
+		}
+		if alive {
+			continue
+		}
+		dead++
+		log.Warnf("dead link: %s links %s", rec.IssueURL, rec.TopicURL)
+		if !fixLinks {
+			continue
+		}
+
+		ok, err := fix(rec, live)
+		if err != nil {
+			log.Errorf("%s", err)
+			continue
+		}
+		if ok {
+			fixed++
+		}
+	}
+
+	if fixed > 0 && live {
+		if err := state.Save(); err != nil {
+			return err
+		}
+	}
+	if dead > fixed {
+		return fmt.Errorf("%d of %d checked topic links are dead, %d fixed", dead, len(linked), fixed)
+	}
+	log.Successf("%d topic links checked, %d fixed", len(linked), fixed)
+	return nil
+}
+
+// fix points the comment of the record to a live topic of the issue. It reports whether there is one.
+func fix(rec *state.Record, live bool) (bool, error) {
+	topic, ok, err := discourse.FindTopic(rec.IssueURL)
+	if err != nil {
+		return false, err
+	}
+	if !ok {
+		log.Warnf("no live topic of %s found, migrate it again with cleanup --run-id=%s", rec.IssueURL, rec.RunID)
+		return false, nil
+	}
+	if !live {
+		log.Printf("would point the comment of %s to %s", rec.IssueURL, topic.URL)
+		return true, nil
+	}
+
+	rec = state.Get(rec.IssueURL)
+	i, err := github.GetIssue(rec.IssueURL)
+	if err != nil {
+		return false, err
+	}
+	data := templates.NewData(i)
+	data.TopicURL = topic.URL
+	comment, err := templates.Execute(templates.Active, data)
+	if err != nil {
+		return false, err
+	}
+
+	if rec.CommentID != 0 {
+		ref, err := github.ParseIssueURL(rec.IssueURL)
+		if err != nil {
+			return false, err
+		}
+		if err := github.EditComment(ref, rec.CommentID, comment); err != nil {
+			return false, err
+		}
+	} else {
+		// comments posted before comment IDs were recorded are posted again
+		if rec.CommentID, _, err = github.PostComment(i, comment); err != nil {
+			return false, fmt.Errorf("post comment to %s: %s", rec.IssueURL, err)
+		}
+	}
+	log.Printf("pointed the comment of %s to %s", rec.IssueURL, topic.URL)
+	rec.TopicURL = topic.URL
+	rec.TopicID, rec.PostID = topic.TopicID, 0
+	rec.Identity, rec.PostedAs = "", ""
+	return true, nil
+}
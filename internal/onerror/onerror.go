@@ -0,0 +1,106 @@
+package onerror
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/lszucs/github-to-discourse/internal/state"
+)
+
+const (
+	Skip  = "skip"
+	Abort = "abort"
+	Pause = "pause"
+)
+
+var (
+	policy string
+	// mu serializes the errors of the pipeline queues, so a single pause asks at a time and the
+	// other queue waits for the answer too.
+	mu sync.Mutex
+	// stdin is shared by the pauses, a reader of their own could swallow the answer of the next.
+	stdin *bufio.Reader
+)
+
+func init() {
+	flag.StringVar(&policy, "on-error", Abort, "--on-error=skip|abort|pause (skip: log and go on, abort: stop the run, pause: save state and wait for operator input)")
+}
+
+// Validate returns an error if the configured policy is unknown.
+func Validate() error {
+	switch policy {
+	case Skip, Abort, Pause:
+		return nil
+	default:
+		return fmt.Errorf("not recognized error policy %s", policy)
+	}
+}
+
+// Handle applies the configured policy to err. A nil return value means the run can go on. It is
+// safe for concurrent use, the calls are handled one at a time.
+func Handle(err error) error {
+	if err == nil {
+		return nil
+	}
+	mu.Lock()
+	defer mu.Unlock()
+
+	switch policy {
+	case Skip:
+		log.Warnf("skip: %s", err)
+		return nil
+	case Pause:
+		return pause(err)
+	default:
+		return err
+	}
+}
+
+// Interactive reports whether the standard input is a terminal, so the user can be asked.
+func Interactive() bool {
+	fi, err := os.Stdin.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
+
+func pause(err error) error {
+	log.Errorf("error: %s", err)
+	// dry runs don't load the state, saving it would overwrite the state file with nothing
+	prompt := "run paused. [c]ontinue or [a]bort? "
+	if state.Loaded() {
+		if serr := state.Save(); serr != nil {
+			return fmt.Errorf("%s; save state: %s", err, serr)
+		}
+		prompt = "run paused, state saved. [c]ontinue or [a]bort? "
+	}
+
+	if !Interactive() {
+		log.Warnf("not an interactive session, abort")
+		return err
+	}
+
+	if stdin == nil {
+		stdin = bufio.NewReader(os.Stdin)
+	}
+	for {
+		fmt.Print(prompt)
+		answer, rerr := stdin.ReadString('\n')
+		if rerr != nil {
+			return err
+		}
+
+		switch strings.TrimSpace(strings.ToLower(answer)) {
+		case "c", "continue":
+			return nil
+		case "a", "abort":
+			return err
+		}
+	}
+}
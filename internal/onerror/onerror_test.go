@@ -0,0 +1,47 @@
+package onerror
+
+import (
+	"errors"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPauseInDryRunKeepsStateFile(t *testing.T) {
+	const content = `[{"issue_url": "https://github.com/octo/repo/issues/7", "done": ["discourse"], "updated_at": "2020-01-02T00:00:00Z"}]`
+	pth := filepath.Join(t.TempDir(), "state.json")
+	if err := ioutil.WriteFile(pth, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for name, value := range map[string]string{"state-file": pth, "on-error": Pause} {
+		old := flag.Lookup(name).Value.String()
+		if err := flag.Set(name, value); err != nil {
+			t.Fatal(err)
+		}
+		defer flag.Set(name, old)
+	}
+	// not a terminal, the pause aborts without asking
+	stdin := os.Stdin
+	devNull, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer devNull.Close()
+	os.Stdin = devNull
+	defer func() { os.Stdin = stdin }()
+
+	fetchErr := errors.New("fetch issues of octo/repo: 502")
+	if err := Handle(fetchErr); err != fetchErr {
+		t.Errorf("Handle = %v, want %s", err, fetchErr)
+	}
+
+	got, err := ioutil.ReadFile(pth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != content {
+		t.Errorf("state file = %s, want it unchanged", got)
+	}
+}
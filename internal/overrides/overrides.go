@@ -0,0 +1,123 @@
+package overrides
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/lszucs/github-to-discourse/internal/github"
+)
+
+// Forced outcomes of Override.Treat.
+const (
+	Active = "active"
+	Stale  = "stale"
+)
+
+// Override forces the decisions about an issue which are otherwise made by the heuristics.
+// Empty fields are left to the heuristics.
+type Override struct {
+	// Treat is active or stale, regardless of the last update of the issue.
+	Treat string `json:"treat,omitempty"`
+	// CategoryID is the Discourse category the topic is posted to.
+	CategoryID int `json:"category_id,omitempty"`
+	// Active, Stale and Footer replace the templates of the issue.
+	Active string `json:"active,omitempty"`
+	Stale  string `json:"stale,omitempty"`
+	Footer string `json:"footer,omitempty"`
+}
+
+var (
+	path string
+	// overrides are keyed by the match key of their issue URL, urls maps the keys to the URLs as written.
+	overrides = map[string]Override{}
+	urls      = map[string]string{}
+)
+
+func init() {
+	flag.StringVar(&path, "overrides-file", "", "--overrides-file=<path> (JSON file mapping issue URLs to forced decisions: treat as active|stale, category_id, active|stale|footer templates)")
+}
+
+// Load reads the overrides file given by --overrides-file, if any.
+func Load() error {
+	if path == "" {
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read overrides file %s: %s", path, err)
+	}
+	loaded := map[string]Override{}
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		return fmt.Errorf("unmarshal overrides file %s: %s", path, err)
+	}
+	return set(loaded)
+}
+
+// set replaces the overrides with the ones of the issue URLs.
+func set(loaded map[string]Override) error {
+	byKey, written := map[string]Override{}, map[string]string{}
+	for url, o := range loaded {
+		if o.Treat != "" && o.Treat != Active && o.Treat != Stale {
+			return fmt.Errorf("override of %s: treat must be %s or %s, got %s", url, Active, Stale, o.Treat)
+		}
+		k, err := key(url)
+		if err != nil {
+			return fmt.Errorf("override of %s: %s", url, err)
+		}
+		if other, ok := written[k]; ok {
+			return fmt.Errorf("override of %s: %s is the same issue", url, other)
+		}
+		byKey[k], written[k] = o, url
+	}
+	overrides, urls = byKey, written
+	return nil
+}
+
+// key returns the match key of the issue URL: its canonical web URL in lower case, as GitHub owner
+// and repo names are case insensitive. API, scheme-less and trailing slash forms of the URL match.
+func key(issueURL string) (string, error) {
+	ref, err := github.ParseIssueURL(issueURL)
+	if err != nil {
+		return "", err
+	}
+	return strings.ToLower(ref.URL()), nil
+}
+
+// Get returns the override of the issue, if there is one.
+func Get(issueURL string) (Override, bool) {
+	k, err := key(issueURL)
+	if err != nil {
+		return Override{}, false
+	}
+	o, ok := overrides[k]
+	return o, ok
+}
+
+// URLs returns the overridden issue URLs, as written in the overrides file, in order.
+func URLs() []string {
+	var written []string
+	for _, url := range urls {
+		written = append(written, url)
+	}
+	sort.Strings(written)
+	return written
+}
+
+// Categories returns the categories forced by the overrides.
+func Categories() []int {
+	var ids []int
+	seen := map[int]bool{}
+	for _, url := range URLs() {
+		o, _ := Get(url)
+		if id := o.CategoryID; id != 0 && !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
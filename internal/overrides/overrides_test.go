@@ -0,0 +1,99 @@
+package overrides
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// load loads the overrides file with the content for the test.
+func load(t *testing.T, content string) error {
+	pth := filepath.Join(t.TempDir(), "overrides.json")
+	if err := ioutil.WriteFile(pth, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	oldPath := path
+	path = pth
+	t.Cleanup(func() {
+		path = oldPath
+		overrides, urls = map[string]Override{}, map[string]string{}
+	})
+	return Load()
+}
+
+func TestGet(t *testing.T) {
+	const content = `{
+  "https://github.com/octo/repo/issues/7": {"treat": "stale", "category_id": 11},
+  "github.com/Octo/Lib/issues/3/": {"treat": "active"}
+}`
+	tests := []struct {
+		name      string
+		issueURL  string
+		wantTreat string
+		wantOK    bool
+	}{
+		{name: "as written", issueURL: "https://github.com/octo/repo/issues/7", wantTreat: Stale, wantOK: true},
+		{name: "API URL", issueURL: "https://api.github.com/repos/octo/repo/issues/7", wantTreat: Stale, wantOK: true},
+		{name: "other case", issueURL: "https://github.com/OCTO/Repo/issues/7", wantTreat: Stale, wantOK: true},
+		{name: "trailing slash", issueURL: "https://github.com/octo/repo/issues/7/", wantTreat: Stale, wantOK: true},
+		{name: "written without scheme and in other case", issueURL: "https://github.com/octo/lib/issues/3", wantTreat: Active, wantOK: true},
+		{name: "other issue", issueURL: "https://github.com/octo/repo/issues/8"},
+		{name: "other repo", issueURL: "https://github.com/octo/other/issues/7"},
+		{name: "same path on another host", issueURL: "https://ghe.example.com/octo/repo/issues/7"},
+		{name: "issue number prefix", issueURL: "https://github.com/octo/repo/issues/70"},
+		{name: "not an issue URL", issueURL: "https://github.com/octo/repo"},
+	}
+
+	if err := load(t, content); err != nil {
+		t.Fatalf("Load: %s", err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o, ok := Get(tt.issueURL)
+			if ok != tt.wantOK || o.Treat != tt.wantTreat {
+				t.Errorf("Get(%s) = %+v, %t, want treat %q, %t", tt.issueURL, o, ok, tt.wantTreat, tt.wantOK)
+			}
+		})
+	}
+
+	if got := strings.Join(URLs(), " "); got != "github.com/Octo/Lib/issues/3/ https://github.com/octo/repo/issues/7" {
+		t.Errorf("URLs = %s, want the URLs as written", got)
+	}
+	if got := Categories(); len(got) != 1 || got[0] != 11 {
+		t.Errorf("Categories = %v, want [11]", got)
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{name: "not JSON", content: `{"https://github.com/octo/repo/issues/7": `, wantErr: "unmarshal"},
+		{name: "unknown treat", content: `{"https://github.com/octo/repo/issues/7": {"treat": "closed"}}`, wantErr: "treat must be"},
+		{name: "not an issue URL", content: `{"https://github.com/octo/repo": {"treat": "stale"}}`, wantErr: "not an issue URL"},
+		{
+			name:    "same issue twice",
+			content: `{"https://github.com/octo/repo/issues/7": {"treat": "stale"}, "https://github.com/Octo/Repo/issues/7": {"treat": "active"}}`,
+			wantErr: "is the same issue",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := load(t, tt.content); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Load: error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadWithoutFile(t *testing.T) {
+	if err := Load(); err != nil {
+		t.Errorf("Load without --overrides-file: %s", err)
+	}
+	if _, ok := Get("https://github.com/octo/repo/issues/7"); ok {
+		t.Error("Get without overrides found one")
+	}
+}
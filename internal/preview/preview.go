@@ -0,0 +1,78 @@
+package preview
+
+import (
+	"flag"
+	"html/template"
+	"net/http"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/log"
+
+	"github.com/lszucs/github-to-discourse/internal/github"
+	"github.com/lszucs/github-to-discourse/internal/runmode"
+)
+
+var listen string
+
+func init() {
+	flag.StringVar(&listen, "preview-listen", "localhost:8090", "--preview-listen=<addr> (address of the templates serve preview UI)")
+}
+
+var page = template.Must(template.New("preview").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Template preview</title>
+<style>body{max-width:60em;margin:2em auto;font-family:sans-serif}input[type=text]{width:40em}pre{white-space:pre-wrap;background:#f6f8fa;padding:1em}.error{color:#b00}</style>
+</head>
+<body>
+<h1>Template preview</h1>
+<form>
+<input type="text" name="issue" placeholder="https://github.com/owner/repo/issues/1" value="{{.IssueURL}}">
+<input type="submit" value="Preview">
+</form>
+{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+{{with .Preview}}
+<ul>{{range .Notes}}<li>{{.}}</li>{{end}}</ul>
+{{if .Title}}<h2>Discourse topic</h2>
+<h3>{{.Title}}</h3>
+<pre>{{.Raw}}</pre>{{end}}
+{{if .Comment}}<h2>GitHub comment ({{.Template}})</h2>
+<pre>{{.Comment}}</pre>{{end}}
+{{end}}
+</body>
+</html>
+`))
+
+type view struct {
+	IssueURL string
+	Error    string
+	Preview  *runmode.Preview
+}
+
+func handler(w http.ResponseWriter, r *http.Request) {
+	v := view{IssueURL: strings.TrimSpace(r.URL.Query().Get("issue"))}
+	if v.IssueURL != "" {
+		if i, err := github.GetIssue(v.IssueURL); err != nil {
+			v.Error = err.Error()
+		} else if p, err := runmode.PreviewIssue(i); err != nil {
+			v.Error = err.Error()
+		} else {
+			v.Preview = &p
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := page.Execute(w, v); err != nil {
+		log.Warnf("render preview: %s", err)
+	}
+}
+
+// Serve runs the preview UI: operators paste an issue URL and see the topic and the GitHub comment
+// rendered with the current flags and config. Templates are read at startup, restart it after changes.
+func Serve() error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", handler)
+	log.Infof("template preview on http://%s", listen)
+	return http.ListenAndServe(listen, mux)
+}
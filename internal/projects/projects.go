@@ -0,0 +1,78 @@
+package projects
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/bitrise-io/go-utils/log"
+
+	"github.com/lszucs/github-to-discourse/internal/github"
+	"github.com/lszucs/github-to-discourse/internal/state"
+)
+
+const defaultTitle = "GitHub to Discourse migration"
+
+var (
+	owner       string
+	number      int
+	statusField string
+)
+
+func init() {
+	flag.StringVar(&owner, "project-owner", "", "--project-owner=<login> (organization or user owning the GitHub project to export the state to)")
+	flag.IntVar(&number, "project-number", 0, "--project-number=<int> (number of the GitHub project to export the state to, 0 creates a new project)")
+	flag.StringVar(&statusField, "project-status-field", "Status", "--project-status-field=<name> (single select project field holding the pipeline stage)")
+}
+
+// Export adds every issue of the state file to a GitHub Projects (v2) board and sets its status
+// field to the pipeline stage of the issue. The status field needs an option per stage.
+func Export() error {
+	if owner == "" {
+		return fmt.Errorf("--project-owner not set")
+	}
+
+	var p github.Project
+	var err error
+	if number == 0 {
+		log.Printf("create project %s", defaultTitle)
+		p, err = github.CreateProject(owner, defaultTitle, statusField)
+	} else {
+		p, err = github.GetProject(owner, number, statusField)
+	}
+	if err != nil {
+		return err
+	}
+	if p.StatusFieldID == "" {
+		return fmt.Errorf("project %s has no single select field %s", p.URL, statusField)
+	}
+
+	var missing []string
+	for _, s := range state.Stages {
+		if _, ok := p.StatusOptions[s]; !ok {
+			missing = append(missing, s)
+		}
+	}
+	if len(missing) > 0 {
+		log.Warnf("field %s of %s has no options %v, items in these stages get no status", statusField, p.URL, missing)
+	}
+
+	records := state.All()
+	for _, r := range records {
+		itemID, err := github.AddProjectItem(p, r.IssueURL)
+		if err != nil {
+			return err
+		}
+
+		stage := r.Stage()
+		if _, ok := p.StatusOptions[stage]; !ok {
+			continue
+		}
+		log.Printf("%s: %s", r.IssueURL, stage)
+		if err := github.SetProjectItemStatus(p, itemID, stage); err != nil {
+			return fmt.Errorf("set status of %s: %s", r.IssueURL, err)
+		}
+	}
+
+	log.Successf("exported %d issues to %s", len(records), p.URL)
+	return nil
+}
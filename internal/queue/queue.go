@@ -0,0 +1,284 @@
+package queue
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	logFile = "queue.log"
+	posFile = "queue.pos"
+)
+
+// Entry is a queued item.
+type Entry struct {
+	Seq  int64           `json:"seq"`
+	Data json.RawMessage `json:"data"`
+}
+
+// Queue is a durable FIFO queue safe for concurrent use. Entries are appended to a log file
+// and the position below which every entry is acknowledged is persisted, so unacknowledged
+// entries are handed out again after a restart.
+//
+// Each file is changed atomically: an entry is a single synced line of the log, and a line torn
+// by a crash is dropped when the queue is opened again; the log is compacted and the position is
+// written to a temporary file renamed over the previous one. The position only moves past entries
+// the log holds, so a crash between the two leaves entries to hand out again, never lost ones.
+type Queue struct {
+	dir string
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	log     *os.File
+	next    int64
+	pos     int64
+	pending []Entry
+	acked   map[int64]bool
+	closed  bool
+}
+
+// Open opens the queue persisted in dir, creating it if needed.
+func Open(dir string) (*Queue, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("create queue dir %s: %s", dir, err)
+	}
+
+	q := &Queue{
+		dir:   dir,
+		acked: map[int64]bool{},
+	}
+	q.cond = sync.NewCond(&q.mu)
+
+	pos, err := readPos(filepath.Join(dir, posFile))
+	if err != nil {
+		return nil, err
+	}
+	q.pos = pos
+	q.next = pos
+
+	entries, err := readLog(filepath.Join(dir, logFile))
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		if e.Seq < pos {
+			continue
+		}
+		q.pending = append(q.pending, e)
+		if e.Seq >= q.next {
+			q.next = e.Seq + 1
+		}
+	}
+
+	// compact the log to the unacknowledged entries
+	if err := q.rewriteLog(); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+func readPos(pth string) (int64, error) {
+	b, err := ioutil.ReadFile(pth)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("read queue position %s: %s", pth, err)
+	}
+
+	pos, err := strconv.ParseInt(strings.TrimSpace(string(b)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parse queue position %s: %s", pth, err)
+	}
+	return pos, nil
+}
+
+func readLog(pth string) ([]Entry, error) {
+	f, err := os.Open(pth)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open queue log %s: %s", pth, err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			// a partially written last line after a crash
+			continue
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read queue log %s: %s", pth, err)
+	}
+	return entries, nil
+}
+
+func (q *Queue) rewriteLog() error {
+	pth := filepath.Join(q.dir, logFile)
+	tmp := pth + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("create queue log %s: %s", tmp, err)
+	}
+	for _, e := range q.pending {
+		if err := writeEntry(f, e); err != nil {
+			_ = f.Close()
+			return err
+		}
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("sync queue log %s: %s", tmp, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("close queue log %s: %s", tmp, err)
+	}
+	if err := os.Rename(tmp, pth); err != nil {
+		return fmt.Errorf("replace queue log %s: %s", pth, err)
+	}
+
+	q.log, err = os.OpenFile(pth, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("open queue log %s: %s", pth, err)
+	}
+	return nil
+}
+
+func writeEntry(f *os.File, e Entry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("marshal queue entry: %s", err)
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		return fmt.Errorf("write queue entry: %s", err)
+	}
+	return f.Sync()
+}
+
+// Push durably appends data to the queue.
+func (q *Queue) Push(data []byte) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return fmt.Errorf("queue closed")
+	}
+
+	e := Entry{Seq: q.next, Data: data}
+	if err := writeEntry(q.log, e); err != nil {
+		return err
+	}
+	q.next++
+	q.pending = append(q.pending, e)
+	q.cond.Signal()
+	return nil
+}
+
+// Pop blocks until an entry is available and returns it. It returns false once the queue is closed.
+// Popped entries must be acknowledged by Ack or put back by Retry.
+func (q *Queue) Pop() (Entry, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for len(q.pending) == 0 && !q.closed {
+		q.cond.Wait()
+	}
+	if q.closed {
+		return Entry{}, false
+	}
+
+	e := q.pending[0]
+	q.pending = q.pending[1:]
+	return e, true
+}
+
+// Ack marks the entry as processed and persists the new queue position.
+func (q *Queue) Ack(seq int64) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.acked[seq] = true
+	moved := false
+	for q.acked[q.pos] {
+		delete(q.acked, q.pos)
+		q.pos++
+		moved = true
+	}
+	if !moved {
+		return nil
+	}
+
+	return writePos(filepath.Join(q.dir, posFile), q.pos)
+}
+
+// writePos replaces the position file, a crash leaves the previous position.
+func writePos(pth string, pos int64) error {
+	tmp := pth + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("create queue position %s: %s", tmp, err)
+	}
+	if _, err := f.WriteString(strconv.FormatInt(pos, 10)); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("write queue position %s: %s", tmp, err)
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("sync queue position %s: %s", tmp, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("close queue position %s: %s", tmp, err)
+	}
+	if err := os.Rename(tmp, pth); err != nil {
+		return fmt.Errorf("replace queue position %s: %s", pth, err)
+	}
+	return nil
+}
+
+// Retry puts the entry back to the end of the queue after the given delay.
+func (q *Queue) Retry(e Entry, after time.Duration) {
+	time.AfterFunc(after, func() {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+
+		if q.closed {
+			return
+		}
+		q.pending = append(q.pending, e)
+		q.cond.Signal()
+	})
+}
+
+// Len returns the number of entries waiting to be popped.
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.pending)
+}
+
+// Close wakes up the blocked Pop calls and closes the log file.
+func (q *Queue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.closed = true
+	q.cond.Broadcast()
+	return q.log.Close()
+}
@@ -0,0 +1,205 @@
+package queue
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func openQueue(t *testing.T, dir string) *Queue {
+	t.Helper()
+	q, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open: %s", err)
+	}
+	return q
+}
+
+func push(t *testing.T, q *Queue, items ...string) {
+	t.Helper()
+	for _, item := range items {
+		if err := q.Push([]byte(`"` + item + `"`)); err != nil {
+			t.Fatalf("Push %s: %s", item, err)
+		}
+	}
+}
+
+// pop pops n entries, failing the test if they don't come within a second.
+func pop(t *testing.T, q *Queue, n int) []Entry {
+	t.Helper()
+	var entries []Entry
+	for len(entries) < n {
+		popped := make(chan Entry, 1)
+		go func() {
+			if e, ok := q.Pop(); ok {
+				popped <- e
+			}
+		}()
+		select {
+		case e := <-popped:
+			entries = append(entries, e)
+		case <-time.After(time.Second):
+			t.Fatalf("popped %d entries, want %d", len(entries), n)
+		}
+	}
+	return entries
+}
+
+func data(entries []Entry) []string {
+	var items []string
+	for _, e := range entries {
+		items = append(items, string(e.Data))
+	}
+	return items
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestPushPop(t *testing.T) {
+	q := openQueue(t, t.TempDir())
+	defer q.Close()
+
+	push(t, q, "a", "b", "c")
+	if q.Len() != 3 {
+		t.Errorf("Len = %d, want 3", q.Len())
+	}
+	entries := pop(t, q, 3)
+	if got, want := data(entries), []string{`"a"`, `"b"`, `"c"`}; !equal(got, want) {
+		t.Errorf("popped %v, want %v", got, want)
+	}
+	for n, e := range entries {
+		if e.Seq != int64(n) {
+			t.Errorf("entry %d has seq %d", n, e.Seq)
+		}
+	}
+	if q.Len() != 0 {
+		t.Errorf("Len = %d after popping every entry, want 0", q.Len())
+	}
+}
+
+func TestReopen(t *testing.T) {
+	tests := []struct {
+		name string
+		// acked are the seqs acknowledged before the crash
+		acked []int64
+		// torn is appended to the log, as a crash in the middle of a write leaves it
+		torn string
+		// leftover is written to the temporary position file, as a crash before its rename leaves it
+		leftover string
+		want     []string
+	}{
+		{name: "nothing acknowledged", want: []string{`"a"`, `"b"`, `"c"`}},
+		{name: "first acknowledged", acked: []int64{0}, want: []string{`"b"`, `"c"`}},
+		// the position stays below the unacknowledged entry, the acknowledged one after it is handed out again
+		{name: "out of order", acked: []int64{1}, want: []string{`"a"`, `"b"`, `"c"`}},
+		{name: "every entry acknowledged", acked: []int64{0, 1, 2}},
+		{name: "torn last line", acked: []int64{0}, torn: `{"seq": 3, "da`, want: []string{`"b"`, `"c"`}},
+		{name: "position left half written", acked: []int64{0}, leftover: "2", want: []string{`"b"`, `"c"`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			q := openQueue(t, dir)
+			push(t, q, "a", "b", "c")
+			pop(t, q, 3)
+			for _, seq := range tt.acked {
+				if err := q.Ack(seq); err != nil {
+					t.Fatalf("Ack %d: %s", seq, err)
+				}
+			}
+			if tt.torn != "" {
+				f, err := os.OpenFile(filepath.Join(dir, logFile), os.O_APPEND|os.O_WRONLY, 0644)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if _, err := f.WriteString(tt.torn); err != nil {
+					t.Fatal(err)
+				}
+				f.Close()
+			}
+			if tt.leftover != "" {
+				if err := ioutil.WriteFile(filepath.Join(dir, posFile+".tmp"), []byte(tt.leftover), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			// the crash: the queue is opened again without closing it
+
+			reopened := openQueue(t, dir)
+			defer reopened.Close()
+			if got := data(pop(t, reopened, len(tt.want))); !equal(got, tt.want) {
+				t.Errorf("handed out again %v, want %v", got, tt.want)
+			}
+			if reopened.Len() != 0 {
+				t.Errorf("Len = %d after the entries left, want 0", reopened.Len())
+			}
+
+			// new entries are appended after the ones left, with new seqs
+			push(t, reopened, "d")
+			if e := pop(t, reopened, 1)[0]; string(e.Data) != `"d"` || e.Seq != 3 {
+				t.Errorf("pushed after reopening: %s with seq %d, want \"d\" with seq 3", e.Data, e.Seq)
+			}
+		})
+	}
+}
+
+func TestRetryOrder(t *testing.T) {
+	q := openQueue(t, t.TempDir())
+	defer q.Close()
+
+	push(t, q, "a", "b")
+	a := pop(t, q, 1)[0]
+	q.Retry(a, 0)
+	deadline := time.Now().Add(time.Second)
+	for q.Len() < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	push(t, q, "c")
+	// a is back behind b, and ahead of c pushed after it came back
+	if got, want := data(pop(t, q, 3)), []string{`"b"`, `"a"`, `"c"`}; !equal(got, want) {
+		t.Errorf("popped %v, want %v", got, want)
+	}
+
+	push(t, q, "d")
+	d := pop(t, q, 1)[0]
+	q.Retry(d, 100*time.Millisecond)
+	push(t, q, "e")
+	if got := data(pop(t, q, 1)); !equal(got, []string{`"e"`}) {
+		t.Errorf("popped %v before the retry delay passed, want \"e\"", got)
+	}
+	if got := data(pop(t, q, 1)); !equal(got, []string{`"d"`}) {
+		t.Errorf("popped %v after the retry delay, want \"d\"", got)
+	}
+}
+
+func TestRetryAfterClose(t *testing.T) {
+	q := openQueue(t, t.TempDir())
+	push(t, q, "a")
+	a := pop(t, q, 1)[0]
+	if err := q.Close(); err != nil {
+		t.Fatalf("Close: %s", err)
+	}
+	q.Retry(a, 0)
+	time.Sleep(50 * time.Millisecond)
+	if q.Len() != 0 {
+		t.Errorf("Len = %d, want the retry of a closed queue dropped", q.Len())
+	}
+	if _, ok := q.Pop(); ok {
+		t.Error("Pop of a closed queue returned an entry")
+	}
+	if err := q.Push([]byte(`"b"`)); err == nil {
+		t.Error("Push to a closed queue succeeded")
+	}
+}
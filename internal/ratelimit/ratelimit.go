@@ -0,0 +1,128 @@
+package ratelimit
+
+import (
+	"context"
+	"flag"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/lszucs/github-to-discourse/internal/budget"
+)
+
+var (
+	rpm = map[string]*int{budget.GitHub: new(int), budget.Discourse: new(int)}
+
+	mu      sync.Mutex
+	buckets = map[string]*bucket{}
+
+	// now and sleep are the clock of the package, replaced by the tests.
+	now   = time.Now
+	sleep = func(ctx context.Context, d time.Duration) error {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+)
+
+func init() {
+	flag.IntVar(rpm[budget.GitHub], "github-rpm", 0, "--github-rpm=<int> (maximum GitHub API requests per minute, shared by every worker; 0 is unlimited)")
+	flag.IntVar(rpm[budget.Discourse], "discourse-rpm", 0, "--discourse-rpm=<int> (maximum Discourse API requests per minute, shared by every worker; 0 is unlimited)")
+}
+
+// RPM returns the requests per minute allowed to the service, 0 if unlimited.
+func RPM(service string) int {
+	if limit, ok := rpm[service]; ok {
+		return *limit
+	}
+	return 0
+}
+
+// bucket is a token bucket refilled at a constant rate, holding at most a second worth of tokens.
+type bucket struct {
+	mu       sync.Mutex
+	interval time.Duration
+	burst    float64
+	tokens   float64
+	last     time.Time
+}
+
+func newBucket(rpm int) *bucket {
+	burst := float64(rpm) / 60
+	if burst < 1 {
+		burst = 1
+	}
+	return &bucket{interval: time.Minute / time.Duration(rpm), burst: burst, tokens: burst, last: now()}
+}
+
+// reserve takes a token and returns how long to wait before using it.
+func (b *bucket) reserve() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	t := now()
+	b.tokens += float64(t.Sub(b.last)) / float64(b.interval)
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = t
+
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens * float64(b.interval))
+}
+
+// bucketOf returns the bucket shared by the requests of the service to host, nil if unlimited.
+func bucketOf(service, host string) *bucket {
+	limit, ok := rpm[service]
+	if !ok || *limit <= 0 {
+		return nil
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	b, ok := buckets[host]
+	if !ok {
+		b = newBucket(*limit)
+		buckets[host] = b
+	}
+	return b
+}
+
+// Transport delays the requests to a host so every client of the service stays within its
+// requests per minute.
+type Transport struct {
+	// Service is budget.GitHub or budget.Discourse, it selects the limit.
+	Service string
+	// Base is the underlying transport, http.DefaultTransport if nil.
+	Base http.RoundTripper
+}
+
+func (t *Transport) base() http.RoundTripper {
+	if t.Base == nil {
+		return http.DefaultTransport
+	}
+	return t.Base
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if b := bucketOf(t.Service, req.URL.Host); b != nil {
+		if wait := b.reserve(); wait > 0 {
+			if err := sleep(req.Context(), wait); err != nil {
+				if req.Body != nil {
+					req.Body.Close()
+				}
+				return nil, err
+			}
+		}
+	}
+	return t.base().RoundTrip(req)
+}
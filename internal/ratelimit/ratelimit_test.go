@@ -0,0 +1,176 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/lszucs/github-to-discourse/internal/budget"
+)
+
+// fakeClock is a clock advanced by its sleeps and by the tests only.
+type fakeClock struct {
+	t      time.Time
+	sleeps []time.Duration
+}
+
+// useFakeClock replaces the clock of the package for the test.
+func useFakeClock(t *testing.T) *fakeClock {
+	c := &fakeClock{t: testNow}
+	oldNow, oldSleep := now, sleep
+	now = func() time.Time { return c.t }
+	sleep = func(ctx context.Context, d time.Duration) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		c.sleeps = append(c.sleeps, d)
+		c.t = c.t.Add(d)
+		return nil
+	}
+	t.Cleanup(func() { now, sleep = oldNow, oldSleep })
+	return c
+}
+
+// reserveStep advances the clock by idle, then reserves a token expecting to wait want.
+type reserveStep struct{ idle, want time.Duration }
+
+func TestBucketReserve(t *testing.T) {
+	tests := []struct {
+		name  string
+		rpm   int
+		steps []reserveStep
+	}{
+		{
+			name:  "a request per second",
+			rpm:   60,
+			steps: []reserveStep{{0, 0}, {0, time.Second}, {0, 2 * time.Second}, {2 * time.Second, time.Second}},
+		},
+		{
+			name:  "a second worth of burst",
+			rpm:   600,
+			steps: []reserveStep{{0, 0}, {0, 0}, {0, 0}, {0, 0}, {0, 0}, {0, 0}, {0, 0}, {0, 0}, {0, 0}, {0, 0}, {0, 100 * time.Millisecond}},
+		},
+		{
+			name:  "burst of one below a request per second",
+			rpm:   30,
+			steps: []reserveStep{{0, 0}, {0, 2 * time.Second}, {3 * time.Second, time.Second}},
+		},
+		{
+			name: "idle refill is capped at the burst",
+			rpm:  120,
+			// the burst is 2 tokens however long the bucket was idle
+			steps: []reserveStep{{0, 0}, {0, 0}, {time.Hour, 0}, {0, 0}, {0, 500 * time.Millisecond}},
+		},
+		{
+			name:  "partial refill",
+			rpm:   60,
+			steps: []reserveStep{{0, 0}, {250 * time.Millisecond, 750 * time.Millisecond}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := useFakeClock(t)
+			b := newBucket(tt.rpm)
+			for n, s := range tt.steps {
+				c.t = c.t.Add(s.idle)
+				if got := b.reserve(); got != s.want {
+					t.Errorf("reserve %d after %s idle = %s, want %s", n+1, s.idle, got, s.want)
+				}
+			}
+		})
+	}
+}
+
+func setRPM(t *testing.T, service string, limit int) {
+	old := *rpm[service]
+	*rpm[service] = limit
+	mu.Lock()
+	buckets = map[string]*bucket{}
+	mu.Unlock()
+	t.Cleanup(func() {
+		*rpm[service] = old
+		mu.Lock()
+		buckets = map[string]*bucket{}
+		mu.Unlock()
+	})
+}
+
+func TestTransportPaces(t *testing.T) {
+	c := useFakeClock(t)
+	setRPM(t, budget.Discourse, 60)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	client := &http.Client{Transport: &Transport{Service: budget.Discourse}}
+
+	for n := 0; n < 3; n++ {
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			t.Fatalf("Get %d: %s", n+1, err)
+		}
+		resp.Body.Close()
+	}
+	// the clock advances by the sleeps: the second request waits a second, the third one too
+	if len(c.sleeps) != 2 || c.sleeps[0] != time.Second || c.sleeps[1] != time.Second {
+		t.Errorf("sleeps = %v, want [1s 1s]", c.sleeps)
+	}
+	if got := c.t.Sub(testNow); got != 2*time.Second {
+		t.Errorf("3 requests took %s, want 2s at 60 rpm", got)
+	}
+}
+
+func TestTransportUnlimited(t *testing.T) {
+	c := useFakeClock(t)
+	setRPM(t, budget.GitHub, 0)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	client := &http.Client{Transport: &Transport{Service: budget.GitHub}}
+
+	for n := 0; n < 5; n++ {
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			t.Fatalf("Get %d: %s", n+1, err)
+		}
+		resp.Body.Close()
+	}
+	if len(c.sleeps) > 0 {
+		t.Errorf("sleeps = %v, want none without --github-rpm", c.sleeps)
+	}
+}
+
+func TestTransportBucketPerHost(t *testing.T) {
+	useFakeClock(t)
+	setRPM(t, budget.Discourse, 60)
+	if a, b := bucketOf(budget.Discourse, "a.example.com"), bucketOf(budget.Discourse, "b.example.com"); a == nil || a == b {
+		t.Errorf("buckets of two hosts = %p, %p, want two buckets", a, b)
+	}
+	if a, again := bucketOf(budget.Discourse, "a.example.com"), bucketOf(budget.Discourse, "a.example.com"); a != again {
+		t.Error("a host got a second bucket, want its requests to share one")
+	}
+}
+
+func TestTransportCanceledWhileWaiting(t *testing.T) {
+	useFakeClock(t)
+	setRPM(t, budget.Discourse, 60)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	client := &http.Client{Transport: &Transport{Service: budget.Discourse}}
+
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Get: %s", err)
+	}
+	resp.Body.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Do(req); err == nil {
+		t.Error("Do of a canceled request waiting for its token: want error")
+	}
+}
@@ -0,0 +1,173 @@
+package ratelimit
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bitrise-io/go-utils/log"
+)
+
+const (
+	minBackoff = time.Second
+	maxBackoff = time.Minute
+)
+
+var (
+	maxRetries int
+	maxWait    time.Duration
+)
+
+func init() {
+	flag.IntVar(&maxRetries, "max-retries", 5, "--max-retries=<int> (retries of API requests rejected by a rate limit, and of non-POST requests failing with a 5xx status or a network error, with exponential backoff; 0 disables them)")
+	flag.DurationVar(&maxWait, "max-rate-limit-wait", time.Hour, "--max-rate-limit-wait=<duration> (longest wait for an exhausted rate limit to reset, or for a Retry-After, before the request fails)")
+}
+
+// Retry retries the requests rejected by a rate limit: once the limit resets as of the
+// X-RateLimit-Remaining and X-RateLimit-Reset headers, after the Retry-After of secondary (abuse)
+// limits, or with exponential backoff. Requests other than POST, which may have been processed,
+// are also retried on transient 5xx statuses and network errors.
+type Retry struct {
+	// Service is budget.GitHub or budget.Discourse, it names the service in the log.
+	Service string
+	// Base is the underlying transport, http.DefaultTransport if nil.
+	Base http.RoundTripper
+	// Timeout bounds each attempt including reading its response, 0 means none. Unlike the timeout
+	// of an http.Client it doesn't cover the waits between the attempts, and a timed out attempt is
+	// retried like a network error.
+	Timeout time.Duration
+}
+
+func (t *Retry) base() http.RoundTripper {
+	if t.Base == nil {
+		return http.DefaultTransport
+	}
+	return t.Base
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Retry) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		ctx, cancel := req.Context(), context.CancelFunc(func() {})
+		if t.Timeout > 0 {
+			ctx, cancel = context.WithTimeout(ctx, t.Timeout)
+		}
+		r := req.WithContext(ctx)
+		if attempt > 0 {
+			r = req.Clone(ctx)
+			if req.Body != nil && req.Body != http.NoBody {
+				body, err := req.GetBody()
+				if err != nil {
+					cancel()
+					return nil, err
+				}
+				r.Body = body
+			}
+		}
+
+		resp, err := t.base().RoundTrip(r)
+		if err != nil {
+			cancel()
+		} else {
+			resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+		}
+		if attempt >= maxRetries || req.Context().Err() != nil {
+			return resp, err
+		}
+		if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+			// the body can't be sent again
+			return resp, err
+		}
+		wait, reason := retryAfter(req, resp, err, attempt)
+		if reason == "" || wait > maxWait {
+			return resp, err
+		}
+
+		if resp != nil {
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		log.Warnf("%s %s %s: %s, retry in %s (%d/%d)", t.Service, req.Method, req.URL.Path, reason, wait.Round(time.Second), attempt+1, maxRetries)
+
+		if err := sleep(req.Context(), wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// cancelBody ends the context of an attempt once its response is read.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// retryAfter returns how long to wait before sending the request again and why, the reason is
+// empty if it isn't retried.
+func retryAfter(req *http.Request, resp *http.Response, err error, attempt int) (time.Duration, string) {
+	if err != nil {
+		if req.Method == http.MethodPost {
+			return 0, ""
+		}
+		return backoff(attempt), "network error: " + err.Error()
+	}
+
+	switch resp.StatusCode {
+	case http.StatusForbidden, http.StatusTooManyRequests:
+		if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+			if reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+				wait := time.Unix(reset, 0).Sub(now()) + time.Second
+				if wait < minBackoff {
+					wait = minBackoff
+				}
+				return wait, "rate limit exhausted"
+			}
+		}
+		if s, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && s >= 0 {
+			return time.Duration(s)*time.Second + time.Second, "secondary rate limit"
+		}
+		if resp.StatusCode == http.StatusTooManyRequests || secondaryLimit(resp) {
+			return backoff(attempt), "secondary rate limit"
+		}
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		if req.Method != http.MethodPost {
+			return backoff(attempt), resp.Status
+		}
+	}
+	return 0, ""
+}
+
+// secondaryLimit reports whether the 403 response is a secondary (abuse) rate limit rather than
+// missing permissions. The body is kept for the caller.
+func secondaryLimit(resp *http.Response) bool {
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return false
+	}
+	msg := strings.ToLower(string(body))
+	return strings.Contains(msg, "secondary rate limit") || strings.Contains(msg, "abuse")
+}
+
+// backoff doubles the wait per attempt from a second up to a minute, with up to 50% jitter so
+// parallel workers don't retry in lockstep.
+func backoff(attempt int) time.Duration {
+	wait := maxBackoff
+	if attempt < 6 {
+		wait = minBackoff << uint(attempt)
+	}
+	return wait + time.Duration(rand.Int63n(int64(wait)/2+1))
+}
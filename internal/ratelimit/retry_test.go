@@ -0,0 +1,282 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var testNow = time.Unix(1700000000, 0)
+
+// newTestClient returns a client retrying its requests to a test server with the handler, with a
+// fixed clock recording the waits instead of sleeping.
+func newTestClient(t *testing.T, retries int, handler http.Handler) (*http.Client, string, *[]time.Duration) {
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	var waits []time.Duration
+	oldNow, oldSleep, oldRetries, oldWait := now, sleep, maxRetries, maxWait
+	now = func() time.Time { return testNow }
+	sleep = func(_ context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return nil
+	}
+	maxRetries, maxWait = retries, time.Hour
+	t.Cleanup(func() { now, sleep, maxRetries, maxWait = oldNow, oldSleep, oldRetries, oldWait })
+
+	return &http.Client{Transport: &Retry{Service: "test"}}, srv.URL + "/api", &waits
+}
+
+// respondInTurn answers the requests with the responses in turn, the last one repeated, and counts them.
+func respondInTurn(requests *int, responses ...func(w http.ResponseWriter)) http.HandlerFunc {
+	var mu sync.Mutex
+	return func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		n := *requests
+		*requests++
+		mu.Unlock()
+		if n >= len(responses) {
+			n = len(responses) - 1
+		}
+		responses[n](w)
+	}
+}
+
+func status(code int, header map[string]string, body string) func(w http.ResponseWriter) {
+	return func(w http.ResponseWriter) {
+		for k, v := range header {
+			w.Header().Set(k, v)
+		}
+		w.WriteHeader(code)
+		fmt.Fprint(w, body)
+	}
+}
+
+var ok = status(http.StatusOK, nil, `{}`)
+
+func TestRetryWaits(t *testing.T) {
+	tests := []struct {
+		name      string
+		method    string
+		first     func(w http.ResponseWriter)
+		wantCalls int
+		wantCode  int
+		// wantMin and wantMax bound the wait before the retry, if retried
+		wantMin, wantMax time.Duration
+	}{
+		{
+			name:      "rate limit exhausted waits for the reset",
+			method:    http.MethodPost,
+			first:     status(http.StatusForbidden, map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Reset": fmt.Sprint(testNow.Unix() + 30)}, ""),
+			wantCalls: 2,
+			wantCode:  http.StatusOK,
+			wantMin:   31 * time.Second,
+			wantMax:   31 * time.Second,
+		},
+		{
+			name:      "reset passed already waits the minimum",
+			method:    http.MethodGet,
+			first:     status(http.StatusForbidden, map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Reset": fmt.Sprint(testNow.Unix() - 60)}, ""),
+			wantCalls: 2,
+			wantCode:  http.StatusOK,
+			wantMin:   minBackoff,
+			wantMax:   minBackoff,
+		},
+		{
+			name:      "reset beyond the longest wait",
+			method:    http.MethodGet,
+			first:     status(http.StatusForbidden, map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Reset": fmt.Sprint(testNow.Add(2 * time.Hour).Unix())}, ""),
+			wantCalls: 1,
+			wantCode:  http.StatusForbidden,
+		},
+		{
+			name:      "retry after",
+			method:    http.MethodPost,
+			first:     status(http.StatusTooManyRequests, map[string]string{"Retry-After": "7"}, ""),
+			wantCalls: 2,
+			wantCode:  http.StatusOK,
+			wantMin:   8 * time.Second,
+			wantMax:   8 * time.Second,
+		},
+		{
+			name:      "invalid retry after backs off",
+			method:    http.MethodGet,
+			first:     status(http.StatusTooManyRequests, map[string]string{"Retry-After": "soon"}, ""),
+			wantCalls: 2,
+			wantCode:  http.StatusOK,
+			wantMin:   time.Second,
+			wantMax:   1500 * time.Millisecond,
+		},
+		{
+			name:      "secondary limit backs off",
+			method:    http.MethodPost,
+			first:     status(http.StatusForbidden, nil, `{"message": "You have exceeded a secondary rate limit."}`),
+			wantCalls: 2,
+			wantCode:  http.StatusOK,
+			wantMin:   time.Second,
+			wantMax:   1500 * time.Millisecond,
+		},
+		{
+			name:      "forbidden is not a rate limit",
+			method:    http.MethodGet,
+			first:     status(http.StatusForbidden, nil, `{"message": "Resource not accessible by integration"}`),
+			wantCalls: 1,
+			wantCode:  http.StatusForbidden,
+		},
+		{
+			name:      "server error of a GET",
+			method:    http.MethodGet,
+			first:     status(http.StatusBadGateway, nil, ""),
+			wantCalls: 2,
+			wantCode:  http.StatusOK,
+			wantMin:   time.Second,
+			wantMax:   1500 * time.Millisecond,
+		},
+		{
+			name:      "server error of a POST is not retried",
+			method:    http.MethodPost,
+			first:     status(http.StatusBadGateway, nil, ""),
+			wantCalls: 1,
+			wantCode:  http.StatusBadGateway,
+		},
+		{
+			name:      "client error",
+			method:    http.MethodGet,
+			first:     status(http.StatusNotFound, nil, ""),
+			wantCalls: 1,
+			wantCode:  http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			client, url, waits := newTestClient(t, 5, respondInTurn(&calls, tt.first, ok))
+
+			req, err := http.NewRequest(tt.method, url, strings.NewReader(`{"raw": "body"}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("Do: %s", err)
+			}
+			resp.Body.Close()
+
+			if calls != tt.wantCalls {
+				t.Errorf("requests = %d, want %d", calls, tt.wantCalls)
+			}
+			if resp.StatusCode != tt.wantCode {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantCode)
+			}
+			if tt.wantCalls == 1 {
+				if len(*waits) > 0 {
+					t.Errorf("waits = %v, want none", *waits)
+				}
+				return
+			}
+			if len(*waits) != 1 || (*waits)[0] < tt.wantMin || (*waits)[0] > tt.wantMax {
+				t.Errorf("waits = %v, want one of %s-%s", *waits, tt.wantMin, tt.wantMax)
+			}
+		})
+	}
+}
+
+func TestRetrySendsTheBodyAgain(t *testing.T) {
+	calls := 0
+	var bodies []string
+	handler := respondInTurn(&calls, status(http.StatusTooManyRequests, map[string]string{"Retry-After": "1"}, ""), ok)
+	client, url, _ := newTestClient(t, 5, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("read request body: %s", err)
+		}
+		bodies = append(bodies, string(body))
+		handler(w, r)
+	}))
+
+	resp, err := client.Post(url, "application/json", strings.NewReader(`{"raw": "body"}`))
+	if err != nil {
+		t.Fatalf("Post: %s", err)
+	}
+	resp.Body.Close()
+	if len(bodies) != 2 || bodies[0] != `{"raw": "body"}` || bodies[1] != bodies[0] {
+		t.Errorf("bodies = %q, want the body sent twice", bodies)
+	}
+}
+
+func TestRetryMaxRetries(t *testing.T) {
+	tests := []struct {
+		retries   int
+		wantCalls int
+	}{
+		{retries: 0, wantCalls: 1},
+		{retries: 1, wantCalls: 2},
+		{retries: 3, wantCalls: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%d retries", tt.retries), func(t *testing.T) {
+			calls := 0
+			client, url, waits := newTestClient(t, tt.retries, respondInTurn(&calls, status(http.StatusServiceUnavailable, nil, "")))
+
+			resp, err := client.Get(url)
+			if err != nil {
+				t.Fatalf("Get: %s", err)
+			}
+			resp.Body.Close()
+
+			if calls != tt.wantCalls {
+				t.Errorf("requests = %d, want %d", calls, tt.wantCalls)
+			}
+			if resp.StatusCode != http.StatusServiceUnavailable {
+				t.Errorf("status = %d, want the last 503", resp.StatusCode)
+			}
+			for n, wait := range *waits {
+				// exponential backoff with up to 50% jitter
+				min := minBackoff << uint(n)
+				if wait < min || wait > min+min/2 {
+					t.Errorf("wait %d = %s, want %s-%s", n+1, wait, min, min+min/2)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryTimeoutPerAttempt(t *testing.T) {
+	calls := 0
+	client, url, waits := newTestClient(t, 5, respondInTurn(&calls, ok))
+	client.Transport.(*Retry).Timeout = 50 * time.Millisecond
+	// the first attempt hangs until it times out, the test clock doesn't wait between the attempts
+	var once sync.Once
+	client.Transport.(*Retry).Base = roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		hang := false
+		once.Do(func() { hang = true })
+		if hang {
+			<-r.Context().Done()
+			return nil, r.Context().Err()
+		}
+		return http.DefaultTransport.RoundTrip(r)
+	})
+
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatalf("Get: %s", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || calls != 1 || len(*waits) != 1 {
+		t.Errorf("status %d after %d requests and waits %v, want 200 after a timed out attempt", resp.StatusCode, calls, *waits)
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
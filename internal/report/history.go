@@ -0,0 +1,117 @@
+package report
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"time"
+)
+
+// historyLimit is the number of runs kept in the history file.
+const historyLimit = 100
+
+var historyPath string
+
+func init() {
+	flag.StringVar(&historyPath, "history-file", "history.json", "--history-file=<path> (file keeping the metrics of past runs, the summary compares the run to the last one of the same mode; empty disables it)")
+}
+
+// HistoryPath returns the metrics history file, empty if disabled.
+func HistoryPath() string {
+	return historyPath
+}
+
+// Metrics are the aggregates of a run kept in the history file.
+type Metrics struct {
+	RunID     string    `json:"run_id"`
+	Mode      string    `json:"mode"`
+	StartedAt time.Time `json:"started_at"`
+	Duration  float64   `json:"duration_seconds"`
+	Processed int       `json:"processed"`
+	// Throughput is the number of issues processed per minute.
+	Throughput float64 `json:"throughput"`
+	// FailureRate is the ratio of failed and quarantined issues to the processed ones.
+	FailureRate float64 `json:"failure_rate"`
+}
+
+// Metrics returns the aggregates of the run.
+func (r Report) Metrics() Metrics {
+	d := r.FinishedAt.Sub(r.StartedAt)
+	m := Metrics{
+		RunID:     r.RunID,
+		Mode:      r.Mode,
+		StartedAt: r.StartedAt,
+		Duration:  math.Round(d.Seconds()),
+		Processed: r.Stats.Processed,
+	}
+	if d.Minutes() > 0 {
+		m.Throughput = float64(r.Stats.Processed) / d.Minutes()
+	}
+	if r.Stats.Processed > 0 {
+		m.FailureRate = float64(r.Stats.Failed+r.Stats.Quarantined) / float64(r.Stats.Processed)
+	}
+	return m
+}
+
+// Record appends the metrics of the run to the history file and sets the trend of the report
+// compared to the last run of the same mode.
+func (r *Report) Record() error {
+	if historyPath == "" {
+		return nil
+	}
+
+	var history []Metrics
+	data, err := ioutil.ReadFile(historyPath)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return fmt.Errorf("read %s: %s", historyPath, err)
+	default:
+		if err := json.Unmarshal(data, &history); err != nil {
+			return fmt.Errorf("unmarshal %s: %s", historyPath, err)
+		}
+	}
+
+	cur := r.Metrics()
+	for n := len(history) - 1; n >= 0; n-- {
+		if history[n].Mode == cur.Mode {
+			r.Trend = trend(history[n], cur)
+			break
+		}
+	}
+
+	history = append(history, cur)
+	if len(history) > historyLimit {
+		history = history[len(history)-historyLimit:]
+	}
+	if data, err = json.MarshalIndent(history, "", "  "); err != nil {
+		return fmt.Errorf("marshal history: %s", err)
+	}
+	if err := ioutil.WriteFile(historyPath, data, 0644); err != nil {
+		return fmt.Errorf("write %s: %s", historyPath, err)
+	}
+	return nil
+}
+
+// trend compares the run to the previous one.
+func trend(prev, cur Metrics) []string {
+	var lines []string
+	if prev.Throughput > 0 && cur.Throughput > 0 {
+		change := (cur.Throughput/prev.Throughput - 1) * 100
+		switch {
+		case math.Abs(change) < 1:
+			lines = append(lines, fmt.Sprintf("as fast as last run (%.1f issues per minute)", cur.Throughput))
+		case change > 0:
+			lines = append(lines, fmt.Sprintf("%.0f%% faster than last run (%.1f issues per minute, was %.1f)", change, cur.Throughput, prev.Throughput))
+		default:
+			lines = append(lines, fmt.Sprintf("%.0f%% slower than last run (%.1f issues per minute, was %.1f)", -change, cur.Throughput, prev.Throughput))
+		}
+	}
+	if cur.Processed > 0 && prev.Processed > 0 {
+		lines = append(lines, fmt.Sprintf("failure rate %.1f%% (last run %.1f%%)", cur.FailureRate*100, prev.FailureRate*100))
+	}
+	return lines
+}
@@ -0,0 +1,251 @@
+package report
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lszucs/github-to-discourse/internal/github"
+	"github.com/lszucs/github-to-discourse/internal/run"
+	"github.com/lszucs/github-to-discourse/internal/runmode"
+	"github.com/lszucs/github-to-discourse/internal/sealed"
+	"github.com/lszucs/github-to-discourse/internal/state"
+)
+
+const (
+	defaultReportPath  = "report.json"
+	defaultMappingPath = "mapping.json"
+)
+
+var (
+	reportPath  string
+	mappingPath string
+	usersPath   string
+)
+
+func init() {
+	flag.StringVar(&reportPath, "report-file", defaultReportPath, "--report-file=<path> (file to write the run report to, relative to --out-dir)")
+	flag.StringVar(&usersPath, "users-file", "", "--users-file=<path> (CSV file to write the issues migrated to Discourse per GitHub user to, for follow-ups, relative to --out-dir; empty disables it)")
+	flag.StringVar(&mappingPath, "mapping-file", defaultMappingPath, "--mapping-file=<path> (file to write the GitHub issue to Discourse topic mapping to, relative to --out-dir)")
+}
+
+// Issue is the outcome of a single issue in the run.
+type Issue struct {
+	URL      string `json:"url"`
+	TopicURL string `json:"topic_url,omitempty"`
+	Error    string `json:"error,omitempty"`
+	Status   string `json:"status,omitempty"`
+	// Suspect lists the problems of the rendered topic.
+	Suspect []string `json:"suspect,omitempty"`
+	// Author is the login of the user who opened the issue.
+	Author string `json:"author,omitempty"`
+	// TopicID and PostID identify the topic and its first post.
+	TopicID int `json:"topic_id,omitempty"`
+	PostID  int `json:"post_id,omitempty"`
+	// Identity and PostedAs tell who the topic was posted as, empty for the API user.
+	Identity string `json:"identity,omitempty"`
+	PostedAs string `json:"posted_as,omitempty"`
+	// FallbackTitle is set if the topic got a fallback title for the issue had no valid one.
+	FallbackTitle bool `json:"fallback_title,omitempty"`
+	// Trace lists what the tool did with the issue across runs, in order: the steps with their
+	// API calls and the errors.
+	Trace []state.Event `json:"trace,omitempty"`
+}
+
+// Report summarizes a run, see schema/report.v1.json.
+type Report struct {
+	SchemaVersion int `json:"schema_version"`
+
+	RunID      string        `json:"run_id"`
+	Mode       string        `json:"mode"`
+	StartedAt  time.Time     `json:"started_at"`
+	FinishedAt time.Time     `json:"finished_at"`
+	Repos      []string      `json:"repos"`
+	Stats      runmode.Stats `json:"stats"`
+	Issues     []Issue       `json:"issues"`
+	// Quarantined issues need manual handling.
+	Quarantined []Issue `json:"quarantined,omitempty"`
+	// Suspect issues have topics which may render broken and need review.
+	Suspect []Issue `json:"suspect,omitempty"`
+	// FallbackTitles are the issues without a valid title, whose topics got a fallback title.
+	FallbackTitles []Issue `json:"fallback_titles,omitempty"`
+	// Renamed maps the repo names given which have been renamed to their canonical name.
+	Renamed map[string]string `json:"renamed,omitempty"`
+	// FailedRepos are the repos whose issues couldn't be fetched, they are retried by the next run.
+	FailedRepos []github.RepoFailure `json:"failed_repos,omitempty"`
+	// Trend compares the run to the last one of the same mode in the history file.
+	Trend []string `json:"trend,omitempty"`
+}
+
+// New creates the report of a run from its stats and the state records of the given issues.
+func New(mode string, startedAt time.Time, repos []string, issueURLs []string, stats runmode.Stats) Report {
+	r := Report{
+		SchemaVersion: SchemaVersion,
+
+		RunID:      run.ID(),
+		Mode:       mode,
+		StartedAt:  startedAt,
+		FinishedAt: time.Now(),
+		Repos:      repos,
+		Stats:      stats,
+		Renamed:    github.Renames(),
+
+		FailedRepos: github.RepoFailures(),
+	}
+	for _, u := range issueURLs {
+		issue := Issue{URL: u}
+		if rec, ok := state.Lookup(u); ok {
+			issue.TopicURL = rec.TopicURL
+			issue.Error = rec.Error
+			issue.Status = rec.Status
+			issue.Suspect = rec.Suspect
+			issue.Author = rec.Author
+			issue.TopicID, issue.PostID = rec.TopicID, rec.PostID
+			issue.Identity, issue.PostedAs = rec.Identity, rec.PostedAs
+			issue.FallbackTitle = rec.FallbackTitle
+			issue.Trace = rec.Events
+		}
+		r.Issues = append(r.Issues, issue)
+		if issue.Status == state.StatusQuarantined {
+			r.Quarantined = append(r.Quarantined, issue)
+		}
+		if len(issue.Suspect) > 0 {
+			r.Suspect = append(r.Suspect, issue)
+		}
+		if issue.FallbackTitle {
+			r.FallbackTitles = append(r.FallbackTitles, issue)
+		}
+	}
+	return r
+}
+
+// Mapping returns the Discourse topic URLs keyed by GitHub issue URLs.
+func (r Report) Mapping() map[string]string {
+	m := map[string]string{}
+	for _, i := range r.Issues {
+		if i.TopicURL != "" {
+			m[i.URL] = i.TopicURL
+		}
+	}
+	return m
+}
+
+// Topics returns the IDs of the Discourse topics keyed by GitHub issue URLs, for topics whose IDs are known.
+func (r Report) Topics() map[string]MappedTopic {
+	m := map[string]MappedTopic{}
+	for _, i := range r.Issues {
+		if i.TopicID != 0 {
+			m[i.URL] = MappedTopic{TopicID: i.TopicID, PostID: i.PostID, Identity: i.Identity, PostedAs: i.PostedAs}
+		}
+	}
+	return m
+}
+
+// Summary returns a human readable summary of the run.
+func (r Report) Summary() string {
+	var attention string
+	if len(r.Quarantined) > 0 {
+		attention = fmt.Sprintf("**%d issues are quarantined after repeated failures and need manual handling:**\n\n", len(r.Quarantined))
+		for _, i := range r.Quarantined {
+			attention += fmt.Sprintf("- %s: %s\n", i.URL, i.Error)
+		}
+		attention += "\n"
+	}
+	if len(r.Suspect) > 0 {
+		attention += fmt.Sprintf("**%d topics may render broken, review them:**\n\n", len(r.Suspect))
+		for _, i := range r.Suspect {
+			attention += fmt.Sprintf("- %s: %s\n", i.TopicURL, strings.Join(i.Suspect, ", "))
+		}
+		attention += "\n"
+	}
+	if len(r.FallbackTitles) > 0 {
+		attention += fmt.Sprintf("**%d issues have no valid title, their topics got a fallback title, consider renaming them:**\n\n", len(r.FallbackTitles))
+		for _, i := range r.FallbackTitles {
+			attention += fmt.Sprintf("- %s: %s\n", i.URL, i.TopicURL)
+		}
+		attention += "\n"
+	}
+	if len(r.FailedRepos) > 0 {
+		attention += fmt.Sprintf("**The issues of %d repos couldn't be fetched:**\n\n", len(r.FailedRepos))
+		for _, f := range r.FailedRepos {
+			attention += fmt.Sprintf("- %s: %s\n", f.Repo, f.Error)
+		}
+		attention += "\n"
+	}
+	if len(r.Renamed) > 0 {
+		var from []string
+		for f := range r.Renamed {
+			from = append(from, f)
+		}
+		sort.Strings(from)
+		attention += fmt.Sprintf("**%d repos have been renamed, update their source:**\n\n", len(from))
+		for _, f := range from {
+			attention += fmt.Sprintf("- %s is now %s\n", f, r.Renamed[f])
+		}
+		attention += "\n"
+	}
+
+	var trend string
+	if len(r.Trend) > 0 {
+		trend = "Compared to the last run: " + strings.Join(r.Trend, "; ") + ".\n\n"
+	}
+
+	return attention + trend + fmt.Sprintf(`Migrated %d issues from %d repos; see attached mapping.
+
+| | |
+|---|---|
+| processed | %d |
+| active (topic created) | %d |
+| stale (closed) | %d |
+| pull requests (skipped) | %d |
+| failed | %d |
+| converted to discussion | %d |
+| mirrored | %d |
+| quarantined | %d |
+| skipped by hook | %d |
+| duplicates (canonical topic linked) | %d |
+| campaign comments | %d |
+| edited since planned (not migrated) | %d |
+| deferred by category quota | %d |
+
+Run %s started at %s and finished at %s, its topics are tagged %s.`,
+		len(r.Mapping()), len(r.Repos),
+		r.Stats.Processed, r.Stats.Active, r.Stats.Stale, r.Stats.PullRequest, r.Stats.Failed, r.Stats.Converted, r.Stats.Mirrored, r.Stats.Quarantined, r.Stats.Skipped, r.Stats.Duplicates, r.Stats.Campaigned, r.Stats.Drifted, r.Stats.Deferred,
+		r.RunID, r.StartedAt.UTC().Format(time.RFC3339), r.FinishedAt.UTC().Format(time.RFC3339), run.TagPrefix+r.RunID)
+}
+
+func writeJSON(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal %s: %s", path, err)
+	}
+	if err := run.MkdirFor(path); err != nil {
+		return err
+	}
+	if err := sealed.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("write %s: %s", path, err)
+	}
+	return nil
+}
+
+// Write writes the report and the mapping files, and the users file if enabled.
+func Write(r Report) error {
+	if err := writeJSON(run.Path(reportPath), r); err != nil {
+		return err
+	}
+	if err := writeJSON(run.Path(mappingPath), Mapping{SchemaVersion: SchemaVersion, Issues: r.Mapping(), Topics: r.Topics()}); err != nil {
+		return err
+	}
+	if usersPath == "" {
+		return nil
+	}
+	return writeUsers(run.Path(usersPath), r)
+}
+
+// MappingPath returns the path of the mapping file.
+func MappingPath() string {
+	return run.Path(mappingPath)
+}
@@ -0,0 +1,129 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/lszucs/github-to-discourse/internal/github"
+	"github.com/lszucs/github-to-discourse/internal/run"
+	"github.com/lszucs/github-to-discourse/internal/sealed"
+	"github.com/lszucs/github-to-discourse/internal/state"
+)
+
+// SchemaVersion is the version of the report and mapping file schemas in the schema directory.
+// It is incremented on incompatible changes, fields may be added within a version.
+const SchemaVersion = 1
+
+// Mapping is the content of the mapping file, see schema/mapping.v1.json.
+type Mapping struct {
+	SchemaVersion int `json:"schema_version"`
+	// Issues holds the Discourse topic URLs keyed by GitHub issue URLs.
+	Issues map[string]string `json:"issues"`
+	// Topics holds the IDs of the topics keyed by GitHub issue URLs, the URLs may change with the slug.
+	Topics map[string]MappedTopic `json:"topics,omitempty"`
+}
+
+// MappedTopic identifies the topic of an issue and its first post.
+type MappedTopic struct {
+	TopicID int `json:"topic_id"`
+	PostID  int `json:"post_id,omitempty"`
+	// Identity and PostedAs tell who the topic was posted as, to fix its ownership later.
+	Identity string `json:"identity,omitempty"`
+	PostedAs string `json:"posted_as,omitempty"`
+}
+
+var (
+	modes    = map[string]bool{"dry": true, "live": true, "export-project": true}
+	statuses = map[string]bool{"": true, state.StatusConverted: true, state.StatusMirrored: true, state.StatusQuarantined: true, state.StatusSkipped: true, state.StatusDeferred: true}
+)
+
+// decodeStrict decodes the JSON file at path into v, failing on unknown fields.
+func decodeStrict(path string, v interface{}) error {
+	data, err := sealed.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read %s: %s", path, err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("decode %s: %s", path, err)
+	}
+	return nil
+}
+
+func checkVersion(path string, version int) error {
+	if version != SchemaVersion {
+		return fmt.Errorf("%s: schema version %d, this version of the tool supports %d", path, version, SchemaVersion)
+	}
+	return nil
+}
+
+// Validate checks the report and the mapping files against their schema and each other,
+// and returns the problems found. The error is set if a file can't be read or decoded.
+func Validate() ([]string, error) {
+	reportPath, mappingPath := Paths()
+
+	var r Report
+	if err := decodeStrict(reportPath, &r); err != nil {
+		return nil, err
+	}
+	if err := checkVersion(reportPath, r.SchemaVersion); err != nil {
+		return nil, err
+	}
+	var m Mapping
+	if err := decodeStrict(mappingPath, &m); err != nil {
+		return nil, err
+	}
+	if err := checkVersion(mappingPath, m.SchemaVersion); err != nil {
+		return nil, err
+	}
+
+	var problems []string
+	problem := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if r.RunID == "" {
+		problem("%s: run_id empty", reportPath)
+	}
+	if !modes[r.Mode] {
+		problem("%s: unknown mode %q", reportPath, r.Mode)
+	}
+	if r.StartedAt.IsZero() || r.FinishedAt.Before(r.StartedAt) {
+		problem("%s: finished_at %s is before started_at %s", reportPath, r.FinishedAt, r.StartedAt)
+	}
+
+	urls := map[string]bool{}
+	for _, i := range r.Issues {
+		if _, err := github.ParseIssueURL(i.URL); err != nil {
+			problem("%s: issue %q: %s", reportPath, i.URL, err)
+		}
+		if !statuses[i.Status] {
+			problem("%s: issue %s: unknown status %q", reportPath, i.URL, i.Status)
+		}
+		urls[i.URL] = true
+	}
+	for _, i := range append(append(append([]Issue{}, r.Quarantined...), r.Suspect...), r.FallbackTitles...) {
+		if !urls[i.URL] {
+			problem("%s: %s is listed as quarantined, suspect or with a fallback title but not under issues", reportPath, i.URL)
+		}
+	}
+
+	if m.Issues == nil {
+		problem("%s: issues missing", mappingPath)
+	}
+	if !reflect.DeepEqual(m.Issues, r.Mapping()) && len(m.Issues)+len(r.Mapping()) > 0 {
+		problem("%s: the mapping doesn't match the topics of %s", mappingPath, reportPath)
+	}
+	if !reflect.DeepEqual(m.Topics, r.Topics()) && len(m.Topics)+len(r.Topics()) > 0 {
+		problem("%s: the topic IDs don't match the topics of %s", mappingPath, reportPath)
+	}
+	return problems, nil
+}
+
+// Paths returns the paths of the report and the mapping files.
+func Paths() (string, string) {
+	return run.Path(reportPath), run.Path(mappingPath)
+}
@@ -0,0 +1,67 @@
+package report
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/lszucs/github-to-discourse/internal/run"
+	"github.com/lszucs/github-to-discourse/internal/sealed"
+)
+
+// User lists the issues of a GitHub user migrated to Discourse topics.
+type User struct {
+	Login     string
+	IssueURLs []string
+	TopicURLs []string
+}
+
+// Users returns the authors of the issues with a topic, ordered by login, with their issues in order.
+func (r Report) Users() []User {
+	byLogin := map[string]*User{}
+	var logins []string
+	for _, i := range r.Issues {
+		if i.TopicURL == "" || i.Author == "" {
+			continue
+		}
+		u, ok := byLogin[i.Author]
+		if !ok {
+			u = &User{Login: i.Author}
+			byLogin[i.Author] = u
+			logins = append(logins, i.Author)
+		}
+		u.IssueURLs = append(u.IssueURLs, i.URL)
+		u.TopicURLs = append(u.TopicURLs, i.TopicURL)
+	}
+	sort.Strings(logins)
+
+	var users []User
+	for _, l := range logins {
+		users = append(users, *byLogin[l])
+	}
+	return users
+}
+
+// writeUsers writes a CSV row per user: login, number of issues, then the issue and the topic
+// URLs separated by spaces, in the same order.
+func writeUsers(path string, r Report) error {
+	var b bytes.Buffer
+	w := csv.NewWriter(&b)
+	rows := [][]string{{"login", "issues", "issue_urls", "topic_urls"}}
+	for _, u := range r.Users() {
+		rows = append(rows, []string{u.Login, strconv.Itoa(len(u.IssueURLs)), strings.Join(u.IssueURLs, " "), strings.Join(u.TopicURLs, " ")})
+	}
+	if err := w.WriteAll(rows); err != nil {
+		return fmt.Errorf("write users: %s", err)
+	}
+	if err := run.MkdirFor(path); err != nil {
+		return err
+	}
+	if err := sealed.WriteFile(path, b.Bytes(), 0644); err != nil {
+		return fmt.Errorf("write %s: %s", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package run
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// TagPrefix prefixes the Discourse tag of the topics created by a run.
+const TagPrefix = "migration-run-"
+
+var (
+	id      string
+	outDir  string
+	validID = regexp.MustCompile(`^[a-z0-9-]+$`)
+
+	generated bool
+)
+
+func init() {
+	flag.StringVar(&id, "run-id", "", "--run-id=<id> (identifies the run in the state file and in the Discourse tag of its topics, lowercase letters, digits and dashes; generated from the start time if empty)")
+	flag.StringVar(&outDir, "out-dir", "", "--out-dir=<dir> (directory of the artifacts of the run: report, mapping, users file, debug bundles and snapshots given as relative paths are written under it; runs/<run id> if empty, . keeps them in the working directory)")
+}
+
+// Init validates --run-id or generates it from now.
+func Init(now time.Time) error {
+	if id == "" {
+		id = now.UTC().Format("20060102-150405")
+		generated = true
+		return nil
+	}
+	if !validID.MatchString(id) {
+		return fmt.Errorf("invalid run id %s: only lowercase letters, digits and dashes are allowed", id)
+	}
+	return nil
+}
+
+// Generated reports whether the ID was generated instead of given with --run-id.
+func Generated() bool {
+	return generated
+}
+
+// ID returns the ID of the current run.
+func ID() string {
+	return id
+}
+
+// Tag returns the Discourse tag of the topics created by the current run.
+func Tag() string {
+	return TagPrefix + id
+}
+
+// OutDir returns the directory of the artifacts of the run.
+func OutDir() string {
+	if outDir != "" {
+		return outDir
+	}
+	return filepath.Join("runs", id)
+}
+
+// ArtifactsDir returns the directory of the artifacts of the run given with --run-id or --out-dir,
+// or the directory of the artifacts of every run if none is given.
+func ArtifactsDir() string {
+	if outDir == "" && generated {
+		return "runs"
+	}
+	return OutDir()
+}
+
+// Path returns where the artifact at p is written: relative paths are resolved against --out-dir,
+// absolute and empty paths are returned as is.
+func Path(p string) string {
+	if p == "" || filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(OutDir(), p)
+}
+
+// MkdirFor creates the directory of the file at path.
+func MkdirFor(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("create directory of %s: %s", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package runmode
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/lszucs/github-to-discourse/internal/state"
+)
+
+const defaultActions = "discourse,comment,close,lock"
+
+var actions string
+
+func init() {
+	flag.StringVar(&actions, "actions", defaultActions, "--actions=discourse,comment,close,lock (steps to perform on each issue in live mode)")
+}
+
+// ValidateActions returns an error if --actions contains an unknown step.
+func ValidateActions() error {
+	for _, a := range strings.Split(actions, ",") {
+		switch a {
+		case state.StepDiscourse, state.StepComment, state.StepClose, state.StepLock:
+		default:
+			return fmt.Errorf("not recognized action %s", a)
+		}
+	}
+	return nil
+}
+
+func enabled(step string) bool {
+	for _, a := range strings.Split(actions, ",") {
+		if a == step {
+			return true
+		}
+	}
+	return false
+}
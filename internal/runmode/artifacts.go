@@ -0,0 +1,114 @@
+package runmode
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/bitrise-io/go-utils/log"
+	gh "github.com/google/go-github/github"
+
+	"github.com/lszucs/github-to-discourse/internal/github"
+	"github.com/lszucs/github-to-discourse/internal/run"
+	"github.com/lszucs/github-to-discourse/internal/state"
+)
+
+var artifactsDir string
+
+func init() {
+	flag.StringVar(&artifactsDir, "dry-artifacts", "", "--dry-artifacts=<dir> (dry runs write the planned topic, comment and actions of each issue to <dir>/<owner>/<name>/<number>.json for reviewers' scripts, relative to --out-dir; empty disables it)")
+}
+
+// Artifact is what a live run would do with an issue, written by dry runs with --dry-artifacts.
+type Artifact struct {
+	IssueURL string `json:"issue_url"`
+	// Notes explain the decisions made for the issue.
+	Notes []string `json:"notes,omitempty"`
+	// Topic is the topic payload, nil if no topic would be created.
+	Topic *ArtifactTopic `json:"topic,omitempty"`
+	// Comment is the GitHub comment, nil if no comment would be posted.
+	Comment *ArtifactComment `json:"comment,omitempty"`
+	// Actions lists the steps a live run would perform, in order.
+	Actions []string `json:"actions"`
+	// CloseReason is the state reason the issue would be closed with.
+	CloseReason string `json:"close_reason,omitempty"`
+	// Error is set if the issue could not be rendered.
+	Error string `json:"error,omitempty"`
+}
+
+// ArtifactTopic is a planned Discourse topic.
+type ArtifactTopic struct {
+	Title      string   `json:"title"`
+	Raw        string   `json:"raw"`
+	CategoryID int      `json:"category_id"`
+	Tags       []string `json:"tags"`
+}
+
+// ArtifactComment is a planned GitHub comment.
+type ArtifactComment struct {
+	Template string `json:"template"`
+	Body     string `json:"body"`
+}
+
+// writeArtifact writes the plan of the issue with --dry-artifacts. Failing to do so doesn't fail the run.
+func writeArtifact(i *gh.Issue) {
+	if artifactsDir == "" || campaign || i.IsPullRequest() {
+		return
+	}
+	ref, err := github.ParseIssueURL(i.GetHTMLURL())
+	if err != nil {
+		log.Warnf("write dry-run artifact of %s: %s", i.GetHTMLURL(), err)
+		return
+	}
+
+	a := Artifact{IssueURL: i.GetHTMLURL(), Actions: []string{}}
+	if p, err := PreviewIssue(i); err != nil {
+		a.Error = err.Error()
+	} else {
+		a.Notes = p.Notes
+		if p.Title != "" {
+			a.Topic = &ArtifactTopic{Title: p.Title, Raw: p.Raw, CategoryID: p.CategoryID, Tags: topicTags(i)}
+			if enabled(state.StepDiscourse) || mirror {
+				a.Actions = append(a.Actions, state.StepDiscourse)
+			}
+		}
+		if p.Comment != "" {
+			a.Comment = &ArtifactComment{Template: p.Template, Body: p.Comment}
+			a.Actions = append(a.Actions, plannedGitHubSteps(i)...)
+			if enabled(state.StepClose) && i.GetState() != "closed" {
+				a.CloseReason = reasonOf(step{i: i, commentTpl: p.Template})
+			}
+		}
+	}
+
+	path := filepath.Join(run.Path(artifactsDir), ref.Owner, ref.Name, fmt.Sprintf("%d.json", ref.Number))
+	if err := run.MkdirFor(path); err != nil {
+		log.Warnf("write dry-run artifact of %s: %s", i.GetHTMLURL(), err)
+		return
+	}
+	data, err := json.MarshalIndent(a, "", "  ")
+	if err != nil {
+		log.Warnf("write dry-run artifact of %s: %s", i.GetHTMLURL(), err)
+		return
+	}
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		log.Warnf("write dry-run artifact of %s: %s", i.GetHTMLURL(), err)
+	}
+}
+
+// plannedGitHubSteps returns the GitHub steps a live run would perform on the issue.
+func plannedGitHubSteps(i *gh.Issue) []string {
+	var steps []string
+	if enabled(state.StepComment) {
+		steps = append(steps, state.StepComment)
+	}
+	if enabled(state.StepClose) && i.GetState() != "closed" {
+		steps = append(steps, state.StepClose)
+	}
+	if enabled(state.StepLock) && !i.GetLocked() {
+		steps = append(steps, state.StepLock)
+	}
+	return steps
+}
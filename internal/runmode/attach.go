@@ -0,0 +1,67 @@
+package runmode
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/log"
+	gh "github.com/google/go-github/github"
+
+	"github.com/lszucs/github-to-discourse/internal/discourse"
+	"github.com/lszucs/github-to-discourse/internal/github"
+)
+
+var attachOriginal bool
+
+func init() {
+	flag.BoolVar(&attachOriginal, "attach-original", false, "--attach-original (attach the raw markdown of the issue and its comments to the topic as a .md file)")
+}
+
+// original renders the untransformed markdown of the issue and its comments.
+func original(i *gh.Issue, comments []*gh.IssueComment) string {
+	var md strings.Builder
+	fmt.Fprintf(&md, "# %s\n\n", i.GetTitle())
+	fmt.Fprintf(&md, "Original issue: %s\n", i.GetHTMLURL())
+	fmt.Fprintf(&md, "Opened by @%s at %s\n\n", i.GetUser().GetLogin(), i.GetCreatedAt().UTC().Format("2006-01-02 15:04 MST"))
+	md.WriteString(i.GetBody())
+	md.WriteString("\n")
+	for _, c := range comments {
+		fmt.Fprintf(&md, "\n---\n\n@%s commented at %s:\n\n", c.GetUser().GetLogin(), c.GetCreatedAt().UTC().Format("2006-01-02 15:04 MST"))
+		md.WriteString(c.GetBody())
+		md.WriteString("\n")
+	}
+	return md.String()
+}
+
+// uploadOriginal uploads the original markdown of the issue and returns the attachment link to put
+// in the topic.
+func uploadOriginal(i *gh.Issue, comments []*gh.IssueComment) (string, error) {
+	dir, err := ioutil.TempDir("", "original")
+	if err != nil {
+		return "", fmt.Errorf("create temp dir: %s", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(dir); err != nil {
+			log.Warnf("remove %s: %s", dir, err)
+		}
+	}()
+
+	name := fmt.Sprintf("issue-%d.md", i.GetNumber())
+	if ref, err := github.ParseIssueURL(i.GetHTMLURL()); err == nil {
+		name = fmt.Sprintf("%s-%s-%d.md", ref.Owner, ref.Name, ref.Number)
+	}
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(original(i, comments)), 0644); err != nil {
+		return "", fmt.Errorf("write %s: %s", path, err)
+	}
+
+	url, err := discourse.Upload(path)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("[%s|attachment](%s)", name, url), nil
+}
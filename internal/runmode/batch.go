@@ -0,0 +1,37 @@
+package runmode
+
+import (
+	"flag"
+
+	"github.com/bitrise-io/go-utils/log"
+
+	"github.com/lszucs/github-to-discourse/internal/github"
+	"github.com/lszucs/github-to-discourse/internal/state"
+)
+
+var graphqlBatch bool
+
+func init() {
+	flag.BoolVar(&graphqlBatch, "graphql-batch", false, "--graphql-batch (close and lock each issue with a single GraphQL request instead of two REST calls, falling back to them if it fails; requires a GitHub token)")
+}
+
+// batchable reports whether the issue is to be both closed and locked with a single GraphQL request.
+func batchable(s step) bool {
+	return graphqlBatch && github.Authenticated() &&
+		enabled(state.StepClose) && s.i.GetState() != "closed" && !s.rec.IsDone(state.StepClose) &&
+		enabled(state.StepLock) && !s.i.GetLocked() && !s.rec.IsDone(state.StepLock)
+}
+
+// closeAndLock closes and locks the issue in one request, and reports whether it succeeded. If it
+// failed, the steps are left to the REST calls: closing and locking again is harmless.
+func closeAndLock(s step) bool {
+	log.Printf("close issue as %s and lock it", reasonOf(s))
+	call, err := github.CloseAndLock(s.i, reasonOf(s))
+	if err != nil {
+		log.Warnf("%s, close and lock separately", err)
+		return false
+	}
+	s.rec.MarkDoneBy(state.StepClose, call)
+	s.rec.MarkDoneBy(state.StepLock, call)
+	return true
+}
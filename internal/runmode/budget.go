@@ -0,0 +1,64 @@
+package runmode
+
+import (
+	"github.com/lszucs/github-to-discourse/internal/archive"
+	"github.com/lszucs/github-to-discourse/internal/budget"
+	"github.com/lszucs/github-to-discourse/internal/state"
+)
+
+// IssueCalls estimates the API calls migrating a single issue makes with the current flags.
+func IssueCalls() map[string]int {
+	calls := map[string]int{budget.GitHub: 0, budget.Discourse: 0}
+	if campaign {
+		calls[budget.GitHub]++
+		if greetByName {
+			calls[budget.GitHub]++
+		}
+		return calls
+	}
+	if fetchesComments() {
+		calls[budget.GitHub]++
+	}
+	if withComments {
+		calls[budget.GitHub]++
+	}
+	if lockedComments != lockedInclude && fetchesComments() {
+		calls[budget.GitHub]++
+	}
+	if ccMaintainers != "" {
+		calls[budget.GitHub]++
+		if ccEnabled(ccWatch) {
+			calls[budget.Discourse]++
+		}
+	}
+	if linkDuplicates {
+		calls[budget.GitHub]++
+	}
+	if archive.Enabled() {
+		calls[budget.GitHub]++
+	}
+	if greetByName {
+		calls[budget.GitHub]++
+	}
+	if enabled(state.StepDiscourse) || mirror {
+		calls[budget.Discourse]++
+		if verifyTopics {
+			calls[budget.Discourse]++
+		}
+		if attachOriginal {
+			calls[budget.Discourse]++
+		}
+	}
+	if mirror {
+		return calls
+	}
+	for _, step := range []string{state.StepComment, state.StepClose, state.StepLock} {
+		if enabled(step) {
+			calls[budget.GitHub]++
+		}
+	}
+	if graphqlBatch && enabled(state.StepClose) && enabled(state.StepLock) {
+		calls[budget.GitHub]--
+	}
+	return calls
+}
@@ -0,0 +1,47 @@
+package runmode
+
+import (
+	"fmt"
+
+	"github.com/bitrise-io/go-utils/log"
+
+	"github.com/lszucs/github-to-discourse/internal/github"
+	"github.com/lszucs/github-to-discourse/internal/state"
+	"github.com/lszucs/github-to-discourse/internal/templates"
+)
+
+var campaign bool
+
+// SetCampaign switches to the campaign mode: issues are only commented with the campaign template,
+// nothing is migrated, closed or locked.
+func SetCampaign() {
+	campaign = true
+}
+
+// campaignComment posts the campaign comment on the issue, once.
+func campaignComment(s step, stats *Stats) error {
+	i, rec := s.i, s.rec
+	if rec.IsDone(state.StepCampaign) {
+		log.Printf("skip %s: campaign comment already posted", i.GetHTMLURL())
+		return nil
+	}
+
+	comment, err := templates.Execute(templates.Campaign, s.data)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("post campaign comment")
+	_, call, err := github.PostComment(i, comment)
+	if err != nil {
+		if conv, ok := err.(*github.ConvertedError); ok {
+			// the notice isn't posted to the discussion
+			return converted(conv, rec, stats, "")
+		}
+		rec.Called(call)
+		return fmt.Errorf("post campaign comment to %s: %s", i.GetHTMLURL(), err)
+	}
+	rec.MarkDoneBy(state.StepCampaign, call)
+	stats.Campaigned++
+	return nil
+}
@@ -0,0 +1,34 @@
+package runmode
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/lszucs/github-to-discourse/internal/github"
+	"github.com/lszucs/github-to-discourse/internal/templates"
+)
+
+var closeReason string
+
+func init() {
+	flag.StringVar(&closeReason, "close-reason", github.ReasonCompleted, "--close-reason=completed|not_planned (state reason of the issues closed after migrating them to a topic or linking a duplicate's topic, stale issues are always closed as not_planned)")
+}
+
+// ValidateCloseReason returns an error if --close-reason is unknown.
+func ValidateCloseReason() error {
+	switch closeReason {
+	case github.ReasonCompleted, github.ReasonNotPlanned:
+		return nil
+	default:
+		return fmt.Errorf("not recognized --close-reason %s", closeReason)
+	}
+}
+
+// reasonOf returns the state reason the issue is closed with: not_planned for stale issues,
+// --close-reason for the others.
+func reasonOf(s step) string {
+	if s.commentTpl == templates.Stale {
+		return github.ReasonNotPlanned
+	}
+	return closeReason
+}
@@ -0,0 +1,42 @@
+package runmode
+
+import (
+	"flag"
+
+	gh "github.com/google/go-github/github"
+)
+
+var concurrency int
+
+func init() {
+	flag.IntVar(&concurrency, "concurrency", 1, "--concurrency=<int> (number of issues processed in parallel in dry mode, output is kept in repo and issue number order)")
+}
+
+// parallel runs fn on every issue with at most --concurrency at a time and passes the results to
+// emit in the order the issues were received, as soon as all earlier ones are done.
+func parallel(issues <-chan *gh.Issue, fn func(*gh.Issue) string, emit func(string)) {
+	workers := concurrency
+	if workers < 1 {
+		workers = 1
+	}
+
+	// pending bounds the issues in flight, so the output of at most workers issues is buffered
+	pending := make(chan chan string, workers)
+	done := make(chan bool)
+	go func() {
+		for out := range pending {
+			emit(<-out)
+		}
+		close(done)
+	}()
+
+	for i := range issues {
+		out := make(chan string, 1)
+		pending <- out
+		go func(i *gh.Issue) {
+			out <- fn(i)
+		}(i)
+	}
+	close(pending)
+	<-done
+}
@@ -0,0 +1,30 @@
+package runmode
+
+import (
+	"flag"
+
+	"github.com/lszucs/github-to-discourse/internal/config"
+)
+
+// CheckFlags returns the violations of flags which contradict each other.
+func CheckFlags() []config.Violation {
+	set := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+
+	var vs []config.Violation
+	if mirror && set["actions"] {
+		vs = append(vs, config.Violation{Path: "--mirror", Message: "conflicts with --actions, mirror mode never comments, closes or locks"})
+	}
+	if mirror && linkDuplicates {
+		vs = append(vs, config.Violation{Path: "--mirror", Message: "conflicts with --link-duplicates, which comments on GitHub"})
+	}
+	if mirror && linkCopies {
+		vs = append(vs, config.Violation{Path: "--mirror", Message: "conflicts with --link-copies, which comments on GitHub"})
+	}
+	if set["locked-comments"] && lockedComments != lockedInclude && !fetchesComments() {
+		vs = append(vs, config.Violation{Path: "--locked-comments", Message: "has no effect without --attach-original, --highlights or --with-comments"})
+	}
+	return vs
+}
@@ -0,0 +1,78 @@
+package runmode
+
+import (
+	"flag"
+	"strings"
+	"sync"
+	"time"
+
+	gh "github.com/google/go-github/github"
+
+	"github.com/lszucs/github-to-discourse/internal/state"
+)
+
+var linkCopies bool
+
+func init() {
+	flag.BoolVar(&linkCopies, "link-copies", false, "--link-copies (migrate a single topic for issues carried by several repos of the run, e.g. forks: issues with the same title, author and creation time are commented with the topic of the first one instead of getting their own)")
+}
+
+var (
+	copiesMu sync.Mutex
+	// originals maps fingerprints to the URL of the first issue seen with it, nil until loaded from the state.
+	originals map[string]string
+)
+
+// fingerprint identifies copies of an issue: the title ignoring case and whitespace, the author and
+// the creation time to the minute.
+func fingerprint(i *gh.Issue) string {
+	title := strings.Join(strings.Fields(strings.ToLower(i.GetTitle())), " ")
+	return strings.Join([]string{title, strings.ToLower(i.GetUser().GetLogin()), i.GetCreatedAt().UTC().Truncate(time.Minute).Format(time.RFC3339)}, "\n")
+}
+
+// originalOf returns the URL of the first issue of the run or of earlier runs the issue is a copy
+// of, and records the issue as the original of its copies if there is none. ok is false if the issue
+// is not a copy.
+func originalOf(i *gh.Issue, rec *state.Record) (string, bool) {
+	copiesMu.Lock()
+	defer copiesMu.Unlock()
+
+	if originals == nil {
+		originals = map[string]string{}
+		for _, r := range state.All() {
+			if r.Fingerprint != "" && r.DuplicateOf == "" {
+				if _, ok := originals[r.Fingerprint]; !ok {
+					originals[r.Fingerprint] = r.IssueURL
+				}
+			}
+		}
+	}
+
+	fp := fingerprint(i)
+	if rec != nil {
+		rec.Fingerprint = fp
+	}
+	original, ok := originals[fp]
+	if !ok {
+		originals[fp] = i.GetHTMLURL()
+		return "", false
+	}
+	return original, original != i.GetHTMLURL()
+}
+
+// originalTopic returns the issue the issue is a copy of and its topic, resolved via the state file.
+// ok is false if the issue isn't a copy or the original has no topic.
+func originalTopic(i *gh.Issue, rec *state.Record) (string, string, bool) {
+	if !linkCopies {
+		return "", "", false
+	}
+	original, ok := originalOf(i, rec)
+	if !ok {
+		return "", "", false
+	}
+	orec, ok := state.Lookup(original)
+	if !ok || orec.TopicURL == "" {
+		return original, "", false
+	}
+	return original, orec.TopicURL, true
+}
@@ -0,0 +1,40 @@
+package runmode
+
+import (
+	"flag"
+
+	"github.com/bitrise-io/go-utils/log"
+
+	"github.com/lszucs/github-to-discourse/internal/github"
+	"github.com/lszucs/github-to-discourse/internal/state"
+)
+
+var followDiscussions bool
+
+func init() {
+	flag.BoolVar(&followDiscussions, "follow-discussions", false, "--follow-discussions (post the migration comment to the discussion of issues converted to discussions mid-run)")
+}
+
+// converted records that the issue has been converted to a discussion. The pending comment, if any,
+// is posted to the discussion with --follow-discussions. Discussions are neither closed nor locked.
+func converted(conv *github.ConvertedError, rec *state.Record, stats *Stats, comment string) error {
+	log.Warnf("%s", conv)
+	stats.Converted++
+	rec.Status = state.StatusConverted
+	rec.DiscussionURL = conv.DiscussionURL
+
+	if comment == "" || !followDiscussions {
+		return nil
+	}
+	if conv.DiscussionURL == "" {
+		log.Warnf("discussion URL unknown, comment not posted")
+		return nil
+	}
+
+	log.Printf("post comment to %s", conv.DiscussionURL)
+	if err := github.CommentDiscussion(conv.DiscussionURL, comment); err != nil {
+		return err
+	}
+	rec.MarkDone(state.StepComment)
+	return nil
+}
@@ -0,0 +1,160 @@
+package runmode
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bitrise-io/go-utils/log"
+	gh "github.com/google/go-github/github"
+
+	"github.com/lszucs/github-to-discourse/internal/state"
+)
+
+// Policies of --on-drift.
+const (
+	driftReplan  = "replan"
+	driftProceed = "proceed"
+	driftSkip    = "skip"
+)
+
+var (
+	planPath string
+	onDrift  string
+
+	planMu sync.Mutex
+	plan   map[string]planned
+)
+
+func init() {
+	flag.StringVar(&planPath, "plan-file", "", "--plan-file=<path> (dry runs record a checksum of the title and body of each issue to this file, live runs compare the issues to it before migrating them, see --on-drift; empty disables it)")
+	flag.StringVar(&onDrift, "on-drift", driftReplan, "--on-drift=replan|proceed|skip (what to do in live runs with an issue edited since the dry run of --plan-file, or missing from it: replan records its new content to migrate it in the next run, proceed migrates the fresh content, skip leaves it)")
+}
+
+// PlanPath returns the plan file, empty without --plan-file.
+func PlanPath() string {
+	return planPath
+}
+
+// planned is an issue as seen by the dry run.
+type planned struct {
+	Checksum  string    `json:"checksum"`
+	PlannedAt time.Time `json:"planned_at"`
+}
+
+// ValidateOnDrift checks the --on-drift policy.
+func ValidateOnDrift() error {
+	switch onDrift {
+	case driftReplan, driftProceed, driftSkip:
+		return nil
+	default:
+		return fmt.Errorf("not recognized --on-drift policy %s", onDrift)
+	}
+}
+
+// LoadPlan reads --plan-file, a missing file is an empty plan.
+func LoadPlan() error {
+	plan = map[string]planned{}
+	if planPath == "" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(planPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read plan %s: %s", planPath, err)
+	}
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return fmt.Errorf("unmarshal plan %s: %s", planPath, err)
+	}
+	return nil
+}
+
+// SavePlan writes the plan to --plan-file.
+func SavePlan() error {
+	if planPath == "" {
+		return nil
+	}
+	planMu.Lock()
+	defer planMu.Unlock()
+	data, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal plan: %s", err)
+	}
+	if err := ioutil.WriteFile(planPath, data, 0644); err != nil {
+		return fmt.Errorf("write plan %s: %s", planPath, err)
+	}
+	return nil
+}
+
+// checksum hashes the title and the body of the issue, ignoring changes of whitespace only.
+func checksum(i *gh.Issue) string {
+	normalize := func(s string) string {
+		return strings.Join(strings.Fields(s), " ")
+	}
+	sum := sha256.Sum256([]byte(normalize(i.GetTitle()) + "\n" + normalize(i.GetBody())))
+	return hex.EncodeToString(sum[:])
+}
+
+// recordPlan records the content of the issue seen by the dry run.
+func recordPlan(i *gh.Issue) {
+	if planPath == "" {
+		return
+	}
+	planMu.Lock()
+	defer planMu.Unlock()
+	if plan == nil {
+		plan = map[string]planned{}
+	}
+	plan[i.GetHTMLURL()] = planned{Checksum: checksum(i), PlannedAt: time.Now()}
+}
+
+// drift returns why the content of the issue differs from the plan, empty if it doesn't.
+func drift(i *gh.Issue) string {
+	planMu.Lock()
+	p, ok := plan[i.GetHTMLURL()]
+	planMu.Unlock()
+	switch {
+	case !ok:
+		return "not in the plan"
+	case p.Checksum != checksum(i):
+		return fmt.Sprintf("edited since planned at %s", p.PlannedAt.UTC().Format(time.RFC3339))
+	}
+	return ""
+}
+
+// checkDrift compares the issue to the plan before its topic is created, and reports whether it
+// is migrated now, as --on-drift says.
+func checkDrift(i *gh.Issue, rec *state.Record, stats *Stats) bool {
+	if planPath == "" || campaign || rec.IsDone(state.StepDiscourse) {
+		return true
+	}
+	rec.Checksum = checksum(i)
+	reason := drift(i)
+	if reason == "" {
+		return true
+	}
+
+	switch onDrift {
+	case driftProceed:
+		log.Warnf("%s %s, migrate the current content", i.GetHTMLURL(), reason)
+		return true
+	case driftSkip:
+		log.Warnf("skip %s: %s", i.GetHTMLURL(), reason)
+	default:
+		// only the content is recorded: a dry run of the issue would take it for migrated, e.g. as
+		// the original of its copies with --link-copies
+		log.Warnf("replan %s: %s, its new content is recorded to migrate it in the next run, review it with a dry run", i.GetHTMLURL(), reason)
+		recordPlan(i)
+	}
+	stats.Drifted++
+	return false
+}
@@ -0,0 +1,46 @@
+package runmode
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	gh "github.com/google/go-github/github"
+
+	"github.com/lszucs/github-to-discourse/internal/state"
+)
+
+func TestCheckDriftReplan(t *testing.T) {
+	setFlag(t, "plan-file", filepath.Join(t.TempDir(), "plan.json"))
+	setFlag(t, "on-drift", driftReplan)
+	setFlag(t, "link-copies", "true")
+	defer func() {
+		plan, originals = nil, nil
+	}()
+
+	created := time.Date(2020, 1, 2, 3, 4, 0, 0, time.UTC)
+	i := &gh.Issue{
+		Number:    gh.Int(7),
+		Title:     gh.String("Step fails on Xcode 11"),
+		Body:      gh.String("edited after the dry run"),
+		HTMLURL:   gh.String("https://github.com/octo/repo/issues/7"),
+		CreatedAt: &created,
+		User:      &gh.User{Login: gh.String("octocat")},
+	}
+	plan = map[string]planned{i.GetHTMLURL(): {Checksum: "before the edit", PlannedAt: created}}
+	originals = map[string]string{}
+
+	var stats Stats
+	if checkDrift(i, &state.Record{IssueURL: i.GetHTMLURL()}, &stats) {
+		t.Fatal("checkDrift = true, want the edited issue left for the next run")
+	}
+	if stats.Drifted != 1 {
+		t.Errorf("drifted = %d, want 1", stats.Drifted)
+	}
+	if reason := drift(i); reason != "" {
+		t.Errorf("drift after the replan = %q, want the new content recorded", reason)
+	}
+	if original, ok := originals[fingerprint(i)]; ok {
+		t.Errorf("%s recorded as the original of its copies, want nothing recorded for an issue left unmigrated", original)
+	}
+}
@@ -0,0 +1,85 @@
+package runmode
+
+import (
+	"flag"
+	"regexp"
+	"strconv"
+	"strings"
+
+	gh "github.com/google/go-github/github"
+
+	"github.com/lszucs/github-to-discourse/internal/github"
+	"github.com/lszucs/github-to-discourse/internal/state"
+)
+
+const duplicateLabel = "duplicate"
+
+var (
+	linkDuplicates bool
+	// duplicateOfRe matches the "Duplicate of #N" comments marking duplicates on GitHub.
+	duplicateOfRe = regexp.MustCompile(`(?i)duplicate of #(\d+)`)
+)
+
+func init() {
+	flag.BoolVar(&linkDuplicates, "link-duplicates", false, "--link-duplicates (instead of a topic, comment on duplicates (labeled "+duplicateLabel+" or commented \"Duplicate of #N\") with the topic of their canonical issue, if it has one)")
+}
+
+// duplicateOf returns the URL of the canonical issue of a duplicate, found in the body or the comments
+// of the issue. ok is false if the issue isn't a duplicate or its canonical issue isn't referenced.
+func duplicateOf(i *gh.Issue) (string, bool, error) {
+	texts := []string{i.GetBody()}
+	if i.GetComments() > 0 {
+		comments, err := github.GetComments(i)
+		if err != nil {
+			return "", false, err
+		}
+		for _, c := range comments {
+			texts = append(texts, c.GetBody())
+		}
+	}
+
+	ref, err := github.ParseIssueURL(i.GetHTMLURL())
+	if err != nil {
+		return "", false, nil
+	}
+	for _, t := range texts {
+		m := duplicateOfRe.FindStringSubmatch(t)
+		if m == nil {
+			continue
+		}
+		n, err := strconv.Atoi(m[1])
+		if err != nil || n == ref.Number {
+			continue
+		}
+		ref.Number = n
+		return ref.URL(), true, nil
+	}
+	return "", false, nil
+}
+
+// labeledDuplicate reports whether the issue has the duplicate label.
+func labeledDuplicate(i *gh.Issue) bool {
+	for _, l := range i.Labels {
+		if strings.EqualFold(l.GetName(), duplicateLabel) {
+			return true
+		}
+	}
+	return false
+}
+
+// canonicalTopic returns the canonical issue of a duplicate and its topic, resolved via the state file.
+// ok is false if the issue isn't a duplicate or the canonical issue has no topic.
+func canonicalTopic(i *gh.Issue) (string, string, bool, error) {
+	if !labeledDuplicate(i) && !duplicateOfRe.MatchString(i.GetBody()) && i.GetComments() == 0 {
+		return "", "", false, nil
+	}
+	canonical, ok, err := duplicateOf(i)
+	if err != nil || !ok {
+		return "", "", false, err
+	}
+	rec, ok := state.Lookup(canonical)
+	if !ok || rec.TopicURL == "" {
+		return canonical, "", false, nil
+	}
+	return canonical, rec.TopicURL, true, nil
+}
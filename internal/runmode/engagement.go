@@ -0,0 +1,25 @@
+package runmode
+
+import (
+	"flag"
+
+	gh "github.com/google/go-github/github"
+)
+
+var (
+	minComments  int
+	minReactions int
+)
+
+func init() {
+	flag.IntVar(&minComments, "min-comments", 0, "--min-comments=<int> (stale issues with at least this many comments get a topic like active ones, 0 disables it)")
+	flag.IntVar(&minReactions, "min-reactions", 0, "--min-reactions=<int> (stale issues with at least this many reactions get a topic like active ones, 0 disables it)")
+}
+
+// engaged reports whether the issue reaches one of the engagement thresholds.
+func engaged(i *gh.Issue) bool {
+	if minComments > 0 && i.GetComments() >= minComments {
+		return true
+	}
+	return minReactions > 0 && i.GetReactions().GetTotalCount() >= minReactions
+}
@@ -0,0 +1,155 @@
+package runmode
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bitrise-io/go-utils/log"
+
+	"github.com/lszucs/github-to-discourse/internal/budget"
+	"github.com/lszucs/github-to-discourse/internal/discourse"
+	"github.com/lszucs/github-to-discourse/internal/github"
+	"github.com/lszucs/github-to-discourse/internal/onerror"
+	"github.com/lszucs/github-to-discourse/internal/ratelimit"
+)
+
+// calibrationCalls is the number of calls per service timed to estimate the latency.
+const calibrationCalls = 3
+
+// ErrDeclined is returned by Estimate if the run wasn't confirmed.
+var ErrDeclined = errors.New("run not confirmed")
+
+var (
+	estimate  bool
+	assumeYes bool
+)
+
+func init() {
+	flag.BoolVar(&estimate, "estimate", false, "--estimate (before a live run, time a few API calls, print the estimated duration of the run and ask for confirmation)")
+	flag.BoolVar(&assumeYes, "yes", false, "--yes (confirm the run after --estimate without asking, required in non-interactive sessions)")
+}
+
+// Estimate counts the open issues of the repos, times a few calls to each API and prints how
+// long the live run takes at the current pacing, then asks for confirmation with --estimate.
+func Estimate(repoURLs []string, since map[string]time.Time) error {
+	if !estimate {
+		return nil
+	}
+
+	count, err := github.CountOpenIssues(repoURLs, since)
+	if err != nil {
+		return err
+	}
+	latency, err := calibrate()
+	if err != nil {
+		return err
+	}
+	for _, service := range []string{budget.GitHub, budget.Discourse} {
+		log.Printf("%s: %s per call measured, %s per call at the current pacing", service, latency[service].Round(time.Millisecond), callTime(service, latency[service]).Round(time.Millisecond))
+	}
+
+	perIssue := issueTime(latency)
+	log.Infof("estimated duration: ~%s at current pacing for %s issues (%s per issue, fewer calls for stale issues and resumed steps)", approx(perIssue*time.Duration(count)), thousands(count), perIssue.Round(time.Millisecond))
+	return confirm()
+}
+
+// calibrate returns the median latency of a few cheap calls per service.
+func calibrate() (map[string]time.Duration, error) {
+	probes := map[string]func() (time.Duration, error){
+		budget.GitHub: func() (time.Duration, error) {
+			start := time.Now()
+			_, err := github.RateLimit()
+			return time.Since(start), err
+		},
+		budget.Discourse: discourse.Ping,
+	}
+
+	latency := map[string]time.Duration{}
+	for service, probe := range probes {
+		var took []time.Duration
+		for n := 0; n < calibrationCalls; n++ {
+			d, err := probe()
+			if err != nil {
+				return nil, fmt.Errorf("calibrate %s latency: %s", service, err)
+			}
+			took = append(took, d)
+		}
+		sort.Slice(took, func(a, b int) bool { return took[a] < took[b] })
+		latency[service] = took[len(took)/2]
+	}
+	return latency, nil
+}
+
+// callTime returns the time a call to the service takes: its latency, or the interval of
+// --github-rpm/--discourse-rpm if longer.
+func callTime(service string, latency time.Duration) time.Duration {
+	if rpm := ratelimit.RPM(service); rpm > 0 {
+		if paced := time.Minute / time.Duration(rpm); paced > latency {
+			return paced
+		}
+	}
+	return latency
+}
+
+// issueTime returns the time migrating an active issue takes with the current flags.
+func issueTime(latency map[string]time.Duration) time.Duration {
+	calls := IssueCalls()
+	githubTime := time.Duration(calls[budget.GitHub]) * callTime(budget.GitHub, latency[budget.GitHub])
+	discourseTime := time.Duration(calls[budget.Discourse]) * callTime(budget.Discourse, latency[budget.Discourse])
+	if !pipelined {
+		return githubTime + discourseTime
+	}
+
+	// the queues run in parallel, the slower one sets the pace
+	githubTime += githubInterval
+	discourseTime += discourseInterval
+	if githubTime > discourseTime {
+		return githubTime
+	}
+	return discourseTime
+}
+
+func confirm() error {
+	if assumeYes {
+		return nil
+	}
+	if !onerror.Interactive() {
+		return fmt.Errorf("%s: not an interactive session, confirm with --yes", ErrDeclined)
+	}
+
+	fmt.Print("start the live run? [y/N] ")
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return ErrDeclined
+	}
+	switch strings.TrimSpace(strings.ToLower(answer)) {
+	case "y", "yes":
+		return nil
+	default:
+		return ErrDeclined
+	}
+}
+
+// approx formats the duration to the minute, or to the second below a minute, e.g. 4h10m.
+func approx(d time.Duration) string {
+	if d < time.Minute {
+		return d.Round(time.Second).String()
+	}
+	s := d.Round(time.Minute).String()
+	return strings.TrimSuffix(s, "0s")
+}
+
+// thousands formats n with thousands separators, e.g. 1,842.
+func thousands(n int) string {
+	s := fmt.Sprintf("%d", n)
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	return s
+}
@@ -0,0 +1,29 @@
+package runmode
+
+import (
+	"flag"
+
+	"github.com/bitrise-io/go-utils/log"
+
+	"github.com/lszucs/github-to-discourse/internal/github"
+	"github.com/lszucs/github-to-discourse/internal/templates"
+)
+
+var greetByName bool
+
+func init() {
+	flag.BoolVar(&greetByName, "greet-by-name", false, "--greet-by-name (fetch the display name of issue authors for .Name in templates, falling back to the login)")
+}
+
+// setName fills in the display name of the author, keeping the login on failure.
+func setName(data *templates.Data) {
+	if !greetByName || data.Author == "" {
+		return
+	}
+	name, err := github.DisplayName(data.Author)
+	if err != nil {
+		log.Warnf("greet %s by login: %s", data.Author, err)
+		return
+	}
+	data.Name = name
+}
@@ -0,0 +1,46 @@
+package runmode
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+
+	gh "github.com/google/go-github/github"
+)
+
+var highlightCount int
+
+func init() {
+	flag.IntVar(&highlightCount, "highlights", 0, "--highlights=<int> (quote this many of the most reacted comments in a Highlights section of the topic, e.g. 3; 0 disables it)")
+}
+
+// highlights renders the most reacted comments verbatim, or an empty string if no comment has reactions.
+func highlights(comments []*gh.IssueComment, n int) string {
+	var reacted []*gh.IssueComment
+	for _, c := range comments {
+		if c.GetReactions().GetTotalCount() > 0 {
+			reacted = append(reacted, c)
+		}
+	}
+	sort.SliceStable(reacted, func(a, b int) bool {
+		return reacted[a].GetReactions().GetTotalCount() > reacted[b].GetReactions().GetTotalCount()
+	})
+	if len(reacted) > n {
+		reacted = reacted[:n]
+	}
+	if len(reacted) == 0 {
+		return ""
+	}
+
+	var md strings.Builder
+	md.WriteString("### Highlights\n")
+	for _, c := range reacted {
+		md.WriteString("\n")
+		for _, line := range strings.Split(strings.TrimSpace(c.GetBody()), "\n") {
+			md.WriteString("> " + line + "\n")
+		}
+		fmt.Fprintf(&md, "\n— @%s, %d reactions ([comment](%s))\n", c.GetUser().GetLogin(), c.GetReactions().GetTotalCount(), c.GetHTMLURL())
+	}
+	return md.String()
+}
@@ -0,0 +1,66 @@
+package runmode
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	gh "github.com/google/go-github/github"
+
+	"github.com/lszucs/github-to-discourse/internal/github"
+)
+
+const (
+	lockedInclude   = "include"
+	lockedExclude   = "exclude"
+	lockedSummarize = "summarize"
+)
+
+var lockedComments string
+
+func init() {
+	flag.StringVar(&lockedComments, "locked-comments", lockedInclude, "--locked-comments=include|exclude|summarize (what to do with the comments of locked issues posted after the lock or by organization members when migrating comments)")
+}
+
+// ValidateLockedComments returns an error if --locked-comments is not a known policy.
+func ValidateLockedComments() error {
+	switch lockedComments {
+	case lockedInclude, lockedExclude, lockedSummarize:
+		return nil
+	default:
+		return fmt.Errorf("not recognized --locked-comments policy %s", lockedComments)
+	}
+}
+
+// restricted tells whether a comment of a conversation locked at the given time is kept out of the topic.
+func restricted(c *gh.IssueComment, locked time.Time) bool {
+	switch c.GetAuthorAssociation() {
+	case "MEMBER", "OWNER":
+		return true
+	}
+	return c.GetCreatedAt().After(locked)
+}
+
+// filterLocked applies --locked-comments to the comments of the issue. It returns the comments to
+// migrate and, with the summarize policy, a note about the held back ones.
+func filterLocked(i *gh.Issue, comments []*gh.IssueComment) ([]*gh.IssueComment, string, error) {
+	if lockedComments == lockedInclude || !i.GetLocked() || len(comments) == 0 {
+		return comments, "", nil
+	}
+	locked, err := github.LockedAt(i)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var kept []*gh.IssueComment
+	for _, c := range comments {
+		if !restricted(c, locked) {
+			kept = append(kept, c)
+		}
+	}
+	held := len(comments) - len(kept)
+	if lockedComments == lockedExclude || held == 0 {
+		return kept, "", nil
+	}
+	return kept, fmt.Sprintf("_%d comments posted while the conversation was locked or by the maintainers are not migrated, see the original issue._", held), nil
+}
@@ -0,0 +1,92 @@
+package runmode
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/log"
+	gh "github.com/google/go-github/github"
+
+	"github.com/lszucs/github-to-discourse/internal/discourse"
+	"github.com/lszucs/github-to-discourse/internal/github"
+	"github.com/lszucs/github-to-discourse/internal/state"
+	"github.com/lszucs/github-to-discourse/internal/steplib"
+)
+
+const (
+	ccComment = "comment"
+	ccWatch   = "watch"
+)
+
+var ccMaintainers string
+
+func init() {
+	flag.StringVar(&ccMaintainers, "cc-maintainers", "", "--cc-maintainers=comment,watch (steplib repos: mention the step maintainers, the default owners of CODEOWNERS, in the GitHub comment and/or make them watch the topic, their Discourse usernames are assumed to match their GitHub logins; empty disables it)")
+}
+
+// ValidateCC returns an error if --cc-maintainers contains an unknown option.
+func ValidateCC() error {
+	for _, o := range strings.Split(ccMaintainers, ",") {
+		switch o {
+		case "", ccComment, ccWatch:
+		default:
+			return fmt.Errorf("not recognized --cc-maintainers option %s", o)
+		}
+	}
+	return nil
+}
+
+func ccEnabled(option string) bool {
+	for _, o := range strings.Split(ccMaintainers, ",") {
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
+
+// maintainers returns the maintainers of the step of the issue's repo. Failing to look them up
+// doesn't fail the issue.
+func maintainers(i *gh.Issue) []string {
+	ref, err := github.ParseIssueURL(i.GetHTMLURL())
+	if err != nil {
+		return nil
+	}
+	m, err := steplib.Maintainers(ref.Repo)
+	if err != nil {
+		log.Warnf("look up maintainers of %s: %s", ref.FullName(), err)
+	}
+	return m
+}
+
+// withCC mentions the maintainers at the end of the comment with --cc-maintainers=comment.
+func withCC(i *gh.Issue, comment string) string {
+	if !ccEnabled(ccComment) {
+		return comment
+	}
+	m := maintainers(i)
+	if len(m) == 0 {
+		return comment
+	}
+	return comment + "\n\ncc @" + strings.Join(m, " @")
+}
+
+// watchTopic makes the maintainers watch the topic of the record with --cc-maintainers=watch.
+// Teams and users missing from Discourse are skipped with a warning.
+func watchTopic(i *gh.Issue, rec *state.Record) {
+	if !ccEnabled(ccWatch) || rec.TopicID == 0 {
+		return
+	}
+	for _, m := range maintainers(i) {
+		if strings.Contains(m, "/") {
+			log.Warnf("%s is a team, it can't watch %s", m, rec.TopicURL)
+			continue
+		}
+		if err := discourse.WatchTopic(rec.TopicID, m); err != nil {
+			log.Warnf("%s", err)
+			continue
+		}
+		log.Printf("%s watches %s", m, rec.TopicURL)
+	}
+}
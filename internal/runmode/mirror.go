@@ -0,0 +1,31 @@
+package runmode
+
+import (
+	"flag"
+
+	"github.com/bitrise-io/go-utils/log"
+	gh "github.com/google/go-github/github"
+
+	"github.com/lszucs/github-to-discourse/internal/state"
+	"github.com/lszucs/github-to-discourse/internal/templates"
+)
+
+var mirror bool
+
+func init() {
+	flag.BoolVar(&mirror, "mirror", false, "--mirror (only copy issues to Discourse topics, leave GitHub untouched: no comment, close or lock)")
+}
+
+// mirrorIssue creates the topic of the issue, active or stale, and skips every GitHub mutation.
+func mirrorIssue(i *gh.Issue, rec *state.Record, data templates.Data, stats *Stats) error {
+	stats.Mirrored++
+	if rec.IsDone(state.StepDiscourse) {
+		log.Printf("skip %s: already mirrored to %s", i.GetHTMLURL(), rec.TopicURL)
+	} else if err := postTopic(i, rec, data); err != nil {
+		return err
+	}
+
+	log.Printf("mirror mode: skip comment, close and lock")
+	rec.Status = state.StatusMirrored
+	return nil
+}
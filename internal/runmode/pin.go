@@ -0,0 +1,63 @@
+package runmode
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/bitrise-io/go-utils/log"
+
+	"github.com/lszucs/github-to-discourse/internal/discourse"
+	"github.com/lszucs/github-to-discourse/internal/state"
+)
+
+const defaultPinDays = 7
+
+// PinMostReacted temporarily pins the most reacted topics migrated from issues, per category
+// as configured by the pin_top and pin_days category options. reactions holds the total reaction
+// count keyed by issue URL.
+func PinMostReacted(reactions map[string]int) error {
+	byCategory := map[int][]*state.Record{}
+	for u := range reactions {
+		rec, ok := state.Lookup(u)
+		if !ok || rec.TopicURL == "" {
+			continue
+		}
+		byCategory[rec.CategoryID] = append(byCategory[rec.CategoryID], rec)
+	}
+
+	for id, migrated := range byCategory {
+		opts := discourse.Options(id)
+		if opts.PinTop == 0 {
+			continue
+		}
+		days := opts.PinDays
+		if days == 0 {
+			days = defaultPinDays
+		}
+		until := time.Now().AddDate(0, 0, days)
+
+		sort.Slice(migrated, func(a, b int) bool {
+			ra, rb := reactions[migrated[a].IssueURL], reactions[migrated[b].IssueURL]
+			if ra != rb {
+				return ra > rb
+			}
+			return migrated[a].IssueURL < migrated[b].IssueURL
+		})
+		if len(migrated) > opts.PinTop {
+			migrated = migrated[:opts.PinTop]
+		}
+
+		for _, rec := range migrated {
+			log.Printf("pin %s (%d reactions) until %s", rec.TopicURL, reactions[rec.IssueURL], until.Format("2006-01-02"))
+			id, err := discourse.KnownTopicID(rec.TopicID, rec.TopicURL)
+			if err != nil {
+				return fmt.Errorf("pin %s: %s", rec.TopicURL, err)
+			}
+			if err := discourse.PinTopic(id, until); err != nil {
+				return fmt.Errorf("pin %s: %s", rec.TopicURL, err)
+			}
+		}
+	}
+	return nil
+}
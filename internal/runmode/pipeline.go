@@ -0,0 +1,132 @@
+package runmode
+
+import (
+	"flag"
+	"sync"
+	"time"
+
+	"github.com/bitrise-io/go-utils/log"
+	gh "github.com/google/go-github/github"
+
+	"github.com/lszucs/github-to-discourse/internal/budget"
+	"github.com/lszucs/github-to-discourse/internal/dashboard"
+	"github.com/lszucs/github-to-discourse/internal/onerror"
+	"github.com/lszucs/github-to-discourse/internal/state"
+)
+
+var (
+	pipelined         bool
+	githubQueue       int
+	discourseInterval time.Duration
+	githubInterval    time.Duration
+)
+
+func init() {
+	flag.BoolVar(&pipelined, "pipeline", false, "--pipeline (run the Discourse and the GitHub steps of live runs on independent queues, so the GitHub steps of an issue go on while Discourse throttles the next one)")
+	flag.IntVar(&githubQueue, "github-queue", 100, "--github-queue=<int> (issues waiting for their GitHub steps with --pipeline, the Discourse queue waits if it is full)")
+	flag.DurationVar(&discourseInterval, "discourse-interval", 0, "--discourse-interval=<duration> (minimum time between the issues of the Discourse queue with --pipeline)")
+	flag.DurationVar(&githubInterval, "github-interval", 0, "--github-interval=<duration> (minimum time between the issues of the GitHub queue with --pipeline)")
+}
+
+// pipelineRun migrates the issues like LiveRunUntil, but the GitHub steps of an issue run on their own
+// queue once its topic is created, while the Discourse queue goes on with the next issue. Issues are
+// handed over with their checked out state record, so each record is changed by one queue at a time. Stopping,
+// aborting or an exhausted budget stops the Discourse queue, the GitHub queue finishes the issues
+// handed over unless it aborted itself: then they are left to the next run to resume.
+func pipelineRun(issues <-chan *gh.Issue, stop <-chan struct{}) (Stats, error) {
+	var stats, githubStats Stats
+	queue := make(chan step, githubQueue)
+	aborted := make(chan struct{})
+	var githubErr error
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for s := range queue {
+			if githubErr != nil {
+				continue
+			}
+			err := finish(s.i, s.rec, githubSteps(s, &githubStats), &githubStats)
+			if err := onerror.Handle(err); err != nil {
+				githubErr = err
+				close(aborted)
+				continue
+			}
+			time.Sleep(githubInterval)
+		}
+	}()
+
+	err := discourseQueue(issues, stop, aborted, queue, &stats)
+	close(queue)
+	wg.Wait()
+
+	stats.add(githubStats)
+	if githubErr != nil {
+		return stats, githubErr
+	}
+	return stats, err
+}
+
+// discourseQueue runs the Discourse steps of the issues and hands them over to the GitHub queue.
+func discourseQueue(issues <-chan *gh.Issue, stop, aborted <-chan struct{}, queue chan<- step, stats *Stats) error {
+	for {
+		var i *gh.Issue
+		select {
+		case <-stop:
+			return ErrCanceled
+		case <-aborted:
+			return nil
+		case next, ok := <-issues:
+			if !ok {
+				return nil
+			}
+			i = next
+		}
+
+		if i.IsPullRequest() {
+			stats.PullRequest++
+			log.Printf("skip %s: is pull request", i.GetHTMLURL())
+			continue
+		}
+
+		dashboard.Wait()
+		if dashboard.Skipped(i) {
+			log.Printf("skip %s: repo skipped from the dashboard", i.GetHTMLURL())
+			continue
+		}
+
+		if !budget.Allow(IssueCalls()) {
+			log.Warnf("API call budget exhausted, stop before %s", i.GetHTMLURL())
+			return budget.ErrExhausted
+		}
+
+		rec, ok := begin(i, stats)
+		if !ok {
+			continue
+		}
+		if !checkDrift(i, rec, stats) {
+			dashboard.Finished(i, nil)
+			if err := onerror.Handle(save(rec)); err != nil {
+				return err
+			}
+			continue
+		}
+		s, err := discourseSteps(i, rec, stats)
+		if err != nil || s.done {
+			if err := onerror.Handle(finish(i, rec, err, stats)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// the GitHub queue saves the topic with its next record, the record is its alone from now on
+		state.Commit(rec)
+		select {
+		case queue <- s:
+		case <-aborted:
+			return nil
+		}
+		time.Sleep(discourseInterval)
+	}
+}
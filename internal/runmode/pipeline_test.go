@@ -0,0 +1,76 @@
+package runmode
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	gh "github.com/google/go-github/github"
+
+	"github.com/lszucs/github-to-discourse/internal/state"
+)
+
+func TestPipelineRunSavesEveryIssue(t *testing.T) {
+	var mu sync.Mutex
+	topics := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.Method == http.MethodPost && r.URL.Path == "/posts.json" {
+			topics++
+			fmt.Fprintf(w, `{"id": %d, "topic_id": %d}`, 100+topics, topics)
+			return
+		}
+		fmt.Fprint(w, `{"post_stream": {"posts": [{"cooked": "<p>migrated</p>"}]}}`)
+	}))
+	defer srv.Close()
+
+	setFlag(t, "discourse-url", srv.URL)
+	setFlag(t, "actions", defaultActions)
+	setFlag(t, "max-retries", "0")
+	setFlag(t, "state-file", filepath.Join(t.TempDir(), "state.json"))
+	defaultTransport := http.DefaultTransport
+	http.DefaultTransport = &fakeGitHub{}
+	defer func() { http.DefaultTransport = defaultTransport }()
+
+	const n = 20
+	issues := make(chan *gh.Issue, n)
+	now := time.Now()
+	for number := 1; number <= n; number++ {
+		issues <- &gh.Issue{
+			Number:    gh.Int(number),
+			State:     gh.String("open"),
+			HTMLURL:   gh.String(fmt.Sprintf("https://github.com/octo/repo/issues/%d", number)),
+			UpdatedAt: &now,
+			User:      &gh.User{Login: gh.String("octocat")},
+		}
+	}
+	close(issues)
+
+	stats, err := pipelineRun(issues, make(chan struct{}))
+	if err != nil {
+		t.Fatalf("pipelineRun: %s", err)
+	}
+	if stats.Processed != n {
+		t.Errorf("processed %d issues, want %d", stats.Processed, n)
+	}
+	if err := state.Load(); err != nil {
+		t.Fatalf("load state: %s", err)
+	}
+	for number := 1; number <= n; number++ {
+		u := fmt.Sprintf("https://github.com/octo/repo/issues/%d", number)
+		rec, ok := state.Lookup(u)
+		if !ok {
+			t.Errorf("%s: no record", u)
+			continue
+		}
+		if rec.Stage() != state.StageLocked || rec.TopicURL == "" {
+			t.Errorf("%s: stage %s, topic %q, want locked with a topic", u, rec.Stage(), rec.TopicURL)
+		}
+		state.Delete(u)
+	}
+}
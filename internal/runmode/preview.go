@@ -0,0 +1,90 @@
+package runmode
+
+import (
+	"fmt"
+
+	gh "github.com/google/go-github/github"
+
+	"github.com/lszucs/github-to-discourse/internal/discourse"
+	"github.com/lszucs/github-to-discourse/internal/hooks"
+	"github.com/lszucs/github-to-discourse/internal/overrides"
+	"github.com/lszucs/github-to-discourse/internal/templates"
+)
+
+// Preview is what a live run would post for an issue with the current flags and config.
+type Preview struct {
+	// Notes explain the decisions made for the issue.
+	Notes []string
+	// Title and Raw are the topic, empty if no topic would be created.
+	Title      string
+	Raw        string
+	CategoryID int
+	// Template names the GitHub comment template, Comment is empty if no comment would be posted.
+	Template string
+	Comment  string
+}
+
+// PreviewIssue renders the topic and the GitHub comment of the issue without posting anything.
+// Topic URLs are placeholders, and the original attachment is not uploaded.
+func PreviewIssue(i *gh.Issue) (Preview, error) {
+	var p Preview
+	note := func(format string, args ...interface{}) {
+		p.Notes = append(p.Notes, fmt.Sprintf(format, args...))
+	}
+
+	data := templates.NewData(i)
+	data.CategoryID = discourse.CategoryID()
+	o, overridden := overrides.Get(i.GetHTMLURL())
+	if overridden {
+		note("the issue has an override")
+		if o.CategoryID != 0 {
+			data.CategoryID = o.CategoryID
+		}
+	}
+
+	hooked := hooks.Evaluate(i)
+	if len(hooked.Matched) > 0 {
+		note("matches the hooks of labels %s", hooked.Matched)
+	}
+	if hooked.Skip {
+		note("skipped by a hook, nothing is posted")
+		return p, nil
+	}
+	data.Redacted = hooked.Redact
+	setName(&data)
+
+	stale := isStale(i)
+	if mirror || !stale {
+		content, err := topicContent(i, data, func([]*gh.IssueComment) (string, error) {
+			return fmt.Sprintf("[issue-%d.md|attachment](upload://preview)", i.GetNumber()), nil
+		})
+		if err != nil {
+			return p, err
+		}
+		if p.Title, err = templates.TopicTitle(data); err != nil {
+			return p, err
+		}
+		p.Raw = discourse.Raw(discourse.Topic{OriginURL: i.GetHTMLURL(), Content: content})
+		p.CategoryID = data.CategoryID
+		note("topic in category %d", data.CategoryID)
+	}
+	if mirror {
+		note("mirror mode, GitHub is left untouched")
+		return p, nil
+	}
+
+	p.Template = templates.Stale
+	if !stale {
+		p.Template = templates.Active
+		data.TopicURL = templates.Sample.TopicURL
+		note("active, the comment links the topic (placeholder URL)")
+	} else {
+		note("stale, no topic is created")
+	}
+	comment, err := templates.Execute(p.Template, data)
+	if err != nil {
+		return p, err
+	}
+	p.Comment = comment
+	return p, nil
+}
@@ -0,0 +1,18 @@
+package runmode
+
+import (
+	"flag"
+
+	"github.com/lszucs/github-to-discourse/internal/state"
+)
+
+var maxAttempts int
+
+func init() {
+	flag.IntVar(&maxAttempts, "max-attempts", 3, "--max-attempts=<int> (quarantine issues failing this many times across runs and retries, 0 retries forever)")
+}
+
+// exhausted reports whether the failed issue ran out of its retry budget.
+func exhausted(rec *state.Record) bool {
+	return maxAttempts > 0 && rec.Attempts >= maxAttempts
+}
@@ -0,0 +1,99 @@
+package runmode
+
+import (
+	"flag"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/lszucs/github-to-discourse/internal/discourse"
+	"github.com/lszucs/github-to-discourse/internal/state"
+)
+
+const dayLayout = "2006-01-02"
+
+var categoryQuota int
+
+func init() {
+	flag.IntVar(&categoryQuota, "category-daily-quota", 0, "--category-daily-quota=<int> (max topics created per Discourse category per UTC day, counting earlier runs in the state file, further issues are deferred to the next day, 0: unlimited; daily_quota of the category in the config file takes precedence)")
+}
+
+// QuotaError is returned for issues whose topic would exceed the daily quota of its category.
+type QuotaError struct {
+	CategoryID int
+	Quota      int
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("category %d reached its quota of %d topics today", e.CategoryID, e.Quota)
+}
+
+var (
+	quotaMu   sync.Mutex
+	quotaDay  string
+	quotaUsed map[int]int
+)
+
+func dailyQuota(categoryID int) int {
+	if q := discourse.Options(categoryID).DailyQuota; q > 0 {
+		return q
+	}
+	return categoryQuota
+}
+
+// reserveTopic counts a topic about to be created in the category today, failing with a
+// QuotaError if the category reached its quota. A reserved topic which is not created is
+// given back with releaseTopic.
+func reserveTopic(categoryID int) error {
+	quota := dailyQuota(categoryID)
+	if quota <= 0 {
+		return nil
+	}
+
+	quotaMu.Lock()
+	defer quotaMu.Unlock()
+
+	if day := time.Now().UTC().Format(dayLayout); day != quotaDay {
+		quotaDay, quotaUsed = day, createdOn(day)
+	}
+	if quotaUsed[categoryID] >= quota {
+		return &QuotaError{CategoryID: categoryID, Quota: quota}
+	}
+	quotaUsed[categoryID]++
+	return nil
+}
+
+func releaseTopic(categoryID int) {
+	if dailyQuota(categoryID) <= 0 {
+		return
+	}
+
+	quotaMu.Lock()
+	defer quotaMu.Unlock()
+	if quotaUsed[categoryID] > 0 {
+		quotaUsed[categoryID]--
+	}
+}
+
+// createdOn counts the topics of the state file created on the given UTC day per category.
+func createdOn(day string) map[int]int {
+	used := map[int]int{}
+	for _, rec := range state.All() {
+		if rec.CategoryID == 0 || !rec.IsDone(state.StepDiscourse) {
+			continue
+		}
+		for _, e := range rec.Events {
+			if e.Step == state.StepDiscourse && e.Time.UTC().Format(dayLayout) == day {
+				used[rec.CategoryID]++
+				break
+			}
+		}
+	}
+	return used
+}
+
+// NextDay returns the time left until the quotas are reset, at the next UTC midnight.
+func NextDay() time.Duration {
+	now := time.Now().UTC()
+	return now.Truncate(24 * time.Hour).Add(24 * time.Hour).Sub(now)
+}
@@ -0,0 +1,75 @@
+package runmode
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/bitrise-io/go-utils/log"
+	gh "github.com/google/go-github/github"
+
+	"github.com/lszucs/github-to-discourse/internal/discourse"
+	"github.com/lszucs/github-to-discourse/internal/github"
+	"github.com/lszucs/github-to-discourse/internal/state"
+	"github.com/lszucs/github-to-discourse/internal/templates"
+)
+
+const replyDateLayout = "2006-01-02 15:04 UTC"
+
+var withComments bool
+
+func init() {
+	flag.BoolVar(&withComments, "with-comments", false, "--with-comments (post the comments of the issue as replies on its topic, oldest first, attributed to their GitHub authors)")
+}
+
+// fetchesComments reports whether the comments of active issues are fetched.
+func fetchesComments() bool {
+	return attachOriginal || highlightCount > 0 || withComments
+}
+
+// replyContent attributes the comment to its GitHub author.
+func replyContent(c *gh.IssueComment) string {
+	return fmt.Sprintf("_Originally posted by @%s on %s:_\n\n%s\n\n[View on GitHub](%s)", c.GetUser().GetLogin(), c.GetCreatedAt().UTC().Format(replyDateLayout), c.GetBody(), c.GetHTMLURL())
+}
+
+// postReplies posts the comments of the issue as replies on its topic in order. Comments already
+// replied with on the topic, recorded in the state, are skipped.
+func postReplies(i *gh.Issue, rec *state.Record, data templates.Data) error {
+	if data.Redacted || rec.TopicID == 0 || i.GetComments() == 0 {
+		return nil
+	}
+	comments, err := github.GetComments(i)
+	if err != nil {
+		return fmt.Errorf("get comments of %s: %s", i.GetHTMLURL(), err)
+	}
+	if comments, _, err = filterLocked(i, comments); err != nil {
+		return err
+	}
+
+	replied := map[int64]bool{}
+	for _, id := range rec.Replies {
+		replied[id] = true
+	}
+	posted := 0
+	for _, c := range comments {
+		if replied[c.GetID()] {
+			continue
+		}
+		_, call, err := discourse.PostReply(discourse.Reply{
+			TopicID:    rec.TopicID,
+			Content:    replyContent(c),
+			CreatedAt:  c.GetCreatedAt(),
+			CategoryID: rec.CategoryID,
+			OriginURL:  i.GetHTMLURL(),
+		})
+		if err != nil {
+			rec.Called(call)
+			return fmt.Errorf("reply with comment %s: %s", c.GetHTMLURL(), err)
+		}
+		rec.Replies = append(rec.Replies, c.GetID())
+		posted++
+	}
+	if posted > 0 {
+		log.Printf("posted %d comments as replies", posted)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package runmode
+
+import (
+	"github.com/bitrise-io/go-utils/log"
+	gh "github.com/google/go-github/github"
+
+	"github.com/lszucs/github-to-discourse/internal/github"
+	"github.com/lszucs/github-to-discourse/internal/state"
+)
+
+// lastStep returns the last step of --actions an issue goes through.
+func lastStep() string {
+	for _, step := range []string{state.StepLock, state.StepClose, state.StepComment, state.StepDiscourse} {
+		if enabled(step) {
+			return step
+		}
+	}
+	return ""
+}
+
+// Resume forwards the issues whose migration stopped halfway in an earlier run first, fetched one
+// by one as issues closed since are not listed among the open ones, then the fetched issues which
+// were not resumed.
+func Resume(fetched <-chan *gh.Issue, stop <-chan struct{}) <-chan *gh.Issue {
+	out := make(chan *gh.Issue)
+	go func() {
+		defer close(out)
+
+		send := func(i *gh.Issue) bool {
+			select {
+			case out <- i:
+				return true
+			case <-stop:
+				return false
+			}
+		}
+
+		unfinished := state.Resume(lastStep())
+		if len(unfinished) > 0 {
+			log.Printf("resume %d issues stopped halfway", len(unfinished))
+		}
+		resumed := map[string]bool{}
+		for _, rec := range unfinished {
+			i, err := github.GetIssue(rec.IssueURL)
+			if err != nil {
+				log.Warnf("resume %s: %s", rec.IssueURL, err)
+				continue
+			}
+			resumed[i.GetHTMLURL()] = true
+			if !send(i) {
+				return
+			}
+		}
+
+		for i := range fetched {
+			if resumed[i.GetHTMLURL()] {
+				continue
+			}
+			if !send(i) {
+				return
+			}
+		}
+	}()
+	return out
+}
@@ -0,0 +1,164 @@
+package runmode
+
+import (
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	gh "github.com/google/go-github/github"
+
+	"github.com/lszucs/github-to-discourse/internal/state"
+)
+
+// fakeGitHub answers the GitHub API in place of http.DefaultTransport and records the requests.
+type fakeGitHub struct {
+	mu       sync.Mutex
+	requests []string
+}
+
+func (f *fakeGitHub) RoundTrip(r *http.Request) (*http.Response, error) {
+	f.mu.Lock()
+	f.requests = append(f.requests, r.Method+" "+r.URL.Path)
+	f.mu.Unlock()
+
+	status, body := http.StatusOK, `{"id": 7}`
+	switch {
+	case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/comments"):
+		body = `[{"id": 1, "body": "same here", "user": {"login": "octocat"}}]`
+	case r.Method == http.MethodPost:
+		status = http.StatusCreated
+	case r.Method == http.MethodPut:
+		status, body = http.StatusNoContent, ""
+	}
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       ioutil.NopCloser(strings.NewReader(body)),
+		Request:    r,
+	}, nil
+}
+
+func (f *fakeGitHub) count(method, suffix string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := 0
+	for _, r := range f.requests {
+		if strings.HasPrefix(r, method+" ") && strings.HasSuffix(r, suffix) {
+			n++
+		}
+	}
+	return n
+}
+
+func setFlag(t *testing.T, name, value string) {
+	t.Helper()
+	old := flag.Lookup(name).Value.String()
+	if err := flag.Set(name, value); err != nil {
+		t.Fatalf("set --%s: %s", name, err)
+	}
+	t.Cleanup(func() { flag.Set(name, old) })
+}
+
+func countDone(rec *state.Record, step string) int {
+	n := 0
+	for _, s := range rec.Done {
+		if s == step {
+			n++
+		}
+	}
+	return n
+}
+
+func TestProcessResumesHalfDoneIssue(t *testing.T) {
+	tests := []struct {
+		name string
+		done []string
+		// wantRequests are the GitHub requests of the steps left
+		wantRequests []string
+	}{
+		{
+			name:         "topic and comment posted",
+			done:         []string{state.StepDiscourse, state.StepComment},
+			wantRequests: []string{"GET /repos/octo/repo/issues/7/comments", "PATCH /repos/octo/repo/issues/7", "PUT /repos/octo/repo/issues/7/lock"},
+		},
+		{
+			name:         "closed before the lock failed",
+			done:         []string{state.StepDiscourse, state.StepComment, state.StepClose},
+			wantRequests: []string{"GET /repos/octo/repo/issues/7/comments", "PUT /repos/octo/repo/issues/7/lock"},
+		},
+		{
+			name: "every step done",
+			done: []string{state.StepDiscourse, state.StepComment, state.StepClose, state.StepLock},
+			// the replies are checked again, the comment was replied with already
+			wantRequests: []string{"GET /repos/octo/repo/issues/7/comments"},
+		},
+	}
+
+	var discourseRequests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		discourseRequests = append(discourseRequests, r.Method+" "+r.URL.Path)
+		http.Error(w, "unexpected request", http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	setFlag(t, "discourse-url", srv.URL)
+	setFlag(t, "actions", defaultActions)
+	setFlag(t, "with-comments", "true")
+	setFlag(t, "max-retries", "0")
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setFlag(t, "state-file", filepath.Join(t.TempDir(), "state.json"))
+			fake := &fakeGitHub{}
+			defaultTransport := http.DefaultTransport
+			http.DefaultTransport = fake
+			defer func() { http.DefaultTransport = defaultTransport }()
+			discourseRequests = nil
+
+			now := time.Now()
+			i := &gh.Issue{
+				Number:    gh.Int(7),
+				State:     gh.String("open"),
+				Comments:  gh.Int(1),
+				HTMLURL:   gh.String("https://github.com/octo/repo/issues/7"),
+				UpdatedAt: &now,
+				User:      &gh.User{Login: gh.String("octocat")},
+			}
+			rec := state.Get(i.GetHTMLURL())
+			rec.Done = append([]string(nil), tt.done...)
+			rec.TopicURL, rec.TopicID, rec.CommentID = "https://forum.example.com/t/3", 3, 42
+			rec.Replies = []int64{1}
+
+			var stats Stats
+			if err := Process(i, &stats); err != nil {
+				t.Fatalf("Process: %s", err)
+			}
+			rec, _ = state.Lookup(i.GetHTMLURL())
+
+			if len(discourseRequests) > 0 {
+				t.Errorf("Discourse requests = %v, want none: the topic and its replies were posted", discourseRequests)
+			}
+			if n := fake.count(http.MethodPost, "/comments"); n > 0 {
+				t.Errorf("%d comments posted on GitHub, want none: the comment was posted", n)
+			}
+			if strings.Join(fake.requests, ", ") != strings.Join(tt.wantRequests, ", ") {
+				t.Errorf("GitHub requests = %v, want %v", fake.requests, tt.wantRequests)
+			}
+			for _, step := range []string{state.StepDiscourse, state.StepComment, state.StepClose, state.StepLock} {
+				if n := countDone(rec, step); n != 1 {
+					t.Errorf("step %s done %d times, want once", step, n)
+				}
+			}
+			if rec.TopicURL != "https://forum.example.com/t/3" || rec.CommentID != 42 {
+				t.Errorf("record = topic %s, comment %d, want the recorded topic and comment kept", rec.TopicURL, rec.CommentID)
+			}
+			state.Delete(i.GetHTMLURL())
+		})
+	}
+}
@@ -1,105 +1,619 @@
 package runmode
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"strings"
+	"sync"
 
-
-	"time"
-
-	gh "github.com/google/go-github/github"
 	"github.com/bitrise-io/go-utils/log"
+	gh "github.com/google/go-github/github"
 
-	"github.com/lszucs/github-to-discourse/internal/github"
+	"github.com/lszucs/github-to-discourse/internal/archive"
+	"github.com/lszucs/github-to-discourse/internal/budget"
+	"github.com/lszucs/github-to-discourse/internal/dashboard"
 	"github.com/lszucs/github-to-discourse/internal/discourse"
+	"github.com/lszucs/github-to-discourse/internal/github"
+	"github.com/lszucs/github-to-discourse/internal/hooks"
+	"github.com/lszucs/github-to-discourse/internal/onerror"
+	"github.com/lszucs/github-to-discourse/internal/overrides"
+	"github.com/lszucs/github-to-discourse/internal/run"
+	"github.com/lszucs/github-to-discourse/internal/state"
+	"github.com/lszucs/github-to-discourse/internal/steplib"
+	"github.com/lszucs/github-to-discourse/internal/templates"
 )
 
-const (
-	discourseDone = iota
-	commentDone
-	closeDone
-	lockDone
-)
+// DryRun prints what would happen to the issues received until the channel is closed.
+func DryRun(issues <-chan *gh.Issue) (Stats, error) {
+	var stats Stats
+	var mu sync.Mutex
+	parallel(issues, func(i *gh.Issue) string {
+		var out strings.Builder
+		var is Stats
+		dryIssue(i, &out, &is)
+		writeArtifact(i)
 
-const (
-	activeTpl = `Hi %s!
-	We are migrating our GitHub issues to Discourse (https://discuss.bitrise.io/c/issues/build-issues).
-	From now on, you can track this issue at: %s`
-	staleTpl  = `Hi %s!
-	We are migrating our GitHub issues to Discourse (https://discuss.bitrise.io/c/issues/build-issues).
-	Because this issue has been inactive for more than three months, we will be closing it.
-	
-	If you feel it is still relevant, please open a ticket on Discourse!`
-)
+		mu.Lock()
+		stats.add(is)
+		stats.Processed++
+		mu.Unlock()
+		return out.String()
+	}, func(out string) {
+		fmt.Print(out)
+	})
+	return stats, nil
+}
 
-func DryRun(issues []*gh.Issue) (Stats, error) {
-	var stats Stats
-	for _, i := range issues {
-		log.Printf("process issue %s", i.GetHTMLURL())
-		if i.IsPullRequest() {
-			stats.PullRequest++
-			fmt.Println(fmt.Sprintf("skip %s: is pull request", i.GetHTMLURL()))
-			continue
+// dryIssue writes what would happen to the issue to out.
+func dryIssue(i *gh.Issue, out io.Writer, stats *Stats) {
+	fmt.Fprintf(out, "process issue %s\n", i.GetHTMLURL())
+	recordPlan(i)
+	if i.IsPullRequest() {
+		stats.PullRequest++
+		fmt.Fprintf(out, "skip %s: is pull request\n", i.GetHTMLURL())
+		return
+	}
+
+	if hooked := hooks.Evaluate(i); len(hooked.Matched) > 0 {
+		fmt.Fprintf(out, "%s matches hooks of labels %s\n", i.GetHTMLURL(), hooked.Matched)
+		if hooked.Skip {
+			stats.Skipped++
+			fmt.Fprintf(out, "%s would be skipped by hook\n", i.GetHTMLURL())
+			return
 		}
-	
-		if !github.IsStale(i) {
-			stats.Active++
-			fmt.Println(fmt.Sprintf("%s is active", i.GetHTMLURL()))
+		if hooked.Redact {
+			fmt.Fprintf(out, "%s content would be redacted from the topic\n", i.GetHTMLURL())
+		}
+	}
+
+	if campaign {
+		stats.Campaigned++
+		if comment, err := templates.Execute(templates.Campaign, templates.NewData(i)); err != nil {
+			fmt.Fprintf(out, "%s campaign comment: %s\n", i.GetHTMLURL(), err)
 		} else {
-			stats.Stale++
-			fmt.Println(fmt.Sprintf("%s is stale", i.GetHTMLURL()))
+			fmt.Fprintf(out, "%s would be commented:\n%s\n", i.GetHTMLURL(), comment)
 		}
-		time.Sleep(time.Millisecond + 1000)
+		return
 	}
-	stats.Processed = len(issues)
-	return stats, nil
+
+	if _, ok := overrides.Get(i.GetHTMLURL()); ok {
+		fmt.Fprintf(out, "%s has an override\n", i.GetHTMLURL())
+	}
+
+	if mirror {
+		stats.Mirrored++
+		fmt.Fprintf(out, "%s would be mirrored, GitHub is left untouched\n", i.GetHTMLURL())
+	} else if !isStale(i) {
+		stats.Active++
+		if github.IsStale(i) && engaged(i) {
+			fmt.Fprintf(out, "%s is stale but engaged, handled as active\n", i.GetHTMLURL())
+		} else {
+			fmt.Fprintf(out, "%s is active\n", i.GetHTMLURL())
+		}
+	} else {
+		stats.Stale++
+		fmt.Fprintf(out, "%s is stale\n", i.GetHTMLURL())
+	}
+	if _, forced := overrides.Get(i.GetHTMLURL()); linkDuplicates && !mirror && !forced {
+		if canonical, topicURL, ok, err := canonicalTopic(i); err != nil {
+			fmt.Fprintf(out, "%s duplicate check: %s\n", i.GetHTMLURL(), err)
+		} else if ok {
+			fmt.Fprintf(out, "%s is a duplicate of %s, the comment would link %s instead of a new topic\n", i.GetHTMLURL(), canonical, topicURL)
+			return
+		} else if canonical != "" {
+			fmt.Fprintf(out, "%s is a duplicate of %s, its topic would be linked if it has one by then\n", i.GetHTMLURL(), canonical)
+		}
+	}
+	if _, forced := overrides.Get(i.GetHTMLURL()); linkCopies && !mirror && !forced {
+		if original, ok := originalOf(i, nil); ok {
+			fmt.Fprintf(out, "%s is a copy of %s, the comment would link its topic instead of a new topic\n", i.GetHTMLURL(), original)
+			return
+		}
+	}
+	if mirror || !isStale(i) {
+		data := templates.NewData(i)
+		title, err := templates.TopicTitle(data)
+		if err != nil {
+			fmt.Fprintf(out, "%s topic title: %s\n", i.GetHTMLURL(), err)
+		} else if data.FallbackTitle {
+			fmt.Fprintf(out, "%s has no valid title, topic title would be the fallback %q\n", i.GetHTMLURL(), title)
+		} else {
+			fmt.Fprintf(out, "%s topic title would be %q\n", i.GetHTMLURL(), title)
+		}
+		if withComments && !mirror && i.GetComments() > 0 {
+			fmt.Fprintf(out, "%s %d comments would be posted as replies\n", i.GetHTMLURL(), i.GetComments())
+		}
+	}
+	if i.GetLocked() {
+		fmt.Fprintf(out, "%s is already locked, lock would be skipped\n", i.GetHTMLURL())
+	}
+	if ccMaintainers != "" {
+		if m := maintainers(i); len(m) > 0 {
+			fmt.Fprintf(out, "%s maintainers %s would be cc'd (%s)\n", i.GetHTMLURL(), strings.Join(m, ", "), ccMaintainers)
+		}
+	}
+}
+
+// isStale reports whether the issue is stale and not engaged, unless the overrides file forces the decision.
+func isStale(i *gh.Issue) bool {
+	if o, ok := overrides.Get(i.GetHTMLURL()); ok && o.Treat != "" {
+		return o.Treat == overrides.Stale
+	}
+	return github.IsStale(i) && !engaged(i)
+}
+
+// commentedAsStale reports whether an earlier attempt got as far as the stale comment, the issue
+// looks active since, being updated by the comment.
+func commentedAsStale(rec *state.Record) bool {
+	return rec.IsDone(state.StepComment) && !rec.IsDone(state.StepDiscourse) && rec.DuplicateOf == ""
+}
+
+// LiveRun migrates the issues received until the channel is closed.
+func LiveRun(issues <-chan *gh.Issue) (Stats, error) {
+	return LiveRunUntil(issues, nil)
 }
 
-func LiveRun(issues []*gh.Issue) (Stats, error) {
+// ErrCanceled is returned by LiveRunUntil if the run was stopped before processing every issue.
+var ErrCanceled = errors.New("run canceled")
+
+// LiveRunUntil migrates the issues like LiveRun, but stops before the next issue once stop is closed.
+func LiveRunUntil(issues <-chan *gh.Issue, stop <-chan struct{}) (Stats, error) {
+	if pipelined {
+		return pipelineRun(issues, stop)
+	}
+
 	var stats Stats
-	for _, i := range issues {
-		log.Infof("process issue %s", i.GetHTMLURL())
+	for {
+		select {
+		case <-stop:
+			return stats, ErrCanceled
+		default:
+		}
+
+		var i *gh.Issue
+		select {
+		case <-stop:
+			return stats, ErrCanceled
+		case next, ok := <-issues:
+			if !ok {
+				return stats, nil
+			}
+			i = next
+		}
+
 		if i.IsPullRequest() {
 			stats.PullRequest++
 			log.Printf("skip %s: is pull request", i.GetHTMLURL())
 			continue
 		}
-	
-		var commentTpl string
-		commentTplParams := []interface{}{i.GetUser().GetLogin()}
-		if !github.IsStale(i) {
-			stats.Active++
-			
-			log.Printf("post to discourse")
-			url, err := discourse.PostTopic(i.GetTitle(), i.GetHTMLURL(), i.GetBody())
-			if err != nil {
+
+		dashboard.Wait()
+		if dashboard.Skipped(i) {
+			log.Printf("skip %s: repo skipped from the dashboard", i.GetHTMLURL())
+			continue
+		}
+
+		if !budget.Allow(IssueCalls()) {
+			log.Warnf("API call budget exhausted, stop before %s", i.GetHTMLURL())
+			return stats, budget.ErrExhausted
+		}
+
+		if err := Process(i, &stats); err != nil {
+			if err := onerror.Handle(err); err != nil {
 				return stats, err
 			}
+			continue
+		}
+	}
+}
 
-			commentTpl = activeTpl
-			commentTplParams = append(commentTplParams, url)
-		} else {
-			log.Printf("skip %s: is stale", i.GetHTMLURL())
-			stats.Stale++
-			commentTpl = staleTpl
+// Process migrates a single issue and records its progress in the state file.
+func Process(i *gh.Issue, stats *Stats) error {
+	rec, ok := begin(i, stats)
+	if !ok {
+		return nil
+	}
+	if !checkDrift(i, rec, stats) {
+		dashboard.Finished(i, nil)
+		return save(rec)
+	}
+	return finish(i, rec, migrate(i, rec, stats), stats)
+}
+
+// begin checks out the record of the issue, ok is false if the issue is quarantined. The record is
+// saved by save or finish.
+func begin(i *gh.Issue, stats *Stats) (*state.Record, bool) {
+	log.Infof("process issue %s", i.GetHTMLURL())
+	rec := state.Checkout(i.GetHTMLURL())
+	if rec.Status == state.StatusQuarantined {
+		log.Warnf("skip %s: quarantined after %d failed attempts", i.GetHTMLURL(), rec.Attempts)
+		stats.Quarantined++
+		dashboard.Finished(i, nil)
+		return rec, false
+	}
+	dashboard.Started(i)
+	if ref, err := github.ParseIssueURL(i.GetHTMLURL()); err == nil {
+		rec.Repo = ref.FullName()
+		rec.Number = ref.Number
+	}
+	rec.Author = i.GetUser().GetLogin()
+	if rec.RunID == "" {
+		rec.RunID = run.ID()
+	}
+	return rec, true
+}
+
+// finish records the outcome of the migration of the issue and saves the state.
+func finish(i *gh.Issue, rec *state.Record, err error, stats *Stats) error {
+	defer dashboard.Finished(i, err)
+	if quota, ok := err.(*QuotaError); ok {
+		log.Warnf("defer %s to the next day: %s", i.GetHTMLURL(), quota)
+		rec.Status = state.StatusDeferred
+		stats.Deferred++
+		return save(rec)
+	}
+	if err != nil {
+		rec.Fail(err)
+		compensate(i, rec)
+		if _, ok := err.(*discourse.BlockedWordsError); ok {
+			// posting the same content again fails the same way
+			log.Warnf("quarantine %s: %s", i.GetHTMLURL(), err)
+			rec.Quarantine()
+			stats.Quarantined++
+			return save(rec)
+		}
+		if exhausted(rec) {
+			log.Warnf("quarantine %s after %d failed attempts: %s", i.GetHTMLURL(), rec.Attempts, err)
+			rec.Quarantine()
+			stats.Quarantined++
+			return save(rec)
+		}
+
+		stats.Failed++
+		if serr := save(rec); serr != nil {
+			log.Warnf("%s", serr)
+		}
+		return err
+	}
+	rec.Succeed()
+	stats.Processed++
+	return save(rec)
+}
+
+// save commits the record and saves the state.
+func save(rec *state.Record) error {
+	state.Commit(rec)
+	return state.Save()
+}
+
+// redactedContent replaces the body of issues redacted by a hook.
+const redactedContent = "The content of this issue is not migrated publicly, see the original issue on GitHub."
+
+// topicContent renders the content of the topic of the issue, attach returns the link to the
+// attached original of the issue with --attach-original.
+func topicContent(i *gh.Issue, data templates.Data, attach func([]*gh.IssueComment) (string, error)) (string, error) {
+	footer, err := templates.Execute(templates.Footer, data)
+	if err != nil {
+		return "", err
+	}
+
+	content := renderTaskLists(i) + "\n\n"
+	if data.Redacted {
+		content = redactedContent + "\n\n"
+	}
+
+	var comments []*gh.IssueComment
+	if !data.Redacted && fetchesComments() && i.GetComments() > 0 {
+		if comments, err = github.GetComments(i); err != nil {
+			return "", err
 		}
-	
+	}
+	comments, held, err := filterLocked(i, comments)
+	if err != nil {
+		return "", err
+	}
+	if held != "" {
+		content += held + "\n\n"
+	}
+
+	if !data.Redacted && highlightCount > 0 {
+		if h := highlights(comments, highlightCount); h != "" {
+			content += h + "\n"
+		}
+	}
+
+	if !data.Redacted && attachOriginal {
+		link, err := attach(comments)
+		if err != nil {
+			return "", fmt.Errorf("upload original of %s: %s", i.GetHTMLURL(), err)
+		}
+		content += link + "\n\n"
+	}
+
+	if link := archive.PageURL(i.GetHTMLURL()); link != "" && !data.Redacted {
+		content += fmt.Sprintf("The full history of the issue is archived at %s\n\n", link)
+	}
+	return content + footer, nil
+}
+
+// topicTags returns the tags of the topic of the issue: the tag of the run, and the tag of its
+// step with --step-tags.
+func topicTags(i *gh.Issue) []string {
+	tags := []string{run.Tag()}
+	if ref, err := github.ParseIssueURL(i.GetHTMLURL()); err == nil {
+		if t := steplib.Tag(ref.Repo); t != "" {
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
+
+func postTopic(i *gh.Issue, rec *state.Record, data templates.Data) error {
+	if err := reserveTopic(data.CategoryID); err != nil {
+		return err
+	}
+	reserved := data.CategoryID
+	content, err := topicContent(i, data, func(comments []*gh.IssueComment) (string, error) {
+		log.Printf("upload original issue")
+		return uploadOriginal(i, comments)
+	})
+	if err != nil {
+		return err
+	}
+
+	title, err := templates.TopicTitle(data)
+	if err != nil {
+		return err
+	}
+	if data.FallbackTitle {
+		log.Warnf("%s has no valid title, post as %q", i.GetHTMLURL(), title)
+		rec.FallbackTitle = true
+	}
+
+	log.Printf("post to discourse")
+	topic := discourse.Topic{
+		Title:      title,
+		OriginURL:  i.GetHTMLURL(),
+		Content:    content,
+		CreatedAt:  i.GetCreatedAt(),
+		Tags:       topicTags(i),
+		CategoryID: data.CategoryID,
+	}
+	if !data.Redacted {
+		topic.Author = i.GetUser().GetLogin()
+	}
+	created, err := discourse.PostTopic(topic)
+	if catErr, ok := err.(*discourse.CategoryError); ok && discourse.FallbackCategoryID() != 0 {
+		log.Warnf("%s, post to fallback category %d", catErr, discourse.FallbackCategoryID())
+		topic.CategoryID = discourse.FallbackCategoryID()
+		topic.Tags = append(topic.Tags, discourse.NeedsRecategorizationTag)
+		data.CategoryID = topic.CategoryID
+		created, err = discourse.PostTopic(topic)
+	}
+	if err != nil {
+		releaseTopic(reserved)
+		rec.Called(created.Call)
+		if blocked, ok := err.(*discourse.BlockedWordsError); ok {
+			return blocked
+		}
+		return fmt.Errorf("post topic for %s: %s", i.GetHTMLURL(), err)
+	}
+	rec.TopicURL = created.URL
+	rec.TopicID, rec.PostID = created.TopicID, created.PostID
+	rec.Replies = nil
+	rec.Identity, rec.PostedAs = created.Identity, created.PostedAs
+	rec.CategoryID = data.CategoryID
+	rec.RunID = run.ID()
+	if rec.Status == state.StatusDeferred {
+		rec.Status = ""
+	}
+	if verifyTopics {
+		verifyTopic(rec, topic.Content)
+	}
+	if len(created.Rewritten) > 0 {
+		rec.Suspect = append(rec.Suspect, fmt.Sprintf("blocked words rewritten: %s", strings.Join(created.Rewritten, ", ")))
+	}
+	watchTopic(i, rec)
+	rec.MarkDoneBy(state.StepDiscourse, created.Call)
+	return nil
+}
+
+// step is an issue passing the steps of the migration.
+type step struct {
+	i    *gh.Issue
+	rec  *state.Record
+	data templates.Data
+	// commentTpl is the template of the GitHub comment, active if a topic was created.
+	commentTpl string
+	// done is set if no GitHub step is left.
+	done bool
+}
+
+func migrate(i *gh.Issue, rec *state.Record, stats *Stats) error {
+	s, err := discourseSteps(i, rec, stats)
+	if err != nil || s.done {
+		return err
+	}
+	return githubSteps(s, stats)
+}
+
+// discourseSteps runs the hooks of the issue and creates its topic if it is active.
+func discourseSteps(i *gh.Issue, rec *state.Record, stats *Stats) (step, error) {
+	s := step{i: i, rec: rec, data: templates.NewData(i), commentTpl: templates.Stale}
+	s.data.CategoryID = discourse.CategoryID()
+	if o, ok := overrides.Get(i.GetHTMLURL()); ok && o.CategoryID != 0 {
+		s.data.CategoryID = o.CategoryID
+	}
+
+	hooked := hooks.Evaluate(i)
+	if len(hooked.Matched) > 0 && !rec.IsDone(state.StepHooks) && !campaign {
+		log.Printf("run hooks of labels %s", hooked.Matched)
+		if err := hooks.Notify(i); err != nil {
+			return s, fmt.Errorf("run hooks of %s: %s", i.GetHTMLURL(), err)
+		}
+		rec.MarkDone(state.StepHooks)
+	}
+	if hooked.Skip {
+		log.Printf("skip %s: by hook", i.GetHTMLURL())
+		rec.Status = state.StatusSkipped
+		stats.Skipped++
+		s.done = true
+		return s, nil
+	}
+	s.data.Redacted = hooked.Redact
+	setName(&s.data)
+
+	if campaign {
+		s.commentTpl = templates.Campaign
+		return s, nil
+	}
+
+	if archive.Enabled() && !s.data.Redacted {
+		if err := snapshot(i); err != nil {
+			return s, err
+		}
+	}
+
+	if mirror {
+		s.done = true
+		return s, mirrorIssue(i, rec, s.data, stats)
+	}
+
+	if _, forced := overrides.Get(i.GetHTMLURL()); linkDuplicates && !forced {
+		canonical, topicURL, ok, err := canonicalTopic(i)
+		if err != nil {
+			return s, fmt.Errorf("check duplicate %s: %s", i.GetHTMLURL(), err)
+		}
+		if ok {
+			log.Printf("%s is a duplicate of %s, link its topic", i.GetHTMLURL(), canonical)
+			stats.Duplicates++
+			rec.DuplicateOf = canonical
+			s.commentTpl = templates.Duplicate
+			s.data.DuplicateOf = canonical
+			s.data.TopicURL = topicURL
+			return s, nil
+		}
+		if canonical != "" {
+			log.Printf("%s is a duplicate of %s, which has no topic", i.GetHTMLURL(), canonical)
+		}
+	}
+
+	if _, forced := overrides.Get(i.GetHTMLURL()); !forced && !rec.IsDone(state.StepDiscourse) {
+		original, topicURL, ok := originalTopic(i, rec)
+		if ok {
+			log.Printf("%s is a copy of %s, link its topic", i.GetHTMLURL(), original)
+			stats.Duplicates++
+			rec.DuplicateOf = original
+			s.commentTpl = templates.Duplicate
+			s.data.DuplicateOf = original
+			s.data.TopicURL = topicURL
+			return s, nil
+		}
+		if original != "" {
+			log.Printf("%s is a copy of %s, which has no topic", i.GetHTMLURL(), original)
+		}
+	}
+
+	if !isStale(i) && !commentedAsStale(rec) {
+		stats.Active++
+
+		if enabled(state.StepDiscourse) {
+			if rec.IsDone(state.StepDiscourse) {
+				log.Printf("skip topic: already posted to %s", rec.TopicURL)
+			} else if err := postTopic(i, rec, s.data); err != nil {
+				return s, err
+			}
+			if withComments {
+				if err := postReplies(i, rec, s.data); err != nil {
+					return s, err
+				}
+			}
+		}
+
+		s.commentTpl = templates.Active
+		s.data.TopicURL = rec.TopicURL
+	} else {
+		log.Printf("skip %s: is stale", i.GetHTMLURL())
+		stats.Stale++
+	}
+	return s, nil
+}
+
+// githubSteps comments, closes and locks the issue.
+func githubSteps(s step, stats *Stats) error {
+	if campaign {
+		return campaignComment(s, stats)
+	}
+
+	i, rec, data := s.i, s.rec, s.data
+	commentTpl := s.commentTpl
+
+	if enabled(state.StepComment) && rec.IsDone(state.StepComment) {
+		log.Printf("skip comment: already posted")
+	} else if enabled(state.StepComment) {
+		if commentTpl == templates.Active && rec.TopicURL == "" {
+			return fmt.Errorf("post comment to %s: no discourse topic recorded", i.GetHTMLURL())
+		}
+
+		comment, err := templates.Execute(commentTpl, data)
+		if err != nil {
+			return err
+		}
+		comment = withCC(i, comment)
+
 		log.Printf("post comment")
-		if err := github.PostComment(i, fmt.Sprintf(commentTpl, commentTplParams...)); err != nil {
-			return stats, fmt.Errorf("post comment to %s: %s",i.GetHTMLURL(), err)
+		commentID, call, err := github.PostComment(i, comment)
+		if err != nil {
+			if conv, ok := err.(*github.ConvertedError); ok {
+				return converted(conv, rec, stats, comment)
+			}
+			rec.Called(call)
+			return fmt.Errorf("post comment to %s: %s", i.GetHTMLURL(), err)
 		}
-		
-		log.Printf("close issue")
-		if err := github.Close(i); err != nil {
-			return stats, fmt.Errorf("close %s: %s",i.GetHTMLURL(), err)
+		rec.CommentID = commentID
+		rec.MarkDoneBy(state.StepComment, call)
+	}
+
+	if batchable(s) && closeAndLock(s) {
+		return nil
+	}
+
+	if enabled(state.StepClose) && !rec.IsDone(state.StepClose) {
+		if i.GetState() == "closed" {
+			log.Printf("skip close: already closed")
+			rec.MarkAlreadyDone(state.StepClose)
+		} else {
+			log.Printf("close issue as %s", reasonOf(s))
+			call, err := github.Close(i, reasonOf(s))
+			if err != nil {
+				if conv, ok := err.(*github.ConvertedError); ok {
+					return converted(conv, rec, stats, "")
+				}
+				rec.Called(call)
+				return fmt.Errorf("close %s: %s", i.GetHTMLURL(), err)
+			}
+			rec.MarkDoneBy(state.StepClose, call)
 		}
-		
-		log.Printf("lock issue")
-		if err := github.Lock(i); err != nil {
-			return stats, fmt.Errorf("lock %s: %s",i.GetHTMLURL(), err)
+	}
+
+	if enabled(state.StepLock) && !rec.IsDone(state.StepLock) {
+		if i.GetLocked() {
+			log.Printf("skip lock: already locked")
+			rec.MarkAlreadyDone(state.StepLock)
+		} else {
+			log.Printf("lock issue")
+			call, err := github.Lock(i)
+			if err != nil {
+				if conv, ok := err.(*github.ConvertedError); ok {
+					return converted(conv, rec, stats, "")
+				}
+				rec.Called(call)
+				return fmt.Errorf("lock %s: %s", i.GetHTMLURL(), err)
+			}
+			rec.MarkDoneBy(state.StepLock, call)
 		}
-	
-		stats.Processed++
-		time.Sleep(time.Millisecond + 1000)
 	}
-	return stats, nil
+
+	return nil
 }
@@ -0,0 +1,28 @@
+package runmode
+
+import (
+	"fmt"
+
+	"github.com/bitrise-io/go-utils/log"
+	gh "github.com/google/go-github/github"
+
+	"github.com/lszucs/github-to-discourse/internal/archive"
+	"github.com/lszucs/github-to-discourse/internal/github"
+)
+
+// snapshot saves the issue and its comments for export-archive before anything is changed.
+func snapshot(i *gh.Issue) error {
+	if archive.Taken(i.GetHTMLURL()) {
+		return nil
+	}
+
+	var comments []*gh.IssueComment
+	if i.GetComments() > 0 {
+		var err error
+		if comments, err = github.GetComments(i); err != nil {
+			return fmt.Errorf("snapshot %s: %s", i.GetHTMLURL(), err)
+		}
+	}
+	log.Printf("snapshot issue")
+	return archive.Save(i, comments)
+}
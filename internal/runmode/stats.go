@@ -1,9 +1,35 @@
 package runmode
 
-
 type Stats struct {
-	Processed   int
-	Stale       int
-	Active      int
-	PullRequest int
-}
\ No newline at end of file
+	Processed   int `json:"processed"`
+	Stale       int `json:"stale"`
+	Active      int `json:"active"`
+	PullRequest int `json:"pull_request"`
+	Failed      int `json:"failed"`
+	Converted   int `json:"converted"`
+	Mirrored    int `json:"mirrored"`
+	Quarantined int `json:"quarantined"`
+	Skipped     int `json:"skipped"`
+	Duplicates  int `json:"duplicates"`
+	Campaigned  int `json:"campaigned"`
+	// Drifted counts the issues not migrated for differing from --plan-file.
+	Drifted int `json:"drifted"`
+	// Deferred counts the issues left for the next day by the daily quota of their category.
+	Deferred int `json:"deferred"`
+}
+
+func (s *Stats) add(o Stats) {
+	s.Processed += o.Processed
+	s.Stale += o.Stale
+	s.Active += o.Active
+	s.PullRequest += o.PullRequest
+	s.Failed += o.Failed
+	s.Converted += o.Converted
+	s.Mirrored += o.Mirrored
+	s.Quarantined += o.Quarantined
+	s.Skipped += o.Skipped
+	s.Duplicates += o.Duplicates
+	s.Campaigned += o.Campaigned
+	s.Drifted += o.Drifted
+	s.Deferred += o.Deferred
+}
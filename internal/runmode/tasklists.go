@@ -0,0 +1,118 @@
+package runmode
+
+import (
+	"flag"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	gh "github.com/google/go-github/github"
+
+	"github.com/lszucs/github-to-discourse/internal/github"
+)
+
+// Task list rendering modes, see --task-lists.
+const (
+	tasksChecklist = "checklist"
+	tasksText      = "text"
+	tasksKeep      = "keep"
+)
+
+var taskLists string
+
+func init() {
+	flag.StringVar(&taskLists, "task-lists", tasksChecklist, "--task-lists=checklist|text|keep (render task lists of issues as checklists of the Discourse checklist plugin, as plain text check marks for instances without it, or keep the body as is)")
+}
+
+// ValidateTaskLists returns an error if --task-lists is unknown.
+func ValidateTaskLists() error {
+	switch taskLists {
+	case tasksChecklist, tasksText, tasksKeep:
+		return nil
+	default:
+		return fmt.Errorf("not recognized --task-lists %s", taskLists)
+	}
+}
+
+var (
+	taskItemRe = regexp.MustCompile(`^(\s*(?:[-*+]|\d+[.)])\s+)\[([ xX])\]\s+(.*)$`)
+	fenceRe    = regexp.MustCompile("^\\s*(```|~~~)")
+	// issueRefRe matches #123 and owner/name#123 references, which GitHub renders as links in task lists.
+	issueRefRe = regexp.MustCompile(`(^|[\s(])((?:[\w.-]+/[\w.-]+)?)#(\d+)\b`)
+)
+
+// renderTaskLists rewrites the task list items of the issue body with --task-lists, keeping their
+// checked state, links the issues they reference and heads the body with the progress.
+func renderTaskLists(i *gh.Issue) string {
+	body := i.GetBody()
+	if taskLists == tasksKeep {
+		return body
+	}
+	repo, err := github.ParseRepoURL(i.GetHTMLURL())
+	if err != nil {
+		return body
+	}
+
+	lines := strings.Split(body, "\n")
+	done, total := 0, 0
+	fenced := ""
+	for n, line := range lines {
+		if m := fenceRe.FindStringSubmatch(line); m != nil {
+			if fenced == "" {
+				fenced = m[1]
+			} else if fenced == m[1] {
+				fenced = ""
+			}
+			continue
+		}
+		if fenced != "" {
+			continue
+		}
+		m := taskItemRe.FindStringSubmatch(strings.TrimRight(line, "\r"))
+		if m == nil {
+			continue
+		}
+
+		total++
+		checked := m[2] != " "
+		if checked {
+			done++
+		}
+		lines[n] = m[1] + taskBox(checked) + " " + linkIssueRefs(m[3], repo)
+	}
+	if total == 0 {
+		return body
+	}
+	return fmt.Sprintf("**Tasks: %d of %d done**\n\n", done, total) + strings.Join(lines, "\n")
+}
+
+func taskBox(checked bool) string {
+	switch {
+	case taskLists == tasksText && checked:
+		return "☑"
+	case taskLists == tasksText:
+		return "☐"
+	case checked:
+		return "[x]"
+	default:
+		return "[ ]"
+	}
+}
+
+// linkIssueRefs replaces the issue references of a task with links to the issues on GitHub.
+func linkIssueRefs(task string, repo github.Repo) string {
+	return issueRefRe.ReplaceAllStringFunc(task, func(ref string) string {
+		m := issueRefRe.FindStringSubmatch(ref)
+		number, err := strconv.Atoi(m[3])
+		if err != nil {
+			return ref
+		}
+		target := github.IssueRef{Repo: repo, Number: number}
+		if m[2] != "" {
+			parts := strings.SplitN(m[2], "/", 2)
+			target.Repo = github.Repo{Host: repo.Host, Owner: parts[0], Name: parts[1]}
+		}
+		return fmt.Sprintf("%s[%s#%d](%s)", m[1], m[2], number, target.URL())
+	})
+}
@@ -0,0 +1,97 @@
+package runmode
+
+import (
+	"testing"
+
+	gh "github.com/google/go-github/github"
+)
+
+func TestRenderTaskLists(t *testing.T) {
+	tests := []struct {
+		name string
+		mode string
+		body string
+		want string
+	}{
+		{
+			name: "checklist",
+			mode: tasksChecklist,
+			body: "Plan:\n- [ ] write\n- [x] test\n* [X] ship",
+			want: "**Tasks: 2 of 3 done**\n\nPlan:\n- [ ] write\n- [x] test\n* [x] ship",
+		},
+		{
+			name: "text",
+			mode: tasksText,
+			body: "- [ ] write\n- [x] test",
+			want: "**Tasks: 1 of 2 done**\n\n- ☐ write\n- ☑ test",
+		},
+		{
+			name: "keep",
+			mode: tasksKeep,
+			body: "- [ ] write #1\n- [x] test",
+			want: "- [ ] write #1\n- [x] test",
+		},
+		{
+			name: "no tasks",
+			mode: tasksChecklist,
+			body: "- write\n- [link](https://example.com)",
+			want: "- write\n- [link](https://example.com)",
+		},
+		{
+			name: "numbered and nested items",
+			mode: tasksChecklist,
+			body: "1. [x] first\n   2) [ ] nested",
+			want: "**Tasks: 1 of 2 done**\n\n1. [x] first\n   2) [ ] nested",
+		},
+		{
+			name: "fenced code is left as is",
+			mode: tasksText,
+			body: "```\n- [ ] not a task\n```\n~~~\n- [x] neither\n```\n- [x] still fenced\n~~~\n- [ ] task",
+			want: "**Tasks: 0 of 1 done**\n\n```\n- [ ] not a task\n```\n~~~\n- [x] neither\n```\n- [x] still fenced\n~~~\n- ☐ task",
+		},
+		{
+			name: "issue references",
+			mode: tasksChecklist,
+			body: "- [ ] fix #12\n- [x] see other/lib#3 (and #4)\n- [ ] not a#5 ref",
+			want: "**Tasks: 1 of 3 done**\n\n" +
+				"- [ ] fix [#12](https://github.com/octo/repo/issues/12)\n" +
+				"- [x] see [other/lib#3](https://github.com/other/lib/issues/3) (and [#4](https://github.com/octo/repo/issues/4))\n" +
+				"- [ ] not a#5 ref",
+		},
+		{
+			name: "references outside tasks are left as is",
+			mode: tasksChecklist,
+			body: "Fixes #1\n- [ ] task",
+			want: "**Tasks: 0 of 1 done**\n\nFixes #1\n- [ ] task",
+		},
+		{
+			name: "carriage returns",
+			mode: tasksChecklist,
+			body: "- [X] done\r\n- [ ] todo\r",
+			want: "**Tasks: 1 of 2 done**\n\n- [x] done\n- [ ] todo",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setFlag(t, "task-lists", tt.mode)
+			i := &gh.Issue{Body: gh.String(tt.body), HTMLURL: gh.String("https://github.com/octo/repo/issues/7")}
+			if got := renderTaskLists(i); got != tt.want {
+				t.Errorf("renderTaskLists =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateTaskLists(t *testing.T) {
+	for _, mode := range []string{tasksChecklist, tasksText, tasksKeep} {
+		setFlag(t, "task-lists", mode)
+		if err := ValidateTaskLists(); err != nil {
+			t.Errorf("ValidateTaskLists(%s): %s", mode, err)
+		}
+	}
+	setFlag(t, "task-lists", "markdown")
+	if err := ValidateTaskLists(); err == nil {
+		t.Error("ValidateTaskLists(markdown): want error")
+	}
+}
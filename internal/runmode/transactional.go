@@ -0,0 +1,42 @@
+package runmode
+
+import (
+	"flag"
+
+	"github.com/bitrise-io/go-utils/log"
+	gh "github.com/google/go-github/github"
+
+	"github.com/lszucs/github-to-discourse/internal/cleanup"
+	"github.com/lszucs/github-to-discourse/internal/state"
+)
+
+var transactional bool
+
+func init() {
+	flag.BoolVar(&transactional, "transactional", false, "--transactional (undo the steps of an issue if a later step fails: delete its topic and comment, reopen and unlock it, so no issue is left half-migrated; the next attempt starts over)")
+}
+
+// compensate undoes the steps performed on the failed issue with --transactional. If undoing
+// fails too, the record is left as is, to be handled by the next attempt or cleanup.
+func compensate(i *gh.Issue, rec *state.Record) {
+	if !transactional || !partial(rec) {
+		return
+	}
+
+	log.Warnf("undo the steps of %s: %s", i.GetHTMLURL(), rec.Done)
+	if err := cleanup.Undo(rec); err != nil {
+		log.Errorf("undo %s: %s, the issue is left half-migrated", i.GetHTMLURL(), err)
+		return
+	}
+	rec.Reset()
+}
+
+// partial reports whether a step with side effects to undo has been performed on the issue.
+func partial(rec *state.Record) bool {
+	for _, s := range rec.Done {
+		if s != state.StepHooks {
+			return true
+		}
+	}
+	return false
+}
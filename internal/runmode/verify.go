@@ -0,0 +1,30 @@
+package runmode
+
+import (
+	"flag"
+
+	"github.com/bitrise-io/go-utils/log"
+
+	"github.com/lszucs/github-to-discourse/internal/discourse"
+	"github.com/lszucs/github-to-discourse/internal/state"
+)
+
+var verifyTopics bool
+
+func init() {
+	flag.BoolVar(&verifyTopics, "verify-topics", true, "--verify-topics (check the rendered HTML of created topics for broken markdown, flagging suspect topics in the report)")
+}
+
+// verifyTopic flags the topic of the record as suspect if its rendered HTML looks broken. Failing
+// to verify doesn't fail the issue.
+func verifyTopic(rec *state.Record, raw string) {
+	cooked, err := discourse.FirstPostCooked(rec.TopicID)
+	if err != nil {
+		log.Warnf("verify %s: %s", rec.TopicURL, err)
+		return
+	}
+	rec.Suspect = discourse.VerifyCooked(raw, cooked)
+	for _, p := range rec.Suspect {
+		log.Warnf("suspect topic %s: %s", rec.TopicURL, p)
+	}
+}
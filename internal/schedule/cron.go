@@ -0,0 +1,156 @@
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxLookahead bounds the search for the next time of an expression, which may never match,
+// e.g. 0 0 30 2 *.
+const maxLookahead = 5 * 366 * 24 * time.Hour
+
+// macros are the shorthands of common expressions.
+var macros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// field is the set of values matched by a field of an expression.
+type field map[int]bool
+
+// Cron is a parsed cron expression: minute, hour, day of month, month and day of week.
+type Cron struct {
+	minute, hour, dom, month, dow field
+	// domAny and dowAny are set if the day fields are *, cron matches a day if either of the
+	// restricted ones matches.
+	domAny, dowAny bool
+}
+
+// Parse parses a standard five field cron expression, e.g. "0 2 * * *". Fields are * or lists of
+// values, ranges (1-5) and steps (*/15, 1-30/2); 7 is Sunday as well as 0. The @hourly, @daily,
+// @weekly, @monthly and @yearly shorthands are supported too.
+func Parse(expr string) (Cron, error) {
+	expr = strings.TrimSpace(expr)
+	if m, ok := macros[expr]; ok {
+		expr = m
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return Cron{}, fmt.Errorf("cron expression %q: want 5 fields (minute hour day-of-month month day-of-week), got %d", expr, len(fields))
+	}
+
+	var c Cron
+	var err error
+	if c.minute, err = parseField(fields[0], 0, 59); err != nil {
+		return Cron{}, fmt.Errorf("cron expression %q: minute: %s", expr, err)
+	}
+	if c.hour, err = parseField(fields[1], 0, 23); err != nil {
+		return Cron{}, fmt.Errorf("cron expression %q: hour: %s", expr, err)
+	}
+	if c.dom, err = parseField(fields[2], 1, 31); err != nil {
+		return Cron{}, fmt.Errorf("cron expression %q: day of month: %s", expr, err)
+	}
+	if c.month, err = parseField(fields[3], 1, 12); err != nil {
+		return Cron{}, fmt.Errorf("cron expression %q: month: %s", expr, err)
+	}
+	if c.dow, err = parseField(fields[4], 0, 7); err != nil {
+		return Cron{}, fmt.Errorf("cron expression %q: day of week: %s", expr, err)
+	}
+	if c.dow[7] {
+		c.dow[0] = true
+	}
+	c.domAny, c.dowAny = fields[2] == "*", fields[4] == "*"
+
+	if c.Next(time.Now()).IsZero() {
+		return Cron{}, fmt.Errorf("cron expression %q never matches", expr)
+	}
+	return c, nil
+}
+
+func parseField(s string, min, max int) (field, error) {
+	f := field{}
+	for _, part := range strings.Split(s, ",") {
+		rng, step := part, 1
+		if n := strings.Index(part, "/"); n >= 0 {
+			var err error
+			rng = part[:n]
+			if step, err = strconv.Atoi(part[n+1:]); err != nil || step <= 0 {
+				return nil, fmt.Errorf("invalid step in %s", part)
+			}
+		}
+
+		lo, hi := min, max
+		switch {
+		case rng == "*":
+		case strings.Contains(rng, "-"):
+			bounds := strings.SplitN(rng, "-", 2)
+			var err error
+			if lo, err = strconv.Atoi(bounds[0]); err != nil {
+				return nil, fmt.Errorf("invalid range %s", part)
+			}
+			if hi, err = strconv.Atoi(bounds[1]); err != nil {
+				return nil, fmt.Errorf("invalid range %s", part)
+			}
+		default:
+			v, err := strconv.Atoi(rng)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value %s", part)
+			}
+			lo, hi = v, v
+			if strings.Contains(part, "/") {
+				// 5/15 is 5, 20, 35, 50
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return nil, fmt.Errorf("%s is out of %d-%d", part, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			f[v] = true
+		}
+	}
+	return f, nil
+}
+
+func (c Cron) matchesDay(t time.Time) bool {
+	dom, dow := c.dom[t.Day()], c.dow[int(t.Weekday())]
+	switch {
+	case c.domAny && c.dowAny:
+		return true
+	case c.domAny:
+		return dow
+	case c.dowAny:
+		return dom
+	default:
+		return dom || dow
+	}
+}
+
+// Next returns the first time after t the expression matches, in the location of t, or the zero
+// time if it doesn't match in the next five years.
+func (c Cron) Next(t time.Time) time.Time {
+	end := t.Add(maxLookahead)
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	for t.Before(end) {
+		switch {
+		case !c.month[int(t.Month())]:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !c.matchesDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case !c.hour[t.Hour()]:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case !c.minute[t.Minute()]:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,102 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronNext(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+		// after and want are local times in UTC, formatted 2006-01-02 15:04
+		after string
+		want  string
+	}{
+		{name: "daily", expr: "0 2 * * *", after: "2024-03-10 01:59", want: "2024-03-10 02:00"},
+		{name: "daily passed today", expr: "0 2 * * *", after: "2024-03-10 02:00", want: "2024-03-11 02:00"},
+		{name: "every minute", expr: "* * * * *", after: "2024-03-10 23:59", want: "2024-03-11 00:00"},
+		{name: "minute range", expr: "10-12 * * * *", after: "2024-03-10 05:12", want: "2024-03-10 06:10"},
+		{name: "hour range", expr: "0 9-17 * * *", after: "2024-03-10 17:30", want: "2024-03-11 09:00"},
+		{name: "step", expr: "*/15 * * * *", after: "2024-03-10 05:16", want: "2024-03-10 05:30"},
+		{name: "step of a range", expr: "1-30/10 * * * *", after: "2024-03-10 05:12", want: "2024-03-10 05:21"},
+		{name: "step from a value", expr: "5/20 * * * *", after: "2024-03-10 05:26", want: "2024-03-10 05:45"},
+		{name: "list", expr: "0 6,18 * * *", after: "2024-03-10 07:00", want: "2024-03-10 18:00"},
+		{name: "list of ranges", expr: "0 1-2,22-23 * * *", after: "2024-03-10 03:00", want: "2024-03-10 22:00"},
+		// 2024-03-10 is a Sunday
+		{name: "day of week", expr: "0 0 * * 1", after: "2024-03-10 12:00", want: "2024-03-11 00:00"},
+		{name: "sunday as 7", expr: "0 0 * * 7", after: "2024-03-11 12:00", want: "2024-03-17 00:00"},
+		{name: "weekdays", expr: "30 8 * * 1-5", after: "2024-03-08 09:00", want: "2024-03-11 08:30"},
+		{name: "day of month", expr: "0 0 15 * *", after: "2024-03-10 12:00", want: "2024-03-15 00:00"},
+		// with both days restricted either matches: the 15th or the next Monday
+		{name: "day of month or week", expr: "0 0 15 * 1", after: "2024-03-10 12:00", want: "2024-03-11 00:00"},
+		{name: "day of month or week, month first", expr: "0 0 12 * 5", after: "2024-03-10 12:00", want: "2024-03-12 00:00"},
+		{name: "day of week with any day of month", expr: "0 0 * * 5", after: "2024-03-10 12:00", want: "2024-03-15 00:00"},
+		{name: "month rollover", expr: "0 0 1 * *", after: "2024-01-31 12:00", want: "2024-02-01 00:00"},
+		{name: "year rollover", expr: "0 0 * * *", after: "2024-12-31 23:59", want: "2025-01-01 00:00"},
+		{name: "month list", expr: "0 0 1 1,7 *", after: "2024-03-10 12:00", want: "2024-07-01 00:00"},
+		{name: "31st skips short months", expr: "0 0 31 * *", after: "2024-03-31 00:00", want: "2024-05-31 00:00"},
+		{name: "leap day", expr: "0 0 29 2 *", after: "2024-03-01 00:00", want: "2028-02-29 00:00"},
+		{name: "hourly", expr: "@hourly", after: "2024-03-10 05:00", want: "2024-03-10 06:00"},
+		{name: "weekly", expr: "@weekly", after: "2024-03-10 00:00", want: "2024-03-17 00:00"},
+		{name: "yearly", expr: "@yearly", after: "2024-03-10 00:00", want: "2025-01-01 00:00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := Parse(tt.expr)
+			if err != nil {
+				t.Fatalf("Parse(%q): %s", tt.expr, err)
+			}
+			after, err := time.Parse("2006-01-02 15:04", tt.after)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// seconds are dropped: the next minute is the first one after
+			got := c.Next(after.Add(30 * time.Second)).Format("2006-01-02 15:04")
+			if got != tt.want {
+				t.Errorf("Next(%s) = %s, want %s", tt.after, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCronNextKeepsLocation(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("no timezone database: %s", err)
+	}
+	c, err := Parse("0 2 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := c.Next(time.Date(2024, 6, 1, 12, 0, 0, 0, newYork))
+	if want := time.Date(2024, 6, 2, 2, 0, 0, 0, newYork); !got.Equal(want) || got.Location() != newYork {
+		t.Errorf("Next = %s, want %s", got, want)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []string{
+		"",
+		"0 2 * *",
+		"0 2 * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"a * * * *",
+		"1-x * * * *",
+		"@often",
+		// never matches
+		"0 0 30 2 *",
+	}
+	for _, expr := range tests {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("Parse(%q): want error", expr)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package schedule
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/bitrise-io/go-utils/log"
+
+	"github.com/lszucs/github-to-discourse/internal/delta"
+	"github.com/lszucs/github-to-discourse/internal/state"
+)
+
+var expr string
+
+func init() {
+	flag.StringVar(&expr, "schedule", "", "--schedule=<cron> (keep running and migrate on the cron schedule in local time, e.g. \"0 2 * * *\": a full run if the state file records none yet, then delta runs)")
+}
+
+// Enabled reports whether --schedule is set.
+func Enabled() bool {
+	return expr != ""
+}
+
+// Validate returns an error if --schedule is not a valid cron expression.
+func Validate() error {
+	if expr == "" {
+		return nil
+	}
+	_, err := Parse(expr)
+	return err
+}
+
+// Run runs the tool on the schedule with the flags and the repo arguments, each run in a process of
+// its own, so every run gets its own run ID, budgets and report. A run is the delta command once a
+// run is recorded in the state file, a regular run before. Runs never overlap: scheduled times
+// passing during a run are skipped. It returns once interrupted, after the current run finished.
+func Run(flags, args []string) error {
+	cron, err := Parse(expr)
+	if err != nil {
+		return err
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("find the executable: %s", err)
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	for {
+		next := cron.Next(time.Now())
+		log.Infof("next run at %s", next.Format(time.RFC3339))
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case sig := <-signals:
+			timer.Stop()
+			log.Printf("%s received, stop the schedule", sig)
+			return nil
+		case <-timer.C:
+		}
+
+		command, err := nextCommand()
+		if err != nil {
+			log.Errorf("skip the run of %s: %s", next.Format(time.RFC3339), err)
+			continue
+		}
+		cmdArgs := append(append([]string{}, flags...), command...)
+		cmdArgs = append(cmdArgs, args...)
+		interrupted, err := runOnce(exe, cmdArgs, signals)
+		if err != nil {
+			log.Errorf("run of %s: %s", next.Format(time.RFC3339), err)
+		}
+		if interrupted {
+			return nil
+		}
+	}
+}
+
+// nextCommand returns delta if the state file records a run, nothing for a regular run otherwise.
+func nextCommand() ([]string, error) {
+	if err := state.Load(); err != nil {
+		return nil, err
+	}
+	if _, _, ok := delta.LastRun(state.All()); ok {
+		return []string{"delta"}, nil
+	}
+	return nil, nil
+}
+
+// runOnce runs the tool and waits for it to exit, passing on the signals received meanwhile. It
+// reports whether it was interrupted.
+func runOnce(exe string, args []string, signals <-chan os.Signal) (bool, error) {
+	log.Infof("run %s", args)
+	cmd := exec.Command(exe, args...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Start(); err != nil {
+		return false, fmt.Errorf("start: %s", err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	interrupted := false
+	for {
+		select {
+		case err := <-done:
+			return interrupted, err
+		case sig := <-signals:
+			log.Printf("%s received, stop the schedule once the run finished", sig)
+			interrupted = true
+			if err := cmd.Process.Signal(sig); err != nil {
+				log.Warnf("pass %s on to the run: %s", sig, err)
+			}
+		}
+	}
+}
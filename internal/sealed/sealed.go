@@ -0,0 +1,152 @@
+package sealed
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// header marks encrypted files, it is followed by the nonce and the AES-GCM sealed content.
+var header = []byte("github-to-discourse/aes-gcm/v1\n")
+
+var (
+	keySrc string
+
+	keyOnce sync.Once
+	key     []byte
+	keyErr  error
+)
+
+func init() {
+	flag.StringVar(&keySrc, "encryption-key", "", "--encryption-key=env:<var>|exec:<command> (base64 encoded 32 byte AES key to encrypt the state, report and mapping files with, e.g. exec:'aws kms decrypt ...'; empty stores them in plain text)")
+}
+
+// Enabled reports whether files are encrypted.
+func Enabled() bool {
+	return keySrc != ""
+}
+
+func resolveKey() ([]byte, error) {
+	var encoded string
+	switch {
+	case strings.HasPrefix(keySrc, "env:"):
+		name := strings.TrimPrefix(keySrc, "env:")
+		encoded = os.Getenv(name)
+		if encoded == "" {
+			return nil, fmt.Errorf("%s empty", name)
+		}
+	case strings.HasPrefix(keySrc, "exec:"):
+		out, err := exec.Command("sh", "-c", strings.TrimPrefix(keySrc, "exec:")).Output()
+		if err != nil {
+			return nil, fmt.Errorf("run encryption key command: %s", err)
+		}
+		encoded = string(out)
+	default:
+		return nil, fmt.Errorf("not recognized encryption key source %s", keySrc)
+	}
+
+	k, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("decode encryption key: %s", err)
+	}
+	if len(k) != 32 {
+		return nil, fmt.Errorf("encryption key is %d bytes, 32 required", len(k))
+	}
+	return k, nil
+}
+
+func aead() (cipher.AEAD, error) {
+	keyOnce.Do(func() { key, keyErr = resolveKey() })
+	if keyErr != nil {
+		return nil, keyErr
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("create cipher: %s", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// CheckKey resolves the encryption key, if enabled, and returns an error if it is not usable.
+func CheckKey() error {
+	if !Enabled() {
+		return nil
+	}
+	_, err := aead()
+	return err
+}
+
+// Seal encrypts data if encryption is enabled.
+func Seal(data []byte) ([]byte, error) {
+	if !Enabled() {
+		return data, nil
+	}
+	gcm, err := aead()
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("generate nonce: %s", err)
+	}
+	out := append(append([]byte{}, header...), nonce...)
+	return gcm.Seal(out, nonce, data, header), nil
+}
+
+// Open decrypts data if it was encrypted by Seal. Plain data is returned as is, so files written
+// before encryption was enabled stay readable.
+func Open(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, header) {
+		return data, nil
+	}
+	if !Enabled() {
+		return nil, fmt.Errorf("encrypted, --encryption-key required")
+	}
+	gcm, err := aead()
+	if err != nil {
+		return nil, err
+	}
+
+	data = data[len(header):]
+	if len(data) < gcm.NonceSize() {
+		return nil, fmt.Errorf("encrypted content truncated")
+	}
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], header)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt: %s", err)
+	}
+	return plain, nil
+}
+
+// ReadFile reads the file and decrypts it if needed.
+func ReadFile(path string) ([]byte, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := Open(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	return plain, nil
+}
+
+// WriteFile encrypts data if encryption is enabled and writes it to the file.
+func WriteFile(path string, data []byte, perm os.FileMode) error {
+	sealed, err := Seal(data)
+	if err != nil {
+		return fmt.Errorf("%s: %s", path, err)
+	}
+	return ioutil.WriteFile(path, sealed, perm)
+}
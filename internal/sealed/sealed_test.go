@@ -0,0 +1,159 @@
+package sealed
+
+import (
+	"bytes"
+	"encoding/base64"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// useKey enables encryption with the key, read from the environment.
+func useKey(t *testing.T, k []byte) {
+	t.Setenv("SEALED_TEST_KEY", base64.StdEncoding.EncodeToString(k))
+	keySrc = "env:SEALED_TEST_KEY"
+	keyOnce, key, keyErr = sync.Once{}, nil, nil
+	t.Cleanup(func() {
+		keySrc = ""
+		keyOnce, key, keyErr = sync.Once{}, nil, nil
+	})
+}
+
+func testKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, 32)
+}
+
+func TestSealOpen(t *testing.T) {
+	useKey(t, testKey(1))
+	plain := []byte(`{"issue_url": "https://github.com/octo/repo/issues/7"}`)
+
+	sealed, err := Seal(plain)
+	if err != nil {
+		t.Fatalf("Seal: %s", err)
+	}
+	if !bytes.HasPrefix(sealed, header) || bytes.Contains(sealed, []byte("octo/repo")) {
+		t.Errorf("sealed = %q, want the header and no plain text", sealed)
+	}
+	again, err := Seal(plain)
+	if err != nil {
+		t.Fatalf("Seal: %s", err)
+	}
+	if bytes.Equal(sealed, again) {
+		t.Error("sealing twice gave the same output, want a new nonce each time")
+	}
+
+	opened, err := Open(sealed)
+	if err != nil {
+		t.Fatalf("Open: %s", err)
+	}
+	if !bytes.Equal(opened, plain) {
+		t.Errorf("Open = %q, want %q", opened, plain)
+	}
+}
+
+func TestFileRoundTrip(t *testing.T) {
+	useKey(t, testKey(1))
+	pth := filepath.Join(t.TempDir(), "state.json")
+	if err := WriteFile(pth, []byte("[]"), 0600); err != nil {
+		t.Fatalf("WriteFile: %s", err)
+	}
+	data, err := ReadFile(pth)
+	if err != nil {
+		t.Fatalf("ReadFile: %s", err)
+	}
+	if string(data) != "[]" {
+		t.Errorf("ReadFile = %q, want []", data)
+	}
+}
+
+func TestOpenFails(t *testing.T) {
+	useKey(t, testKey(1))
+	sealed, err := Seal([]byte("secret"))
+	if err != nil {
+		t.Fatalf("Seal: %s", err)
+	}
+	nonceEnd := len(header) + 12
+
+	tests := []struct {
+		name    string
+		data    func() []byte
+		key     []byte
+		noKey   bool
+		wantErr string
+	}{
+		{name: "wrong key", data: func() []byte { return sealed }, key: testKey(2), wantErr: "decrypt"},
+		{
+			name: "tampered ciphertext",
+			data: func() []byte {
+				tampered := append([]byte{}, sealed...)
+				tampered[len(tampered)-1] ^= 1
+				return tampered
+			},
+			wantErr: "decrypt",
+		},
+		{
+			name: "tampered nonce",
+			data: func() []byte {
+				tampered := append([]byte{}, sealed...)
+				tampered[nonceEnd-1] ^= 1
+				return tampered
+			},
+			wantErr: "decrypt",
+		},
+		{name: "truncated", data: func() []byte { return sealed[:nonceEnd-1] }, wantErr: "truncated"},
+		{name: "no key", data: func() []byte { return sealed }, noKey: true, wantErr: "--encryption-key required"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.key == nil {
+				tt.key = testKey(1)
+			}
+			useKey(t, tt.key)
+			if tt.noKey {
+				keySrc = ""
+			}
+			if _, err := Open(tt.data()); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Open: error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPlainPassesThrough(t *testing.T) {
+	plain := []byte(`[{"issue_url": "https://github.com/octo/repo/issues/7"}]`)
+	sealed, err := Seal(plain)
+	if err != nil || !bytes.Equal(sealed, plain) {
+		t.Errorf("Seal without a key = %q, %v, want the data as is", sealed, err)
+	}
+
+	// files written before encryption was enabled stay readable
+	useKey(t, testKey(1))
+	opened, err := Open(plain)
+	if err != nil || !bytes.Equal(opened, plain) {
+		t.Errorf("Open of plain data = %q, %v, want the data as is", opened, err)
+	}
+}
+
+func TestCheckKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		encoded string
+		wantErr bool
+	}{
+		{name: "valid", encoded: base64.StdEncoding.EncodeToString(testKey(1))},
+		{name: "short", encoded: base64.StdEncoding.EncodeToString(testKey(1)[:16]), wantErr: true},
+		{name: "not base64", encoded: "not a key!", wantErr: true},
+		{name: "empty", encoded: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useKey(t, nil)
+			t.Setenv("SEALED_TEST_KEY", tt.encoded)
+			if err := CheckKey(); (err != nil) != tt.wantErr {
+				t.Errorf("CheckKey: error = %v, want error %t", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,229 @@
+package soak
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bitrise-io/go-utils/log"
+	gh "github.com/google/go-github/github"
+
+	"github.com/lszucs/github-to-discourse/internal/budget"
+	"github.com/lszucs/github-to-discourse/internal/discourse"
+	"github.com/lszucs/github-to-discourse/internal/github"
+	"github.com/lszucs/github-to-discourse/internal/ratelimit"
+	"github.com/lszucs/github-to-discourse/internal/run"
+)
+
+// Operations of a soak cycle.
+const (
+	opIssue     = "create issue"
+	opTopic     = "create topic"
+	opComment   = "comment"
+	opDelete    = "delete topic"
+	opUncomment = "delete comment"
+	opClose     = "close issue"
+)
+
+// slack is the tolerated excess of the measured request rate over --github-rpm and --discourse-rpm.
+const slack = 1.1
+
+var (
+	duration time.Duration
+	repo     string
+	retries  int
+)
+
+func init() {
+	flag.DurationVar(&duration, "soak-duration", 10*time.Minute, "--soak-duration=<duration> (soak: how long to run the synthetic cycles)")
+	flag.StringVar(&repo, "soak-repo", "", "--soak-repo=<owner/name> (soak: scratch GitHub repo to open, comment and close issues in)")
+	flag.IntVar(&retries, "soak-retries", 3, "--soak-retries=<int> (soak: attempts of each operation before the cycle fails)")
+}
+
+// opStats holds the outcome of an operation across the cycles.
+type opStats struct {
+	Calls    int
+	Failures int
+	Retries  int
+	GaveUp   int
+	Total    time.Duration
+	Max      time.Duration
+}
+
+type soak struct {
+	ops map[string]*opStats
+}
+
+// do runs the operation, retrying it with a growing backoff up to --soak-retries attempts.
+func (s *soak) do(op string, f func() error) error {
+	st, ok := s.ops[op]
+	if !ok {
+		st = &opStats{}
+		s.ops[op] = st
+	}
+
+	var err error
+	backoff := time.Second
+	for attempt := 1; attempt <= retries; attempt++ {
+		if attempt > 1 {
+			st.Retries++
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+		start := time.Now()
+		err = f()
+		took := time.Since(start)
+		st.Calls++
+		st.Total += took
+		if took > st.Max {
+			st.Max = took
+		}
+		if err == nil {
+			return nil
+		}
+		st.Failures++
+		log.Warnf("%s, attempt %d: %s", op, attempt, err)
+	}
+	st.GaveUp++
+	return err
+}
+
+// cycle opens an issue, posts a topic and a comment linking it, then deletes the topic and the
+// comment and closes the issue. The cleanup operations run even if a previous one failed.
+func (s *soak) cycle(r github.Repo, n int) error {
+	title := fmt.Sprintf("Soak test %s cycle %d", run.ID(), n)
+	body := fmt.Sprintf("Synthetic issue of the soak test of run %s, opened at %s.", run.ID(), time.Now().UTC().Format(time.RFC3339))
+
+	var issue *gh.Issue
+	var failed []string
+	fail := func(op string, err error) {
+		failed = append(failed, fmt.Sprintf("%s: %s", op, err))
+	}
+
+	if err := s.do(opIssue, func() (err error) {
+		issue, err = github.CreateIssue(r, title, body)
+		return err
+	}); err != nil {
+		return err
+	}
+	ref, err := github.ParseIssueURL(issue.GetHTMLURL())
+	if err != nil {
+		return err
+	}
+
+	var created discourse.Created
+	if err := s.do(opTopic, func() (err error) {
+		created, err = discourse.PostTopic(discourse.Topic{Title: title, OriginURL: issue.GetHTMLURL(), Content: body, Tags: []string{run.Tag()}})
+		return err
+	}); err != nil {
+		fail(opTopic, err)
+	}
+
+	var commentID int64
+	if created.TopicID != 0 {
+		if err := s.do(opComment, func() (err error) {
+			commentID, _, err = github.PostComment(issue, "Soak test topic: "+created.URL)
+			return err
+		}); err != nil {
+			fail(opComment, err)
+		}
+		if err := s.do(opDelete, func() error {
+			return discourse.DeleteTopic(created.TopicID)
+		}); err != nil {
+			fail(opDelete, err)
+		}
+	}
+	if commentID != 0 {
+		if err := s.do(opUncomment, func() error {
+			return github.DeleteComment(ref, commentID)
+		}); err != nil {
+			fail(opUncomment, err)
+		}
+	}
+	if err := s.do(opClose, func() error {
+		_, err := github.Close(issue, github.ReasonNotPlanned)
+		return err
+	}); err != nil {
+		fail(opClose, err)
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("%s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// Run runs create/comment/delete cycles against a sandbox Discourse instance and the scratch
+// --soak-repo for --soak-duration, then reports the latency, the failures and retries of each
+// operation and the request rates measured. It returns an error if an operation failed after every
+// retry or a rate exceeded its limit. Unless live is set it only prints the plan.
+func Run(live bool) error {
+	if repo == "" {
+		return fmt.Errorf("soak requires --soak-repo")
+	}
+	if !discourse.Staging() {
+		return fmt.Errorf("soak requires a sandbox --discourse-url, not the production instance")
+	}
+	r, err := github.ParseRepoURL(repo)
+	if err != nil {
+		return err
+	}
+	if !live {
+		log.Printf("would open, comment and close issues in %s and create and delete topics in category %d for %s", r.FullName(), discourse.CategoryID(), duration)
+		return nil
+	}
+
+	s := &soak{ops: map[string]*opStats{}}
+	start := time.Now()
+	deadline := start.Add(duration)
+	var cycles, failed int
+	for time.Now().Before(deadline) {
+		cycles++
+		if err := s.cycle(r, cycles); err != nil {
+			failed++
+			log.Errorf("cycle %d: %s", cycles, err)
+			continue
+		}
+		log.Printf("cycle %d done", cycles)
+	}
+	elapsed := time.Since(start)
+
+	s.print(cycles, failed, elapsed)
+
+	var problems []string
+	if failed > 0 {
+		problems = append(problems, fmt.Sprintf("%d of %d cycles failed after retries", failed, cycles))
+	}
+	for _, service := range []string{budget.GitHub, budget.Discourse} {
+		limit := ratelimit.RPM(service)
+		rate := float64(budget.Used(service)) / elapsed.Minutes()
+		log.Printf("%s: %d requests, %.1f per minute (limit %d)", service, budget.Used(service), rate, limit)
+		if limit > 0 && rate > float64(limit)*slack {
+			problems = append(problems, fmt.Sprintf("%s rate %.1f per minute exceeds the limit of %d", service, rate, limit))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("soak failed: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func (s *soak) print(cycles, failed int, elapsed time.Duration) {
+	log.Infof("soak: %d cycles in %s, %d failed", cycles, elapsed.Round(time.Second), failed)
+
+	var ops []string
+	for op := range s.ops {
+		ops = append(ops, op)
+	}
+	sort.Strings(ops)
+	for _, op := range ops {
+		st := s.ops[op]
+		avg := time.Duration(0)
+		if st.Calls > 0 {
+			avg = st.Total / time.Duration(st.Calls)
+		}
+		log.Printf("%-15s %5d calls %4d failures %4d retries %3d gave up, avg %s max %s", op, st.Calls, st.Failures, st.Retries, st.GaveUp, avg.Round(time.Millisecond), st.Max.Round(time.Millisecond))
+	}
+}
@@ -0,0 +1,161 @@
+package state
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/lszucs/github-to-discourse/internal/sealed"
+)
+
+var (
+	dir  string
+	only string
+	// loaded holds the partitions read in full, the others are merged with the file when saved.
+	loaded = map[string]bool{}
+)
+
+func init() {
+	flag.StringVar(&dir, "state-dir", "", "--state-dir=<dir> (persist the progress in one file per repo in the directory instead of --state-file, e.g. <dir>/owner/name.json)")
+}
+
+// Paths returns the state file, or the state directory with --state-dir.
+func Paths() []string {
+	if dir != "" {
+		return []string{dir}
+	}
+	return []string{path}
+}
+
+// Only restricts Load to the records of the repo (owner/name). With --state-dir only its file is read.
+func Only(repo string) {
+	only = repo
+}
+
+// repoOf returns the owner/name of the repo of an issue URL.
+func repoOf(issueURL string) string {
+	parts := strings.Split(strings.TrimPrefix(strings.TrimPrefix(issueURL, "https://"), "http://"), "/")
+	if len(parts) < 3 {
+		return ""
+	}
+	return parts[1] + "/" + parts[2]
+}
+
+func relevant(issueURL string) bool {
+	return only == "" || strings.EqualFold(repoOf(issueURL), only)
+}
+
+func partitionPath(repo string) string {
+	if repo == "" {
+		repo = "_unknown"
+	}
+	return filepath.Join(dir, filepath.FromSlash(repo)+".json")
+}
+
+// readFile returns the records of a state file, none if it is missing.
+func readFile(p string) ([]*Record, error) {
+	data, err := sealed.ReadFile(p)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read state file %s: %s", p, err)
+	}
+
+	var loaded []*Record
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		return nil, fmt.Errorf("unmarshal state file %s: %s", p, err)
+	}
+	return loaded, nil
+}
+
+func writeFile(p string, rs []*Record) error {
+	data, err := json.MarshalIndent(rs, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal state: %s", err)
+	}
+	if err := sealed.WriteFile(p, data, 0644); err != nil {
+		return fmt.Errorf("write state file %s: %s", p, err)
+	}
+	return nil
+}
+
+// readPartitions returns the records of every repo file of --state-dir, or only of the repo set by Only.
+func readPartitions() ([]*Record, error) {
+	paths := []string{partitionPath(only)}
+	if only == "" {
+		var err error
+		if paths, err = filepath.Glob(filepath.Join(dir, "*", "*.json")); err != nil {
+			return nil, fmt.Errorf("list state files in %s: %s", dir, err)
+		}
+	}
+
+	var all []*Record
+	for _, p := range paths {
+		rs, err := readFile(p)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, rs...)
+		if rel, err := filepath.Rel(dir, p); err == nil {
+			loaded[strings.TrimSuffix(filepath.ToSlash(rel), ".json")] = true
+		}
+	}
+	return all, nil
+}
+
+// keepUnloaded adds the records read from disk which are neither loaded nor deleted to rs.
+func keepUnloaded(rs, disk []*Record) []*Record {
+	for _, r := range disk {
+		if _, ok := records[r.IssueURL]; !ok && !deleted[r.IssueURL] {
+			rs = append(rs, r)
+		}
+	}
+	sort.Slice(rs, func(i, j int) bool { return rs[i].IssueURL < rs[j].IssueURL })
+	return rs
+}
+
+// writePartitions writes the records to their repo files. Files of repos which weren't loaded
+// keep their other records, files left without records are removed.
+func writePartitions() error {
+	parts := map[string][]*Record{}
+	for _, r := range sorted() {
+		repo := repoOf(r.IssueURL)
+		parts[repo] = append(parts[repo], r)
+	}
+	for url := range deleted {
+		repo := repoOf(url)
+		if _, ok := parts[repo]; !ok {
+			parts[repo] = nil
+		}
+	}
+
+	for repo, rs := range parts {
+		p := partitionPath(repo)
+		if !loaded[repo] {
+			disk, err := readFile(p)
+			if err != nil {
+				return err
+			}
+			rs = keepUnloaded(rs, disk)
+		}
+
+		if len(rs) == 0 {
+			if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("remove state file %s: %s", p, err)
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			return fmt.Errorf("create state dir %s: %s", filepath.Dir(p), err)
+		}
+		if err := writeFile(p, rs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
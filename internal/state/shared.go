@@ -0,0 +1,82 @@
+package state
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	lockRetry = 50 * time.Millisecond
+	// lockTimeout is the age of a lock file considered left behind by a crashed process.
+	lockTimeout = time.Minute
+)
+
+var (
+	shared bool
+	// dirty holds the issues whose records this process changed, deleted the ones it removed.
+	dirty   = map[string]bool{}
+	deleted = map[string]bool{}
+)
+
+func init() {
+	flag.BoolVar(&shared, "shared-state", false, "--shared-state (lock the state file while reading and saving it and keep the records saved by other processes, for workers sharing it on a network file system)")
+}
+
+// Shared reports whether --shared-state is set.
+func Shared() bool {
+	return shared
+}
+
+// lock creates the lock file of the state file, waiting for other processes to release it.
+func lock() (func(), error) {
+	lockPath := path + ".lock"
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("create state dir %s: %s", dir, err)
+		}
+		lockPath = filepath.Join(dir, ".lock")
+	}
+	for {
+		f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			if cerr := f.Close(); cerr != nil {
+				return nil, fmt.Errorf("close lock file %s: %s", lockPath, cerr)
+			}
+			return func() { _ = os.Remove(lockPath) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("create lock file %s: %s", lockPath, err)
+		}
+		if fi, serr := os.Stat(lockPath); serr == nil && time.Since(fi.ModTime()) > lockTimeout {
+			_ = os.Remove(lockPath)
+			continue
+		}
+		time.Sleep(lockRetry)
+	}
+}
+
+// read returns the records of the state file or of the files of --state-dir, none if missing.
+func read() ([]*Record, error) {
+	if dir != "" {
+		return readPartitions()
+	}
+	return readFile(path)
+}
+
+// merge takes the records saved by other processes, keeping the ones changed by this process.
+func merge() error {
+	loaded, err := read()
+	if err != nil {
+		return err
+	}
+	for _, r := range loaded {
+		if dirty[r.IssueURL] || deleted[r.IssueURL] {
+			continue
+		}
+		records[r.IssueURL] = r
+	}
+	return nil
+}
@@ -0,0 +1,442 @@
+package state
+
+import (
+	"flag"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lszucs/github-to-discourse/internal/trace"
+)
+
+const (
+	defaultPath = "state.json"
+
+	StepDiscourse = "discourse"
+	StepComment   = "comment"
+	StepClose     = "close"
+	StepLock      = "lock"
+	// StepHooks marks the side effects of the label hooks as performed.
+	StepHooks = "hooks"
+	// StepCampaign marks the comment of the campaign command as posted.
+	StepCampaign = "campaign"
+	// StepUndone is the event of the steps of the issue undone by Reset.
+	StepUndone = "undone"
+
+	// StatusConverted marks issues converted to a discussion mid-run.
+	StatusConverted = "converted"
+	// StatusMirrored marks issues copied to Discourse and left untouched on GitHub.
+	StatusMirrored = "mirrored"
+	// StatusQuarantined marks issues which failed too many times to be retried.
+	StatusQuarantined = "quarantined"
+	// StatusSkipped marks issues left out of the migration by a hook.
+	StatusSkipped = "skipped"
+	// StatusDeferred marks issues left for the next day by the daily quota of their category.
+	StatusDeferred = "deferred"
+)
+
+// Record holds the progress of a single issue.
+type Record struct {
+	IssueURL    string   `json:"issue_url"`
+	Repo        string   `json:"repo,omitempty"`
+	Number      int      `json:"number,omitempty"`
+	Done        []string `json:"done,omitempty"`
+	AlreadyDone []string `json:"already_done,omitempty"`
+	TopicURL    string   `json:"topic_url,omitempty"`
+	CategoryID  int      `json:"category_id,omitempty"`
+	Error       string   `json:"error,omitempty"`
+	Attempts    int      `json:"attempts,omitempty"`
+
+	Status        string `json:"status,omitempty"`
+	DiscussionURL string `json:"discussion_url,omitempty"`
+	// RunID identifies the run which created the topic, or which first processed the issue if it has none.
+	RunID string `json:"run_id,omitempty"`
+	// Suspect lists the problems found in the rendered topic, to be reviewed.
+	Suspect []string `json:"suspect,omitempty"`
+	// RenamedFrom lists the former URLs of the issue, recorded when its repo was renamed.
+	RenamedFrom []string `json:"renamed_from,omitempty"`
+	// Author is the login of the user who opened the issue.
+	Author string `json:"author,omitempty"`
+	// CommentID is the ID of the comment posted on the issue.
+	CommentID int64 `json:"comment_id,omitempty"`
+	// DuplicateOf is the canonical issue whose topic the comment links instead of a topic of the issue.
+	DuplicateOf string `json:"duplicate_of,omitempty"`
+	// TopicID and PostID identify the topic and its first post, the URL may change with the slug.
+	TopicID int `json:"topic_id,omitempty"`
+	PostID  int `json:"post_id,omitempty"`
+	// Identity is the --post-as identity the topic was posted as and PostedAs its Discourse
+	// username, both empty for the API user.
+	Identity string `json:"identity,omitempty"`
+	PostedAs string `json:"posted_as,omitempty"`
+	// Checksum hashes the title and body of the issue as migrated, with --plan-file.
+	Checksum string `json:"checksum,omitempty"`
+	// Fingerprint identifies copies of the issue in other repos, with --link-copies.
+	Fingerprint string `json:"fingerprint,omitempty"`
+	// FallbackTitle is set if the topic got a fallback title for the issue had no valid one.
+	FallbackTitle bool `json:"fallback_title,omitempty"`
+	// Replies lists the IDs of the GitHub comments posted as replies on the topic, with --with-comments.
+	Replies []int64 `json:"replies,omitempty"`
+	// ManualCleanup lists what a rollback left in place as it may not be the tool's, to be cleaned up by hand.
+	ManualCleanup []string `json:"manual_cleanup,omitempty"`
+
+	UpdatedAt time.Time `json:"updated_at"`
+	Events    []Event   `json:"events,omitempty"`
+
+	failedCall *trace.Call
+}
+
+// Event is a timestamped change of a record: a completed step, an API call or an error.
+type Event struct {
+	Time  time.Time `json:"time"`
+	Step  string    `json:"step,omitempty"`
+	Error string    `json:"error,omitempty"`
+	// Call is the API call of the step or of the error, if the tool made one.
+	Call *trace.Call `json:"call,omitempty"`
+}
+
+func (r *Record) addEvent(e Event) {
+	r.UpdatedAt = e.Time
+	r.Events = append(r.Events, e)
+}
+
+// MarkDone records step as completed.
+func (r *Record) MarkDone(step string) {
+	r.Done = append(r.Done, step)
+	r.addEvent(Event{Time: time.Now(), Step: step})
+}
+
+// MarkDoneBy records step as completed by the API call.
+func (r *Record) MarkDoneBy(step string, call trace.Call) {
+	r.Done = append(r.Done, step)
+	r.addEvent(Event{Time: time.Now(), Step: step, Call: &call})
+}
+
+// Called keeps the failed API call of a step, to be recorded with the error by Fail.
+func (r *Record) Called(call trace.Call) {
+	r.failedCall = &call
+}
+
+// Fail records the error of the issue's last attempt.
+func (r *Record) Fail(err error) {
+	r.Attempts++
+	r.Error = err.Error()
+	r.addEvent(Event{Time: time.Now(), Error: r.Error, Call: r.failedCall})
+	r.failedCall = nil
+}
+
+// Succeed clears the error of a previous attempt.
+func (r *Record) Succeed() {
+	r.Error = ""
+	r.Attempts = 0
+}
+
+// Reset forgets the steps performed on the issue after they have been undone, except the hooks
+// whose side effects can't be undone, so the next attempt starts over.
+func (r *Record) Reset() {
+	var kept []string
+	if r.IsDone(StepHooks) {
+		kept = []string{StepHooks}
+	}
+	r.Done, r.AlreadyDone = kept, nil
+	r.TopicURL, r.TopicID, r.PostID, r.CategoryID = "", 0, 0, 0
+	r.Identity, r.PostedAs = "", ""
+	r.CommentID = 0
+	r.Replies = nil
+	r.DuplicateOf = ""
+	r.Suspect = nil
+	r.addEvent(Event{Time: time.Now(), Step: StepUndone})
+}
+
+// Quarantine excludes the issue from further attempts until the status is removed from the state file.
+func (r *Record) Quarantine() {
+	r.Status = StatusQuarantined
+}
+
+var (
+	path    string
+	mu      sync.Mutex
+	records = map[string]*Record{}
+	// fileLoaded is set once the records were read from the state file, before that saving them
+	// would replace the file with the records of this process only.
+	fileLoaded bool
+)
+
+func init() {
+	flag.StringVar(&path, "state-file", defaultPath, "--state-file=<path> (file to persist the progress of live runs to)")
+}
+
+// IsDone reports whether step has already been completed for the issue.
+func (r *Record) IsDone(step string) bool {
+	for _, s := range r.Done {
+		if s == step {
+			return true
+		}
+	}
+	return false
+}
+
+// MarkAlreadyDone records step as done without the tool performing it,
+// e.g. closing an issue which was closed on GitHub already.
+func (r *Record) MarkAlreadyDone(step string) {
+	r.MarkDone(step)
+	r.AlreadyDone = append(r.AlreadyDone, step)
+}
+
+// Unmark forgets step after it has been undone, so it isn't undone again.
+func (r *Record) Unmark(step string) {
+	var kept []string
+	for _, s := range r.Done {
+		if s != step {
+			kept = append(kept, s)
+		}
+	}
+	r.Done = kept
+}
+
+// Pipeline stages of a record, see Stage.
+const (
+	StagePending      = "Pending"
+	StageTopicCreated = "Topic created"
+	StageCommented    = "Commented"
+	StageClosed       = "Closed"
+	StageLocked       = "Locked"
+	StageFailed       = "Failed"
+	StageConverted    = "Converted"
+	StageMirrored     = "Mirrored"
+	StageQuarantined  = "Quarantined"
+	StageSkipped      = "Skipped"
+	StageDeferred     = "Deferred"
+)
+
+// Stages lists the pipeline stages in order.
+var Stages = []string{StagePending, StageTopicCreated, StageCommented, StageClosed, StageLocked, StageFailed, StageConverted, StageMirrored, StageQuarantined, StageSkipped, StageDeferred}
+
+// Stage returns the pipeline stage the issue is in.
+func (r *Record) Stage() string {
+	switch {
+	case r.Status == StatusConverted:
+		return StageConverted
+	case r.Status == StatusQuarantined:
+		return StageQuarantined
+	case r.Status == StatusSkipped:
+		return StageSkipped
+	case r.Status == StatusDeferred:
+		return StageDeferred
+	case r.Error != "":
+		return StageFailed
+	case r.Status == StatusMirrored:
+		return StageMirrored
+	case r.IsDone(StepLock):
+		return StageLocked
+	case r.IsDone(StepClose):
+		return StageClosed
+	case r.IsDone(StepComment):
+		return StageCommented
+	case r.IsDone(StepDiscourse):
+		return StageTopicCreated
+	default:
+		return StagePending
+	}
+}
+
+// Load reads the records of previous runs from the state file. A missing file is not an error.
+// With --shared-state it can be called again to take the records saved by other processes since.
+func Load() error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if shared {
+		unlock, err := lock()
+		if err != nil {
+			return err
+		}
+		defer unlock()
+	}
+
+	loaded, err := read()
+	if err != nil {
+		return err
+	}
+	for _, r := range loaded {
+		if relevant(r.IssueURL) {
+			records[r.IssueURL] = r
+		}
+	}
+	fileLoaded = true
+	return nil
+}
+
+// Loaded reports whether the records were read from the state file, which dry runs don't do.
+func Loaded() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return fileLoaded
+}
+
+// Get returns the record of the issue, creating an empty one if there is none yet.
+func Get(issueURL string) *Record {
+	mu.Lock()
+	defer mu.Unlock()
+
+	r, ok := records[issueURL]
+	if !ok {
+		r = &Record{IssueURL: issueURL}
+		records[issueURL] = r
+	}
+	dirty[issueURL] = true
+	delete(deleted, issueURL)
+	return r
+}
+
+// Checkout returns a copy of the record of the issue to migrate it with, creating an empty record if
+// there is none yet. Unlike the record returned by Get, the copy is the caller's alone: it may be
+// changed while other goroutines save the state, and its changes are saved once passed to Commit.
+func Checkout(issueURL string) *Record {
+	mu.Lock()
+	defer mu.Unlock()
+
+	r, ok := records[issueURL]
+	if !ok {
+		r = &Record{IssueURL: issueURL}
+		records[issueURL] = r
+	}
+	dirty[issueURL] = true
+	delete(deleted, issueURL)
+	return r.clone()
+}
+
+// Commit keeps a copy of the record checked out by Checkout, to be written by the next Save. The
+// caller may go on changing the record and commit it again.
+func Commit(r *Record) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	records[r.IssueURL] = r.clone()
+	dirty[r.IssueURL] = true
+	delete(deleted, r.IssueURL)
+}
+
+// clone returns a copy of the record sharing nothing changed by its methods.
+func (r *Record) clone() *Record {
+	c := *r
+	c.Done = append([]string(nil), r.Done...)
+	c.AlreadyDone = append([]string(nil), r.AlreadyDone...)
+	c.Suspect = append([]string(nil), r.Suspect...)
+	c.RenamedFrom = append([]string(nil), r.RenamedFrom...)
+	c.Replies = append([]int64(nil), r.Replies...)
+	c.ManualCleanup = append([]string(nil), r.ManualCleanup...)
+	c.Events = append([]Event(nil), r.Events...)
+	return &c
+}
+
+// Lookup returns the record of the issue, if there is one.
+func Lookup(issueURL string) (*Record, bool) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	r, ok := records[issueURL]
+	return r, ok
+}
+
+// RenameRepo moves the records of the issues of the repo renamed from fromURL to toURL, both repo
+// web URLs, to their new issue URLs, and sets their repo to fullName.
+func RenameRepo(fromURL, toURL, fullName string) int {
+	mu.Lock()
+	defer mu.Unlock()
+
+	moved := 0
+	for url, r := range records {
+		if !strings.HasPrefix(strings.ToLower(url), strings.ToLower(fromURL)+"/") {
+			continue
+		}
+		newURL := toURL + url[len(fromURL):]
+		if _, ok := records[newURL]; ok {
+			continue
+		}
+		delete(records, url)
+		deleted[url] = true
+		r.RenamedFrom = append(r.RenamedFrom, url)
+		r.IssueURL = newURL
+		r.Repo = fullName
+		records[newURL] = r
+		dirty[newURL] = true
+		moved++
+	}
+	return moved
+}
+
+// Delete removes the record of the issue, so it is migrated again from scratch.
+func Delete(issueURL string) {
+	mu.Lock()
+	defer mu.Unlock()
+	delete(records, issueURL)
+	delete(dirty, issueURL)
+	deleted[issueURL] = true
+}
+
+// All returns every record ordered by issue URL.
+func All() []*Record {
+	mu.Lock()
+	defer mu.Unlock()
+	return sorted()
+}
+
+// Resume returns the records of the issues whose migration stopped halfway: some of the steps
+// are done, but not the last one (the last of --actions). Records with a status are left out.
+func Resume(last string) []*Record {
+	mu.Lock()
+	defer mu.Unlock()
+
+	var unfinished []*Record
+	for _, r := range sorted() {
+		if r.Status != "" || r.IsDone(last) {
+			continue
+		}
+		if r.IsDone(StepDiscourse) || r.IsDone(StepComment) || r.IsDone(StepClose) || r.IsDone(StepLock) {
+			unfinished = append(unfinished, r)
+		}
+	}
+	return unfinished
+}
+
+func sorted() []*Record {
+	var all []*Record
+	for _, r := range records {
+		all = append(all, r)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].IssueURL < all[j].IssueURL })
+	return all
+}
+
+// Save writes all records to the state file, or to the files of their repos with --state-dir.
+// With --shared-state the records saved by other processes are kept, except the ones this process changed.
+func Save() error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if shared {
+		unlock, err := lock()
+		if err != nil {
+			return err
+		}
+		defer unlock()
+		if err := merge(); err != nil {
+			return err
+		}
+	}
+
+	if dir != "" {
+		return writePartitions()
+	}
+	all := sorted()
+	if only != "" && !shared {
+		// keep the records of the other repos, which weren't loaded
+		disk, err := readFile(path)
+		if err != nil {
+			return err
+		}
+		all = keepUnloaded(all, disk)
+	}
+	return writeFile(path, all)
+}
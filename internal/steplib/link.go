@@ -0,0 +1,165 @@
+package steplib
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/log"
+
+	"github.com/lszucs/github-to-discourse/internal/discourse"
+	"github.com/lszucs/github-to-discourse/internal/github"
+	"github.com/lszucs/github-to-discourse/internal/state"
+)
+
+const (
+	stepYML      = "step.yml"
+	linkBranch   = "discourse-support-url"
+	defaultIndex = "index.json"
+)
+
+var indexPath string
+
+func init() {
+	flag.StringVar(&indexPath, "index-file", defaultIndex, "--index-file=<path> (index topic URL per repo, posted by link-steplib)")
+}
+
+var supportURLLine = regexp.MustCompile(`(?m)^support_url:.*$`)
+
+// SetSupportURL returns stepYML with its top level support_url set to url, and whether it changed.
+func SetSupportURL(stepYML, url string) (string, bool) {
+	line := "support_url: " + url
+	if m := supportURLLine.FindString(stepYML); m != "" {
+		if strings.TrimSpace(m) == line {
+			return stepYML, false
+		}
+		return supportURLLine.ReplaceAllLiteralString(stepYML, line), true
+	}
+
+	// keep it next to the other links of the step
+	for _, key := range []string{"source_code_url:", "website:"} {
+		re := regexp.MustCompile(`(?m)^` + key + `.*$`)
+		if loc := re.FindStringIndex(stepYML); loc != nil {
+			return stepYML[:loc[1]] + "\n" + line + stepYML[loc[1]:], true
+		}
+	}
+
+	if !strings.HasSuffix(stepYML, "\n") {
+		stepYML += "\n"
+	}
+	return stepYML + line + "\n", true
+}
+
+func loadIndex() (map[string]string, error) {
+	index := map[string]string{}
+	b, err := ioutil.ReadFile(indexPath)
+	if os.IsNotExist(err) {
+		return index, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read index file: %s", err)
+	}
+	if err := json.Unmarshal(b, &index); err != nil {
+		return nil, fmt.Errorf("parse index file %s: %s", indexPath, err)
+	}
+	return index, nil
+}
+
+func saveIndex(index map[string]string) error {
+	b, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal index file: %s", err)
+	}
+	if err := ioutil.WriteFile(indexPath, b, 0644); err != nil {
+		return fmt.Errorf("write index file: %s", err)
+	}
+	return nil
+}
+
+// indexTopic posts the topic listing the migrated topics of repo.
+func indexTopic(repo github.Repo, recs []*state.Record) (string, error) {
+	sort.Slice(recs, func(a, b int) bool { return recs[a].Number < recs[b].Number })
+
+	var content strings.Builder
+	fmt.Fprintf(&content, "Issues of [%s](%s) migrated from GitHub:\n\n", repo.FullName(), repo.URL())
+	for _, r := range recs {
+		fmt.Fprintf(&content, "- [#%d](%s): %s\n", r.Number, r.IssueURL, r.TopicURL)
+	}
+
+	created, err := discourse.PostTopic(discourse.Topic{
+		Title:     fmt.Sprintf("Support for %s", repo.Name),
+		OriginURL: repo.URL(),
+		Content:   content.String(),
+	})
+	return created.URL, err
+}
+
+// Link posts an index topic for every steplib repo with migrated topics in the state, and opens a pull
+// request pointing the support_url of its step.yml at the index topic.
+func Link(steplibURL string, fromOrgs []string) error {
+	repoURLs, err := LoadRepos(steplibURL, fromOrgs)
+	if err != nil {
+		return err
+	}
+
+	byRepo := map[string][]*state.Record{}
+	for _, r := range state.All() {
+		if r.TopicURL != "" {
+			byRepo[r.Repo] = append(byRepo[r.Repo], r)
+		}
+	}
+
+	index, err := loadIndex()
+	if err != nil {
+		return err
+	}
+
+	for _, u := range repoURLs {
+		repo, err := github.ParseRepoURL(u)
+		if err != nil {
+			log.Warnf("skip %s: %s", u, err)
+			continue
+		}
+		recs := byRepo[repo.FullName()]
+		if len(recs) == 0 {
+			log.Printf("skip %s: no migrated topics", repo.FullName())
+			continue
+		}
+
+		topicURL, ok := index[repo.FullName()]
+		if !ok {
+			log.Printf("post index topic of %s", repo.FullName())
+			if topicURL, err = indexTopic(repo, recs); err != nil {
+				return fmt.Errorf("post index topic of %s: %s", repo.FullName(), err)
+			}
+			index[repo.FullName()] = topicURL
+			if err := saveIndex(index); err != nil {
+				return err
+			}
+		}
+
+		f, err := github.GetFile(repo, stepYML)
+		if err != nil {
+			return err
+		}
+		content, changed := SetSupportURL(f.Content, topicURL)
+		if !changed {
+			log.Printf("skip %s: support_url already points to %s", repo.FullName(), topicURL)
+			continue
+		}
+
+		prURL, err := github.ProposeChange(f, linkBranch, content,
+			"Point support_url to the Discourse topic",
+			fmt.Sprintf("Issues of this step were migrated to Discourse, support continues at %s.", topicURL))
+		if err != nil {
+			return err
+		}
+		log.Printf("opened %s", prURL)
+	}
+	return nil
+}
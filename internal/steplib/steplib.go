@@ -5,12 +5,94 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
-	"strings"
+	"sort"
 
-	stepmanModels "github.com/bitrise-io/stepman/models"
 	"github.com/bitrise-io/go-utils/log"
+	stepmanModels "github.com/bitrise-io/stepman/models"
+	"github.com/lszucs/github-to-discourse/internal/github"
 )
 
+// Skipped is a step of the spec which can't be migrated.
+type Skipped struct {
+	StepID string
+	Reason string
+}
+
+// Source is the source repo of a step.
+type Source struct {
+	StepID string
+	URL    string
+	Repo   github.Repo
+}
+
+// stepIDs maps the full names of the repos loaded from the spec to their step ID.
+var stepIDs = map[string]string{}
+
+// StepID returns the ID of the step of the repo loaded from the spec, or the name of the repo
+// if it wasn't loaded from a spec.
+func StepID(repo github.Repo) string {
+	if id, ok := stepIDs[repo.FullName()]; ok {
+		return id
+	}
+	return repo.Name
+}
+
+// Maintainers returns the maintainers of the step of the repo: the default owners of its
+// CODEOWNERS file. Repos not loaded from the spec have none.
+func Maintainers(repo github.Repo) ([]string, error) {
+	if _, ok := stepIDs[repo.FullName()]; !ok {
+		return nil, nil
+	}
+	return github.CodeOwners(repo)
+}
+
+// Validate returns the source repos of the steps of the spec, and the steps skipped with the reason.
+// It fails if the spec has no steps at all, which is the sign of a changed spec format.
+func Validate(data stepmanModels.StepCollectionModel) ([]Source, []Skipped, error) {
+	if len(data.Steps) == 0 {
+		return nil, nil, fmt.Errorf("spec has no steps, its format may have changed")
+	}
+
+	var ids []string
+	for id := range data.Steps {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var sources []Source
+	var skipped []Skipped
+	skip := func(id, format string, args ...interface{}) {
+		skipped = append(skipped, Skipped{StepID: id, Reason: fmt.Sprintf(format, args...)})
+	}
+	for _, id := range ids {
+		stp := data.Steps[id]
+		if stp.LatestVersionNumber == "" {
+			skip(id, "latest_version_number missing")
+			continue
+		}
+		version, ok := stp.Versions[stp.LatestVersionNumber]
+		if !ok {
+			skip(id, "latest version %s missing from versions", stp.LatestVersionNumber)
+			continue
+		}
+		if version.Source == nil || version.Source.Git == "" {
+			skip(id, "version %s has no source.git", stp.LatestVersionNumber)
+			continue
+		}
+		repo, err := github.ParseRepoURL(version.Source.Git)
+		if err != nil {
+			skip(id, "source.git %s: %s", version.Source.Git, err)
+			continue
+		}
+		if repo.Host != "github.com" {
+			skip(id, "source.git %s is not hosted on github.com", version.Source.Git)
+			continue
+		}
+		sources = append(sources, Source{StepID: id, URL: version.Source.Git, Repo: repo})
+	}
+	return sources, skipped, nil
+}
+
 func LoadRepos(steplibURL string, fromOrgs []string) (repoURLs []string, err error) {
 	// get spec file
 	resp, err := http.Get(steplibURL)
@@ -22,6 +104,9 @@ func LoadRepos(steplibURL string, fromOrgs []string) (repoURLs []string, err err
 			log.Warnf("close response body: %s", err)
 		}
 	}()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch steplib json: %s", resp.Status)
+	}
 
 	// read spec file
 	sp, err := ioutil.ReadAll(resp.Body)
@@ -35,22 +120,25 @@ func LoadRepos(steplibURL string, fromOrgs []string) (repoURLs []string, err err
 		return nil, fmt.Errorf("unmarshal steplib json %s: %s", string(sp), err)
 	}
 
-	// process steps
-	for _, stp := range data.Steps {
-		// filter to our repositories
-		for _, o := range fromOrgs {
-			parts := strings.SplitAfter(stp.Versions[stp.LatestVersionNumber].Source.Git, "github.com/")
-			if len(parts) == 1 {
-				continue
-			}
+	sources, skipped, err := Validate(data)
+	if err != nil {
+		return nil, fmt.Errorf("validate steplib json: %s", err)
+	}
+	for _, s := range skipped {
+		log.Warnf("skip step %s: %s", s.StepID, s.Reason)
+	}
 
-			owner := strings.Split(parts[1], "/")[0]
-			if owner == o {
-				repoURLs = append(repoURLs, stp.Versions[stp.LatestVersionNumber].Source.Git)
+	// filter to our repositories
+	for _, src := range sources {
+		for _, o := range fromOrgs {
+			if src.Repo.Owner == o {
+				repoURLs = append(repoURLs, src.URL)
+				stepIDs[src.Repo.FullName()] = src.StepID
 				break
 			}
 		}
 	}
+	log.Printf("%d of %d steps are valid, %d owned by %s", len(sources), len(data.Steps), len(repoURLs), fromOrgs)
 
 	return repoURLs, nil
 }
@@ -0,0 +1,77 @@
+package steplib
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/lszucs/github-to-discourse/internal/github"
+)
+
+// TagPrefix prefixes the step IDs in the tags of the topics.
+const TagPrefix = "step-"
+
+var (
+	stepTags     bool
+	stepTagGroup string
+)
+
+func init() {
+	flag.BoolVar(&stepTags, "step-tags", false, "--step-tags (tag the topics of the repos loaded with --repo-src=steplib with "+TagPrefix+"<step ID>, e.g. step-xcode-archive)")
+	flag.StringVar(&stepTagGroup, "step-tag-group", "", "--step-tag-group=<name> (create the Discourse tag group of the step tags before live runs, or add the missing tags to it, requires --step-tags and an admin API user; empty disables it)")
+}
+
+// ValidateTags fails if the tag group is given without the tags.
+func ValidateTags() error {
+	if stepTagGroup != "" && !stepTags {
+		return fmt.Errorf("--step-tag-group requires --step-tags")
+	}
+	return nil
+}
+
+// tagOf turns a step ID into a tag: lowercase, with the characters Discourse doesn't allow in
+// tags replaced by dashes.
+func tagOf(stepID string) string {
+	return TagPrefix + strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r - 'A' + 'a'
+		default:
+			return '-'
+		}
+	}, stepID)
+}
+
+// Tag returns the tag of the step of the repo, empty without --step-tags or if the repo wasn't
+// loaded from the spec.
+func Tag(repo github.Repo) string {
+	id, ok := stepIDs[repo.FullName()]
+	if !stepTags || !ok {
+		return ""
+	}
+	return tagOf(id)
+}
+
+// Tags returns the tags of every step loaded from the spec, sorted, none without --step-tags.
+func Tags() []string {
+	if !stepTags {
+		return nil
+	}
+	var tags []string
+	for _, id := range stepIDs {
+		tags = append(tags, tagOf(id))
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// TagGroup returns the name of the tag group of the step tags, empty if it isn't managed.
+func TagGroup() string {
+	if !stepTags {
+		return ""
+	}
+	return stepTagGroup
+}
@@ -0,0 +1,244 @@
+package templates
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+
+	"github.com/lszucs/github-to-discourse/internal/config"
+	"github.com/lszucs/github-to-discourse/internal/overrides"
+)
+
+// named is a configured template text with its qualified name, e.g. hu/active or label:bug/stale.
+type named struct {
+	Name string
+	Text string
+}
+
+// configured returns every template in effect: the templates of each locale along their fallback
+// chain, then the templates of the label rules and of the overrides, and the topic title template.
+func configured() ([]named, error) {
+	locs := []string{defaultLocale}
+	for l := range locales.Sets {
+		if l != defaultLocale {
+			locs = append(locs, l)
+		}
+	}
+	sort.Strings(locs[1:])
+
+	var all []named
+	for _, l := range locs {
+		for _, name := range []string{Active, Stale, Duplicate, Campaign, Footer} {
+			text, err := lookup(l, name)
+			if err != nil {
+				return nil, err
+			}
+			all = append(all, named{Name: l + "/" + name, Text: text})
+		}
+	}
+
+	for _, r := range rules {
+		for _, name := range []string{Active, Stale, Footer} {
+			if text := pick(name, r.Active, r.Stale, r.Footer); text != "" {
+				all = append(all, named{Name: "label:" + r.Label + "/" + name, Text: text})
+			}
+		}
+	}
+
+	for _, url := range overrides.URLs() {
+		o, _ := overrides.Get(url)
+		for _, name := range []string{Active, Stale, Footer} {
+			if text := pick(name, o.Active, o.Stale, o.Footer); text != "" {
+				all = append(all, named{Name: "override:" + url + "/" + name, Text: text})
+			}
+		}
+	}
+
+	all = append(all, named{Name: Title, Text: titleTpl})
+	return all, nil
+}
+
+// Sample is the data previews are rendered with.
+var Sample = Data{
+	Author:     "octocat",
+	Name:       "The Octocat",
+	IssueURL:   "https://github.com/bitrise-io/bitrise/issues/42",
+	Repo:       "bitrise-io/bitrise",
+	Number:     42,
+	Title:      "Step fails with exit code 1",
+	CreatedAt:  time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC),
+	TopicURL:   "https://discuss.bitrise.io/t/1234",
+	CategoryID: 11,
+	Labels:     []string{"bug"},
+	StepID:     "script",
+
+	DuplicateOf: "https://github.com/bitrise-io/bitrise/issues/12",
+}
+
+// fields lists the variables of Data.
+func fields() []string {
+	var names []string
+	t := reflect.TypeOf(Data{})
+	for n := 0; n < t.NumField(); n++ {
+		names = append(names, t.Field(n).Name)
+	}
+	return names
+}
+
+// unknownFields returns the problems of the variables referenced by the template which are not in Data.
+func unknownFields(tpl *template.Template) []string {
+	known := map[string]bool{}
+	for _, f := range fields() {
+		known[f] = true
+	}
+
+	var problems []string
+	check := func(ident string) {
+		if known[ident] {
+			return
+		}
+		for _, f := range fields() {
+			if strings.EqualFold(f, ident) {
+				problems = append(problems, fmt.Sprintf("unknown variable .%s, did you mean .%s?", ident, f))
+				return
+			}
+		}
+		problems = append(problems, fmt.Sprintf("unknown variable .%s, available: .%s", ident, strings.Join(fields(), ", .")))
+	}
+
+	var walk func(n parse.Node)
+	walk = func(n parse.Node) {
+		switch n := n.(type) {
+		case *parse.ListNode:
+			if n == nil {
+				return
+			}
+			for _, c := range n.Nodes {
+				walk(c)
+			}
+		case *parse.ActionNode:
+			walk(n.Pipe)
+		case *parse.PipeNode:
+			if n == nil {
+				return
+			}
+			for _, c := range n.Cmds {
+				walk(c)
+			}
+		case *parse.CommandNode:
+			for _, a := range n.Args {
+				walk(a)
+			}
+		case *parse.FieldNode:
+			check(n.Ident[0])
+		case *parse.IfNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.RangeNode:
+			// dot is the element inside the range body
+			walk(n.Pipe)
+			walk(n.ElseList)
+		case *parse.WithNode:
+			walk(n.Pipe)
+			walk(n.ElseList)
+		}
+	}
+	walk(tpl.Tree.Root)
+	return problems
+}
+
+// Lint parses every configured template, checks the variables they reference and writes a preview
+// of each rendered with Sample to w. It returns an error if any template has problems.
+func Lint(w io.Writer) error {
+	all, err := configured()
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, t := range all {
+		fmt.Fprintf(w, "=== %s\n", t.Name)
+
+		tpl, err := template.New(t.Name).Option("missingkey=error").Parse(t.Text)
+		if err != nil {
+			failed++
+			fmt.Fprintf(w, "error: %s\n\n", err)
+			continue
+		}
+
+		problems := unknownFields(tpl)
+		for _, p := range problems {
+			fmt.Fprintf(w, "error: %s\n", p)
+		}
+		if len(problems) > 0 {
+			failed++
+			fmt.Fprintln(w)
+			continue
+		}
+
+		out, err := Render(t.Name, t.Text, Sample)
+		if err != nil {
+			failed++
+			fmt.Fprintf(w, "error: %s\n\n", err)
+			continue
+		}
+		fmt.Fprintf(w, "%s\n\n", out)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d templates have problems", failed, len(all))
+	}
+	return nil
+}
+
+// Check returns the violations of the templates of the config file: texts which do not parse or
+// reference variables not in Data.
+func Check(cfg config.Config) []config.Violation {
+	var vs []config.Violation
+	check := func(p, text string) {
+		if text == "" {
+			return
+		}
+		tpl, err := template.New(p).Parse(text)
+		if err != nil {
+			vs = append(vs, config.Violation{Path: p, Message: err.Error()})
+			return
+		}
+		for _, problem := range unknownFields(tpl) {
+			vs = append(vs, config.Violation{Path: p, Message: problem})
+		}
+	}
+
+	check("templates.active", cfg.Templates.Active)
+	check("templates.stale", cfg.Templates.Stale)
+	check("templates.footer", cfg.Templates.Footer)
+	check("templates.duplicate", cfg.Templates.Duplicate)
+	check("templates.campaign", cfg.Templates.Campaign)
+	check("templates.title", cfg.Templates.Title)
+
+	var names []string
+	for name := range cfg.Locales.Sets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		set := cfg.Locales.Sets[name]
+		p := "locales.sets." + name
+		check(p+".active", set.Active)
+		check(p+".stale", set.Stale)
+		check(p+".footer", set.Footer)
+	}
+	for n, r := range cfg.TemplateRules {
+		p := fmt.Sprintf("template_rules[%d]", n)
+		check(p+".active", r.Active)
+		check(p+".stale", r.Stale)
+		check(p+".footer", r.Footer)
+	}
+	return vs
+}
@@ -0,0 +1,309 @@
+package templates
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+	"unicode"
+
+	gh "github.com/google/go-github/github"
+
+	"github.com/lszucs/github-to-discourse/internal/config"
+	"github.com/lszucs/github-to-discourse/internal/github"
+	"github.com/lszucs/github-to-discourse/internal/overrides"
+	"github.com/lszucs/github-to-discourse/internal/steplib"
+)
+
+const (
+	Active = "active"
+	Stale  = "stale"
+	Footer = "footer"
+	Title  = "title"
+	// Duplicate is the comment of duplicates linking the topic of their canonical issue.
+	Duplicate = "duplicate"
+	// Campaign is the comment posted by the campaign command.
+	Campaign = "campaign"
+
+	defaultLocale = "en"
+)
+
+const (
+	defaultActiveTpl = `Hi {{.Name}}!
+	We are migrating our GitHub issues to Discourse (https://discuss.bitrise.io/c/issues/build-issues).
+	From now on, you can track this issue at: {{.TopicURL}}`
+	defaultStaleTpl = `Hi {{.Name}}!
+	We are migrating our GitHub issues to Discourse (https://discuss.bitrise.io/c/issues/build-issues).
+	Because this issue has been inactive for more than three months, we will be closing it.
+	
+	If you feel it is still relevant, please open a ticket on Discourse!`
+	defaultDuplicateTpl = `Hi {{.Name}}!
+	We are migrating our GitHub issues to Discourse (https://discuss.bitrise.io/c/issues/build-issues).
+	This issue is a duplicate of {{.DuplicateOf}}, you can track it at: {{.TopicURL}}`
+	defaultCampaignTpl = `Hi {{.Name}}!
+	Support has moved to Discourse (https://discuss.bitrise.io/c/issues/build-issues), please open a topic there if you still need help.`
+	defaultTitleTpl  = `{{.Title}}`
+	defaultFooterTpl = `---
+<small>Originally reported by @{{.Author}} on GitHub: {{.IssueURL}}. The content is licensed under the terms of the {{.Repo}} repository.</small>`
+)
+
+var (
+	footerTplPath   string
+	campaignTplPath string
+	titleTpl        string
+	configuredTpls  config.Templates
+	locales         config.Locales
+	rules           []config.TemplateRule
+)
+
+func init() {
+	flag.StringVar(&footerTplPath, "footer-tpl", "", "--footer-tpl=<path> (text/template file rendered and appended to every migrated topic, the built-in footer is used if empty)")
+	flag.StringVar(&campaignTplPath, "campaign-tpl", "", "--campaign-tpl=<path> (text/template file of the comment posted by the campaign command, a built-in notice about the move to Discourse is used if empty)")
+	flag.StringVar(&titleTpl, "title-tpl", defaultTitleTpl, "--title-tpl=<template> (text/template of the topic titles, e.g. \"[{{.StepID}}] {{.Title}} (GH#{{.Number}})\")")
+}
+
+// Data holds the variables available in templates.
+type Data struct {
+	Author     string
+	IssueURL   string
+	Repo       string
+	Number     int
+	Title      string
+	CreatedAt  time.Time
+	TopicURL   string
+	CategoryID int
+	Labels     []string
+	// Redacted is set if a hook keeps the issue content off the topic.
+	Redacted bool
+	// Name is the display name of the author with --greet-by-name, the login otherwise.
+	Name string
+	// StepID is the ID of the step in the steplib spec with --repo-src=steplib, the repo name otherwise.
+	StepID string
+	// DuplicateOf is the URL of the canonical issue of a duplicate, TopicURL is then the topic of the canonical issue.
+	DuplicateOf string
+	// FallbackTitle is set if the issue has no valid title, Title is then made of the repo and the number.
+	FallbackTitle bool
+}
+
+// IssueTitle returns the title of the issue without control characters, or if nothing readable
+// is left of it a fallback like "bitrise-io/steps-xcode-test issue #87"; fallback tells which.
+func IssueTitle(i *gh.Issue) (title string, fallback bool) {
+	title = strings.TrimSpace(strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return ' '
+		}
+		return r
+	}, i.GetTitle()))
+	if strings.IndexFunc(title, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) >= 0 {
+		return title, false
+	}
+
+	repo := i.GetHTMLURL()
+	if ref, err := github.ParseIssueURL(i.GetHTMLURL()); err == nil {
+		repo = ref.FullName()
+	}
+	return fmt.Sprintf("%s issue #%d", repo, i.GetNumber()), true
+}
+
+// NewData collects the template variables of an issue.
+func NewData(i *gh.Issue) Data {
+	var repo, stepID string
+	if ref, err := github.ParseIssueURL(i.GetHTMLURL()); err == nil {
+		repo = ref.FullName()
+		stepID = steplib.StepID(ref.Repo)
+	}
+
+	var labels []string
+	for _, l := range i.Labels {
+		labels = append(labels, l.GetName())
+	}
+
+	title, fallback := IssueTitle(i)
+	return Data{
+		Labels:    labels,
+		Author:    i.GetUser().GetLogin(),
+		Name:      i.GetUser().GetLogin(),
+		StepID:    stepID,
+		IssueURL:  i.GetHTMLURL(),
+		Repo:      repo,
+		Number:    i.GetNumber(),
+		Title:     title,
+		CreatedAt: i.GetCreatedAt(),
+
+		FallbackTitle: fallback,
+	}
+}
+
+// Configure sets the locales of the config file.
+func Configure(cfg config.Config) error {
+	locales = cfg.Locales
+	rules = cfg.TemplateRules
+	configuredTpls = cfg.Templates
+
+	for _, r := range rules {
+		if r.Label == "" {
+			return fmt.Errorf("template rule without label")
+		}
+	}
+
+	for name, set := range locales.Sets {
+		seen := map[string]bool{name: true}
+		for l := set.Fallback; l != ""; l = locales.Sets[l].Fallback {
+			if seen[l] {
+				return fmt.Errorf("locale %s: fallback loop at %s", name, l)
+			}
+			seen[l] = true
+			if _, ok := locales.Sets[l]; !ok && l != defaultLocale {
+				return fmt.Errorf("locale %s: unknown fallback locale %s", name, l)
+			}
+		}
+	}
+	return nil
+}
+
+// Locale returns the locale of the messages for data.
+func Locale(data Data) string {
+	if l, ok := locales.ByRepo[data.Repo]; ok {
+		return l
+	}
+	if l, ok := locales.ByCategory[strconv.Itoa(data.CategoryID)]; ok {
+		return l
+	}
+	if locales.Default != "" {
+		return locales.Default
+	}
+	return defaultLocale
+}
+
+// builtin returns the template of the templates section of the config file, or the built-in one.
+func builtin(name string) (string, error) {
+	switch name {
+	case Active:
+		return orDefault(configuredTpls.Active, defaultActiveTpl), nil
+	case Stale:
+		return orDefault(configuredTpls.Stale, defaultStaleTpl), nil
+	case Duplicate:
+		return orDefault(configuredTpls.Duplicate, defaultDuplicateTpl), nil
+	case Campaign:
+		if campaignTplPath == "" {
+			return orDefault(configuredTpls.Campaign, defaultCampaignTpl), nil
+		}
+		b, err := ioutil.ReadFile(campaignTplPath)
+		if err != nil {
+			return "", fmt.Errorf("read campaign template %s: %s", campaignTplPath, err)
+		}
+		return string(b), nil
+	case Footer:
+		if footerTplPath == "" {
+			return orDefault(configuredTpls.Footer, defaultFooterTpl), nil
+		}
+		b, err := ioutil.ReadFile(footerTplPath)
+		if err != nil {
+			return "", fmt.Errorf("read footer template %s: %s", footerTplPath, err)
+		}
+		return string(b), nil
+	default:
+		return "", fmt.Errorf("unknown template %s", name)
+	}
+}
+
+func orDefault(text, def string) string {
+	if text == "" {
+		return def
+	}
+	return text
+}
+
+func pick(name, active, stale, footer string) string {
+	switch name {
+	case Active:
+		return active
+	case Stale:
+		return stale
+	case Footer:
+		return footer
+	}
+	return ""
+}
+
+// byLabel returns the template of the first rule matching a label of data.
+func byLabel(name string, data Data) (string, string) {
+	for _, r := range rules {
+		for _, l := range data.Labels {
+			if l != r.Label {
+				continue
+			}
+			if text := pick(name, r.Active, r.Stale, r.Footer); text != "" {
+				return "label:" + r.Label, text
+			}
+		}
+	}
+	return "", ""
+}
+
+func lookup(locale, name string) (string, error) {
+	for l := locale; l != ""; l = locales.Sets[l].Fallback {
+		set := locales.Sets[l]
+		if text := pick(name, set.Active, set.Stale, set.Footer); text != "" {
+			return text, nil
+		}
+	}
+	return builtin(name)
+}
+
+// Render executes the template text with data.
+func Render(name, text string, data Data) (string, error) {
+	tpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("parse %s template: %s", name, err)
+	}
+
+	var b bytes.Buffer
+	if err := tpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("execute %s template: %s", name, err)
+	}
+	return b.String(), nil
+}
+
+// Execute renders the named template of the override of the issue, of the first label rule matching data,
+// or else of the locale of data.
+func Execute(name string, data Data) (string, error) {
+	if o, ok := overrides.Get(data.IssueURL); ok {
+		if text := pick(name, o.Active, o.Stale, o.Footer); text != "" {
+			return Render("override:"+data.IssueURL+"/"+name, text, data)
+		}
+	}
+	if rule, text := byLabel(name, data); text != "" {
+		return Render(rule+"/"+name, text, data)
+	}
+
+	locale := Locale(data)
+	text, err := lookup(locale, name)
+	if err != nil {
+		return "", err
+	}
+	return Render(locale+"/"+name, text, data)
+}
+
+// TopicTitle renders the title of the topic of the issue with --title-tpl.
+func TopicTitle(data Data) (string, error) {
+	return Render(Title, titleTpl, data)
+}
+
+// Validate renders every template of every locale with empty data to catch errors before a run.
+func Validate() error {
+	all, err := configured()
+	if err != nil {
+		return err
+	}
+	for _, t := range all {
+		if _, err := Render(t.Name, t.Text, Data{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
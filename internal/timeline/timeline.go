@@ -0,0 +1,111 @@
+package timeline
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/lszucs/github-to-discourse/internal/state"
+)
+
+const (
+	// stallMinutes is the number of consecutive idle minutes reported as a stall.
+	stallMinutes = 2
+	// burstErrors is the number of errors within a minute reported as an error burst.
+	burstErrors = 3
+	barWidth    = 40
+)
+
+// Minute aggregates the events of a minute of a run.
+type Minute struct {
+	At     time.Time
+	Steps  int
+	Errors int
+	Issues int
+}
+
+// Build buckets the events of the records per minute, from the first to the last event.
+func Build(records []*state.Record) []Minute {
+	var first, last time.Time
+	for _, r := range records {
+		for _, e := range r.Events {
+			if first.IsZero() || e.Time.Before(first) {
+				first = e.Time
+			}
+			if e.Time.After(last) {
+				last = e.Time
+			}
+		}
+	}
+	if first.IsZero() {
+		return nil
+	}
+
+	first = first.Truncate(time.Minute)
+	minutes := make([]Minute, int(last.Sub(first)/time.Minute)+1)
+	for i := range minutes {
+		minutes[i].At = first.Add(time.Duration(i) * time.Minute)
+	}
+
+	for _, r := range records {
+		touched := map[int]bool{}
+		for _, e := range r.Events {
+			idx := int(e.Time.Sub(first) / time.Minute)
+			if e.Error != "" {
+				minutes[idx].Errors++
+			} else {
+				minutes[idx].Steps++
+			}
+			if !touched[idx] {
+				touched[idx] = true
+				minutes[idx].Issues++
+			}
+		}
+	}
+	return minutes
+}
+
+// Print renders the minutes as a chronological view, marking stalls and error bursts.
+func Print(w io.Writer, minutes []Minute) {
+	if len(minutes) == 0 {
+		fmt.Fprintln(w, "no events recorded")
+		return
+	}
+
+	max := 1
+	for _, m := range minutes {
+		if m.Steps > max {
+			max = m.Steps
+		}
+	}
+
+	fmt.Fprintf(w, "run timeline from %s (steps/issues/errors per minute)\n", minutes[0].At.Local().Format("2006-01-02 15:04"))
+	for i := 0; i < len(minutes); i++ {
+		m := minutes[i]
+		if m.Steps == 0 && m.Errors == 0 {
+			j := i
+			for j < len(minutes) && minutes[j].Steps == 0 && minutes[j].Errors == 0 {
+				j++
+			}
+			if j-i >= stallMinutes {
+				fmt.Fprintf(w, "%s  -- stall: %dm without progress --\n", m.At.Local().Format("15:04"), j-i)
+				i = j - 1
+				continue
+			}
+		}
+
+		line := fmt.Sprintf("%s  %3d/%3d/%3d  %s", m.At.Local().Format("15:04"), m.Steps, m.Issues, m.Errors, strings.Repeat("#", m.Steps*barWidth/max))
+		if m.Errors >= burstErrors {
+			line += "  << error burst"
+		}
+		fmt.Fprintln(w, line)
+	}
+
+	var steps, errors int
+	for _, m := range minutes {
+		steps += m.Steps
+		errors += m.Errors
+	}
+	fmt.Fprintf(w, "%d steps and %d errors in %d minutes, %.1f steps/minute\n", steps, errors, len(minutes), float64(steps)/float64(len(minutes)))
+}
@@ -0,0 +1,18 @@
+package trace
+
+import "strconv"
+
+// Call is a write API call made for an issue, recorded in the execution trace of the report.
+type Call struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	// Status is the HTTP status code of the response, 0 if there was none.
+	Status int `json:"status,omitempty"`
+	// Resource is the ID or URL of the resource the call created or changed.
+	Resource string `json:"resource,omitempty"`
+}
+
+// ID formats a resource ID.
+func ID(id int64) string {
+	return strconv.FormatInt(id, 10)
+}
@@ -0,0 +1,120 @@
+package watermark
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	gh "github.com/google/go-github/github"
+
+	"github.com/lszucs/github-to-discourse/internal/github"
+	"github.com/lszucs/github-to-discourse/internal/state"
+)
+
+const defaultPath = "watermarks.json"
+
+var (
+	path        string
+	incremental bool
+	marks       = map[string]time.Time{}
+
+	mu       sync.Mutex
+	observed []seen
+)
+
+func init() {
+	flag.StringVar(&path, "watermark-file", defaultPath, "--watermark-file=<path> (file to persist the most recent updated_at seen per repo to)")
+	flag.BoolVar(&incremental, "incremental", false, "--incremental (only fetch issues updated since the watermark of their repo, set by previous live runs)")
+}
+
+// Path returns the watermark file.
+func Path() string {
+	return path
+}
+
+// Enabled reports whether --incremental is set.
+func Enabled() bool {
+	return incremental
+}
+
+// Load reads the watermarks of previous runs. A missing file is not an error.
+func Load() error {
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read watermark file: %s", err)
+	}
+	if err := json.Unmarshal(b, &marks); err != nil {
+		return fmt.Errorf("parse watermark file %s: %s", path, err)
+	}
+	return nil
+}
+
+// Since returns the watermarks keyed by repo full name.
+func Since() map[string]time.Time {
+	return marks
+}
+
+// seen is the part of an issue the watermark depends on.
+type seen struct {
+	url     string
+	repo    string
+	updated time.Time
+	pr      bool
+}
+
+// Observe records a fetched issue for Advance.
+func Observe(i *gh.Issue) {
+	ref, err := github.ParseIssueURL(i.GetHTMLURL())
+	if err != nil {
+		return
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	observed = append(observed, seen{url: i.GetHTMLURL(), repo: ref.FullName(), updated: i.GetUpdatedAt(), pr: i.IsPullRequest()})
+}
+
+// Advance moves the watermark of every repo to the most recent updated_at of its observed issues,
+// but before the oldest failed or unprocessed one, so those are fetched again by the next run.
+func Advance() {
+	mu.Lock()
+	defer mu.Unlock()
+
+	pending := map[string]time.Time{}
+	for _, i := range observed {
+		rec, ok := state.Lookup(i.url)
+		if !i.pr && (!ok || rec.Error != "" && rec.Status != state.StatusQuarantined) {
+			if p, ok := pending[i.repo]; !ok || i.updated.Before(p) {
+				pending[i.repo] = i.updated
+			}
+			continue
+		}
+		if i.updated.After(marks[i.repo]) {
+			marks[i.repo] = i.updated
+		}
+	}
+
+	for repo, p := range pending {
+		if !marks[repo].Before(p) {
+			marks[repo] = p.Add(-time.Second)
+		}
+	}
+}
+
+// Save writes the watermarks to the watermark file.
+func Save() error {
+	b, err := json.MarshalIndent(marks, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal watermarks: %s", err)
+	}
+	if err := ioutil.WriteFile(path, b, 0644); err != nil {
+		return fmt.Errorf("write watermark file: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+package watermark
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	gh "github.com/google/go-github/github"
+
+	"github.com/lszucs/github-to-discourse/internal/state"
+)
+
+var base = time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+// reset forgets the watermarks and the observed issues, and sets the watermark file for the test.
+func reset(t *testing.T) string {
+	pth := filepath.Join(t.TempDir(), "watermarks.json")
+	oldPath := path
+	path, marks, observed = pth, map[string]time.Time{}, nil
+	t.Cleanup(func() {
+		path, marks, observed = oldPath, map[string]time.Time{}, nil
+	})
+	return pth
+}
+
+func TestSaveLoad(t *testing.T) {
+	reset(t)
+	if err := Load(); err != nil {
+		t.Fatalf("Load without a file: %s", err)
+	}
+	if len(Since()) != 0 {
+		t.Errorf("Since = %v without a file, want none", Since())
+	}
+
+	marks["octo/repo"] = base
+	marks["octo/lib"] = base.Add(-time.Hour)
+	if err := Save(); err != nil {
+		t.Fatalf("Save: %s", err)
+	}
+	marks = map[string]time.Time{}
+	if err := Load(); err != nil {
+		t.Fatalf("Load: %s", err)
+	}
+	if got := Since(); len(got) != 2 || !got["octo/repo"].Equal(base) || !got["octo/lib"].Equal(base.Add(-time.Hour)) {
+		t.Errorf("Since = %v after a save and a load, want the saved watermarks", got)
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	pth := reset(t)
+	if err := ioutil.WriteFile(pth, []byte(`{"octo/repo": "yesterday"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Load(); err == nil {
+		t.Error("Load of an invalid file: want error")
+	}
+}
+
+// observedIssue is an issue seen by the run: its updated_at after base and its state record.
+type observedIssue struct {
+	updated time.Duration
+	// record is done, failed, quarantined or none
+	record string
+	pr     bool
+}
+
+func TestAdvance(t *testing.T) {
+	tests := []struct {
+		name   string
+		before time.Duration
+		issues []observedIssue
+		// want is the watermark after base, none if it stays unset
+		want time.Duration
+		none bool
+	}{
+		{
+			name:   "every issue processed",
+			issues: []observedIssue{{1 * time.Hour, "done", false}, {3 * time.Hour, "done", false}, {2 * time.Hour, "done", false}},
+			want:   3 * time.Hour,
+		},
+		{
+			name:   "failed issue holds the watermark before it",
+			issues: []observedIssue{{1 * time.Hour, "done", false}, {2 * time.Hour, "failed", false}, {3 * time.Hour, "done", false}},
+			want:   2*time.Hour - time.Second,
+		},
+		{
+			name:   "oldest failure counts",
+			issues: []observedIssue{{3 * time.Hour, "failed", false}, {2 * time.Hour, "failed", false}, {4 * time.Hour, "done", false}},
+			want:   2*time.Hour - time.Second,
+		},
+		{
+			name:   "failure after the processed issues",
+			issues: []observedIssue{{1 * time.Hour, "done", false}, {2 * time.Hour, "failed", false}},
+			want:   1 * time.Hour,
+		},
+		{
+			name:   "unprocessed issue",
+			issues: []observedIssue{{1 * time.Hour, "none", false}, {2 * time.Hour, "done", false}},
+			want:   1*time.Hour - time.Second,
+		},
+		{
+			name:   "quarantined issue is not fetched again",
+			issues: []observedIssue{{1 * time.Hour, "quarantined", false}, {2 * time.Hour, "done", false}},
+			want:   2 * time.Hour,
+		},
+		{
+			name:   "pull requests are never processed",
+			issues: []observedIssue{{1 * time.Hour, "none", true}, {2 * time.Hour, "done", false}},
+			want:   2 * time.Hour,
+		},
+		{
+			name:   "no newer issue keeps the watermark",
+			before: 5 * time.Hour,
+			issues: []observedIssue{{1 * time.Hour, "done", false}},
+			want:   5 * time.Hour,
+		},
+		{
+			name:   "failure before the watermark moves it back",
+			before: 5 * time.Hour,
+			issues: []observedIssue{{1 * time.Hour, "failed", false}},
+			want:   1*time.Hour - time.Second,
+		},
+		{
+			name: "no issue observed",
+			none: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reset(t)
+			if tt.before != 0 {
+				marks["octo/repo"] = base.Add(tt.before)
+			}
+			for n, o := range tt.issues {
+				u := fmt.Sprintf("https://github.com/octo/repo/issues/%d", n+1)
+				updated := base.Add(o.updated)
+				i := &gh.Issue{HTMLURL: gh.String(u), UpdatedAt: &updated}
+				if o.pr {
+					i.PullRequestLinks = &gh.PullRequestLinks{URL: gh.String(u)}
+				}
+				Observe(i)
+
+				if o.record == "none" {
+					continue
+				}
+				rec := state.Get(u)
+				switch o.record {
+				case "failed":
+					rec.Fail(errors.New("api error"))
+				case "quarantined":
+					rec.Fail(errors.New("api error"))
+					rec.Quarantine()
+				}
+				defer state.Delete(u)
+			}
+
+			Advance()
+			got, ok := Since()["octo/repo"]
+			if tt.none {
+				if ok {
+					t.Errorf("watermark = %s, want none", got)
+				}
+				return
+			}
+			if want := base.Add(tt.want); !got.Equal(want) {
+				t.Errorf("watermark = %s, want %s", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package wizard
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/log"
+
+	"github.com/lszucs/github-to-discourse/internal/config"
+	"github.com/lszucs/github-to-discourse/internal/discourse"
+	"github.com/lszucs/github-to-discourse/internal/github"
+	"github.com/lszucs/github-to-discourse/internal/templates"
+)
+
+const (
+	defaultConfigPath    = "config.json"
+	credentialsFile      = "discourse-credentials.json"
+	defaultDiscourseURL  = "https://discuss.bitrise.io"
+	defaultDiscourseUser = "system"
+)
+
+type prompter struct {
+	r *bufio.Reader
+	w io.Writer
+}
+
+func (p prompter) ask(label, def string) (string, error) {
+	if def != "" {
+		fmt.Fprintf(p.w, "%s [%s]: ", label, def)
+	} else {
+		fmt.Fprintf(p.w, "%s: ", label)
+	}
+
+	answer, err := p.r.ReadString('\n')
+	if err != nil && (err != io.EOF || answer == "") {
+		return "", fmt.Errorf("read answer: %s", err)
+	}
+	answer = strings.TrimSpace(answer)
+	if answer == "" {
+		return def, nil
+	}
+	return answer, nil
+}
+
+// askValid asks until validate accepts the answer.
+func (p prompter) askValid(label, def string, validate func(string) error) (string, error) {
+	for {
+		answer, err := p.ask(label, def)
+		if err != nil {
+			return "", err
+		}
+		if err := validate(answer); err != nil {
+			log.Warnf("%s", err)
+			continue
+		}
+		return answer, nil
+	}
+}
+
+// Run asks for the credentials, target category and templates, validates them against the
+// live APIs and writes a starter config file with a default profile.
+func Run() error {
+	p := prompter{r: bufio.NewReader(os.Stdin), w: os.Stdout}
+
+	configPath := config.Path()
+	if configPath == "" {
+		configPath = defaultConfigPath
+	}
+	if _, err := os.Stat(configPath); err == nil {
+		answer, err := p.ask(fmt.Sprintf("%s exists, overwrite? (y/n)", configPath), "n")
+		if err != nil {
+			return err
+		}
+		if answer != "y" {
+			return fmt.Errorf("%s exists", configPath)
+		}
+	}
+
+	log.Infof("GitHub")
+	token, err := p.askValid("GitHub access token (empty: unauthenticated, dry runs only)", os.Getenv("GITHUB_ACCESS_TOKEN"), func(token string) error {
+		if token == "" {
+			return nil
+		}
+		login, err := github.ValidateToken(token)
+		if err != nil {
+			return err
+		}
+		log.Printf("authenticated as %s", login)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Discourse")
+	url, err := p.ask("Discourse URL", defaultDiscourseURL)
+	if err != nil {
+		return err
+	}
+	var creds discourse.Credentials
+	if creds.APIUser, err = p.ask("Discourse API user", defaultDiscourseUser); err != nil {
+		return err
+	}
+	if _, err := p.askValid("Discourse API key", os.Getenv("DISCOURSE_API_KEY"), func(key string) error {
+		creds.APIKey = key
+		discourse.Connect(url, creds)
+		caps, err := discourse.DetectCapabilities()
+		if err != nil {
+			return err
+		}
+		log.Printf("connected to %s, API user is admin: %t", url, caps.Admin)
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	categoryAnswer, err := p.askValid("target category ID", strconv.Itoa(discourse.CategoryID()), func(answer string) error {
+		id, err := strconv.Atoi(answer)
+		if err != nil {
+			return fmt.Errorf("not a number: %s", answer)
+		}
+		c, err := discourse.GetCategory(id)
+		if err != nil {
+			return err
+		}
+		log.Printf("category %d: %s", c.ID, c.Name)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	categoryID, _ := strconv.Atoi(categoryAnswer)
+
+	log.Infof("Templates")
+	var tpls config.Templates
+	for _, t := range []struct {
+		name   string
+		target *string
+	}{
+		{templates.Active, &tpls.Active},
+		{templates.Stale, &tpls.Stale},
+		{templates.Footer, &tpls.Footer},
+	} {
+		if _, err := p.askValid(fmt.Sprintf("%s template file (empty: built-in)", t.name), "", func(pth string) error {
+			if pth == "" {
+				return nil
+			}
+			b, err := ioutil.ReadFile(pth)
+			if err != nil {
+				return err
+			}
+			if _, err := templates.Render(t.name, string(b), templates.Data{}); err != nil {
+				return err
+			}
+			*t.target = string(b)
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+
+	credsPath := filepath.Join(filepath.Dir(configPath), credentialsFile)
+	credsData, err := json.MarshalIndent(creds, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal credentials: %s", err)
+	}
+	if err := ioutil.WriteFile(credsPath, credsData, 0600); err != nil {
+		return fmt.Errorf("write credentials file %s: %s", credsPath, err)
+	}
+
+	cfg := config.Config{
+		Discourse: config.Discourse{
+			URL:         url,
+			CategoryID:  categoryID,
+			Credentials: "file:" + credsPath,
+		},
+		Templates: tpls,
+	}
+	if err := config.Write(configPath, cfg); err != nil {
+		return err
+	}
+
+	log.Successf("config written to %s, Discourse credentials to %s", configPath, credsPath)
+	if token != "" && os.Getenv("GITHUB_ACCESS_TOKEN") != token {
+		log.Printf("the GitHub token is not stored, export it before running: export GITHUB_ACCESS_TOKEN=<token>")
+	}
+	log.Printf("try a dry run: go run . --config=%s https://github.com/<owner>/<repo>", configPath)
+	return nil
+}
@@ -0,0 +1,150 @@
+package worker
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/bitrise-io/go-utils/log"
+
+	"github.com/lszucs/github-to-discourse/internal/github"
+	"github.com/lszucs/github-to-discourse/internal/runmode"
+	"github.com/lszucs/github-to-discourse/internal/state"
+)
+
+// idleDelay is the wait before running the receive command again after it returned no messages.
+const idleDelay = 10 * time.Second
+
+var (
+	source string
+	ackCmd string
+)
+
+func init() {
+	flag.StringVar(&source, "queue-source", "stdin", "--queue-source=stdin|exec:<command> (where the worker receives messages from, one per line: stdin, or the output of a command run again once it exits, e.g. an SQS or NATS CLI)")
+	flag.StringVar(&ackCmd, "queue-ack", "", "--queue-ack=exec:<command> (command acknowledging a processed message, run with QUEUE_RECEIPT and ISSUE_URL set; unacknowledged messages are left to the queue to redeliver)")
+}
+
+// Message is a queue message asking to migrate an issue. A line holding only a URL is a message too.
+type Message struct {
+	IssueURL string `json:"issue_url"`
+	// Receipt identifies the message for the acknowledgement, e.g. the receipt handle of SQS.
+	Receipt string `json:"receipt,omitempty"`
+}
+
+func parse(line string) (Message, error) {
+	var m Message
+	if !strings.HasPrefix(line, "{") {
+		m.IssueURL = line
+		return m, nil
+	}
+	if err := json.Unmarshal([]byte(line), &m); err != nil {
+		return m, fmt.Errorf("unmarshal message %s: %s", line, err)
+	}
+	if m.IssueURL == "" {
+		return m, fmt.Errorf("message %s: issue_url missing", line)
+	}
+	return m, nil
+}
+
+// Validate fails if the source or the acknowledgement command is malformed, or without
+// --shared-state.
+func Validate() error {
+	if source != "stdin" && !strings.HasPrefix(source, "exec:") {
+		return fmt.Errorf("not recognized queue source %s", source)
+	}
+	if ackCmd != "" && !strings.HasPrefix(ackCmd, "exec:") {
+		return fmt.Errorf("not recognized queue ack %s", ackCmd)
+	}
+	if !state.Shared() {
+		return fmt.Errorf("workers need --shared-state, otherwise they overwrite each other's state file")
+	}
+	return nil
+}
+
+// Run migrates the issues of the messages received until stdin is closed, or forever with a command source.
+func Run() error {
+	if source == "stdin" {
+		return consume(os.Stdin)
+	}
+
+	command := strings.TrimPrefix(source, "exec:")
+	for {
+		cmd := exec.Command("sh", "-c", command)
+		cmd.Stderr = os.Stderr
+		out, err := cmd.Output()
+		if err != nil {
+			log.Warnf("receive messages: %s, retry in %s", err, idleDelay)
+			time.Sleep(idleDelay)
+			continue
+		}
+		if strings.TrimSpace(string(out)) == "" {
+			time.Sleep(idleDelay)
+			continue
+		}
+		if err := consume(strings.NewReader(string(out))); err != nil {
+			return err
+		}
+	}
+}
+
+func consume(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		m, err := parse(line)
+		if err != nil {
+			log.Errorf("drop message: %s", err)
+			continue
+		}
+		if err := handle(m); err != nil {
+			log.Errorf("%s, left to the queue to redeliver", err)
+			continue
+		}
+		if err := ack(m); err != nil {
+			log.Warnf("ack %s: %s", m.IssueURL, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("read messages: %s", err)
+	}
+	return nil
+}
+
+// handle migrates the issue of the message with the records saved by other workers taken first.
+func handle(m Message) error {
+	if err := state.Load(); err != nil {
+		return err
+	}
+	i, err := github.GetIssue(m.IssueURL)
+	if err != nil {
+		return err
+	}
+	if i.GetState() != "open" {
+		log.Printf("skip %s: %s", i.GetHTMLURL(), i.GetState())
+		return nil
+	}
+
+	var stats runmode.Stats
+	return runmode.Process(i, &stats)
+}
+
+func ack(m Message) error {
+	if ackCmd == "" {
+		return nil
+	}
+	cmd := exec.Command("sh", "-c", strings.TrimPrefix(ackCmd, "exec:"))
+	cmd.Env = append(os.Environ(), "QUEUE_RECEIPT="+m.Receipt, "ISSUE_URL="+m.IssueURL)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
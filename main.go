@@ -1,15 +1,48 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
 	"flag"
 	"os"
 	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/bitrise-io/go-utils/log"
+	gh "github.com/google/go-github/github"
+	"github.com/lszucs/github-to-discourse/internal/analytics"
+	"github.com/lszucs/github-to-discourse/internal/archive"
+	"github.com/lszucs/github-to-discourse/internal/budget"
+	"github.com/lszucs/github-to-discourse/internal/bundle"
+	"github.com/lszucs/github-to-discourse/internal/chaos"
+	"github.com/lszucs/github-to-discourse/internal/cleanup"
+	"github.com/lszucs/github-to-discourse/internal/config"
+	"github.com/lszucs/github-to-discourse/internal/daemon"
+	"github.com/lszucs/github-to-discourse/internal/dashboard"
+	"github.com/lszucs/github-to-discourse/internal/delta"
+	"github.com/lszucs/github-to-discourse/internal/discourse"
 	"github.com/lszucs/github-to-discourse/internal/github"
-	"github.com/lszucs/github-to-discourse/internal/steplib"
+	"github.com/lszucs/github-to-discourse/internal/hooks"
+	"github.com/lszucs/github-to-discourse/internal/invites"
+	"github.com/lszucs/github-to-discourse/internal/linkcheck"
+	"github.com/lszucs/github-to-discourse/internal/onerror"
+	"github.com/lszucs/github-to-discourse/internal/overrides"
+	"github.com/lszucs/github-to-discourse/internal/preview"
+	"github.com/lszucs/github-to-discourse/internal/projects"
+	"github.com/lszucs/github-to-discourse/internal/report"
+	"github.com/lszucs/github-to-discourse/internal/run"
 	"github.com/lszucs/github-to-discourse/internal/runmode"
+	"github.com/lszucs/github-to-discourse/internal/schedule"
+	"github.com/lszucs/github-to-discourse/internal/sealed"
+	"github.com/lszucs/github-to-discourse/internal/soak"
+	"github.com/lszucs/github-to-discourse/internal/state"
+	"github.com/lszucs/github-to-discourse/internal/steplib"
+	"github.com/lszucs/github-to-discourse/internal/templates"
+	"github.com/lszucs/github-to-discourse/internal/timeline"
+	"github.com/lszucs/github-to-discourse/internal/watermark"
+	"github.com/lszucs/github-to-discourse/internal/wizard"
+	"github.com/lszucs/github-to-discourse/internal/worker"
 )
 
 const (
@@ -18,16 +51,46 @@ const (
 	defaultOrgs = "bitrise-steplib,bitrise-io,bitrise-community"
 )
 
+// commands are run instead of the migration if given as the first argument.
+var commands = map[string]bool{
+	"stats":           true,
+	"daemon":          true,
+	"init":            true,
+	"export-project":  true,
+	"timeline":        true,
+	"link-steplib":    true,
+	"templates":       true,
+	"delta":           true,
+	"validate-report": true,
+	"export-archive":  true,
+	"state":           true,
+	"cleanup":         true,
+	"continue":        true,
+	"check-links":     true,
+	"campaign":        true,
+	"soak":            true,
+	"invites":         true,
+	"worker":          true,
+}
+
 var (
 	mode   string
 	repoSrc string
 	orgs   string
+	limit   int
+	// continueRepo is the owner/name of the repo the continue command migrates.
+	continueRepo string
+
+	summaryCategoryID int
 )
 
 func init() {
 	flag.StringVar(&mode, "mode", defaultMode, "--mode=dry|live (dry: only prints what would happen, but modifies nothing)")
-	flag.StringVar(&repoSrc, "repo-src", defaultRepoSrc, "--repo-src=cherry|steplib (repo loader to use to process arguments)")
+	flag.StringVar(&repoSrc, "repo-src", defaultRepoSrc, "--repo-src=cherry|steplib|topic|starred (repo loader to use to process arguments: repo URLs, steplib spec URL, GitHub topic or GitHub user)")
 	flag.StringVar(&orgs, "orgs", defaultOrgs, "--orgs=bitrise-steplib,bitrise-io (filters step repos to those owned by given orgs)")
+	flag.IntVar(&summaryCategoryID, "summary-category-id", 0, "--summary-category-id=<int> (staff category to post a run summary topic with the mapping file attached to, 0 disables it)")
+	flag.IntVar(&limit, "limit", 0, "--limit=<int> (process at most this many issues, 0 means no limit)")
+	flag.StringVar(&continueRepo, "repo", "", "--repo=<owner/name> (repo the continue command migrates, loading only its state)")
 }
 
 func getRepoURLs(repoSrc string, srcStr string) ([]string, error) {
@@ -38,55 +101,808 @@ func getRepoURLs(repoSrc string, srcStr string) ([]string, error) {
 		fromOrgs := strings.Split(orgs, ",")
 		repoURLs, err = steplib.LoadRepos(srcStr, fromOrgs)
 		if err != nil {
-			return nil, fmt.Errorf("load repos from steplib: %s")
+			return nil, fmt.Errorf("load repos from steplib: %s", err)
 		}
 
 		return repoURLs, nil
 	case "cherry":
 		return strings.Split(srcStr, ","), nil
+	case "topic":
+		return github.ReposByTopic(srcStr)
+	case "starred":
+		return github.StarredRepos(srcStr)
 	default:
 		return nil, fmt.Errorf("error: not recognized repo source %s", repoSrc)
 	}
 }
 
+func printStats(issues []*gh.Issue) {
+	stats, total := analytics.Analyze(issues, github.Now())
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	analytics.Print(w, stats, total)
+	if err := w.Flush(); err != nil {
+		log.Warnf("flush stats: %s", err)
+	}
+}
+
+// scheduledFlags returns the flags set on the command line for the runs of --schedule.
+func scheduledFlags() []string {
+	var flags []string
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name != "schedule" {
+			flags = append(flags, fmt.Sprintf("--%s=%s", f.Name, f.Value))
+		}
+	})
+	return flags
+}
+
+// tracked is what is kept of the streamed issues once they are processed.
+type tracked struct {
+	urls      []string
+	reactions map[string]int
+	// complete is set if every open issue was fetched and forwarded.
+	complete bool
+}
+
+// track forwards at most --limit of the fetched issues to the returned channel, observing them
+// for the watermarks and recording their URLs and reactions in t. The second channel is closed
+// once t is final: after fetched is drained, or stop is closed.
+func track(fetched <-chan *gh.Issue, stop <-chan struct{}, t *tracked) (<-chan *gh.Issue, <-chan bool) {
+	out := make(chan *gh.Issue)
+	done := make(chan bool)
+	t.reactions = map[string]int{}
+
+	go func() {
+		defer close(done)
+		defer close(out)
+
+		for i := range fetched {
+			if limit > 0 && len(t.urls) == limit {
+				log.Printf("limit to the first %d issues", limit)
+				return
+			}
+
+			watermark.Observe(i)
+			if !i.IsPullRequest() {
+				dashboard.Fetched(i)
+			}
+			t.urls = append(t.urls, i.GetHTMLURL())
+			t.reactions[i.GetHTMLURL()] = i.GetReactions().GetTotalCount()
+
+			select {
+			case out <- i:
+			case <-stop:
+				return
+			}
+		}
+		t.complete = true
+	}()
+	return out, done
+}
+
+func printRateLimit() {
+	rate, err := github.RateLimit()
+	if err != nil {
+		log.Warnf("%s", err)
+		return
+	}
+	log.Warnf("GitHub rate limit: %d/%d requests remaining, resets at %s", rate.Remaining, rate.Limit, rate.Reset.Format(time.RFC3339))
+}
+
+func checkCredentials(mode string) error {
+	if github.Authenticated() {
+		return nil
+	}
+
+	if mode != "dry" {
+		return fmt.Errorf("GITHUB_ACCESS_TOKEN empty, %s mode requires an authenticated GitHub client", mode)
+	}
+
+	log.Warnf("GITHUB_ACCESS_TOKEN empty, running unauthenticated: only public repos are visible and the rate limit is 60 requests/hour")
+	printRateLimit()
+	return nil
+}
+
+func checkQuietMode() {
+	caps, err := discourse.DetectCapabilities()
+	if err != nil {
+		log.Warnf("detect discourse capabilities: %s", err)
+		return
+	}
+
+	if caps.Admin {
+		log.Printf("discourse API user is admin: topics keep their GitHub creation date and won't notify category watchers as new")
+		return
+	}
+	log.Warnf("discourse API user is not admin: created_at is ignored by the instance, only auto tracking is disabled and watchers will be notified")
+}
+
+func postSummary(rep report.Report) (string, error) {
+	mapping, err := sealed.ReadFile(report.MappingPath())
+	if err != nil {
+		return "", fmt.Errorf("read mapping: %s", err)
+	}
+	mappingURL, err := discourse.UploadReader(report.MappingPath(), bytes.NewReader(mapping))
+	if err != nil {
+		return "", err
+	}
+
+	created, err := discourse.PostTopic(discourse.Topic{
+		Title:      fmt.Sprintf("GitHub migration run summary %s", rep.FinishedAt.UTC().Format("2006-01-02 15:04")),
+		Content:    fmt.Sprintf("%s\n\n[mapping.json|attachment](%s)", rep.Summary(), mappingURL),
+		CategoryID: summaryCategoryID,
+	})
+	return created.URL, err
+}
+
+// stateCommand exports the state, the watermarks, the history, the plan and the run artifacts to a
+// bundle, or imports them from one, to resume runs on another machine.
+func stateCommand() error {
+	sub := flag.Arg(0)
+	// flags may follow the subcommand as well
+	if err := flag.CommandLine.Parse(flag.Args()[1:]); err != nil {
+		return err
+	}
+	file := flag.Arg(0)
+	if file == "" {
+		file = bundle.DefaultPath()
+	}
+
+	switch sub {
+	case "export":
+		paths := append(state.Paths(), watermark.Path(), run.ArtifactsDir())
+		for _, p := range []string{report.HistoryPath(), runmode.PlanPath()} {
+			if p != "" {
+				paths = append(paths, p)
+			}
+		}
+		files, err := bundle.Export(file, paths)
+		if err != nil {
+			return err
+		}
+		log.Successf("exported %d files to %s", len(files), file)
+	case "import":
+		files, err := bundle.Import(file)
+		if err != nil {
+			return err
+		}
+		for _, f := range files {
+			log.Printf("- %s", f)
+		}
+		log.Successf("imported %d files from %s, resume with the same flags", len(files), file)
+	default:
+		return fmt.Errorf("usage: state export|import [--format=tar.gz|tar] [<file>]")
+	}
+	return nil
+}
+
 func main() {
+	startedAt := time.Now()
 
 	flag.Parse()
-	
-	if len(flag.Args()) == 0 {
+
+	command := ""
+	if flag.NArg() > 0 && commands[flag.Arg(0)] {
+		command = flag.Arg(0)
+		// flags may follow the command as well
+		if err := flag.CommandLine.Parse(flag.Args()[1:]); err != nil {
+			log.Errorf("error: %s", err)
+			os.Exit(1)
+		}
+	}
+
+	if command == "init" {
+		if err := wizard.Run(); err != nil {
+			log.Errorf("error: %s", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Errorf("error: %s", err)
+		os.Exit(1)
+	}
+
+	var violations []config.Violation
+	violations = append(violations, config.Check(cfg)...)
+	violations = append(violations, templates.Check(cfg)...)
+	violations = append(violations, hooks.Check(cfg)...)
+	violations = append(violations, runmode.CheckFlags()...)
+	if len(violations) > 0 {
+		for _, v := range violations {
+			log.Errorf("%s", v)
+		}
+		log.Errorf("error: %d problems in the config and flags", len(violations))
+		os.Exit(1)
+	}
+
+	if err := templates.Configure(cfg); err != nil {
+		log.Errorf("error: %s", err)
+		os.Exit(1)
+	}
+	discourse.Configure(cfg)
+	if err := discourse.ConfigureTransport(); err != nil {
+		log.Errorf("error: %s", err)
+		os.Exit(1)
+	}
+	if err := hooks.Configure(cfg); err != nil {
+		log.Errorf("error: %s", err)
+		os.Exit(1)
+	}
+	if err := overrides.Load(); err != nil {
+		log.Errorf("error: %s", err)
+		os.Exit(1)
+	}
+
+	if command == "templates" {
+		switch flag.Arg(0) {
+		case "lint":
+			if err := templates.Lint(os.Stdout); err != nil {
+				log.Errorf("error: %s", err)
+				os.Exit(1)
+			}
+		case "serve":
+			if err := templates.Validate(); err != nil {
+				log.Errorf("error: %s", err)
+				os.Exit(1)
+			}
+			if err := preview.Serve(); err != nil {
+				log.Errorf("error: %s", err)
+				os.Exit(1)
+			}
+		default:
+			log.Errorf("error: usage: templates lint|serve")
+			os.Exit(1)
+		}
+		return
+	}
+
+	if err := run.Init(startedAt); err != nil {
+		log.Errorf("error: %s", err)
+		os.Exit(1)
+	}
+
+	if command == "export-archive" {
+		if len(flag.Args()) < 1 {
+			log.Errorf("error: usage: export-archive <out-dir>")
+			os.Exit(1)
+		}
+		if err := archive.Export(flag.Arg(0)); err != nil {
+			log.Errorf("error: %s", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if command == "state" {
+		if err := stateCommand(); err != nil {
+			log.Errorf("error: %s", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if command == "validate-report" {
+		problems, err := report.Validate()
+		if err != nil {
+			log.Errorf("error: %s", err)
+			os.Exit(1)
+		}
+		for _, p := range problems {
+			log.Errorf("- %s", p)
+		}
+		reportFile, mappingFile := report.Paths()
+		if len(problems) > 0 {
+			log.Errorf("error: %s and %s don't match schema version %d", reportFile, mappingFile, report.SchemaVersion)
+			os.Exit(1)
+		}
+		log.Successf("%s and %s match schema version %d", reportFile, mappingFile, report.SchemaVersion)
+		return
+	}
+
+	if command == "timeline" {
+		if err := state.Load(); err != nil {
+			log.Errorf("error: %s", err)
+			os.Exit(1)
+		}
+		timeline.Print(os.Stdout, timeline.Build(state.All()))
+		return
+	}
+
+	if err := sealed.CheckKey(); err != nil {
+		log.Errorf("error: %s", err)
+		os.Exit(1)
+	}
+
+	if err := runmode.ValidateActions(); err != nil {
+		log.Errorf("error: %s", err)
+		os.Exit(1)
+	}
+
+	if err := runmode.ValidateLockedComments(); err != nil {
+		log.Errorf("error: %s", err)
+		os.Exit(1)
+	}
+
+	if err := runmode.ValidateCC(); err != nil {
+		log.Errorf("error: %s", err)
+		os.Exit(1)
+	}
+
+	if err := discourse.ValidatePostAs(); err != nil {
+		log.Errorf("error: %s", err)
+		os.Exit(1)
+	}
+
+	if err := runmode.ValidateCloseReason(); err != nil {
+		log.Errorf("error: %s", err)
+		os.Exit(1)
+	}
+
+	if err := runmode.ValidateTaskLists(); err != nil {
+		log.Errorf("error: %s", err)
+		os.Exit(1)
+	}
+
+	if err := schedule.Validate(); err != nil {
+		log.Errorf("error: %s", err)
+		os.Exit(1)
+	}
+
+	if err := runmode.ValidateOnDrift(); err != nil {
+		log.Errorf("error: %s", err)
+		os.Exit(1)
+	}
+
+	if err := discourse.ValidatePreserveDates(); err != nil {
+		log.Errorf("error: %s", err)
+		os.Exit(1)
+	}
+
+	if err := github.ValidateAsOf(); err != nil {
+		log.Errorf("error: %s", err)
+		os.Exit(1)
+	}
+
+	if err := github.ValidateSince(); err != nil {
+		log.Errorf("error: %s", err)
+		os.Exit(1)
+	}
+
+	if err := github.ValidatePerPage(); err != nil {
+		log.Errorf("error: %s", err)
+		os.Exit(1)
+	}
+
+	if err := steplib.ValidateTags(); err != nil {
+		log.Errorf("error: %s", err)
+		os.Exit(1)
+	}
+
+	if err := archive.Validate(); err != nil {
+		log.Errorf("error: %s", err)
+		os.Exit(1)
+	}
+
+	if err := onerror.Validate(); err != nil {
+		log.Errorf("error: %s", err)
+		os.Exit(1)
+	}
+
+	if command == "campaign" {
+		runmode.SetCampaign()
+	}
+
+	if command == "continue" {
+		if strings.Count(continueRepo, "/") != 1 {
+			log.Errorf("error: usage: continue --repo=<owner/name>")
+			os.Exit(1)
+		}
+		state.Only(continueRepo)
+	}
+
+	runMode := mode
+	switch command {
+	case "", "delta", "cleanup", "continue", "check-links", "campaign", "soak":
+	case "daemon", "link-steplib", "worker":
+		runMode = "live"
+	case "export-project":
+		// only writes to GitHub
+		runMode = command
+	default:
+		// commands only read from the APIs
+		runMode = "dry"
+	}
+
+	if err := chaos.Validate(runMode, discourse.Staging()); err != nil {
+		log.Errorf("error: %s", err)
+		os.Exit(1)
+	}
+	if chaos.Enabled() {
+		log.Warnf("chaos enabled: API calls fail randomly")
+	}
+
+	if err := checkCredentials(runMode); err != nil {
+		log.Errorf("error: %s", err)
+		os.Exit(1)
+	}
+
+	if runMode == "live" {
+		// campaigns only comment on GitHub, Discourse is needed for the run summary only
+		if command != "campaign" || summaryCategoryID != 0 {
+			if err := discourse.CheckCredentials(); err != nil {
+				log.Errorf("error: %s", err)
+				os.Exit(1)
+			}
+		}
+
+		if err := templates.Validate(); err != nil {
+			log.Errorf("error: %s", err)
+			os.Exit(1)
+		}
+
+		if command != "campaign" {
+			if err := discourse.ConfigureImportMode(); err != nil {
+				log.Errorf("error: %s", err)
+				os.Exit(1)
+			}
+			if discourse.Quiet() {
+				checkQuietMode()
+			}
+
+			if err := discourse.ResolveCategories(true); err != nil {
+				log.Errorf("error: %s", err)
+				os.Exit(1)
+			}
+
+			log.Infof("check category permissions")
+			if err := discourse.CheckCategories(append(append(discourse.ConfiguredCategories(), overrides.Categories()...), summaryCategoryID)); err != nil {
+				log.Errorf("error: %s", err)
+				os.Exit(1)
+			}
+			if err := discourse.CheckTagRequirements(append(discourse.ConfiguredCategories(), overrides.Categories()...)); err != nil {
+				log.Errorf("error: %s", err)
+				os.Exit(1)
+			}
+		}
+
+		if err := state.Load(); err != nil {
+			log.Errorf("error: %s", err)
+			os.Exit(1)
+		}
+	}
+
+	if runMode == "dry" {
+		if err := discourse.ResolveCategories(false); err != nil {
+			log.Errorf("error: %s", err)
+			os.Exit(1)
+		}
+	}
+
+	if schedule.Enabled() {
+		if command != "" && command != "delta" {
+			log.Errorf("error: --schedule runs migrations, it can't be combined with the %s command", command)
+			os.Exit(1)
+		}
+		if !run.Generated() {
+			log.Errorf("error: --schedule gives every run an ID of its own, --run-id can't be set")
+			os.Exit(1)
+		}
+		if len(flag.Args()) == 0 {
+			log.Errorf("error: no repo source url specified")
+			os.Exit(1)
+		}
+		if err := schedule.Run(scheduledFlags(), flag.Args()); err != nil {
+			log.Errorf("error: %s", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if command == "worker" {
+		if err := worker.Validate(); err != nil {
+			log.Errorf("error: %s", err)
+			os.Exit(1)
+		}
+		if err := worker.Run(); err != nil {
+			log.Errorf("error: %s", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if command == "cleanup" {
+		if run.Generated() {
+			log.Errorf("error: cleanup requires the --run-id of the run to clean up")
+			os.Exit(1)
+		}
+		if runMode != "live" {
+			if err := state.Load(); err != nil {
+				log.Errorf("error: %s", err)
+				os.Exit(1)
+			}
+		}
+		if err := cleanup.Run(run.ID(), runMode == "live"); err != nil {
+			log.Errorf("error: %s", err)
+			os.Exit(1)
+		}
+		log.Successf("run %s cleaned up", run.ID())
+		return
+	}
+
+	if command == "check-links" {
+		if runMode != "live" {
+			if err := state.Load(); err != nil {
+				log.Errorf("error: %s", err)
+				os.Exit(1)
+			}
+		}
+		if err := linkcheck.Run(runMode == "live"); err != nil {
+			log.Errorf("error: %s", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if command == "soak" {
+		if err := soak.Run(runMode == "live"); err != nil {
+			log.Errorf("error: %s", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	var deltaSince time.Time
+	if command == "delta" {
+		if runMode != "live" {
+			if err := state.Load(); err != nil {
+				log.Errorf("error: %s", err)
+				os.Exit(1)
+			}
+		}
+		id, start, ok := delta.LastRun(state.All())
+		if !ok {
+			log.Errorf("error: no previous run recorded in the state file, run a regular migration first")
+			os.Exit(1)
+		}
+		deltaSince = start
+		log.Printf("delta since run %s started at %s", id, start.Format(time.RFC3339))
+	}
+
+	if command == "export-project" {
+		if err := state.Load(); err != nil {
+			log.Errorf("error: %s", err)
+			os.Exit(1)
+		}
+		if err := projects.Export(); err != nil {
+			log.Errorf("error: %s", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if command == "daemon" {
+		if err := daemon.Run(); err != nil {
+			log.Errorf("error: %s", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(flag.Args()) == 0 && command != "continue" {
 		log.Errorf("error: no repo source url specified")
 		os.Exit(1)
 	}
 
+	if command == "link-steplib" {
+		if err := steplib.Link(flag.Args()[0], strings.Split(orgs, ",")); err != nil {
+			log.Errorf("error: %s", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	log.Infof("get repos")
-	repoURLs, err := getRepoURLs(repoSrc, flag.Args()[0])
-	if err != nil {
-		log.Errorf("error getting repos using mode %s and arg %s: %s", repoSrc, flag.Args()[0], err)
-		os.Exit(1)
+	repoURLs := []string{"https://github.com/" + continueRepo}
+	if command != "continue" {
+		repoURLs, err = getRepoURLs(repoSrc, flag.Args()[0])
+		if err != nil {
+			log.Errorf("error getting repos using mode %s and arg %s: %s", repoSrc, flag.Args()[0], err)
+			os.Exit(1)
+		}
 	}
 	log.Printf("loaded %d repos: %s", len(repoURLs), repoURLs)
-	
+
+	if group := steplib.TagGroup(); group != "" && command != "continue" {
+		if runMode != "live" {
+			log.Printf("would add the tags of %d steps to the tag group %s", len(steplib.Tags()), group)
+		} else if added, err := discourse.EnsureTagGroup(group, steplib.Tags()); err != nil {
+			log.Errorf("error: %s", err)
+			os.Exit(1)
+		} else {
+			log.Printf("added %d step tags to the tag group %s", len(added), group)
+		}
+	}
+
+	if err := github.CheckSSO(repoURLs); err != nil {
+		log.Errorf("error: %s", err)
+		os.Exit(1)
+	}
+
+	if mode == "live" {
+		budget.Estimate{
+			Discovery: map[string]int{budget.GitHub: len(repoURLs)},
+			PerIssue:  runmode.IssueCalls(),
+		}.Print(log.Printf)
+	}
+
 	log.Infof("get open issues")
-	issues := github.GetOpenIssues(repoURLs)
-	log.Printf("found %d open issues: %s", len(issues), github.GetHTMLURLs(issues))
+	if watermark.Enabled() {
+		if err := watermark.Load(); err != nil {
+			log.Errorf("error: %s", err)
+			os.Exit(1)
+		}
+	}
+
+	if command == "stats" {
+		issues, err := github.GetOpenIssuesSince(repoURLs, watermark.Since())
+		if err != nil {
+			log.Errorf("error: %s", err)
+			os.Exit(1)
+		}
+		log.Printf("found %d open issues", len(issues))
+		printStats(issues)
+		return
+	}
+
+	if command == "invites" {
+		issues, err := github.GetOpenIssuesSince(repoURLs, watermark.Since())
+		if err != nil {
+			log.Errorf("error: %s", err)
+			os.Exit(1)
+		}
+		log.Printf("found %d open issues", len(issues))
+		people, err := invites.Collect(issues)
+		if err != nil {
+			log.Errorf("error: %s", err)
+			os.Exit(1)
+		}
+		if err := invites.Write(people); err != nil {
+			log.Errorf("error: %s", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if mode == "live" {
+		if err := runmode.Estimate(repoURLs, watermark.Since()); err != nil {
+			log.Errorf("error: %s", err)
+			os.Exit(1)
+		}
+	}
+
+	stop := make(chan struct{})
+	fetched, fetchErrs := github.StreamOpenIssues(repoURLs, watermark.Since(), stop)
+	if command == "delta" {
+		fetched = delta.Filter(fetched, deltaSince)
+	}
+	if command == "continue" {
+		fetched = runmode.Resume(fetched, stop)
+	}
+	var seen tracked
+	issues, tracking := track(fetched, stop, &seen)
+
+	if err := runmode.LoadPlan(); err != nil {
+		log.Errorf("error: %s", err)
+		os.Exit(1)
+	}
 
 	var stats runmode.Stats
 	switch mode {
 	case "dry":
 		stats, err = runmode.DryRun(issues)
 	case "live":
+		stopDashboard := func() {}
+		if dashboard.Enabled() {
+			stopDashboard = dashboard.Start()
+		}
 		stats, err = runmode.LiveRun(issues)
+		stopDashboard()
 	default:
 		log.Errorf("error: unkown run mode %s", mode)
 		os.Exit(1)
 	}
-	
+	close(stop)
+	<-tracking
+	if perr := runmode.SavePlan(); perr != nil {
+		log.Warnf("%s", perr)
+	}
+	if ferr := <-fetchErrs; ferr != nil {
+		seen.complete = false
+		if err == nil {
+			err = ferr
+		}
+	}
+	log.Printf("found %d open issues", len(seen.urls))
+	if !github.Authenticated() {
+		printRateLimit()
+	}
+
+	exhausted := err == budget.ErrExhausted
+	if exhausted {
+		log.Warnf("stopped cleanly: API call budget exhausted after %d GitHub and %d Discourse calls", budget.Used(budget.GitHub), budget.Used(budget.Discourse))
+		seen.complete = false
+		err = nil
+	}
+
+	rep := report.New(mode, startedAt, repoURLs, seen.urls, stats)
+	if herr := rep.Record(); herr != nil {
+		log.Warnf("record metrics history: %s", herr)
+	}
+	if werr := report.Write(rep); werr != nil {
+		log.Warnf("write report: %s", werr)
+	}
+
+	if mode == "live" && command != "campaign" {
+		if seen.complete {
+			watermark.Advance()
+			if werr := watermark.Save(); werr != nil {
+				log.Warnf("%s", werr)
+			}
+		} else {
+			log.Warnf("not every issue was fetched, watermarks are left unchanged")
+		}
+	}
+
 	if err != nil {
 		log.Errorf("error: %s", err)
 		os.Exit(1)
 	}
 
+	if mode == "live" && !exhausted && command != "campaign" {
+		if err := runmode.PinMostReacted(seen.reactions); err != nil {
+			log.Errorf("error: %s", err)
+			os.Exit(1)
+		}
+	}
+
+	if mode == "live" && summaryCategoryID != 0 && !exhausted {
+		log.Infof("post run summary")
+		url, err := postSummary(rep)
+		if err != nil {
+			log.Errorf("error: post run summary: %s", err)
+			os.Exit(1)
+		}
+		log.Printf("run summary posted to %s", url)
+	}
+
+	if len(rep.Quarantined) > 0 {
+		log.Warnf("%d issues quarantined, handle them manually:", len(rep.Quarantined))
+		for _, i := range rep.Quarantined {
+			log.Warnf("- %s: %s", i.URL, i.Error)
+		}
+	}
+
+	if len(rep.FailedRepos) > 0 {
+		log.Warnf("the issues of %d repos couldn't be fetched, the next run retries them:", len(rep.FailedRepos))
+		for _, f := range rep.FailedRepos {
+			log.Warnf("- %s: %s", f.Repo, f.Error)
+		}
+	}
+
+	if len(rep.Suspect) > 0 {
+		log.Warnf("%d topics may render broken, review them:", len(rep.Suspect))
+		for _, i := range rep.Suspect {
+			log.Warnf("- %s: %s", i.TopicURL, strings.Join(i.Suspect, ", "))
+		}
+	}
+
 	log.Successf("success!")
 	log.Printf("run stats:")
-	log.Printf("open/pr/stale/migrated: %d/%d/%d/%d ", stats.Processed, stats.PullRequest, stats.Stale, stats.Active)
+	log.Printf("open/pr/stale/migrated/failed/converted/mirrored/quarantined/skipped: %d/%d/%d/%d/%d/%d/%d/%d/%d ", stats.Processed, stats.PullRequest, stats.Stale, stats.Active, stats.Failed, stats.Converted, stats.Mirrored, stats.Quarantined, stats.Skipped)
+	for _, t := range rep.Trend {
+		log.Printf("%s", t)
+	}
 }